@@ -0,0 +1,130 @@
+// Package types contains types and constants of JSON API shared by server and command-line client.
+// It doesn't import other packages of site, so client doesn't depend on server code.
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Prefix is path prefix of JSON API
+const Prefix = "/api/v1/"
+
+const (
+	// SessionCookie is name of cookie with session token
+	SessionCookie = "session_id"
+	// TwoFactorLoginPath is path of second login step page, user is redirected to it if two-factor authentication is enabled
+	TwoFactorLoginPath = "/login/2fa"
+)
+
+const (
+	MaxLabelLen       = 50                 // maximal length of file label
+	MaxDescriptionLen = 500                // maximal length of file description
+	MaxFilesize       = 1024 * 1024 * 1024 // maximal size of uploaded file in bytes
+
+	MaxRating = 10  // maximal rating that user can set
+	MinRating = -10 // minimal rating that user can set
+)
+
+// ErrTooLarge returns when size of file is larger than MaxFilesize
+var ErrTooLarge = fmt.Errorf("Filesize cannot be more than 1GB")
+
+// ValidateFile checks size, label and description of uploaded file.
+// Category of file is stored in database, so it's checked by server only.
+func ValidateFile(filesize int64, label, description string) error {
+	switch {
+	case filesize > MaxFilesize:
+		return ErrTooLarge
+
+	case len(label) > MaxLabelLen:
+		return fmt.Errorf("Filename are too long")
+
+	case len(description) > MaxDescriptionLen:
+		return fmt.Errorf("Description are too long")
+	}
+	return nil
+}
+
+// FileInfo contains not formatted file info from MySQL database.
+// Upload date is stored in UTC, so clients can format it by themselves.
+type FileInfo struct {
+	ID            int       `json:"id"`
+	Label         string    `json:"label"`
+	FilesizeBytes int64     `json:"filesizeBytes"`
+	Description   string    `json:"description"`
+	Owner         string    `json:"owner"`
+	Category      string    `json:"category"`
+	UploadDate    time.Time `json:"uploadDate"`
+	Rating        int       `json:"rating"`
+}
+
+// File contains file info and tags of file
+type File struct {
+	FileInfo
+	Tags []string `json:"tags"`
+}
+
+// FileList contains one page of files list
+type FileList struct {
+	Files []FileInfo `json:"files"`
+	Page  int        `json:"page"`
+	Pages int        `json:"pages"`
+	Total int        `json:"total"`
+}
+
+// Vote contains rating which user sets to file
+type Vote struct {
+	Rating int `json:"rating"`
+}
+
+// Me contains information about authorized user
+type Me struct {
+	Username string `json:"username"`
+	// CSRFToken should be sent in X-CSRF-Token header of POST requests authorized by session cookie
+	CSRFToken string `json:"csrfToken"`
+}
+
+// User contains public information about user
+type User struct {
+	Username      string    `json:"username"`
+	Rating        int       `json:"rating"`
+	JoinDate      time.Time `json:"joinDate"`
+	Uploads       int       `json:"uploads"`
+	UploadedBytes int64     `json:"uploadedBytes"`
+}
+
+// LeaderboardEntry contains relation of username and user rating
+type LeaderboardEntry struct {
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+}
+
+// Leaderboard contains users with the highest rating
+type Leaderboard struct {
+	Users []LeaderboardEntry `json:"users"`
+}
+
+// Category contains information about category of files
+type Category struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parent      string `json:"parent"`
+	Retired     bool   `json:"retired"`
+}
+
+// CategoryList contains all categories including retired
+type CategoryList struct {
+	Categories []Category `json:"categories"`
+}
+
+// ErrorBody is body of error response of JSON API
+type ErrorBody struct {
+	Error ErrorInfo `json:"error"`
+}
+
+// ErrorInfo describes error of JSON API request
+type ErrorInfo struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,15 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFile(t *testing.T) {
+	assert.NoError(t, ValidateFile(1000, "label", "description"))
+	assert.Equal(t, ErrTooLarge, ValidateFile(MaxFilesize+1, "label", "description"))
+	assert.EqualError(t, ValidateFile(1000, strings.Repeat("a", MaxLabelLen+1), "description"), "Filename are too long")
+	assert.EqualError(t, ValidateFile(1000, "label", strings.Repeat("a", MaxDescriptionLen+1)), "Description are too long")
+}
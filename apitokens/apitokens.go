@@ -0,0 +1,189 @@
+// Package apitokens contains functions for working with personal API tokens of users.
+// API tokens are used by scripts instead of session cookie, they are sent in "Authorization: Bearer" header.
+package apitokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	insertToken = "INSERT INTO apiTokens (username, name, tokenHash, scopes, createdAt) VALUES (?, ?, ?, ?, ?);"
+
+	selectTokens = "SELECT id, name, scopes, createdAt, lastUsed FROM apiTokens WHERE username = ? ORDER BY createdAt DESC, id DESC;"
+
+	deleteToken = "DELETE FROM apiTokens WHERE id = ? AND username = ?;"
+
+	deleteUserTokens = "DELETE FROM apiTokens WHERE username = ?;"
+
+	// tokens of banned users are not accepted
+	selectByHash = "SELECT apiTokens.id, apiTokens.username, apiTokens.scopes FROM apiTokens JOIN users ON users.username = apiTokens.username WHERE apiTokens.tokenHash = ? AND users.banned = FALSE;"
+
+	updateLastUsed = "UPDATE apiTokens SET lastUsed = ? WHERE id = ?;"
+)
+
+const (
+	// Prefix is added to all API tokens, so they can be recognized (e.g. by secret scanners)
+	Prefix = "fhs_"
+
+	MaxNameLen = 50 // maximal length of token name
+	tokenLen   = 32 // length of random part of token in bytes
+)
+
+// Scopes of API tokens, each of them grants access to part of JSON API
+const (
+	ScopeRead   = "read"   // reading of files, categories and users info, downloading of files
+	ScopeUpload = "upload" // uploading of files
+	ScopeVote   = "vote"   // rating of files
+	ScopeDelete = "delete" // deleting of own files
+)
+
+// Scopes contains all scopes which can be granted to API token
+var Scopes = []string{ScopeRead, ScopeUpload, ScopeVote, ScopeDelete}
+
+var (
+	// ErrInvalid returns by Authenticate when token doesn't exist, was revoked or its owner is banned
+	ErrInvalid = errors.New("Invalid API token")
+	// ErrNotFound returns by Revoke when user doesn't have token with inputted id
+	ErrNotFound = errors.New("API token not found")
+)
+
+// Token contains information about API token, token itself is not stored and cannot be shown again
+type Token struct {
+	ID        int
+	Name      string
+	Scopes    []string
+	CreatedAt time.Time
+	LastUsed  time.Time // zero if token was never used
+}
+
+// Hash returns SHA-256 hash of token, only hashes of tokens are stored in database
+func Hash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Validate checks name and scopes of new API token
+func Validate(name string, scopes []string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("Token name cannot be empty")
+	case len(name) > MaxNameLen:
+		return fmt.Errorf("Token name are too long")
+	case len(scopes) == 0:
+		return fmt.Errorf("Please choose at least one scope")
+	}
+	for _, scope := range scopes {
+		if !known(scope) {
+			return fmt.Errorf("Unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// known checks if scope is one of Scopes
+func known(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Create creates API token of user and returns it. Token should be shown to user only once.
+// Name and scopes should be checked by Validate().
+func Create(db *sql.DB, username, name string, scopes []string, now time.Time) (string, error) {
+	random := make([]byte, tokenLen)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	token := Prefix + base64.RawURLEncoding.EncodeToString(random)
+
+	_, err = db.Exec(insertToken, username, strings.TrimSpace(name), Hash(token), strings.Join(scopes, ","), now.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// List returns API tokens of user, the newest tokens are first
+func List(db *sql.DB, username string) ([]Token, error) {
+	rows, err := db.Query(selectTokens, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		t := Token{}
+		scopes := ""
+		lastUsed := sql.NullTime{}
+		err = rows.Scan(&t.ID, &t.Name, &scopes, &t.CreatedAt, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.Scopes = strings.Split(scopes, ",")
+		if lastUsed.Valid {
+			t.LastUsed = lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke removes API token with inputted id if it belongs to user
+func Revoke(db *sql.DB, username string, id int) error {
+	res, err := db.Exec(deleteToken, id, username)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeAll removes all API tokens of user inside of transaction, it's used on account deletion and password change
+func RevokeAll(tx *sql.Tx, username string) error {
+	_, err := tx.Exec(deleteUserTokens, username)
+	return err
+}
+
+// Authenticate returns owner and scopes of API token and records time when token was used.
+// If token is unknown Authenticate returns ErrInvalid.
+func Authenticate(db *sql.DB, token string, now time.Time) (string, []string, error) {
+	if !strings.HasPrefix(token, Prefix) {
+		return "", nil, ErrInvalid
+	}
+
+	id := 0
+	username := ""
+	scopes := ""
+	err := db.QueryRow(selectByHash, Hash(token)).Scan(&id, &username, &scopes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, ErrInvalid
+		}
+		return "", nil, err
+	}
+
+	_, err = db.Exec(updateLastUsed, now.UTC().Format("2006-01-02 15:04:05"), id)
+	if err != nil {
+		return "", nil, err
+	}
+	return username, strings.Split(scopes, ","), nil
+}
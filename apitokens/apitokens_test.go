@@ -0,0 +1,102 @@
+package apitokens
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)
+
+// anyHash matches any SHA-256 hash in hex
+type anyHash struct{}
+
+func (anyHash) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && len(s) == 64
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("CI", []string{ScopeRead, ScopeUpload}))
+
+	assert.EqualError(t, Validate(" ", []string{ScopeRead}), "Token name cannot be empty")
+	assert.EqualError(t, Validate(strings.Repeat("a", MaxNameLen+1), []string{ScopeRead}), "Token name are too long")
+	assert.EqualError(t, Validate("CI", nil), "Please choose at least one scope")
+	assert.EqualError(t, Validate("CI", []string{"admin"}), "Unknown scope \"admin\"")
+}
+
+func TestCreateSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("INSERT INTO apiTokens \\(username, name, tokenHash, scopes, createdAt\\)").WithArgs("username", "CI", anyHash{}, "read,vote", "2009-11-17 20:34:58").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	token, err := Create(db, "username", " CI ", []string{ScopeRead, ScopeVote}, now)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, Prefix))
+	assert.Len(t, token, len(Prefix)+43)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestList(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT id, name, scopes, createdAt, lastUsed FROM apiTokens WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "scopes", "createdAt", "lastUsed"}).
+			AddRow(2, "CI", "read,upload", now, now.Add(time.Hour)).
+			AddRow(1, "old", "vote", now, nil))
+
+	tokens, err := List(db, "username")
+	require.NoError(t, err)
+	assert.Equal(t, []Token{
+		{ID: 2, Name: "CI", Scopes: []string{"read", "upload"}, CreatedAt: now, LastUsed: now.Add(time.Hour)},
+		{ID: 1, Name: "old", Scopes: []string{"vote"}, CreatedAt: now},
+	}, tokens)
+}
+
+func TestRevoke(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE id = \\? AND username = \\?;").WithArgs(1, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE id = \\? AND username = \\?;").WithArgs(2, "username").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, Revoke(db, "username", 1))
+	assert.Equal(t, ErrNotFound, Revoke(db, "username", 2))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestAuthenticateSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT (.+) FROM apiTokens JOIN users (.+) WHERE apiTokens.tokenHash = \\? AND users.banned = FALSE;").WithArgs(Hash("fhs_token")).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "username", "scopes"}).AddRow(3, "username", "read,upload"))
+	sqlMock.ExpectExec("UPDATE apiTokens SET lastUsed = \\? WHERE id = \\?;").WithArgs("2009-11-17 20:34:58", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	username, scopes, err := Authenticate(db, "fhs_token", now)
+	require.NoError(t, err)
+	assert.Equal(t, "username", username)
+	assert.Equal(t, []string{"read", "upload"}, scopes)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestAuthenticateInvalid(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT (.+) FROM apiTokens").WithArgs(Hash("fhs_unknown")).WillReturnError(sql.ErrNoRows)
+	sqlMock.ExpectQuery("SELECT (.+) FROM apiTokens").WithArgs(Hash("fhs_token")).WillReturnError(fmt.Errorf("testing error"))
+
+	_, _, err = Authenticate(db, "fhs_unknown", now)
+	assert.Equal(t, ErrInvalid, err)
+	// tokens without prefix are rejected without query to database
+	_, _, err = Authenticate(db, "session-token", now)
+	assert.Equal(t, ErrInvalid, err)
+	_, _, err = Authenticate(db, "fhs_token", now)
+	assert.EqualError(t, err, "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
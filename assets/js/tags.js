@@ -0,0 +1,24 @@
+// suggestTags fills autocomplete list of tags input by the most popular tags
+// which starts with the last inputted tag (tags are separated by comma)
+function suggestTags(input) {
+    var list = document.getElementById(input.getAttribute("list"));
+    var parts = input.value.split(",");
+    var prefix = parts.pop().trim();
+    var head = parts.length > 0 ? parts.join(",") + ", " : "";
+    if (prefix.length === 0) {
+        list.innerHTML = "";
+        return;
+    }
+
+    fetch("/tags-suggest?q=" + encodeURIComponent(prefix), {credentials: "same-origin"})
+        .then(function (response) { return response.json(); })
+        .then(function (suggestions) {
+            list.innerHTML = "";
+            suggestions.forEach(function (tag) {
+                var option = document.createElement("option");
+                // choosed suggestion replaces only the last tag
+                option.value = head + tag;
+                list.appendChild(option);
+            });
+        });
+}
@@ -0,0 +1,132 @@
+package blobstore
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+const (
+	addBlobReference = "INSERT INTO blobs (hash, size, refs) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE refs = refs + 1;"
+
+	selectBlobRefs = "SELECT refs FROM blobs WHERE hash = ? FOR UPDATE;"
+
+	removeBlobReference = "UPDATE blobs SET refs = refs - 1 WHERE hash = ?;"
+
+	deleteBlob = "DELETE FROM blobs WHERE hash = ?;"
+)
+
+// Store is content-addressed storage of uploaded files data.
+// Data stores in storage backend by its SHA-256 hash, so the same data uploaded several times is stored once.
+// Count of files rows which point to blob contains in blobs table.
+type Store struct {
+	db      *sql.DB
+	backend storage.Backend
+}
+
+// New returns blob store which keeps reference counts in MySQL database and data in storage backend
+func New(db *sql.DB, backend storage.Backend) *Store {
+	return &Store{db: db, backend: backend}
+}
+
+// Put stores data from reader and returns its hash and size.
+// If blob with the same hash already exists its reference count will be increased instead of storing data again.
+func (s *Store) Put(r io.Reader) (hash string, size int64, err error) {
+	// data should be hashed before storing, so it will be buffered in temporary file
+	tmp, err := ioutil.TempFile("", "blob-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		return "", 0, err
+	}
+	hash = hex.EncodeToString(hasher.Sum(nil))
+
+	// transaction locks blob row, so concurrent uploads of the same data will wait until data is stored
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	res, err := tx.Exec(addBlobReference, hash, size)
+	if err != nil {
+		tx.Rollback()
+		return "", 0, err
+	}
+	// MySQL returns 1 affected row for inserted row and 2 for updated
+	affected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return "", 0, err
+	}
+
+	if affected == 1 {
+		_, err = tmp.Seek(0, io.SeekStart)
+		if err != nil {
+			tx.Rollback()
+			return "", 0, err
+		}
+		_, err = s.backend.Put(hash, tmp)
+		if err != nil {
+			tx.Rollback()
+			return "", 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// Release decreases reference count of blob. Blob data will be deleted when the last reference goes away
+func (s *Store) Release(hash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	refs := 0
+	err = tx.QueryRow(selectBlobRefs, hash).Scan(&refs)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if refs > 1 {
+		_, err = tx.Exec(removeBlobReference, hash)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	_, err = tx.Exec(deleteBlob, hash)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// data deletes while blob row is locked, so concurrent Put() of the same data will store it again after commit
+	err = s.backend.Delete(hash)
+	if err != nil && err != storage.ErrNotExist {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Open returns reader of blob data
+func (s *Store) Open(hash string) (*storage.Object, error) {
+	return storage.Open(s.backend, hash)
+}
@@ -0,0 +1,145 @@
+package blobstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+// sha256 hash of "binary data"
+const testHash = "9cb63cb779e8c571db3199b783a36cc43cd9e7c076beeb496c39e9cc06196dc5"
+
+// newTestStore returns blob store with local backend in temporary directory
+func newTestStore(t *testing.T) (*Store, sqlmock.Sqlmock, string, func()) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "blobstore-test-")
+	require.NoError(t, err)
+
+	return New(db, storage.NewLocal(dir)), sqlMock, dir, func() { os.RemoveAll(dir) }
+}
+
+func TestPutNewBlobSuccess(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	defer cleanup()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(testHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	hash, size, err := s.Put(strings.NewReader("binary data"))
+	require.NoError(t, err)
+	assert.Equal(t, testHash, hash)
+	assert.Equal(t, int64(11), size)
+
+	data, err := ioutil.ReadFile(dir + "/" + testHash)
+	require.NoError(t, err)
+	assert.Equal(t, "binary data", string(data))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPutExistingBlobSuccess(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	defer cleanup()
+	sqlMock.ExpectBegin()
+	// MySQL returns 2 affected rows when ON DUPLICATE KEY UPDATE updates existing row
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(testHash, 11).WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	hash, _, err := s.Put(strings.NewReader("binary data"))
+	require.NoError(t, err)
+	assert.Equal(t, testHash, hash)
+
+	// data should not be stored again
+	_, err = os.Stat(dir + "/" + testHash)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPutDBError(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	defer cleanup()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(testHash, 11).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	_, _, err := s.Put(strings.NewReader("binary data"))
+	assert.EqualError(t, err, "testing error")
+
+	_, err = os.Stat(dir + "/" + testHash)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPutStorageError(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	cleanup()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(testHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectRollback()
+
+	// storage directory is removed, so storing of data will fail
+	_, _, err := s.Put(strings.NewReader("binary data"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), dir)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestReleaseSharedBlobSuccess(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(dir+"/"+testHash, []byte("binary data"), 0644))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs WHERE hash = (.+) FOR UPDATE").WithArgs(testHash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(2))
+	sqlMock.ExpectExec("UPDATE blobs SET refs = refs - 1").WithArgs(testHash).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, s.Release(testHash))
+
+	// blob still has reference, so data should not be deleted
+	_, err := os.Stat(dir + "/" + testHash)
+	assert.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestReleaseLastReferenceSuccess(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(dir+"/"+testHash, []byte("binary data"), 0644))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs WHERE hash = (.+) FOR UPDATE").WithArgs(testHash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(testHash).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, s.Release(testHash))
+
+	_, err := os.Stat(dir + "/" + testHash)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestReleaseDBError(t *testing.T) {
+	s, sqlMock, dir, cleanup := newTestStore(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(dir+"/"+testHash, []byte("binary data"), 0644))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs WHERE hash = (.+) FOR UPDATE").WithArgs(testHash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(testHash).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	assert.EqualError(t, s.Release(testHash), "testing error")
+
+	_, err := os.Stat(dir + "/" + testHash)
+	assert.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,285 @@
+// Package categories contains functions for working with categories of files stored in database
+package categories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	categoryColumns = "slug, name, description, sortOrder, COALESCE(parent, ''), retired"
+
+	selectAll = "SELECT " + categoryColumns + " FROM categories;"
+
+	selectActive = "SELECT " + categoryColumns + " FROM categories WHERE retired = FALSE;"
+
+	selectCategory = "SELECT " + categoryColumns + " FROM categories WHERE slug = ?;"
+
+	insertCategory = "INSERT INTO categories (slug, name, description, sortOrder, parent) VALUES (?, ?, ?, ?, NULLIF(?, ''));"
+
+	updateCategory = "UPDATE categories SET name = ?, description = ?, sortOrder = ?, parent = NULLIF(?, '') WHERE slug = ?;"
+
+	updateRetired = "UPDATE categories SET retired = ? WHERE slug = ?;"
+
+	moveFiles = "UPDATE files SET category = ? WHERE category = ?;"
+
+	movePartialUploads = "UPDATE partialUploads SET category = ? WHERE category = ?;"
+
+	moveChildren = "UPDATE categories SET parent = ? WHERE parent = ?;"
+
+	deleteCategory = "DELETE FROM categories WHERE slug = ?;"
+)
+
+const (
+	MaxSlugLen        = 20  // maximal length of category slug, it's stored in files.category
+	MaxNameLen        = 50  // maximal length of category display name
+	MaxDescriptionLen = 500 // maximal length of category description
+)
+
+// Default is slug of category selected by default on upload form
+const Default = "other"
+
+// ErrUnknown returns when category doesn't exist or retired
+var ErrUnknown = errors.New("Unknown category")
+
+// Category contains info about category of files.
+// Categories can be nested only one level deep: category with parent cannot have subcategories.
+type Category struct {
+	Slug        string // used in links and stored in files.category
+	Name        string
+	Description string
+	SortOrder   int
+	Parent      string // slug of parent category, empty for top level categories
+	Retired     bool   // new files cannot be added to retired category, but old files are still available
+
+	// Children is filled only by Tree()
+	Children []Category
+}
+
+// All returns all categories including retired sorted by sort order and name
+func All(db *sql.DB) ([]Category, error) {
+	return query(db, selectAll)
+}
+
+// Active returns not retired categories sorted by sort order and name
+func Active(db *sql.DB) ([]Category, error) {
+	return query(db, selectActive)
+}
+
+// Get returns category with inputted slug. If category doesn't exist sql.ErrNoRows will be returned.
+func Get(db *sql.DB, slug string) (Category, error) {
+	c := Category{}
+	err := db.QueryRow(selectCategory, slug).Scan(&c.Slug, &c.Name, &c.Description, &c.SortOrder, &c.Parent, &c.Retired)
+	if err != nil {
+		return Category{}, err
+	}
+	return c, nil
+}
+
+// Check returns ErrUnknown if new files cannot be added to category with inputted slug
+func Check(db *sql.DB, slug string) error {
+	c, err := Get(db, slug)
+	if err == sql.ErrNoRows {
+		return ErrUnknown
+	}
+	if err != nil {
+		return err
+	}
+	if c.Retired {
+		return ErrUnknown
+	}
+	return nil
+}
+
+// query returns sorted categories selected by query
+func query(db *sql.DB, query string) ([]Category, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Category{}
+	for rows.Next() {
+		c := Category{}
+		err = rows.Scan(&c.Slug, &c.Name, &c.Description, &c.SortOrder, &c.Parent, &c.Retired)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].SortOrder != list[j].SortOrder {
+			return list[i].SortOrder < list[j].SortOrder
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Find returns category with inputted slug from list
+func Find(list []Category, slug string) (Category, bool) {
+	for _, c := range list {
+		if c.Slug == slug {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// Tree returns top level categories from list with filled Children.
+// If parent of category isn't in list, category is showed on top level.
+func Tree(list []Category) []Category {
+	tree := []Category{}
+	for _, c := range list {
+		if _, ok := Find(list, c.Parent); c.Parent != "" && ok {
+			continue
+		}
+		c.Children = []Category{}
+		for _, child := range list {
+			if child.Parent == c.Slug {
+				c.Children = append(c.Children, child)
+			}
+		}
+		tree = append(tree, c)
+	}
+	return tree
+}
+
+// WithChildren returns slugs of category and its subcategories from list
+func WithChildren(list []Category, slug string) []string {
+	slugs := []string{slug}
+	for _, c := range list {
+		if c.Parent == slug {
+			slugs = append(slugs, c.Slug)
+		}
+	}
+	return slugs
+}
+
+// Validate checks category inputted by admin. List should contain all existing categories.
+// If isNew is true category should have unique slug, otherwise category with this slug should exist.
+func Validate(c Category, list []Category, isNew bool) error {
+	_, exists := Find(list, c.Slug)
+	switch {
+	case isNew && !validSlug(c.Slug):
+		return fmt.Errorf("Slug can contain only lowercase latin letters, digits and hyphens and cannot be longer than %d symbols", MaxSlugLen)
+	case isNew && exists:
+		return fmt.Errorf("Category %q already exists", c.Slug)
+	case !isNew && !exists:
+		return ErrUnknown
+	case c.Name == "":
+		return fmt.Errorf("Name cannot be empty")
+	case utf8.RuneCountInString(c.Name) > MaxNameLen:
+		return fmt.Errorf("Name are too long")
+	case utf8.RuneCountInString(c.Description) > MaxDescriptionLen:
+		return fmt.Errorf("Description are too long")
+	}
+
+	if c.Parent == "" {
+		return nil
+	}
+	parent, ok := Find(list, c.Parent)
+	switch {
+	case !ok:
+		return fmt.Errorf("Unknown parent category")
+	case parent.Slug == c.Slug:
+		return fmt.Errorf("Category cannot be parent of itself")
+	case parent.Parent != "":
+		return fmt.Errorf("Parent category cannot be subcategory")
+	case len(WithChildren(list, c.Slug)) > 1:
+		return fmt.Errorf("Category with subcategories cannot have parent")
+	}
+	return nil
+}
+
+// ValidateMerge checks that category "from" can be merged into category "to". List should contain all existing categories.
+func ValidateMerge(from, to string, list []Category) error {
+	_, okFrom := Find(list, from)
+	target, okTo := Find(list, to)
+	switch {
+	case !okFrom || !okTo:
+		return ErrUnknown
+	case from == to:
+		return fmt.Errorf("Category cannot be merged into itself")
+	case target.Parent == from:
+		return fmt.Errorf("Category cannot be merged into its subcategory")
+	case target.Parent != "" && len(WithChildren(list, from)) > 1:
+		return fmt.Errorf("Category with subcategories cannot be merged into subcategory")
+	}
+	return nil
+}
+
+// validSlug returns true if slug contains only lowercase latin letters, digits and hyphens
+func validSlug(slug string) bool {
+	if slug == "" || len(slug) > MaxSlugLen {
+		return false
+	}
+	for _, r := range slug {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Create adds new category. Category should be checked by Validate().
+func Create(db *sql.DB, c Category) error {
+	_, err := db.Exec(insertCategory, c.Slug, c.Name, c.Description, c.SortOrder, c.Parent)
+	return err
+}
+
+// Update changes name, description, sort order and parent of category. Category should be checked by Validate().
+func Update(db *sql.DB, c Category) error {
+	_, err := db.Exec(updateCategory, c.Name, c.Description, c.SortOrder, c.Parent, c.Slug)
+	return err
+}
+
+// SetRetired retires or restores category
+func SetRetired(db *sql.DB, slug string, retired bool) error {
+	_, err := db.Exec(updateRetired, retired, slug)
+	return err
+}
+
+// Merge moves files and subcategories of category "from" to category "to" and deletes category "from".
+// Categories should be checked by ValidateMerge().
+func Merge(db *sql.DB, from, to string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(moveFiles, to, from)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(movePartialUploads, to, from)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(moveChildren, to, from)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(deleteCategory, from)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
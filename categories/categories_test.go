@@ -0,0 +1,187 @@
+package categories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// columns contains columns returned by categories queries
+var columns = []string{"slug", "name", "description", "sortOrder", "parent", "retired"}
+
+// testList returns categories with one subcategory and one retired category
+func testList() []Category {
+	return []Category{
+		{Slug: "other", Name: "Other", SortOrder: 10},
+		{Slug: "music", Name: "Music", SortOrder: 20},
+		{Slug: "jazz", Name: "Jazz", SortOrder: 30, Parent: "music"},
+		{Slug: "old", Name: "Old", SortOrder: 40, Retired: true},
+	}
+}
+
+func TestAll(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT slug, name, description, sortOrder, COALESCE\\(parent, ''\\), retired FROM categories;").WillReturnRows(
+		sqlmock.NewRows(columns).
+			AddRow("music", "Music", "", 20, "", false).
+			AddRow("games", "Games", "", 20, "", false).
+			AddRow("other", "Other", "description", 10, "", true))
+
+	list, err := All(db)
+	require.NoError(t, err)
+	assert.Equal(t, []Category{
+		{Slug: "other", Name: "Other", Description: "description", SortOrder: 10, Retired: true},
+		{Slug: "games", Name: "Games", SortOrder: 20},
+		{Slug: "music", Name: "Music", SortOrder: 20},
+	}, list)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestActive(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE;").WillReturnRows(
+		sqlmock.NewRows(columns).AddRow("jazz", "Jazz", "", 0, "music", false))
+
+	list, err := Active(db)
+	require.NoError(t, err)
+	assert.Equal(t, []Category{{Slug: "jazz", Name: "Jazz", Parent: "music"}}, list)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCheck(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("music").WillReturnRows(
+		sqlmock.NewRows(columns).AddRow("music", "Music", "", 0, "", false))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("old").WillReturnRows(
+		sqlmock.NewRows(columns).AddRow("old", "Old", "", 0, "", true))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("music").WillReturnError(fmt.Errorf("testing error"))
+
+	assert.NoError(t, Check(db, "music"))
+	assert.Equal(t, ErrUnknown, Check(db, "old"))
+	assert.Equal(t, ErrUnknown, Check(db, "unknown"))
+	assert.EqualError(t, Check(db, "music"), "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestTree(t *testing.T) {
+	tree := Tree(testList())
+
+	require.Len(t, tree, 3)
+	assert.Equal(t, "other", tree[0].Slug)
+	assert.Equal(t, "music", tree[1].Slug)
+	require.Len(t, tree[1].Children, 1)
+	assert.Equal(t, "jazz", tree[1].Children[0].Slug)
+	assert.Equal(t, "old", tree[2].Slug)
+
+	// subcategory is showed on top level if its parent isn't in list
+	tree = Tree(testList()[2:])
+	require.Len(t, tree, 2)
+	assert.Equal(t, "jazz", tree[0].Slug)
+}
+
+func TestWithChildren(t *testing.T) {
+	assert.Equal(t, []string{"music", "jazz"}, WithChildren(testList(), "music"))
+	assert.Equal(t, []string{"other"}, WithChildren(testList(), "other"))
+}
+
+func TestValidate(t *testing.T) {
+	list := testList()
+
+	assert.NoError(t, Validate(Category{Slug: "rock-n-roll", Name: "Rock'n'roll", Parent: "music"}, list, true))
+	assert.NoError(t, Validate(Category{Slug: "music", Name: "Music and sounds"}, list, false))
+
+	for expected, c := range map[string]Category{
+		"Slug can contain only lowercase latin letters, digits and hyphens and cannot be longer than 20 symbols": {Slug: "Rock", Name: "Rock"},
+		"Category \"music\" already exists":     {Slug: "music", Name: "Music"},
+		"Name cannot be empty":                  {Slug: "rock"},
+		"Name are too long":                     {Slug: "rock", Name: strings.Repeat("a", MaxNameLen+1)},
+		"Description are too long":              {Slug: "rock", Name: "Rock", Description: strings.Repeat("a", MaxDescriptionLen+1)},
+		"Unknown parent category":               {Slug: "rock", Name: "Rock", Parent: "unknown"},
+		"Parent category cannot be subcategory": {Slug: "rock", Name: "Rock", Parent: "jazz"},
+	} {
+		assert.EqualError(t, Validate(c, list, true), expected)
+	}
+
+	assert.Equal(t, ErrUnknown, Validate(Category{Slug: "unknown", Name: "Unknown"}, list, false))
+	assert.EqualError(t, Validate(Category{Slug: "music", Name: "Music", Parent: "music"}, list, false), "Category cannot be parent of itself")
+	assert.EqualError(t, Validate(Category{Slug: "music", Name: "Music", Parent: "other"}, list, false), "Category with subcategories cannot have parent")
+}
+
+func TestValidateMerge(t *testing.T) {
+	list := testList()
+
+	assert.NoError(t, ValidateMerge("old", "other", list))
+	assert.NoError(t, ValidateMerge("jazz", "music", list))
+	assert.Equal(t, ErrUnknown, ValidateMerge("unknown", "other", list))
+	assert.EqualError(t, ValidateMerge("other", "other", list), "Category cannot be merged into itself")
+	assert.EqualError(t, ValidateMerge("music", "jazz", list), "Category cannot be merged into its subcategory")
+
+	list = append(list, Category{Slug: "blues", Name: "Blues", Parent: "other"})
+	assert.EqualError(t, ValidateMerge("music", "blues", list), "Category with subcategories cannot be merged into subcategory")
+}
+
+func TestCreate(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("INSERT INTO categories \\(slug, name, description, sortOrder, parent\\) VALUES \\(\\?, \\?, \\?, \\?, NULLIF\\(\\?, ''\\)\\);").WithArgs("jazz", "Jazz", "description", 30, "music").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = Create(db, Category{Slug: "jazz", Name: "Jazz", Description: "description", SortOrder: 30, Parent: "music"})
+	require.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpdate(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("UPDATE categories SET name = \\?, description = \\?, sortOrder = \\?, parent = NULLIF\\(\\?, ''\\) WHERE slug = \\?;").WithArgs("Jazz music", "", 30, "", "jazz").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = Update(db, Category{Slug: "jazz", Name: "Jazz music", SortOrder: 30})
+	require.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSetRetired(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("UPDATE categories SET retired = \\? WHERE slug = \\?;").WithArgs(true, "old").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = SetRetired(db, "old", true)
+	require.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMerge(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET category = \\? WHERE category = \\?;").WithArgs("other", "old").WillReturnResult(sqlmock.NewResult(0, 5))
+	sqlMock.ExpectExec("UPDATE partialUploads SET category = \\? WHERE category = \\?;").WithArgs("other", "old").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("UPDATE categories SET parent = \\? WHERE parent = \\?;").WithArgs("other", "old").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM categories WHERE slug = \\?;").WithArgs("old").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err = Merge(db, "old", "other")
+	require.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMergeDBError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET category").WithArgs("other", "old").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	err = Merge(db, "old", "other")
+	assert.EqualError(t, err, "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,565 @@
+// Command fhs-admin is command-line tool for maintenance of file hosting site.
+// It works with MySQL database, Redis and files storage directly, so it should be run on server.
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/maintenance"
+	"github.com/vpoletaev11/fileHostingSite/migrations"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+const usage = `Usage: fhs-admin [-mysql ADDR] [-redis ADDR] [-files DIR] [-partial DIR] COMMAND [ARGS]
+
+Commands:
+  create-user [-admin] [-timezone TZ] [-email EMAIL] [-password-stdin] USERNAME
+                                   create user
+  reset-password [-password-stdin] USERNAME
+                                   set new password of user and close sessions of user
+  ban USERNAME                     ban user and close sessions of user
+  unban USERNAME                   unban user
+  recompute-ratings                recompute ratings of files and users from votes
+  check-storage [-repair]          find stored files without database rows and rows without stored files
+  export FILE                      export database and stored files to archive ("-" is stdout)
+  import FILE                      import archive created by export to empty database ("-" is stdin)
+  purge-sessions                   remove expired sessions from sessions index in Redis
+  expire-uploads [-age DURATION]   delete abandoned resumable uploads older than DURATION (default 168h)
+  migrate up                       apply not applied migrations of database schema
+  migrate down [N]                 revert N last applied migrations (default 1)
+  migrate status                   show applied and not applied migrations
+  migrate force VERSION            mark interrupted migration as applied after manual fix of schema
+
+Storage is configured by the same environment variables as site (S3_ENDPOINT, etc.).
+Site should be stopped during check-storage -repair and import.
+`
+
+const (
+	mySQLAddr  = "user:@tcp(localhost:3306)" // docker: root:@tcp(mysql:3306)
+	redisAddr  = "localhost:6379"            // docker: redis:6379
+	filesDir   = "files"                     // directory for uploaded files when S3 storage isn't configured
+	partialDir = "partial"                   // directory for partially uploaded files of resumable uploads
+)
+
+// errUsage is returned when command line is incorrect, usage is printed instead of error message
+var errUsage = errors.New("incorrect usage")
+
+// app contains connections to databases and storage, they are opened by the first command which needs them
+type app struct {
+	mySQLAddr  string
+	redisAddr  string
+	filesDir   string
+	partialDir string
+	db         *sql.DB
+	redis      redis.Conn
+	stdin      *bufio.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err == errUsage {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fhs-admin: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// run parses global flags and runs command
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	a := &app{stdin: bufio.NewReader(stdin), stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("fhs-admin", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&a.mySQLAddr, "mysql", mySQLAddr, "address of MySQL database")
+	fs.StringVar(&a.redisAddr, "redis", redisAddr, "address of Redis")
+	fs.StringVar(&a.filesDir, "files", filesDir, "directory of uploaded files")
+	fs.StringVar(&a.partialDir, "partial", partialDir, "directory of partially uploaded files")
+	if fs.Parse(args) != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+	defer a.close()
+
+	commands := map[string]func([]string) error{
+		"create-user":       a.createUser,
+		"reset-password":    a.resetPassword,
+		"ban":               a.ban,
+		"unban":             a.unban,
+		"recompute-ratings": a.recomputeRatings,
+		"check-storage":     a.checkStorage,
+		"export":            a.export,
+		"import":            a.importDataset,
+		"purge-sessions":    a.purgeSessions,
+		"expire-uploads":    a.expireUploads,
+		"migrate":           a.migrate,
+	}
+	command, ok := commands[fs.Arg(0)]
+	if !ok {
+		return errUsage
+	}
+	return command(fs.Args()[1:])
+}
+
+// parseFlags parses flags of command, flags should be before arguments
+func parseFlags(fs *flag.FlagSet, args []string, numArgs int) error {
+	fs.SetOutput(ioutil.Discard)
+	if fs.Parse(args) != nil || fs.NArg() != numArgs {
+		return errUsage
+	}
+	return nil
+}
+
+// database returns connection to MySQL database
+func (a *app) database() (*sql.DB, error) {
+	if a.db != nil {
+		return a.db, nil
+	}
+	// ?parseTime=true asks the driver to scan DATE and DATETIME automatically to time.Time
+	db, err := sql.Open("mysql", a.mySQLAddr+"/fileHostingSite?parseTime=true")
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	a.db = db
+	return db, nil
+}
+
+// redisConn returns connection to Redis
+func (a *app) redisConn() (redis.Conn, error) {
+	if a.redis != nil {
+		return a.redis, nil
+	}
+	conn, err := redis.Dial("tcp", a.redisAddr)
+	if err != nil {
+		return nil, err
+	}
+	a.redis = conn
+	return conn, nil
+}
+
+// backend returns storage backend the same as site uses
+func (a *app) backend() storage.Backend {
+	cfg, ok := storage.S3ConfigFromEnv()
+	if !ok {
+		return storage.NewLocal(a.filesDir)
+	}
+	return storage.NewS3(cfg)
+}
+
+// close closes opened connections
+func (a *app) close() {
+	if a.db != nil {
+		a.db.Close()
+	}
+	if a.redis != nil {
+		a.redis.Close()
+	}
+}
+
+// readPassword reads password from stdin, with prompt password is asked twice.
+// Password isn't hidden, use -password-stdin to pass it from file or password manager.
+func (a *app) readPassword(prompt bool) (string, error) {
+	if !prompt {
+		return a.readLine("")
+	}
+	password, err := a.readLine("Password: ")
+	if err != nil {
+		return "", err
+	}
+	confirmation, err := a.readLine("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirmation {
+		return "", fmt.Errorf("Passwords doesn't match")
+	}
+	return password, nil
+}
+
+// readLine reads line from stdin after prompt
+func (a *app) readLine(prompt string) (string, error) {
+	fmt.Fprint(a.stderr, prompt)
+	line, err := a.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// createUser handles create-user command
+func (a *app) createUser(args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	isAdmin := fs.Bool("admin", false, "grant admin role")
+	timezone := fs.String("timezone", "UTC", "timezone of user")
+	email := fs.String("email", "", "email to reset forgotten password")
+	passwordStdin := fs.Bool("password-stdin", false, "read password from stdin")
+	err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	username := fs.Arg(0)
+
+	password, err := a.readPassword(!*passwordStdin)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+	err = maintenance.CreateUser(db, username, password, *timezone, *email, *isAdmin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "User %s created\n", username)
+	return nil
+}
+
+// resetPassword handles reset-password command
+func (a *app) resetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	passwordStdin := fs.Bool("password-stdin", false, "read password from stdin")
+	err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	username := fs.Arg(0)
+
+	password, err := a.readPassword(!*passwordStdin)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+	redisConn, err := a.redisConn()
+	if err != nil {
+		return err
+	}
+	err = maintenance.ResetPassword(db, redisConn, username, password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "Password of user %s reset\n", username)
+	return nil
+}
+
+// ban handles ban command
+func (a *app) ban(args []string) error {
+	return a.setBanned(args, true)
+}
+
+// unban handles unban command
+func (a *app) unban(args []string) error {
+	return a.setBanned(args, false)
+}
+
+// setBanned bans or unbans user from arguments
+func (a *app) setBanned(args []string, banned bool) error {
+	err := parseFlags(flag.NewFlagSet("ban", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	username := args[0]
+
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+	redisConn, err := a.redisConn()
+	if err != nil {
+		return err
+	}
+	err = maintenance.SetBanned(db, redisConn, username, banned)
+	if err != nil {
+		return err
+	}
+	if banned {
+		fmt.Fprintf(a.stdout, "User %s banned\n", username)
+	} else {
+		fmt.Fprintf(a.stdout, "User %s unbanned\n", username)
+	}
+	return nil
+}
+
+// recomputeRatings handles recompute-ratings command
+func (a *app) recomputeRatings(args []string) error {
+	err := parseFlags(flag.NewFlagSet("recompute-ratings", flag.ContinueOnError), args, 0)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+	files, users, err := maintenance.RecomputeRatings(db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "Ratings changed: %d files, %d users\n", files, users)
+	return nil
+}
+
+// checkStorage handles check-storage command
+func (a *app) checkStorage(args []string) error {
+	fs := flag.NewFlagSet("check-storage", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "fix found problems")
+	err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+	backend := a.backend()
+
+	report, err := maintenance.Check(db, backend)
+	if err != nil {
+		return err
+	}
+	printReport(a.stdout, report)
+	if report.Empty() || !*repair {
+		return nil
+	}
+
+	err = maintenance.Repair(db, backend, report)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, "Problems fixed")
+	return nil
+}
+
+// printReport writes problems found by check-storage command
+func printReport(w io.Writer, report maintenance.Report) {
+	if report.Empty() {
+		fmt.Fprintln(w, "No problems found")
+		return
+	}
+	for _, key := range report.OrphanedObjects {
+		fmt.Fprintf(w, "Stored object %s doesn't belong to any file\n", key)
+	}
+	for _, id := range report.MissingObjects {
+		fmt.Fprintf(w, "Data of file %d is missing\n", id)
+	}
+	for _, br := range report.WrongRefs {
+		if br.Stored == -1 {
+			fmt.Fprintf(w, "Blob %s doesn't have row in blobs table, it has %d references\n", br.Hash, br.Actual)
+			continue
+		}
+		fmt.Fprintf(w, "Blob %s has reference count %d instead of %d\n", br.Hash, br.Stored, br.Actual)
+	}
+}
+
+// export handles export command
+func (a *app) export(args []string) error {
+	err := parseFlags(flag.NewFlagSet("export", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "-" {
+		return maintenance.Export(db, a.backend(), a.stdout)
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	err = maintenance.Export(db, a.backend(), f)
+	if err != nil {
+		f.Close()
+		os.Remove(args[0])
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "Dataset exported to %s\n", args[0])
+	return nil
+}
+
+// importDataset handles import command
+func (a *app) importDataset(args []string) error {
+	err := parseFlags(flag.NewFlagSet("import", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = a.stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	err = maintenance.Import(db, a.backend(), r)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, "Dataset imported")
+	return nil
+}
+
+// purgeSessions handles purge-sessions command
+func (a *app) purgeSessions(args []string) error {
+	err := parseFlags(flag.NewFlagSet("purge-sessions", flag.ContinueOnError), args, 0)
+	if err != nil {
+		return err
+	}
+	redisConn, err := a.redisConn()
+	if err != nil {
+		return err
+	}
+	purged, err := session.PurgeExpired(redisConn)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "Expired sessions removed: %d\n", purged)
+	return nil
+}
+
+// expireUploads handles expire-uploads command
+func (a *app) expireUploads(args []string) error {
+	fs := flag.NewFlagSet("expire-uploads", flag.ContinueOnError)
+	age := fs.Duration("age", 7*24*time.Hour, "minimal age of deleted uploads")
+	err := parseFlags(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	db, err := a.database()
+	if err != nil {
+		return err
+	}
+	uploads, files, err := maintenance.ExpireUploads(db, a.partialDir, time.Now().Add(-*age))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "Expired uploads deleted: %d, partial files without uploads removed: %d\n", uploads, files)
+	return nil
+}
+
+// migrate handles migrate command
+func (a *app) migrate(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		db, err := a.database()
+		if err != nil {
+			return err
+		}
+		applied, err := migrations.Up(db, migrations.All)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(a.stdout, "Database is up to date")
+		}
+		for _, m := range applied {
+			fmt.Fprintf(a.stdout, "Applied migration %04d %s\n", m.Version, m.Name)
+		}
+		return nil
+
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return errUsage
+			}
+			steps = n
+		}
+		db, err := a.database()
+		if err != nil {
+			return err
+		}
+		reverted, err := migrations.Down(db, migrations.All, steps)
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Fprintln(a.stdout, "No applied migrations")
+		}
+		for _, m := range reverted {
+			fmt.Fprintf(a.stdout, "Reverted migration %04d %s\n", m.Version, m.Name)
+		}
+		return nil
+
+	case args[0] == "status" && len(args) == 1:
+		db, err := a.database()
+		if err != nil {
+			return err
+		}
+		states, err := migrations.Status(db, migrations.All)
+		if err != nil {
+			return err
+		}
+		printStates(a.stdout, states)
+		return nil
+
+	case args[0] == "force" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errUsage
+		}
+		db, err := a.database()
+		if err != nil {
+			return err
+		}
+		err = migrations.Force(db, version)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "Migration %04d marked as applied\n", version)
+		return nil
+	}
+	return errUsage
+}
+
+// printStates writes states of migrations shown by migrate status command
+func printStates(w io.Writer, states []migrations.State) {
+	for _, s := range states {
+		state := "not applied"
+		switch {
+		case s.Dirty:
+			state = "interrupted"
+		case s.Applied:
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%04d %-20s %s\n", s.Version, s.Name, state)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpoletaev11/fileHostingSite/maintenance"
+	"github.com/vpoletaev11/fileHostingSite/migrations"
+)
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"ban"},
+		{"ban", "user1", "user2"},
+		{"check-storage", "-unknown"},
+		{"create-user", "-admin"},
+		{"expire-uploads", "-age", "week"},
+		{"migrate"},
+		{"migrate", "sideways"},
+		{"migrate", "down", "0"},
+		{"migrate", "down", "1", "2"},
+		{"migrate", "force"},
+		{"migrate", "force", "one"},
+	} {
+		assert.Equal(t, errUsage, run(args, nil, ioutil.Discard, ioutil.Discard), args)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	a := &app{stderr: stderr}
+
+	a.stdin = bufioReader("password\npassword\n")
+	password, err := a.readPassword(true)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password)
+	assert.Equal(t, "Password: Confirm password: ", stderr.String())
+
+	a.stdin = bufioReader("password\nanother\n")
+	_, err = a.readPassword(true)
+	assert.EqualError(t, err, "Passwords doesn't match")
+
+	// password from stdin isn't confirmed
+	a.stdin = bufioReader("password")
+	password, err = a.readPassword(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password)
+}
+
+func TestPrintReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printReport(buf, maintenance.Report{})
+	assert.Equal(t, "No problems found\n", buf.String())
+
+	buf.Reset()
+	printReport(buf, maintenance.Report{
+		OrphanedObjects: []string{"6"},
+		MissingObjects:  []int{2},
+		WrongRefs: []maintenance.BlobRefs{
+			{Hash: "hash1", Stored: 1, Actual: 2},
+			{Hash: "hash2", Stored: -1, Actual: 1},
+		},
+	})
+	assert.Equal(t, ""+
+		"Stored object 6 doesn't belong to any file\n"+
+		"Data of file 2 is missing\n"+
+		"Blob hash1 has reference count 1 instead of 2\n"+
+		"Blob hash2 doesn't have row in blobs table, it has 1 references\n", buf.String())
+}
+
+// bufioReader returns reader of stdin with inputted data
+func bufioReader(s string) *bufio.Reader {
+	return bufio.NewReader(strings.NewReader(s))
+}
+
+func TestPrintStates(t *testing.T) {
+	out := &bytes.Buffer{}
+	printStates(out, []migrations.State{
+		{Migration: migrations.Migration{Version: 1, Name: "init"}, Applied: true},
+		{Migration: migrations.Migration{Version: 2, Name: "tags"}, Applied: true, Dirty: true},
+		{Migration: migrations.Migration{Version: 3, Name: "blobs"}},
+	})
+	assert.Equal(t, "0001 init                 applied\n0002 tags                 interrupted\n0003 blobs                not applied\n", out.String())
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+)
+
+// errNotLoggedIn returns by client when user doesn't have API token or session
+var errNotLoggedIn = errors.New("Not logged in. Please run \"fhs login\"")
+
+// apiError is error returned by server
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e apiError) Error() string {
+	return e.Message
+}
+
+// client sends requests to JSON API of site
+type client struct {
+	server  string
+	token   string
+	session string
+	http    *http.Client
+	// csrfToken of session, it's requested before the first state-changing request
+	csrfToken string
+}
+
+// newClient returns client which uses server and credentials from config
+func newClient(cfg config) *client {
+	return &client{
+		server:  strings.TrimSuffix(cfg.Server, "/"),
+		token:   cfg.Token,
+		session: cfg.Session,
+		http:    &http.Client{},
+	}
+}
+
+// url returns absolute URL of path, paths which are already absolute (e.g. from Location header) are not changed
+func (c *client) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.server + path
+}
+
+// do sends authorized request and returns response with successful status.
+// Path is relative to server address. Error responses are converted to apiError.
+func (c *client) do(method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	r, err := http.NewRequest(method, c.url(path), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		r.Header[key] = values
+	}
+
+	switch {
+	case c.token != "":
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	case c.session != "":
+		r.AddCookie(&http.Cookie{Name: types.SessionCookie, Value: c.session})
+		if !csrf.SafeMethod(method) {
+			if c.csrfToken == "" {
+				me, err := c.me()
+				if err != nil {
+					return nil, err
+				}
+				c.csrfToken = me.CSRFToken
+			}
+			r.Header.Set(csrf.HeaderName, c.csrfToken)
+		}
+	default:
+		return nil, errNotLoggedIn
+	}
+
+	resp, err := c.http.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	err = checkResponse(resp)
+	if err != nil {
+		resp.Body.Close()
+		if e, ok := err.(apiError); ok && e.Status == http.StatusUnauthorized && c.token == "" {
+			return nil, errors.New("Session expired. Please run \"fhs login\"")
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checkResponse returns apiError if response has error status.
+// Errors of JSON API contain message in JSON, other errors (e.g. of resumable uploads) are plain text.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+	body := types.ErrorBody{}
+	if json.Unmarshal(data, &body) == nil && body.Error.Message != "" {
+		return apiError{Status: resp.StatusCode, Message: body.Error.Message}
+	}
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return apiError{Status: resp.StatusCode, Message: message}
+}
+
+// getJSON sends GET request to JSON API and decodes response to v
+func (c *client) getJSON(path string, v interface{}) error {
+	resp, err := c.do("GET", types.Prefix+path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sendJSON sends request with JSON body to JSON API and decodes response to out (if it isn't nil)
+func (c *client) sendJSON(method, path string, in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(method, types.Prefix+path, bytes.NewReader(data), http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// me returns authorized user
+func (c *client) me() (types.Me, error) {
+	me := types.Me{}
+	return me, c.getJSON("me", &me)
+}
+
+// file returns info of file with inputted ID
+func (c *client) file(id string) (types.File, error) {
+	f := types.File{}
+	return f, c.getJSON("files/"+id, &f)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// defaultServer is address of site which is used when server isn't set by flag or config
+const defaultServer = "http://localhost:8080"
+
+// config contains address of site and credentials of user, it's saved between runs of client
+type config struct {
+	Server string `json:"server"`
+	// Token is API token, if it's set session isn't used
+	Token string `json:"token,omitempty"`
+	// Session is token of session cookie received on login
+	Session string `json:"session,omitempty"`
+	// Uploads relates unfinished uploads of local files with URLs of resumable uploads on server
+	Uploads map[string]string `json:"uploads,omitempty"`
+}
+
+// configPath returns path of config file, it can be changed by FHS_CONFIG environment variable
+func configPath() (string, error) {
+	if path := os.Getenv("FHS_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fhs", "config.json"), nil
+}
+
+// loadConfig reads config from file, if file doesn't exist default config is returned
+func loadConfig(path string) (config, error) {
+	cfg := config{Server: defaultServer}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Server == "" {
+		cfg.Server = defaultServer
+	}
+	return cfg, nil
+}
+
+// save writes config to file, config contains credentials so only owner can read it
+func (cfg config) save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
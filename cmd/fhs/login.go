@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/credentials"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+)
+
+// warningRegexp finds warning of login page
+var warningRegexp = regexp.MustCompile(`<h2 style="color:red">(.*?)</h2>`)
+
+// login sends login form of site the same way as browser does and returns token of created session.
+// If user has enabled two-factor authentication readCode is called to get code.
+func login(server, username, password string, readCode func() (string, error)) (string, error) {
+	// username and password are checked before sending by the same rules as login page uses
+	err := credentials.ValidateUsername(username)
+	if err != nil {
+		return "", err
+	}
+	err = credentials.ValidatePassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return "", err
+	}
+	httpClient := &http.Client{
+		Jar: jar,
+		// redirect after login shows result of login, so it shouldn't be followed
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	server = strings.TrimSuffix(server, "/")
+
+	// getting of login page sets CSRF cookie for login form
+	resp, err := httpClient.Get(server + "/login")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	u, err := url.Parse(server + "/login")
+	if err != nil {
+		return "", err
+	}
+	csrfToken := ""
+	for _, cookie := range jar.Cookies(u) {
+		if cookie.Name == csrf.CookieName {
+			csrfToken = cookie.Value
+		}
+	}
+
+	resp, err = httpClient.PostForm(server+"/login", url.Values{
+		"username":     {username},
+		"password":     {password},
+		csrf.FieldName: {csrfToken},
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusFound && resp.Header.Get("Location") == types.TwoFactorLoginPath {
+		resp.Body.Close()
+		code, err := readCode()
+		if err != nil {
+			return "", err
+		}
+		resp, err = httpClient.PostForm(server+types.TwoFactorLoginPath, url.Values{
+			"code":         {code},
+			csrf.FieldName: {csrfToken},
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == types.SessionCookie && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+	return "", loginError(resp)
+}
+
+// loginError returns warning shown by login page as error
+func loginError(resp *http.Response) error {
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	match := warningRegexp.FindSubmatch(body)
+	if match == nil {
+		return errors.New("Login failed")
+	}
+	return errors.New(html.UnescapeString(string(match[1])))
+}
+
+// logout removes session on server the same way as logout page does
+func logout(server, sessionToken string) error {
+	r, err := http.NewRequest("GET", strings.TrimSuffix(server, "/")+"/logout", nil)
+	if err != nil {
+		return err
+	}
+	r.AddCookie(&http.Cookie{Name: types.SessionCookie, Value: sessionToken})
+	httpClient := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+)
+
+// newLoginServer returns server with login pages, user "username" has password "password" and two-factor code "123456"
+func newLoginServer(twoFactor bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			http.SetCookie(w, &http.Cookie{Name: csrf.CookieName, Value: "csrf", Path: "/"})
+			return
+		}
+		if r.FormValue(csrf.FieldName) != "csrf" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/login":
+			if r.FormValue("username") != "username" || r.FormValue("password") != "password" {
+				w.Write([]byte(`<h2 style="color:red">Wrong username or password</h2>`))
+				return
+			}
+			if twoFactor {
+				http.Redirect(w, r, types.TwoFactorLoginPath, http.StatusFound)
+				return
+			}
+		case types.TwoFactorLoginPath:
+			if r.FormValue("code") != "123456" {
+				w.Write([]byte(`<h2 style="color:red">Wrong code</h2>`))
+				return
+			}
+		}
+		http.SetCookie(w, &http.Cookie{Name: types.SessionCookie, Value: "session-token"})
+		http.Redirect(w, r, "/", http.StatusFound)
+	}))
+}
+
+func TestLoginSuccess(t *testing.T) {
+	ts := newLoginServer(false)
+	defer ts.Close()
+
+	token, err := login(ts.URL, "username", "password", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "session-token", token)
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	ts := newLoginServer(true)
+	defer ts.Close()
+
+	token, err := login(ts.URL, "username", "password", func() (string, error) { return "123456", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "session-token", token)
+
+	_, err = login(ts.URL, "username", "password", func() (string, error) { return "000000", nil })
+	assert.EqualError(t, err, "Wrong code")
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	ts := newLoginServer(false)
+	defer ts.Close()
+
+	_, err := login(ts.URL, "username", "wrong", nil)
+	assert.EqualError(t, err, "Wrong username or password")
+}
+
+func TestLoginValidation(t *testing.T) {
+	// credentials are checked before connection to server
+	_, err := login("http://localhost:0", "", "password", nil)
+	assert.EqualError(t, err, "Username cannot be empty")
+}
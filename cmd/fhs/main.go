@@ -0,0 +1,448 @@
+// Command fhs is command-line client of file hosting site.
+// It uses JSON API of site with API token or with session created by login command.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+const usage = `Usage: fhs [-server URL] [-token TOKEN] [-json] COMMAND [ARGS]
+
+Commands:
+  login [-token TOKEN] [-password-stdin] [USERNAME]  log in by password or save API token
+  logout                                             remove saved credentials
+  whoami                                             show authorized user
+  ls [-sort recent|popular] [-category SLUG] [-owner USERNAME] [-mine] [-page N]
+                                                     list files
+  search [-category SLUG] [-owner USERNAME] [-min-size MB] [-max-size MB] [-from DATE] [-to DATE] [-sort ORDER] [-page N] [QUERY]
+                                                     search files
+  upload [-label LABEL] [-description TEXT] [-category SLUG] FILE...
+                                                     upload files, interrupted uploads are resumed
+  download [-o PATH] ID                              download file, interrupted download is resumed
+  rate ID RATING                                     vote for file
+  rm ID...                                           delete own files
+
+Config is stored in file set by FHS_CONFIG environment variable (default: fhs/config.json in user config directory).
+`
+
+// errUsage is returned when command line is incorrect, usage is printed instead of error message
+var errUsage = errors.New("incorrect usage")
+
+// app contains state shared by commands
+type app struct {
+	cfg     config
+	cfgPath string
+	// server is address of site from -server flag or config, login saves it to config
+	server string
+	client *client
+	out    printer
+	stdin  *bufio.Reader
+	stderr io.Writer
+	// bar is writer of progress bars, it's nil if progress bars are hidden
+	bar io.Writer
+}
+
+func main() {
+	// progress bars are drawn only to terminal
+	var bar io.Writer
+	if stat, err := os.Stderr.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		bar = os.Stderr
+	}
+
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, bar)
+	if err == errUsage {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fhs: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// run parses global flags and runs command
+func run(args []string, stdin io.Reader, stdout, stderr, bar io.Writer) error {
+	fs := flag.NewFlagSet("fhs", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	server := fs.String("server", "", "address of site")
+	token := fs.String("token", "", "API token")
+	jsonOutput := fs.Bool("json", false, "print results as JSON")
+	if fs.Parse(args) != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read config %s: %s", path, err)
+	}
+	if cfg.Uploads == nil {
+		cfg.Uploads = map[string]string{}
+	}
+	// flags override config only for this run, so they are not saved
+	override := cfg
+	if *server != "" {
+		override.Server = *server
+	}
+	if *token != "" {
+		override.Token = *token
+	}
+
+	a := &app{
+		cfg:     cfg,
+		cfgPath: path,
+		server:  override.Server,
+		client:  newClient(override),
+		out:     printer{w: stdout, json: *jsonOutput},
+		stdin:   bufio.NewReader(stdin),
+		stderr:  stderr,
+	}
+	// JSON output is intended for scripts, so progress bars are hidden
+	if !*jsonOutput {
+		a.bar = bar
+	}
+
+	commands := map[string]func([]string) error{
+		"login":    a.login,
+		"logout":   a.logout,
+		"whoami":   a.whoami,
+		"ls":       a.ls,
+		"search":   a.search,
+		"upload":   a.upload,
+		"download": a.download,
+		"rate":     a.rate,
+		"rm":       a.rm,
+	}
+	command, ok := commands[fs.Arg(0)]
+	if !ok {
+		return errUsage
+	}
+	return command(fs.Args()[1:])
+}
+
+// parseFlags parses flags of command, flags should be before arguments
+func parseFlags(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	fs.SetOutput(ioutil.Discard)
+	if fs.Parse(args) != nil {
+		return errUsage
+	}
+	if fs.NArg() < minArgs || (maxArgs >= 0 && fs.NArg() > maxArgs) {
+		return errUsage
+	}
+	return nil
+}
+
+// prompt asks user to input line
+func (a *app) prompt(text string) (string, error) {
+	fmt.Fprint(a.stderr, text)
+	line, err := a.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// login handles login command.
+// With -token flag API token is checked and saved, otherwise user logs in by username and password
+// and token of created session is saved.
+func (a *app) login(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	token := fs.String("token", "", "API token")
+	passwordStdin := fs.Bool("password-stdin", false, "read password from stdin")
+	err := parseFlags(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	a.cfg.Server = a.server
+	if *token != "" {
+		a.cfg.Token = *token
+		a.cfg.Session = ""
+		a.client = newClient(a.cfg)
+		me, err := a.client.me()
+		if err != nil {
+			return err
+		}
+		err = a.cfg.save(a.cfgPath)
+		if err != nil {
+			return err
+		}
+		return a.out.message("Logged in as %s", me.Username)
+	}
+
+	username := fs.Arg(0)
+	if username == "" {
+		username, err = a.prompt("Username: ")
+		if err != nil {
+			return err
+		}
+	}
+	password := ""
+	if *passwordStdin {
+		password, err = a.prompt("")
+	} else {
+		// password isn't hidden, use -password-stdin to pass it from file or password manager
+		password, err = a.prompt("Password: ")
+	}
+	if err != nil {
+		return err
+	}
+
+	readCode := func() (string, error) {
+		return a.prompt("Two-factor authentication code: ")
+	}
+	sessionToken, err := login(a.cfg.Server, strings.TrimSpace(username), password, readCode)
+	if err != nil {
+		return err
+	}
+	a.cfg.Session = sessionToken
+	a.cfg.Token = ""
+	err = a.cfg.save(a.cfgPath)
+	if err != nil {
+		return err
+	}
+	return a.out.message("Logged in as %s", strings.TrimSpace(username))
+}
+
+// logout handles logout command, it removes session on server and saved credentials.
+// API tokens are not revoked, it can be done on tokens page of site.
+func (a *app) logout(args []string) error {
+	err := parseFlags(flag.NewFlagSet("logout", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+	if a.cfg.Session != "" {
+		err = logout(a.server, a.cfg.Session)
+		if err != nil {
+			return err
+		}
+	}
+	a.cfg.Session = ""
+	a.cfg.Token = ""
+	err = a.cfg.save(a.cfgPath)
+	if err != nil {
+		return err
+	}
+	return a.out.message("Logged out")
+}
+
+// whoami handles whoami command
+func (a *app) whoami(args []string) error {
+	err := parseFlags(flag.NewFlagSet("whoami", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+	me, err := a.client.me()
+	if err != nil {
+		return err
+	}
+	u := types.User{}
+	err = a.client.getJSON("users/"+url.PathEscape(me.Username), &u)
+	if err != nil {
+		return err
+	}
+	return a.out.user(u)
+}
+
+// ls handles ls command
+func (a *app) ls(args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	sort := fs.String("sort", "", "recent or popular")
+	category := fs.String("category", "", "category slug")
+	owner := fs.String("owner", "", "username of owner")
+	mine := fs.Bool("mine", false, "list own files")
+	page := fs.Int("page", 1, "page number")
+	err := parseFlags(fs, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if *mine {
+		me, err := a.client.me()
+		if err != nil {
+			return err
+		}
+		*owner = me.Username
+	}
+	query := url.Values{}
+	setNotEmpty(query, "sort", *sort)
+	setNotEmpty(query, "category", *category)
+	setNotEmpty(query, "owner", *owner)
+	query.Set("page", strconv.Itoa(*page))
+
+	list := types.FileList{}
+	err = a.client.getJSON("files?"+query.Encode(), &list)
+	if err != nil {
+		return err
+	}
+	return a.out.files(list)
+}
+
+// search handles search command, words after flags are search query
+func (a *app) search(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	category := fs.String("category", "", "category slug")
+	owner := fs.String("owner", "", "username of owner")
+	minSize := fs.String("min-size", "", "minimal size in MB")
+	maxSize := fs.String("max-size", "", "maximal size in MB")
+	from := fs.String("from", "", "uploaded from date YYYY-MM-DD")
+	to := fs.String("to", "", "uploaded to date YYYY-MM-DD")
+	sort := fs.String("sort", "", "sort order")
+	page := fs.Int("page", 1, "page number")
+	err := parseFlags(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	setNotEmpty(query, "q", strings.Join(fs.Args(), " "))
+	setNotEmpty(query, "category", *category)
+	setNotEmpty(query, "owner", *owner)
+	setNotEmpty(query, "minSize", *minSize)
+	setNotEmpty(query, "maxSize", *maxSize)
+	setNotEmpty(query, "from", *from)
+	setNotEmpty(query, "to", *to)
+	setNotEmpty(query, "sort", *sort)
+	if len(query) == 0 {
+		return errors.New("Please input search parameters")
+	}
+	query.Set("page", strconv.Itoa(*page))
+
+	list := types.FileList{}
+	err = a.client.getJSON("search?"+query.Encode(), &list)
+	if err != nil {
+		return err
+	}
+	return a.out.files(list)
+}
+
+// upload handles upload command, label and description are used for all uploaded files
+func (a *app) upload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	info := uploadInfo{}
+	fs.StringVar(&info.label, "label", "", "name of file on site (default: filename)")
+	fs.StringVar(&info.description, "description", "", "description of file")
+	fs.StringVar(&info.category, "category", "", "category slug (default: other)")
+	err := parseFlags(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	if info.label != "" && fs.NArg() > 1 {
+		return errors.New("Label can be set only for one file")
+	}
+
+	saveUploads := func() error {
+		return a.cfg.save(a.cfgPath)
+	}
+	for _, path := range fs.Args() {
+		f, err := a.client.upload(path, info, a.cfg.Uploads, saveUploads, a.bar)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		err = a.out.file(f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// download handles download command
+func (a *app) download(args []string) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	dest := fs.String("o", "", "path of downloaded file (default: label of file)")
+	err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := fileID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	path, err := a.client.download(id, *dest, a.bar)
+	if err != nil {
+		return err
+	}
+	return a.out.message("Downloaded file %s to %s", id, path)
+}
+
+// rate handles rate command
+func (a *app) rate(args []string) error {
+	fs := flag.NewFlagSet("rate", flag.ContinueOnError)
+	err := parseFlags(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	id, err := fileID(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// rating is checked by the same rules as server uses
+	rating, err := strconv.Atoi(fs.Arg(1))
+	if err != nil || rating > types.MaxRating || rating < types.MinRating {
+		return fmt.Errorf("Rating should be from %d to %d", types.MinRating, types.MaxRating)
+	}
+
+	f := types.File{}
+	err = a.client.sendJSON("POST", "files/"+id+"/vote", types.Vote{Rating: rating}, &f)
+	if err != nil {
+		return err
+	}
+	return a.out.file(f)
+}
+
+// rm handles rm command
+func (a *app) rm(args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ContinueOnError)
+	err := parseFlags(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+	for _, arg := range fs.Args() {
+		id, err := fileID(arg)
+		if err != nil {
+			return err
+		}
+		resp, err := a.client.do("DELETE", types.Prefix+"files/"+id, nil, nil)
+		if err != nil {
+			return fmt.Errorf("File %s: %s", id, err)
+		}
+		resp.Body.Close()
+		err = a.out.message("Deleted file %s", id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileID checks that argument is ID of file
+func fileID(arg string) (string, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 1 {
+		return "", fmt.Errorf("Incorrect file ID %q", arg)
+	}
+	return strconv.Itoa(id), nil
+}
+
+// setNotEmpty sets value of query parameter if it isn't empty
+func setNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
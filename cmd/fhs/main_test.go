@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withConfig sets FHS_CONFIG to file in temporary directory
+func withConfig(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "fhs-test-")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "config.json")
+	os.Setenv("FHS_CONFIG", path)
+	return path, func() {
+		os.Unsetenv("FHS_CONFIG")
+		os.RemoveAll(dir)
+	}
+}
+
+// newAPIServer returns server which accepts only token "fhs_token" and records requests
+func newAPIServer(requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fhs_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"status":401,"message":"Invalid API token"}}`))
+			return
+		}
+		*requests = append(*requests, r.Method+" "+r.URL.String())
+		switch r.URL.Path {
+		case "/api/v1/me":
+			w.Write([]byte(`{"username":"username","csrfToken":""}`))
+		case "/api/v1/files":
+			w.Write([]byte(`{"files":[],"page":1,"pages":0,"total":0}`))
+		case "/api/v1/files/1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"status":404,"message":"File not found"}}`))
+		}
+	}))
+}
+
+func TestRunUsage(t *testing.T) {
+	_, cleanup := withConfig(t)
+	defer cleanup()
+
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"download"},
+		{"rate", "1"},
+		{"ls", "-unknown"},
+	} {
+		assert.Equal(t, errUsage, run(args, nil, ioutil.Discard, ioutil.Discard, nil), args)
+	}
+}
+
+func TestRunNotLoggedIn(t *testing.T) {
+	_, cleanup := withConfig(t)
+	defer cleanup()
+
+	assert.Equal(t, errNotLoggedIn, run([]string{"ls"}, nil, ioutil.Discard, ioutil.Discard, nil))
+}
+
+func TestRunValidation(t *testing.T) {
+	_, cleanup := withConfig(t)
+	defer cleanup()
+
+	assert.EqualError(t, run([]string{"-token", "fhs_token", "rate", "1", "11"}, nil, ioutil.Discard, ioutil.Discard, nil), "Rating should be from -10 to 10")
+	assert.EqualError(t, run([]string{"-token", "fhs_token", "rm", "abc"}, nil, ioutil.Discard, ioutil.Discard, nil), "Incorrect file ID \"abc\"")
+	assert.EqualError(t, run([]string{"-token", "fhs_token", "search"}, nil, ioutil.Discard, ioutil.Discard, nil), "Please input search parameters")
+}
+
+func TestRunLoginToken(t *testing.T) {
+	path, cleanup := withConfig(t)
+	defer cleanup()
+	requests := []string{}
+	ts := newAPIServer(&requests)
+	defer ts.Close()
+
+	stdout := &bytes.Buffer{}
+	require.NoError(t, run([]string{"-server", ts.URL, "login", "-token", "fhs_token"}, nil, stdout, ioutil.Discard, nil))
+	assert.Equal(t, "Logged in as username\n", stdout.String())
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, config{Server: ts.URL, Token: "fhs_token"}, cfg)
+
+	// saved server and token are used by next commands
+	stdout.Reset()
+	require.NoError(t, run([]string{"-json", "ls", "-mine"}, nil, stdout, ioutil.Discard, nil))
+	assert.JSONEq(t, `{"files":[],"page":1,"pages":0,"total":0}`, stdout.String())
+	assert.Equal(t, []string{"GET /api/v1/me", "GET /api/v1/me", "GET /api/v1/files?owner=username&page=1"}, requests)
+
+	assert.EqualError(t, run([]string{"login", "-token", "fhs_wrong"}, nil, ioutil.Discard, ioutil.Discard, nil), "Invalid API token")
+}
+
+func TestRunRemove(t *testing.T) {
+	_, cleanup := withConfig(t)
+	defer cleanup()
+	requests := []string{}
+	ts := newAPIServer(&requests)
+	defer ts.Close()
+
+	stdout := &bytes.Buffer{}
+	err := run([]string{"-server", ts.URL, "-token", "fhs_token", "rm", "1", "2"}, nil, stdout, ioutil.Discard, nil)
+	assert.EqualError(t, err, "File 2: File not found")
+	assert.Equal(t, "Deleted file 1\n", stdout.String())
+	assert.Equal(t, []string{"DELETE /api/v1/files/1", "DELETE /api/v1/files/2"}, requests)
+}
+
+func TestRunLoginPassword(t *testing.T) {
+	path, cleanup := withConfig(t)
+	defer cleanup()
+	ts := newLoginServer(false)
+	defer ts.Close()
+
+	stderr := &bytes.Buffer{}
+	err := run([]string{"-server", ts.URL, "login", "username"}, strings.NewReader("password\n"), ioutil.Discard, stderr, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Password: ", stderr.String())
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "session-token", cfg.Session)
+	assert.Equal(t, "", cfg.Token)
+}
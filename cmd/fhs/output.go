@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+// printer writes results of commands as tables or as JSON
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// printJSON writes value as indented JSON
+func (p printer) printJSON(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// files writes page of files list
+func (p printer) files(list types.FileList) error {
+	if p.json {
+		return p.printJSON(list)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLABEL\tSIZE\tOWNER\tCATEGORY\tUPLOADED\tRATING")
+	for _, f := range list.Files {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d\n", f.ID, f.Label, size(f.FilesizeBytes), f.Owner, f.Category, f.UploadDate.Format("2006-01-02 15:04"), f.Rating)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "Page %d of %d, %d files\n", list.Page, list.Pages, list.Total)
+	return err
+}
+
+// file writes info of one file
+func (p printer) file(f types.File) error {
+	if p.json {
+		return p.printJSON(f)
+	}
+	return p.fields([][2]string{
+		{"ID", fmt.Sprint(f.ID)},
+		{"Label", f.Label},
+		{"Size", size(f.FilesizeBytes)},
+		{"Description", f.Description},
+		{"Owner", f.Owner},
+		{"Category", f.Category},
+		{"Uploaded", f.UploadDate.Format("2006-01-02 15:04")},
+		{"Rating", fmt.Sprint(f.Rating)},
+		{"Tags", strings.Join(f.Tags, ", ")},
+	})
+}
+
+// user writes public info of user
+func (p printer) user(u types.User) error {
+	if p.json {
+		return p.printJSON(u)
+	}
+	return p.fields([][2]string{
+		{"Username", u.Username},
+		{"Rating", fmt.Sprint(u.Rating)},
+		{"Joined", u.JoinDate.Format("2006-01-02")},
+		{"Uploads", fmt.Sprint(u.Uploads)},
+		{"Uploaded", size(u.UploadedBytes)},
+	})
+}
+
+// message writes result of command which doesn't return data from server, e.g. "Deleted file 1"
+func (p printer) message(format string, args ...interface{}) error {
+	if p.json {
+		return p.printJSON(map[string]string{"message": fmt.Sprintf(format, args...)})
+	}
+	_, err := fmt.Fprintf(p.w, format+"\n", args...)
+	return err
+}
+
+// fields writes pairs of field name and value in two columns
+func (p printer) fields(fields [][2]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	for _, field := range fields {
+		fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+	}
+	return tw.Flush()
+}
+
+// size formats size in bytes the same way as site shows it
+func size(bytes int64) string {
+	return fmt.Sprintf("%.4f MB", megabytes(bytes))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+var testList = types.FileList{
+	Files: []types.FileInfo{{
+		ID:            1,
+		Label:         "file.txt",
+		FilesizeBytes: 1 << 20,
+		Owner:         "username",
+		Category:      "other",
+		UploadDate:    time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC),
+		Rating:        5,
+	}},
+	Page:  1,
+	Pages: 1,
+	Total: 1,
+}
+
+func TestPrinterFilesTable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, printer{w: buf}.files(testList))
+	assert.Equal(t, ""+
+		"ID  LABEL     SIZE       OWNER     CATEGORY  UPLOADED          RATING\n"+
+		"1   file.txt  1.0000 MB  username  other     2009-11-17 20:34  5\n"+
+		"Page 1 of 1, 1 files\n", buf.String())
+}
+
+func TestPrinterFilesJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, printer{w: buf, json: true}.files(testList))
+	assert.JSONEq(t, `{"files": [{"id": 1, "label": "file.txt", "filesizeBytes": 1048576, "description": "", "owner": "username", "category": "other", "uploadDate": "2009-11-17T20:34:58Z", "rating": 5}], "page": 1, "pages": 1, "total": 1}`, buf.String())
+}
+
+func TestPrinterUser(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, printer{w: buf}.user(types.User{Username: "username", Rating: 10, JoinDate: time.Date(2009, 11, 17, 0, 0, 0, 0, time.UTC), Uploads: 2, UploadedBytes: 2 << 20}))
+	assert.Equal(t, ""+
+		"Username:  username\n"+
+		"Rating:    10\n"+
+		"Joined:    2009-11-17\n"+
+		"Uploads:   2\n"+
+		"Uploaded:  2.0000 MB\n", buf.String())
+}
+
+func TestPrinterMessage(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, printer{w: buf}.message("Deleted file %d", 1))
+	assert.Equal(t, "Deleted file 1\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, printer{w: buf, json: true}.message("Deleted file %d", 1))
+	assert.Equal(t, "{\n  \"message\": \"Deleted file 1\"\n}\n", buf.String())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	barWidth       = 30
+	redrawInterval = 100 * time.Millisecond
+)
+
+// progress draws progress bar of file transfer, it's used as io.Writer for io.TeeReader
+type progress struct {
+	w     io.Writer // progress bar isn't drawn if it's nil
+	label string
+	total int64
+	done  int64
+	drawn time.Time
+}
+
+// newProgress returns progress bar of transfer which is already done partially
+func newProgress(w io.Writer, label string, done, total int64) *progress {
+	p := &progress{w: w, label: label, done: done, total: total}
+	p.draw()
+	return p
+}
+
+// Write counts transferred bytes and redraws progress bar
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.drawn) >= redrawInterval {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+// set sets count of transferred bytes, it's used when server reports offset of transfer
+func (p *progress) set(done int64) {
+	p.done = done
+	p.draw()
+}
+
+// finish draws final state of progress bar and moves cursor to next line
+func (p *progress) finish() {
+	if p.w == nil {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+// draw redraws progress bar in current line
+func (p *progress) draw() {
+	if p.w == nil {
+		return
+	}
+	p.drawn = time.Now()
+	fmt.Fprint(p.w, "\r"+p.line())
+}
+
+// line returns text of progress bar, e.g. "file.txt [=====>    ]  50% 1.0/2.0 MB"
+func (p *progress) line() string {
+	percent := 100
+	if p.total > 0 {
+		percent = int(p.done * 100 / p.total)
+	}
+	filled := barWidth * percent / 100
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %3d%% %.1f/%.1f MB", p.label, bar, percent, megabytes(p.done), megabytes(p.total))
+}
+
+// megabytes converts size in bytes to MB
+func megabytes(size int64) float64 {
+	return float64(size) / 1024 / 1024
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressLine(t *testing.T) {
+	p := &progress{label: "file.txt", done: 1 << 20, total: 4 << 20}
+	assert.Equal(t, "file.txt [=======>                      ]  25% 1.0/4.0 MB", p.line())
+
+	p.done = p.total
+	assert.Equal(t, "file.txt [==============================] 100% 4.0/4.0 MB", p.line())
+
+	// empty file
+	p = &progress{label: "empty"}
+	assert.Equal(t, "empty [==============================] 100% 0.0/0.0 MB", p.line())
+}
+
+func TestProgressDraw(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := newProgress(buf, "file.txt", 0, 2<<20)
+	p.set(1 << 20)
+	p.finish()
+	assert.Equal(t, "\rfile.txt [>                             ]   0% 0.0/2.0 MB"+
+		"\rfile.txt [===============>              ]  50% 1.0/2.0 MB"+
+		"\rfile.txt [===============>              ]  50% 1.0/2.0 MB\n", buf.String())
+}
+
+func TestProgressHidden(t *testing.T) {
+	p := newProgress(nil, "file.txt", 0, 10)
+	n, err := p.Write([]byte("data"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, int64(4), p.done)
+	p.finish()
+}
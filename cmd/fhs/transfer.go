@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+const (
+	tusVersion = "1.0.0"
+	chunkSize  = 8 << 20 // size of data sent by one PATCH request of resumable upload
+)
+
+// uploadInfo contains info of uploaded file, empty label means that filename is used
+type uploadInfo struct {
+	label       string
+	description string
+	category    string
+}
+
+// upload uploads local file using resumable upload, so interrupted upload of the same file
+// is continued from the last uploaded byte on the next run.
+// Uploads map of config stores URLs of unfinished uploads, saveUploads is called after each change of it.
+func (c *client) upload(path string, info uploadInfo, uploads map[string]string, saveUploads func() error, bar io.Writer) (types.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return types.File{}, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return types.File{}, err
+	}
+	if stat.IsDir() {
+		return types.File{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.label == "" {
+		info.label = filepath.Base(path)
+	}
+	// file info is checked before sending by the same rules as server uses
+	err = types.ValidateFile(stat.Size(), info.label, info.description)
+	if err != nil {
+		return types.File{}, err
+	}
+
+	key, err := uploadKey(path, stat)
+	if err != nil {
+		return types.File{}, err
+	}
+	location, offset := uploads[key], int64(0)
+	if location != "" {
+		offset, err = c.uploadOffset(location)
+		// upload could be removed from server, in this case it's started again
+		if e, ok := err.(apiError); ok && (e.Status == http.StatusNotFound || e.Status == http.StatusGone) {
+			location = ""
+		} else if err != nil {
+			return types.File{}, err
+		}
+	}
+	if location == "" {
+		location, err = c.createUpload(stat.Size(), filepath.Base(path), info)
+		if err != nil {
+			return types.File{}, err
+		}
+		uploads[key] = location
+		err = saveUploads()
+		if err != nil {
+			return types.File{}, err
+		}
+	}
+
+	p := newProgress(bar, info.label, offset, stat.Size())
+	fileID := ""
+	for fileID == "" {
+		_, err = f.Seek(offset, io.SeekStart)
+		if err != nil {
+			return types.File{}, err
+		}
+		// empty file is uploaded by one empty PATCH request
+		chunk := io.TeeReader(io.LimitReader(f, chunkSize), p)
+		offset, fileID, err = c.patchUpload(location, offset, chunk)
+		if err != nil {
+			return types.File{}, fmt.Errorf("Upload interrupted: %s. Run the same command again to resume it", err)
+		}
+		p.set(offset)
+		if fileID == "" && offset >= stat.Size() {
+			return types.File{}, errors.New("Server didn't finish upload")
+		}
+	}
+	p.finish()
+
+	delete(uploads, key)
+	err = saveUploads()
+	if err != nil {
+		return types.File{}, err
+	}
+	return c.file(fileID)
+}
+
+// uploadKey returns key of local file in map of unfinished uploads, changed file will have another key
+func uploadKey(path string, stat os.FileInfo) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s|%d|%d", abs, stat.Size(), stat.ModTime().Unix()), nil
+}
+
+// createUpload creates resumable upload on server and returns its URL
+func (c *client) createUpload(size int64, filename string, info uploadInfo) (string, error) {
+	metadata := []string{}
+	for _, kv := range [][2]string{
+		{"filename", filename},
+		{"label", info.label},
+		{"description", info.description},
+		{"category", info.category},
+	} {
+		metadata = append(metadata, kv[0]+" "+base64.StdEncoding.EncodeToString([]byte(kv[1])))
+	}
+	resp, err := c.do("POST", types.Prefix+"uploads", nil, http.Header{
+		"Tus-Resumable":   {tusVersion},
+		"Upload-Length":   {strconv.FormatInt(size, 10)},
+		"Upload-Metadata": {strings.Join(metadata, ",")},
+	})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("Server didn't return location of upload")
+	}
+	return location, nil
+}
+
+// uploadOffset returns how many bytes of resumable upload are already received by server
+func (c *client) uploadOffset(location string) (int64, error) {
+	resp, err := c.do("HEAD", location, nil, http.Header{"Tus-Resumable": {tusVersion}})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// patchUpload sends chunk of file starting from offset.
+// It returns new offset and ID of uploaded file if upload is finished.
+func (c *client) patchUpload(location string, offset int64, chunk io.Reader) (int64, string, error) {
+	resp, err := c.do("PATCH", location, chunk, http.Header{
+		"Tus-Resumable": {tusVersion},
+		"Upload-Offset": {strconv.FormatInt(offset, 10)},
+		"Content-Type":  {"application/offset+octet-stream"},
+	})
+	if err != nil {
+		return offset, "", err
+	}
+	resp.Body.Close()
+	newOffset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return offset, "", errors.New("Incorrect Upload-Offset")
+	}
+	return newOffset, resp.Header.Get("Upload-File-ID"), nil
+}
+
+// download downloads file to dest, if dest is empty label of file is used.
+// File is written to dest with .part suffix and renamed when download is finished,
+// so interrupted download is continued from the end of .part file on the next run.
+// ETag of downloaded content is kept in .part.etag file and sent in If-Range header,
+// so .part file of changed file isn't continued by another content.
+func (c *client) download(id, dest string, bar io.Writer) (string, error) {
+	f, err := c.file(id)
+	if err != nil {
+		return "", err
+	}
+	if dest == "" {
+		dest = filepath.Base(f.Label)
+	}
+	part := dest + ".part"
+	etagPath := part + ".etag"
+
+	offset := int64(0)
+	stat, err := os.Stat(part)
+	if err == nil {
+		offset = stat.Size()
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	header := http.Header{}
+	if offset > 0 {
+		etag, err := ioutil.ReadFile(etagPath)
+		// content of .part file cannot be checked without ETag, so it's downloaded again
+		if err == nil && len(etag) > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			header.Set("If-Range", string(etag))
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	resp, err := c.do("GET", types.Prefix+"files/"+id+"/content", nil, header)
+	if e, ok := err.(apiError); ok && e.Status == http.StatusRequestedRangeNotSatisfiable {
+		// .part file isn't shorter than file on server, so it's downloaded again
+		resp, err = c.do("GET", types.Prefix+"files/"+id+"/content", nil, nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	// server sends whole file if it doesn't support ranges, file was changed or range wasn't requested
+	if resp.StatusCode != http.StatusPartialContent {
+		offset = 0
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		err = saveETag(etagPath, resp.Header.Get("ETag"))
+		if err != nil {
+			return "", err
+		}
+	}
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	p := newProgress(bar, f.Label, offset, f.FilesizeBytes)
+	_, err = io.Copy(out, io.TeeReader(resp.Body, p))
+	closeErr := out.Close()
+	if err != nil {
+		return "", fmt.Errorf("Download interrupted: %s. Run the same command again to resume it", err)
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+	p.finish()
+
+	err = os.Rename(part, dest)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(etagPath)
+	return dest, nil
+}
+
+// saveETag writes ETag of downloaded content to path. Weak ETags cannot be used in If-Range header,
+// so they aren't saved and download of such content isn't resumed.
+func saveETag(path, etag string) error {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(etag), 0644)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+const testContent = "binary data"
+
+// fakeTus is server which implements resumable uploads the same way as site does
+type fakeTus struct {
+	data   []byte
+	length int64
+	// failPatch makes PATCH requests fail
+	failPatch bool
+	created   bool
+}
+
+func (ft *fakeTus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer fhs_token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/api/v1/uploads":
+		ft.length, _ = strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		ft.created = true
+		w.Header().Set("Location", "/api/v1/uploads/1")
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "HEAD" && r.URL.Path == "/api/v1/uploads/1":
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(ft.data)))
+	case r.Method == "PATCH" && r.URL.Path == "/api/v1/uploads/1":
+		if ft.failPatch {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(ft.data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		ft.data = append(ft.data, body...)
+		if int64(len(ft.data)) == ft.length {
+			w.Header().Set("Upload-File-ID", "7")
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(ft.data)))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && r.URL.Path == "/api/v1/files/7":
+		w.Write([]byte(`{"id":7,"label":"file.txt","filesizeBytes":11,"tags":[]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newTestFile creates file with testContent in temporary directory
+func newTestFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "fhs-test-")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testContent), 0644))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestUploadSuccess(t *testing.T) {
+	path, cleanup := newTestFile(t)
+	defer cleanup()
+	ft := &fakeTus{}
+	ts := httptest.NewServer(ft)
+	defer ts.Close()
+
+	uploads := map[string]string{}
+	saved := 0
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	f, err := c.upload(path, uploadInfo{}, uploads, func() error { saved++; return nil }, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, f.ID)
+	assert.Equal(t, testContent, string(ft.data))
+	assert.Empty(t, uploads)
+	assert.Equal(t, 2, saved)
+}
+
+func TestUploadResume(t *testing.T) {
+	path, cleanup := newTestFile(t)
+	defer cleanup()
+	// the first part of file is already uploaded
+	ft := &fakeTus{data: []byte(testContent[:6]), length: int64(len(testContent))}
+	ts := httptest.NewServer(ft)
+	defer ts.Close()
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	key, err := uploadKey(path, stat)
+	require.NoError(t, err)
+	uploads := map[string]string{key: "/api/v1/uploads/1"}
+
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	f, err := c.upload(path, uploadInfo{}, uploads, func() error { return nil }, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, f.ID)
+	assert.False(t, ft.created)
+	assert.Equal(t, testContent, string(ft.data))
+	assert.Empty(t, uploads)
+}
+
+func TestUploadInterrupted(t *testing.T) {
+	path, cleanup := newTestFile(t)
+	defer cleanup()
+	ft := &fakeTus{failPatch: true}
+	ts := httptest.NewServer(ft)
+	defer ts.Close()
+
+	uploads := map[string]string{}
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	_, err := c.upload(path, uploadInfo{}, uploads, func() error { return nil }, nil)
+	assert.EqualError(t, err, "Upload interrupted: Internal Server Error. Run the same command again to resume it")
+
+	// URL of upload is kept to resume upload
+	assert.Len(t, uploads, 1)
+	for _, location := range uploads {
+		assert.Equal(t, "/api/v1/uploads/1", location)
+	}
+}
+
+func TestUploadValidation(t *testing.T) {
+	path, cleanup := newTestFile(t)
+	defer cleanup()
+
+	c := newClient(config{Server: "http://localhost:0", Token: "fhs_token"})
+	_, err := c.upload(path, uploadInfo{label: strings.Repeat("a", types.MaxLabelLen+1)}, map[string]string{}, func() error { return nil }, nil)
+	assert.EqualError(t, err, "Filename are too long")
+}
+
+// ETag of content sent by download server
+const testETag = `"9cb63cb779e8c571db3199b783a36cc43cd9e7c076beeb496c39e9cc06196dc5"`
+
+// newDownloadServer returns server which sends file info and content with support of ranges
+func newDownloadServer(ranges *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/files/1":
+			w.Write([]byte(`{"id":1,"label":"file.txt","filesizeBytes":11,"tags":[]}`))
+		case "/api/v1/files/1/content":
+			*ranges = append(*ranges, r.Header.Get("Range"))
+			w.Header().Set("ETag", testETag)
+			http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(testContent))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestDownloadSuccess(t *testing.T) {
+	ranges := []string{}
+	ts := newDownloadServer(&ranges)
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "fhs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	progressBar := &bytes.Buffer{}
+	path, err := c.download("1", filepath.Join(dir, "out.txt"), progressBar)
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(dir, "out.txt"), path)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, testContent, string(data))
+	assert.Equal(t, []string{""}, ranges)
+	assert.True(t, strings.HasSuffix(progressBar.String(), "100% 0.0/0.0 MB\n"))
+	_, err = os.Stat(path + ".part.etag")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadResume(t *testing.T) {
+	ranges := []string{}
+	ts := newDownloadServer(&ranges)
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "fhs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "out.txt")
+	// part of file was downloaded before interruption
+	require.NoError(t, ioutil.WriteFile(dest+".part", []byte(testContent[:6]), 0644))
+	require.NoError(t, ioutil.WriteFile(dest+".part.etag", []byte(testETag), 0644))
+
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	_, err = c.download("1", dest, nil)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, testContent, string(data))
+	assert.Equal(t, []string{"bytes=6-"}, ranges)
+	_, err = os.Stat(dest + ".part")
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(dest + ".part.etag")
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestDownloadResumeChangedFile tests case when content of file was changed after interruption of download
+func TestDownloadResumeChangedFile(t *testing.T) {
+	ranges := []string{}
+	ts := newDownloadServer(&ranges)
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "fhs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "out.txt")
+	require.NoError(t, ioutil.WriteFile(dest+".part", []byte("old co"), 0644))
+	require.NoError(t, ioutil.WriteFile(dest+".part.etag", []byte(`"old"`), 0644))
+
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	_, err = c.download("1", dest, nil)
+	require.NoError(t, err)
+
+	// If-Range doesn't match, so server sends whole file
+	data, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, testContent, string(data))
+	assert.Equal(t, []string{"bytes=6-"}, ranges)
+}
+
+// TestDownloadResumeWithoutETag tests case when .part file cannot be checked because its ETag wasn't saved
+func TestDownloadResumeWithoutETag(t *testing.T) {
+	ranges := []string{}
+	ts := newDownloadServer(&ranges)
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "fhs-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dest := filepath.Join(dir, "out.txt")
+	require.NoError(t, ioutil.WriteFile(dest+".part", []byte("old co"), 0644))
+
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	_, err = c.download("1", dest, nil)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, testContent, string(data))
+	assert.Equal(t, []string{""}, ranges)
+}
+
+func TestDownloadNotFound(t *testing.T) {
+	ts := newDownloadServer(&[]string{})
+	defer ts.Close()
+
+	c := newClient(config{Server: ts.URL, Token: "fhs_token"})
+	_, err := c.download("2", "", nil)
+	assert.Equal(t, apiError{Status: http.StatusNotFound, Message: "Not Found"}, err)
+}
@@ -0,0 +1,54 @@
+// Package credentials contains rules of usernames and passwords and hashing of passwords.
+// They are used by registration and login pages, by command-line client to check input before sending and by admin tool.
+package credentials
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MaxPasswordLen = 40
+	MaxUsernameLen = 20
+)
+
+// ValidateUsername checks username inputted by user
+func ValidateUsername(username string) error {
+	switch {
+	case len(username) == 0:
+		return fmt.Errorf("Username cannot be empty")
+
+	case len(username) > MaxUsernameLen:
+		return fmt.Errorf("Username cannot be longer than " + strconv.Itoa(MaxUsernameLen) + " characters")
+
+	case username != strings.ToLower(username):
+		return fmt.Errorf("Please use lower case username")
+	}
+
+	return nil
+}
+
+// ValidatePassword checks password inputted by user
+func ValidatePassword(password string) error {
+	switch {
+	case len(password) == 0:
+		return fmt.Errorf("Password cannot be empty")
+
+	case len(password) > MaxPasswordLen:
+		return fmt.Errorf("Password cannot be longer than " + strconv.Itoa(MaxPasswordLen) + " characters")
+	}
+
+	return nil
+}
+
+// Hash creates salted hash from password, it's stored in users table
+func Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,30 @@
+package credentials
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestValidateUsername(t *testing.T) {
+	assert.NoError(t, ValidateUsername("username"))
+
+	assert.EqualError(t, ValidateUsername(""), "Username cannot be empty")
+	assert.EqualError(t, ValidateUsername(strings.Repeat("a", MaxUsernameLen+1)), "Username cannot be longer than 20 characters")
+	assert.EqualError(t, ValidateUsername("UserName"), "Please use lower case username")
+}
+
+func TestValidatePassword(t *testing.T) {
+	assert.NoError(t, ValidatePassword("password"))
+
+	assert.EqualError(t, ValidatePassword(""), "Password cannot be empty")
+	assert.EqualError(t, ValidatePassword(strings.Repeat("a", MaxPasswordLen+1)), "Password cannot be longer than 40 characters")
+}
+
+func TestHash(t *testing.T) {
+	hash, err := Hash("password")
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("password")))
+}
@@ -0,0 +1,89 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+)
+
+const (
+	// FieldName is name of hidden form field with CSRF token
+	FieldName = "csrf_token"
+	// HeaderName is name of header with CSRF token, it's used by clients which don't send forms
+	HeaderName = "X-CSRF-Token"
+	// CookieName is name of cookie with CSRF token for forms of unauthorized users (login, registration)
+	CookieName = "csrf_token"
+
+	tokenLen = 32 // length of CSRF token in bytes
+)
+
+// NewToken returns random CSRF token
+func NewToken() (string, error) {
+	token := make([]byte, tokenLen)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// Field returns hidden form field with inputted CSRF token
+func Field(token string) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + FieldName + `" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// SafeMethod returns true if request method doesn't change state, so such requests don't need CSRF token
+func SafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// Valid returns true if request contains expected CSRF token in header or in form field
+func Valid(expected string, r *http.Request) bool {
+	if expected == "" {
+		return false
+	}
+	token := r.Header.Get(HeaderName)
+	if token == "" {
+		token = r.FormValue(FieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// Cookie returns CSRF token for forms of unauthorized users.
+// Token stores in cookie (double-submit cookie), if request doesn't have such cookie new token will be created.
+// Cross-site page cannot read the cookie, so it cannot put right token into submitted form.
+func Cookie(w http.ResponseWriter, r *http.Request, secure bool) (string, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Path:     "/",
+		Value:    token,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// ValidCookie returns true if form of unauthorized user contains the same CSRF token as cookie
+func ValidCookie(r *http.Request) bool {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return false
+	}
+	return Valid(cookie.Value, r)
+}
@@ -0,0 +1,101 @@
+package csrf_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+)
+
+// formRequest returns POST request with inputted form
+func formRequest(form string) *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader(form))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestNewToken(t *testing.T) {
+	token1, err := csrf.NewToken()
+	require.NoError(t, err)
+	token2, err := csrf.NewToken()
+	require.NoError(t, err)
+
+	assert.Len(t, token1, 43)
+	assert.NotEqual(t, token1, token2)
+}
+
+func TestField(t *testing.T) {
+	assert.Equal(t, `<input type="hidden" name="csrf_token" value="token">`, string(csrf.Field("token")))
+	assert.Equal(t, `<input type="hidden" name="csrf_token" value="&#34;&gt;">`, string(csrf.Field(`">`)))
+}
+
+func TestSafeMethod(t *testing.T) {
+	assert.True(t, csrf.SafeMethod("GET"))
+	assert.True(t, csrf.SafeMethod("HEAD"))
+	assert.False(t, csrf.SafeMethod("POST"))
+	assert.False(t, csrf.SafeMethod("PATCH"))
+}
+
+func TestValidFormField(t *testing.T) {
+	assert.True(t, csrf.Valid("token", formRequest("csrf_token=token")))
+	assert.False(t, csrf.Valid("token", formRequest("csrf_token=forged")))
+	assert.False(t, csrf.Valid("token", formRequest("")))
+}
+
+func TestValidHeader(t *testing.T) {
+	r := formRequest("")
+	r.Header.Set("X-CSRF-Token", "token")
+
+	assert.True(t, csrf.Valid("token", r))
+}
+
+func TestValidEmptyExpectedToken(t *testing.T) {
+	assert.False(t, csrf.Valid("", formRequest("csrf_token=")))
+}
+
+func TestCookieNew(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/login", nil)
+	require.NoError(t, err)
+
+	token, err := csrf.Cookie(w, r, true)
+	require.NoError(t, err)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "csrf_token", cookies[0].Name)
+	assert.Equal(t, token, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+}
+
+func TestCookieExisting(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/login", nil)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+
+	token, err := csrf.Cookie(w, r, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "token", token)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestValidCookie(t *testing.T) {
+	r := formRequest("csrf_token=token")
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+	assert.True(t, csrf.ValidCookie(r))
+
+	r = formRequest("csrf_token=token")
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "other"})
+	assert.False(t, csrf.ValidCookie(r))
+
+	// request without cookie
+	assert.False(t, csrf.ValidCookie(formRequest("csrf_token=token")))
+}
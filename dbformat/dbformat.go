@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
 )
 
 const (
@@ -13,9 +15,40 @@ const (
 )
 
 const (
-	getUserTimezone = "SELECT timezone FROM users WHERE username = ?;"
+	getUserPreferences = "SELECT timezone, dateFormat FROM users WHERE username = ?;"
 )
 
+// DefaultDateFormat is name of date format used when user didn't choose another one
+const DefaultDateFormat = "iso"
+
+// DateFormat is format of dates which user can choose on settings page
+type DateFormat struct {
+	Name   string // Name is stored in users table
+	Layout string
+	Label  string
+}
+
+// DateFormats contains date formats which user can choose
+var DateFormats = []DateFormat{
+	{Name: "iso", Layout: "2006-01-02 15:04:05", Label: "YYYY-MM-DD hh:mm:ss"},
+	{Name: "eu", Layout: "02.01.2006 15:04:05", Label: "DD.MM.YYYY hh:mm:ss"},
+	{Name: "us", Layout: "01/02/2006 03:04:05 PM", Label: "MM/DD/YYYY hh:mm:ss AM/PM"},
+}
+
+// DateLayout returns layout of date format with inputted name.
+// If there is no such format layout of DefaultDateFormat is returned.
+func DateLayout(name string) string {
+	for _, f := range DateFormats {
+		if f.Name == name {
+			return f.Layout
+		}
+	}
+	return DateFormats[0].Layout
+}
+
+// FileInfoColumns contains list of files table columns in order expected by FormatedDownloadFileInfo() and FormatedFilesInfo()
+const FileInfoColumns = "id, label, filesizeBytes, description, owner, category, uploadDate, rating"
+
 // FileInfo contains formatted file info from MySQL database
 type FileInfo struct {
 	Label        string
@@ -44,21 +77,35 @@ type DownloadFileInfo struct {
 	Rating       int
 }
 
-func userLocalTime(db *sql.DB, globalTime time.Time, username string) (time.Time, error) {
+// UserPreferences returns location of timezone and layout of date format selected by user
+func UserPreferences(db *sql.DB, username string) (*time.Location, string, error) {
 	userTimezone := ""
-	err := db.QueryRow(getUserTimezone, username).Scan(&userTimezone)
+	dateFormat := ""
+	err := db.QueryRow(getUserPreferences, username).Scan(&userTimezone, &dateFormat)
 	if err != nil {
-		return time.Time{}, err
+		return nil, "", err
 	}
 
 	location, err := time.LoadLocation(userTimezone)
 	if err != nil {
-		return time.Time{}, err
-
+		return nil, "", err
 	}
+	return location, DateLayout(dateFormat), nil
+}
 
-	globalTime = globalTime.In(location)
-	return globalTime, nil
+// UserLocation returns location of timezone selected by user
+func UserLocation(db *sql.DB, username string) (*time.Location, error) {
+	location, _, err := UserPreferences(db, username)
+	return location, err
+}
+
+// UserTime returns time formatted in timezone and date format selected by user
+func UserTime(db *sql.DB, globalTime time.Time, username string) (string, error) {
+	location, layout, err := UserPreferences(db, username)
+	if err != nil {
+		return "", err
+	}
+	return globalTime.In(location).Format(layout), nil
 }
 
 // FormatedDownloadFileInfo returns fromatted download file info
@@ -80,12 +127,11 @@ func FormatedDownloadFileInfo(username string, db *sql.DB, query, argument strin
 	if err != nil {
 		return DownloadFileInfo{}, err
 	}
-	fi.DownloadLink = "/files/" + strconv.Itoa(id)
-	userTime, err := userLocalTime(db, uploadDateTime, username)
+	fi.DownloadLink = "/file?id=" + strconv.Itoa(id)
+	fi.UploadDate, err = UserTime(db, uploadDateTime, username)
 	if err != nil {
 		return DownloadFileInfo{}, err
 	}
-	fi.UploadDate = userTime.Format("2006-01-02 15:04:05")
 	fi.FilesizeMB = fmt.Sprintf("%.6f", float64(filesizeBytes)/1024/1024) + " MB"
 
 	return fi, nil
@@ -118,11 +164,10 @@ func FormatedFilesInfo(username string, db *sql.DB, query string, args ...interf
 		if err != nil {
 			return []FileInfo{}, err
 		}
-		userTime, err := userLocalTime(db, uploadDateTime, username)
+		fiTable.UploadDate, err = UserTime(db, uploadDateTime, username)
 		if err != nil {
 			return []FileInfo{}, err
 		}
-		fiTable.UploadDate = userTime.Format("2006-01-02 15:04:05")
 
 		if len(fiTable.LabelComment) > filenameLen {
 			fiTable.Label = fiTable.LabelComment[:filenameLen] + "..."
@@ -148,3 +193,56 @@ func FormatedFilesInfo(username string, db *sql.DB, query string, args ...interf
 	}
 	return fiTableCollection, nil
 }
+
+// File contains not formatted file info from MySQL database, it's the same type as file info of JSON API
+type File = types.FileInfo
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanFile reads file info in order of FileInfoColumns
+func scanFile(s scanner) (File, error) {
+	f := File{}
+	err := s.Scan(
+		&f.ID,
+		&f.Label,
+		&f.FilesizeBytes,
+		&f.Description,
+		&f.Owner,
+		&f.Category,
+		&f.UploadDate,
+		&f.Rating,
+	)
+	if err != nil {
+		return File{}, err
+	}
+	f.UploadDate = f.UploadDate.UTC()
+	return f, nil
+}
+
+// OneFile returns file info selected by query with FileInfoColumns.
+// If file doesn't exist sql.ErrNoRows will be returned.
+func OneFile(db *sql.DB, query string, args ...interface{}) (File, error) {
+	return scanFile(db.QueryRow(query, args...))
+}
+
+// Files returns list of file info selected by query with FileInfoColumns
+func Files(db *sql.DB, query string, args ...interface{}) ([]File, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []File{}
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
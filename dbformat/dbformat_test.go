@@ -1,6 +1,7 @@
 package dbformat
 
 import (
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
@@ -10,32 +11,48 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestUserLocalTimeSuccess(t *testing.T) {
+func TestUserTimeSuccess(t *testing.T) {
 	db, sqlMock, err := sqlmock.New()
 	require.NoError(t, err)
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
-	tm, err := userLocalTime(db, time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC), "example")
+	tm, err := UserTime(db, time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC), "example")
 	require.NoError(t, err)
 
-	location, err := time.LoadLocation("Europe/Moscow")
+	assert.Equal(t, "2009-11-17 23:34:58", tm)
+}
+
+func TestUserTimeDateFormats(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
 	require.NoError(t, err)
 
-	assert.Equal(t, time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC).In(location), tm)
+	expected := map[string]string{
+		"iso":     "2009-11-17 23:34:58",
+		"eu":      "17.11.2009 23:34:58",
+		"us":      "11/17/2009 11:34:58 PM",
+		"unknown": "2009-11-17 23:34:58",
+	}
+	for dateFormat, formatted := range expected {
+		sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", dateFormat))
+
+		tm, err := UserTime(db, time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC), "example")
+		require.NoError(t, err)
+		assert.Equal(t, formatted, tm, "date format %q", dateFormat)
+	}
 }
 
-func TestUserLocalUserLocationError(t *testing.T) {
+func TestUserTimeUserLocationError(t *testing.T) {
 	db, sqlMock, err := sqlmock.New()
 	require.NoError(t, err)
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Unknown/location"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Unknown/location", "iso"))
 
-	tm, err := userLocalTime(db, time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC), "example")
+	tm, err := UserTime(db, time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC), "example")
 
 	if err != nil {
 	} else {
 		t.Errorf("Unknown timezone not recognized as error")
 	}
-	assert.Equal(t, time.Time{}, tm)
+	assert.Equal(t, "", tm)
 }
 
 func TestDownloadFileInfoSuccess(t *testing.T) {
@@ -63,13 +80,13 @@ func TestDownloadFileInfoSuccess(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	fileInfo, err := FormatedDownloadFileInfo("username", db, "SELECT * FROM files WHERE id = ?;", "1")
 	require.NoError(t, err)
 
 	assert.Equal(t, DownloadFileInfo{
-		DownloadLink: "/files/1",
+		DownloadLink: "/file?id=1",
 		Label:        "label",
 		FilesizeMB:   "0.000977 MB",
 		Description:  "description",
@@ -125,7 +142,7 @@ func TestDownloadFileInfoUserLocalTimeError(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing Error"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing Error"))
 
 	fileInfo, err := FormatedDownloadFileInfo("username", db, "SELECT * FROM files WHERE id = ?;", "1")
 
@@ -167,7 +184,7 @@ func TestFormatedFilesInfoSuccess(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	fileInfo, err := FormatedFilesInfo("username", db, "SELECT * FROM files WHERE id = ?;", "1")
 	require.NoError(t, err)
@@ -212,7 +229,7 @@ func TestFormatedFilesInfoSuccessLongFilename(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	fileInfo, err := FormatedFilesInfo("username", db, "SELECT * FROM files WHERE id = ?;", "1")
 	require.NoError(t, err)
@@ -257,7 +274,7 @@ func TestFormatedFilesInfoSuccessLongDescription(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	fileInfo, err := FormatedFilesInfo("username", db, "SELECT * FROM files WHERE id = ?;", "1")
 	require.NoError(t, err)
@@ -349,10 +366,38 @@ func TestFileInfoUserLocalTimeError(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing Error"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing Error"))
 
 	fileInfo, err := FormatedFilesInfo("username", db, "SELECT * FROM files WHERE id = ?;", "1")
 
 	assert.Equal(t, []FileInfo{}, fileInfo)
 	assert.Equal(t, fmt.Errorf("testing Error"), err)
 }
+
+func TestFilesSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT \\* FROM files").WillReturnRows(sqlmock.NewRows([]string{"id", "label", "filesizeBytes", "description", "owner", "category", "uploadDate", "rating"}).
+		AddRow(1, "label", 1024, "description", "owner", "other", time.Date(2009, 11, 17, 23, 34, 58, 0, moscow), 10).
+		AddRow(2, "label2", 2048, "", "owner", "music", time.Date(2009, 11, 18, 20, 34, 58, 0, time.UTC), -1))
+
+	files, err := Files(db, "SELECT * FROM files;")
+	require.NoError(t, err)
+
+	assert.Equal(t, []File{
+		{ID: 1, Label: "label", FilesizeBytes: 1024, Description: "description", Owner: "owner", Category: "other", UploadDate: time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC), Rating: 10},
+		{ID: 2, Label: "label2", FilesizeBytes: 2048, Owner: "owner", Category: "music", UploadDate: time.Date(2009, 11, 18, 20, 34, 58, 0, time.UTC), Rating: -1},
+	}, files)
+}
+
+func TestOneFileNotExist(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE id =").WithArgs("1").WillReturnError(sql.ErrNoRows)
+
+	_, err = OneFile(db, "SELECT * FROM files WHERE id = ?;", "1")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
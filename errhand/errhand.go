@@ -1,22 +1,44 @@
 package errhand
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"runtime"
 	"strconv"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
 )
 
 // InternalError writes error in log and page.
 func InternalError(err error, w http.ResponseWriter) {
+	logError(err)
+
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintln(w, "INTERNAL ERROR. Please try later")
+}
+
+// JSONInternalError writes error in log and writes internal error in JSON API format.
+func JSONInternalError(err error, w http.ResponseWriter) {
+	logError(err)
+
+	JSONError(w, http.StatusInternalServerError, "Internal error. Please try later")
+}
+
+// JSONError writes error with inputted HTTP status and message in JSON API format.
+func JSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.ErrorBody{Error: types.ErrorInfo{Status: status, Message: message}})
+}
+
+// logError writes error in log together with function and line where error was handled
+func logError(err error) {
 	pc := make([]uintptr, 15)
-	n := runtime.Callers(2, pc)
+	n := runtime.Callers(3, pc)
 	frames := runtime.CallersFrames(pc[:n])
 	frame, _ := frames.Next()
 
 	log.Println(frame.Function+"():"+strconv.Itoa(frame.Line), "INTERNAL ERROR:", err)
-
-	w.WriteHeader(http.StatusInternalServerError)
-	fmt.Fprintln(w, "INTERNAL ERROR. Please try later")
 }
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/vpoletaev11/fileHostingSite/test"
 )
 
 func TestErrhand(t *testing.T) {
@@ -38,8 +37,31 @@ func TestErrhand(t *testing.T) {
 	InternalError(fmt.Errorf("testing error"), w)
 	writer.Close()
 
-	assert.Equal(t, time.Now().Format("2006/01/02 15:04:05")+" github.com/vpoletaev11/fileHostingSite/errhand.TestErrhand():38 INTERNAL ERROR: testing error\n", <-out)
+	assert.Equal(t, time.Now().Format("2006/01/02 15:04:05")+" github.com/vpoletaev11/fileHostingSite/errhand.TestErrhand():37 INTERNAL ERROR: testing error\n", <-out)
 
-	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	assert.Equal(t, "INTERNAL ERROR. Please try later\n", w.Body.String())
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestJSONError(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	JSONError(w, http.StatusNotFound, "File not found")
+
+	assert.Equal(t, `{"error":{"status":404,"message":"File not found"}}`+"\n", w.Body.String())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+}
+
+func TestJSONInternalError(t *testing.T) {
+	w := httptest.NewRecorder()
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	JSONInternalError(fmt.Errorf("testing error"), w)
+
+	assert.Contains(t, buf.String(), " github.com/vpoletaev11/fileHostingSite/errhand.TestJSONInternalError():62 INTERNAL ERROR: testing error\n")
+	assert.Equal(t, `{"error":{"status":500,"message":"Internal error. Please try later"}}`+"\n", w.Body.String())
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
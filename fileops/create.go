@@ -0,0 +1,127 @@
+package fileops
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"log"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/blobstore"
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tags"
+)
+
+const insertFile = "INSERT INTO files (label, filesizeBytes, description, owner, category, uploadDate, mimeType, originalName, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);"
+
+// ErrTooLarge returns by Validate and Create when size of file is larger than MaxFilesize
+var ErrTooLarge = types.ErrTooLarge
+
+// Meta contains information about created file. Label, description and category should be checked by caller.
+type Meta struct {
+	Label        string
+	Description  string
+	Category     string
+	OriginalName string // name of uploaded file, its extension is used if MIME type isn't recognized by content
+	Tags         []string
+	// Finish is called inside of transaction which creates file, it can be nil.
+	// E.g. resumable upload is deleted in the same transaction, so file isn't created twice if request is retried.
+	Finish func(tx *sql.Tx) error
+}
+
+// Create stores data of file from reader to blob store, sends information about file and its tags to MySQL database
+// in one transaction and adds file to search index. Owner of file is user of dep.
+// Data larger than MaxFilesize isn't stored, in this case ErrTooLarge is returned.
+// It returns information about created file.
+func Create(dep session.Dependency, meta Meta, r io.Reader) (dbformat.File, error) {
+	// first bytes of file are used for MIME type detection and then returned to the beginning of data
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return dbformat.File{}, err
+	}
+	mimeType := mimeTypeOf(buf[:n], meta.OriginalName)
+
+	// file content hash will be used as ETag on download
+	blobs := blobstore.New(dep.Db, dep.Storage)
+	hash, size, err := blobs.Put(&limitReader{Reader: io.MultiReader(bytes.NewReader(buf[:n]), r), left: MaxFilesize})
+	if err != nil {
+		return dbformat.File{}, err
+	}
+
+	f := dbformat.File{
+		Label:         meta.Label,
+		FilesizeBytes: size,
+		Description:   meta.Description,
+		Owner:         dep.Username,
+		Category:      meta.Category,
+		UploadDate:    dep.Now().UTC().Truncate(time.Second),
+	}
+	id, err := insertFileInfo(dep.Db, f, meta, mimeType, hash)
+	if err != nil {
+		// blob reference was created for this row, so it should be released.
+		// File isn't created, so only hash identifies leaked data if it cannot be released
+		releaseErr := blobs.Release(hash)
+		if releaseErr != nil {
+			log.Println("fileops: releasing data", hash, "of not created file", f.Label, "error:", releaseErr)
+		}
+		return dbformat.File{}, err
+	}
+	f.ID = int(id)
+
+	// uploaded file becomes searchable immediately
+	dep.Search.Add(searchindex.Document{ID: f.ID, Label: f.Label, Description: f.Description, Owner: f.Owner})
+	return f, nil
+}
+
+// insertFileInfo sends information about file and its tags to MySQL database in one transaction and returns ID of file
+func insertFileInfo(db *sql.DB, f dbformat.File, meta Meta, mimeType, hash string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(insertFile, f.Label, f.FilesizeBytes, f.Description, f.Owner, f.Category, f.UploadDate.Format("2006-01-02 15:04:05"), mimeType, meta.OriginalName, hash)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if len(meta.Tags) > 0 {
+		err = tags.SetTx(tx, id, meta.Tags)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if meta.Finish != nil {
+		err = meta.Finish(tx)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// limitReader returns ErrTooLarge when more than left bytes are read.
+// Size of uploaded data cannot be trusted before data is read, so it's checked while data is copied.
+type limitReader struct {
+	io.Reader
+	left int64
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	n, err := lr.Reader.Read(p)
+	lr.left -= int64(n)
+	if lr.left < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
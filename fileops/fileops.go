@@ -0,0 +1,233 @@
+package fileops
+
+import (
+	"database/sql"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/blobstore"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+const (
+	selectFileForDelete = "SELECT owner, rating, hash FROM files WHERE id = ? FOR UPDATE;"
+
+	deleteFileRatings = "DELETE FROM filesRating WHERE fileID = ?;"
+
+	deleteFileTags = "DELETE FROM file_tags WHERE fileID = ?;"
+
+	decreaseOwnerRating = "UPDATE users SET rating = rating - ? WHERE username = ?;"
+
+	deleteFileInfo = "DELETE FROM files WHERE id = ?;"
+
+	selectOwnerRating = "SELECT COALESCE(SUM(rating), 0) FROM files WHERE owner = ? FOR UPDATE;"
+
+	changeOwner = "UPDATE files SET owner = ? WHERE owner = ?;"
+
+	increaseOwnerRating = "UPDATE users SET rating = rating + ? WHERE username = ?;"
+
+	selectVotes = "SELECT filesRating.fileID, filesRating.rating, files.owner FROM filesRating JOIN files ON files.id = filesRating.fileID WHERE filesRating.voter = ? FOR UPDATE;"
+
+	decreaseFileRating = "UPDATE files SET rating = rating - ? WHERE id = ?;"
+
+	deleteVotes = "DELETE FROM filesRating WHERE voter = ?;"
+)
+
+// limits of file info are shared with command-line client
+const (
+	MaxFilenameLen    = types.MaxLabelLen       // maximal length of file label
+	MaxDescriptionLen = types.MaxDescriptionLen // maximal length of file description
+	MaxFilesize       = types.MaxFilesize       // maximal size of uploaded file in bytes
+)
+
+// Validate checks file info inputted by user.
+// Category of file is stored in database, so it should be checked by categories package.
+func Validate(filesize int64, filename, description string) error {
+	return types.ValidateFile(filesize, filename, description)
+}
+
+// mimeTypeOf returns MIME type of uploaded file by its first bytes.
+// MIME type detects by file content, but if content can't be recognized will be used original filename extension
+func mimeTypeOf(head []byte, filename string) string {
+	mimeType := http.DetectContentType(head)
+	if mimeType != "application/octet-stream" && !strings.HasPrefix(mimeType, "text/plain") {
+		return mimeType
+	}
+
+	byExtension := mime.TypeByExtension(filepath.Ext(filename))
+	if byExtension != "" {
+		return byExtension
+	}
+	return mimeType
+}
+
+// Delete removes file info, file ratings and stored file data.
+// Rating which file brought to its owner will be subtracted from owner rating.
+// If file doesn't exist sql.ErrNoRows will be returned.
+func Delete(db *sql.DB, backend storage.Backend, id string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	hash, err := DeleteTx(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return ReleaseData(db, backend, id, hash)
+}
+
+// DeleteTx removes file info and file ratings inside of transaction and returns hash of file data.
+// Stored data isn't transactional, so it should be released by ReleaseData after commit.
+// Transaction isn't rolled back on error.
+func DeleteTx(tx *sql.Tx, id string) (string, error) {
+	owner := ""
+	rating := 0
+	hash := ""
+	err := tx.QueryRow(selectFileForDelete, id).Scan(&owner, &rating, &hash)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(deleteFileRatings, id)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(deleteFileTags, id)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(decreaseOwnerRating, rating, owner)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(deleteFileInfo, id)
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// ReleaseData removes stored data of deleted file with inputted id and hash
+func ReleaseData(db *sql.DB, backend storage.Backend, id, hash string) error {
+	// files uploaded before blob store was added are stored by id
+	if hash == "" {
+		err := backend.Delete(id)
+		if err != nil && err != storage.ErrNotExist {
+			return err
+		}
+		return nil
+	}
+	return blobstore.New(db, backend).Release(hash)
+}
+
+// Reassign makes user "to" owner of all files of user "from".
+// Rating which files brought to previous owner will be added to rating of new owner.
+func Reassign(db *sql.DB, from, to string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = ReassignTx(tx, from, to)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReassignTx makes user "to" owner of all files of user "from" inside of transaction.
+// Transaction isn't rolled back on error.
+func ReassignTx(tx *sql.Tx, from, to string) error {
+	rating := 0
+	err := tx.QueryRow(selectOwnerRating, from).Scan(&rating)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(changeOwner, to, from)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(decreaseOwnerRating, rating, from)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(increaseOwnerRating, rating, to)
+	return err
+}
+
+// vote contains rating which user set to file
+type vote struct {
+	fileID int
+	rating int
+	owner  string
+}
+
+// RemoveVotes removes all ratings which user set to files.
+// Ratings of voted files and ratings of their owners are recalculated without votes of user.
+func RemoveVotes(db *sql.DB, voter string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = RemoveVotesTx(tx, voter)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveVotesTx removes all ratings which user set to files inside of transaction.
+// Transaction isn't rolled back on error.
+func RemoveVotesTx(tx *sql.Tx, voter string) error {
+	rows, err := tx.Query(selectVotes, voter)
+	if err != nil {
+		return err
+	}
+	// all votes should be read before updates, transaction cannot run queries while rows are open
+	votes := []vote{}
+	for rows.Next() {
+		v := vote{}
+		err = rows.Scan(&v.fileID, &v.rating, &v.owner)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		votes = append(votes, v)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range votes {
+		_, err = tx.Exec(decreaseFileRating, v.rating, v.fileID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(decreaseOwnerRating, v.rating, v.owner)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(deleteVotes, voter)
+	return err
+}
@@ -0,0 +1,231 @@
+package fileops
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+// sha256 hash of "binary data"
+const testHash = "9cb63cb779e8c571db3199b783a36cc43cd9e7c076beeb496c39e9cc06196dc5"
+
+// newTestBackend returns local storage backend in temporary directory
+func newTestBackend(t *testing.T) (*sql.DB, sqlmock.Sqlmock, storage.Backend, string, func()) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "fileops-test-")
+	require.NoError(t, err)
+
+	return db, sqlMock, storage.NewLocal(dir), dir, func() { os.RemoveAll(dir) }
+}
+
+func fileRows(hash string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"owner", "rating", "hash"}).AddRow("owner", 15, hash)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(1000, "label", "description"))
+	assert.EqualError(t, Validate(MaxFilesize+1, "label", "description"), "Filesize cannot be more than 1GB")
+	assert.EqualError(t, Validate(1000, strings.Repeat("a", MaxFilenameLen+1), "description"), "Filename are too long")
+	assert.EqualError(t, Validate(1000, "label", strings.Repeat("a", MaxDescriptionLen+1)), "Description are too long")
+}
+
+func TestDeleteSuccess(t *testing.T) {
+	db, sqlMock, backend, dir, cleanup := newTestBackend(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(dir+"/"+testHash, []byte("binary data"), 0644))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(fileRows(testHash))
+	sqlMock.ExpectExec("DELETE FROM filesRating WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating - (.+) WHERE username").WithArgs(15, "owner").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	// releasing of blob
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs").WithArgs(testHash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(testHash).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, Delete(db, backend, "1"))
+
+	_, err := os.Stat(dir + "/" + testHash)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDeleteLegacyFileSuccess(t *testing.T) {
+	db, sqlMock, backend, dir, cleanup := newTestBackend(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(dir+"/1", []byte("binary data"), 0644))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(fileRows(""))
+	sqlMock.ExpectExec("DELETE FROM filesRating").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(15, "owner").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, Delete(db, backend, "1"))
+
+	_, err := os.Stat(dir + "/1")
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDeleteNotExistingFile(t *testing.T) {
+	db, sqlMock, backend, _, cleanup := newTestBackend(t)
+	defer cleanup()
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnError(sql.ErrNoRows)
+	sqlMock.ExpectRollback()
+
+	assert.Equal(t, sql.ErrNoRows, Delete(db, backend, "1"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDeleteDBError(t *testing.T) {
+	db, sqlMock, backend, dir, cleanup := newTestBackend(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(dir+"/"+testHash, []byte("binary data"), 0644))
+
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(fileRows(testHash))
+	sqlMock.ExpectExec("DELETE FROM filesRating").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(15, "owner").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	assert.EqualError(t, Delete(db, backend, "1"), "testing error")
+
+	// file data should stay untouched
+	_, err := os.Stat(dir + "/" + testHash)
+	assert.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestReassignSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT COALESCE(.+) FROM files WHERE owner = (.+) FOR UPDATE").WithArgs("from").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(25))
+	sqlMock.ExpectExec("UPDATE files SET owner").WithArgs("to", "from").WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating - (.+) WHERE username").WithArgs(25, "from").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating \\+ (.+) WHERE username").WithArgs(25, "to").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, Reassign(db, "from", "to"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestReassignDBError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT COALESCE(.+) FROM files WHERE owner").WithArgs("from").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(25))
+	sqlMock.ExpectExec("UPDATE files SET owner").WithArgs("to", "from").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	assert.EqualError(t, Reassign(db, "from", "to"), "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRemoveVotesSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT filesRating.fileID, filesRating.rating, files.owner FROM filesRating").WithArgs("voter").WillReturnRows(
+		sqlmock.NewRows([]string{"fileID", "rating", "owner"}).AddRow(1, 10, "owner1").AddRow(2, -5, "owner2"))
+	sqlMock.ExpectExec("UPDATE files SET rating = rating - (.+) WHERE id").WithArgs(10, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating - (.+) WHERE username").WithArgs(10, "owner1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("UPDATE files SET rating = rating - (.+) WHERE id").WithArgs(-5, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating - (.+) WHERE username").WithArgs(-5, "owner2").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM filesRating WHERE voter").WithArgs("voter").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, RemoveVotes(db, "voter"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRemoveVotesDBError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT filesRating.fileID").WithArgs("voter").WillReturnRows(
+		sqlmock.NewRows([]string{"fileID", "rating", "owner"}).AddRow(1, 10, "owner1"))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(10, 1).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	assert.EqualError(t, RemoveVotes(db, "voter"), "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestMimeTypeOf(t *testing.T) {
+	for content, expected := range map[string]string{
+		"%PDF-1.4":    "application/pdf",
+		"binary data": "application/json",
+	} {
+		assert.Equal(t, expected, mimeTypeOf([]byte(content), "file.json"))
+	}
+
+	// unknown extension
+	assert.Equal(t, "text/plain; charset=utf-8", mimeTypeOf([]byte("binary data"), "file"))
+}
+
+func TestCreateSuccess(t *testing.T) {
+	db, sqlMock, backend, dir, cleanup := newTestBackend(t)
+	defer cleanup()
+	dep := session.Dependency{Db: db, Storage: backend, Search: searchindex.New(), Username: "owner",
+		Clock: func() time.Time { return time.Date(2020, 5, 10, 12, 0, 0, 0, time.UTC) }}
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(testHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO files").WithArgs("label", 11, "description", "owner", "music", "2020-05-10 12:00:00", "text/plain; charset=utf-8", "file.txt", testHash).WillReturnResult(sqlmock.NewResult(7, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("jazz").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs(int64(7), "jazz").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("DELETE FROM partialUploads").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	meta := Meta{Label: "label", Description: "description", Category: "music", OriginalName: "file.txt", Tags: []string{"jazz"},
+		Finish: func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM partialUploads WHERE id = ?;", "id")
+			return err
+		}}
+	f, err := Create(dep, meta, strings.NewReader("binary data"))
+	require.NoError(t, err)
+	assert.Equal(t, 7, f.ID)
+	assert.Equal(t, int64(11), f.FilesizeBytes)
+	assert.Equal(t, "owner", f.Owner)
+	assert.Equal(t, 1, dep.Search.Len())
+	_, err = os.Stat(dir + "/" + testHash)
+	assert.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateTooLarge(t *testing.T) {
+	lr := &limitReader{Reader: strings.NewReader("binary data"), left: 5}
+	_, err := ioutil.ReadAll(lr)
+	assert.Equal(t, ErrTooLarge, err)
+
+	lr = &limitReader{Reader: strings.NewReader("binary data"), left: 11}
+	data, err := ioutil.ReadAll(lr)
+	require.NoError(t, err)
+	assert.Equal(t, "binary data", string(data))
+}
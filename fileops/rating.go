@@ -0,0 +1,81 @@
+package fileops
+
+import (
+	"database/sql"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+const (
+	selectOwnerForVote = "SELECT owner FROM files WHERE id = ? FOR UPDATE;"
+
+	selectVote = "SELECT rating FROM filesRating WHERE fileID = ? AND voter = ? FOR UPDATE;"
+
+	createFileRating = "INSERT INTO filesRating (fileID, voter, rating) VALUES (?, ?, ?);"
+
+	updateGlobalFileRating = "UPDATE files SET rating= rating - ? + ?  WHERE id=?;"
+
+	updateFileRating = "UPDATE filesRating SET rating=? WHERE fileID=? AND voter=?;"
+
+	updateUserRating = "UPDATE users SET rating= rating -?  + ?  WHERE username= ?;"
+)
+
+const (
+	MaxRating = types.MaxRating // maximal rating that user can set
+	MinRating = types.MinRating // minimal rating that user can set
+)
+
+// Rate sets rating which user gives to file.
+// If user already rated file, previous rating will be replaced.
+// Rating of file owner changes together with rating of file in one transaction.
+// If file doesn't exist sql.ErrNoRows will be returned.
+func Rate(db *sql.DB, id, username string, rating int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = RateTx(tx, id, username, rating)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RateTx sets rating which user gives to file inside of transaction.
+// Row of file is locked before previous vote is read, so concurrent votes for the same file are applied one by one.
+// Transaction isn't rolled back on error.
+func RateTx(tx *sql.Tx, id, username string, rating int) error {
+	owner := ""
+	err := tx.QueryRow(selectOwnerForVote, id).Scan(&owner)
+	if err != nil {
+		return err
+	}
+
+	// user who didn't vote yet has previous rating 0
+	oldRating := 0
+	err = tx.QueryRow(selectVote, id, username).Scan(&oldRating)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(createFileRating, id, username, rating)
+	case err != nil:
+		return err
+	case oldRating == rating:
+		return nil
+	default:
+		_, err = tx.Exec(updateFileRating, rating, id, username)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(updateGlobalFileRating, oldRating, rating, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(updateUserRating, oldRating, rating, owner)
+	return err
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// File is Sender which writes messages to .eml files in local directory instead of delivering them.
+// It's used in development and tests, when SMTP server isn't available.
+type File struct {
+	dir  string
+	from string
+	// now returns current time, it replaced in tests
+	now func() time.Time
+}
+
+// NewFile returns Sender which writes messages to inputted directory
+func NewFile(dir, from string) *File {
+	return &File{dir: dir, from: from, now: time.Now}
+}
+
+// Send writes message to new file in directory of File
+func (f *File) Send(msg Message) error {
+	data, err := format(f.from, msg, f.now())
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(f.dir, 0755)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(f.dir, "mail-*.eml")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	return file.Close()
+}
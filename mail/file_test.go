@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTime is time of messages in tests
+var testTime = time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// testMessage is formatted message which sent from "site@example.com" at testTime
+const testMessage = "From: site@example.com\r\n" +
+	"To: user@example.com\r\n" +
+	"Subject: Password reset\r\n" +
+	"Date: Fri, 01 May 2020 12:00:00 +0000\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: 8bit\r\n" +
+	"\r\n" +
+	"line 1\r\nline 2\r\n"
+
+func newTestFile(t *testing.T) (*File, string, func()) {
+	dir, err := ioutil.TempDir("", "mail-test-")
+	require.NoError(t, err)
+	f := NewFile(filepath.Join(dir, "outbox"), "site@example.com")
+	f.now = func() time.Time { return testTime }
+	return f, filepath.Join(dir, "outbox"), func() { os.RemoveAll(dir) }
+}
+
+func TestFileSendSuccess(t *testing.T) {
+	f, dir, cleanup := newTestFile(t)
+	defer cleanup()
+
+	err := f.Send(Message{To: "user@example.com", Subject: "Password reset", Body: "line 1\nline 2\n"})
+	require.NoError(t, err)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, testMessage, string(data))
+}
+
+func TestFileSendEveryMessageToNewFile(t *testing.T) {
+	f, dir, cleanup := newTestFile(t)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		err := f.Send(Message{To: "user@example.com", Subject: "Password reset", Body: "body"})
+		require.NoError(t, err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+}
+
+func TestFileSendHeaderInjection(t *testing.T) {
+	f, dir, cleanup := newTestFile(t)
+	defer cleanup()
+
+	err := f.Send(Message{To: "user@example.com\r\nBcc: another@example.com", Subject: "Password reset", Body: "body"})
+	assert.EqualError(t, err, "mail: line break in message header")
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFormatEncodesSubject(t *testing.T) {
+	data, err := format("site@example.com", Message{To: "user@example.com", Subject: "Сброс пароля", Body: "body"}, testTime)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: =?utf-8?q?")
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails to users
+type Sender interface {
+	// Send delivers message to its recipient
+	Send(msg Message) error
+}
+
+// format returns message with headers in RFC 5322 format
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	// line breaks in headers allow to add another headers to message
+	if strings.ContainsAny(from+msg.To+msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("mail: line break in message header")
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	// SMTP requires CRLF line endings in message body
+	buf.WriteString(strings.Replace(strings.Replace(msg.Body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	return buf.Bytes(), nil
+}
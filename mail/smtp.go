@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig contains settings of SMTP server
+type SMTPConfig struct {
+	Addr     string // e.g. smtp.example.com:587
+	From     string // address of sender
+	Username string // if Username is empty messages are sent without authentication
+	Password string
+}
+
+// SMTP is Sender which delivers messages through SMTP server
+type SMTP struct {
+	cfg SMTPConfig
+	// now returns current time, it replaced in tests
+	now func() time.Time
+}
+
+// NewSMTP returns Sender which delivers messages through SMTP server
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	return &SMTP{cfg: cfg, now: time.Now}
+}
+
+// Send delivers message through SMTP server.
+// STARTTLS is used if server supports it.
+func (s *SMTP) Send(msg Message) error {
+	data, err := format(s.cfg.From, msg, s.now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(s.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, host)
+	}
+	return smtp.SendMail(s.cfg.Addr, auth, s.cfg.From, []string{msg.To}, data)
+}
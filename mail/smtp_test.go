@@ -0,0 +1,84 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer accepts one SMTP session and sends commands and message data received from client to channel
+func fakeSMTPServer(t *testing.T) (string, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	received := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		lines := []string{}
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250 localhost\r\n"))
+			case line == "DATA":
+				conn.Write([]byte("354 go ahead\r\n"))
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(data, "\r\n"))
+				}
+				conn.Write([]byte("250 ok\r\n"))
+			case line == "QUIT":
+				conn.Write([]byte("221 bye\r\n"))
+				received <- lines
+				return
+			default:
+				conn.Write([]byte("250 ok\r\n"))
+			}
+		}
+		received <- lines
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestSMTPSendSuccess(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+
+	s := NewSMTP(SMTPConfig{Addr: addr, From: "site@example.com"})
+	s.now = func() time.Time { return testTime }
+	err := s.Send(Message{To: "user@example.com", Subject: "Password reset", Body: "line 1\nline 2\n"})
+	require.NoError(t, err)
+
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<site@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<user@example.com>")
+	assert.Contains(t, strings.Join(lines, "\r\n")+"\r\n", testMessage)
+}
+
+func TestSMTPSendHeaderInjection(t *testing.T) {
+	s := NewSMTP(SMTPConfig{Addr: "127.0.0.1:1", From: "site@example.com"})
+	err := s.Send(Message{To: "user@example.com", Subject: "Password reset\nBcc: another@example.com", Body: "body"})
+	assert.EqualError(t, err, "mail: line break in message header")
+}
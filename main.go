@@ -4,24 +4,44 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/mail"
+	"github.com/vpoletaev11/fileHostingSite/migrations"
+	"github.com/vpoletaev11/fileHostingSite/pages/admin"
+	"github.com/vpoletaev11/fileHostingSite/pages/api"
 	"github.com/vpoletaev11/fileHostingSite/pages/categories"
 	"github.com/vpoletaev11/fileHostingSite/pages/download"
+	"github.com/vpoletaev11/fileHostingSite/pages/edit"
+	"github.com/vpoletaev11/fileHostingSite/pages/file"
 	"github.com/vpoletaev11/fileHostingSite/pages/index"
 	"github.com/vpoletaev11/fileHostingSite/pages/login"
 	"github.com/vpoletaev11/fileHostingSite/pages/logout"
 	"github.com/vpoletaev11/fileHostingSite/pages/popular"
 	"github.com/vpoletaev11/fileHostingSite/pages/registration"
+	"github.com/vpoletaev11/fileHostingSite/pages/search"
+	"github.com/vpoletaev11/fileHostingSite/pages/sessions"
+	"github.com/vpoletaev11/fileHostingSite/pages/tags"
+	"github.com/vpoletaev11/fileHostingSite/pages/tokens"
+	"github.com/vpoletaev11/fileHostingSite/pages/twofactor"
 	"github.com/vpoletaev11/fileHostingSite/pages/upload"
 	"github.com/vpoletaev11/fileHostingSite/pages/users"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
 	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/storage"
 )
 
 const (
 	mySQLAddr = "user:@tcp(localhost:3306)" // docker: root:@tcp(mysql:3306)
 	redisAddr = "localhost:6379"            // docker: redis:6379
+	filesDir  = "files"                     // directory for uploaded files when S3 storage isn't configured
+	mailDir   = "mail"                      // directory for emails when SMTP server isn't configured
+	siteURL   = "http://localhost:8080"     // public address of site when SITE_URL isn't set
+	indexPath = "searchindex.gob"           // file of search index when SEARCH_INDEX isn't set
 )
 
 func main() {
@@ -30,23 +50,56 @@ func main() {
 	// creating file server handler for assets
 	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("assets"))))
 
-	// creating file server handler for files
-	http.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir("files"))))
-
-	http.HandleFunc("/registration", registration.Page(dep.Db))
+	http.HandleFunc("/registration", registration.Page(dep))
+	http.HandleFunc(registration.ForgotPath, registration.Forgot(dep))
+	http.HandleFunc(registration.ResetPath, registration.Reset(dep))
 	http.HandleFunc("/login", login.Page(dep))
+	http.HandleFunc(twofactor.LoginPath, twofactor.Login(dep))
 	http.HandleFunc("/", session.AuthWrapper(index.Page, dep))
 	http.HandleFunc("/logout", logout.Page(dep))
 	http.HandleFunc("/upload", session.AuthWrapper(upload.Page, dep))
+	http.HandleFunc(upload.ResumablePath, session.AuthWrapper(upload.Resumable, dep))
 	http.HandleFunc("/categories/", session.AuthWrapper(categories.Page, dep))
 	http.HandleFunc("/download", session.AuthWrapper(download.Page, dep))
+	http.HandleFunc("/file", session.AuthWrapper(file.Page, dep))
+	http.HandleFunc("/edit", session.AuthWrapper(edit.Page, dep))
 	http.HandleFunc("/popular", session.AuthWrapper(popular.Page, dep))
+	http.HandleFunc("/search", session.AuthWrapper(search.Page, dep))
+	http.HandleFunc("/tags", session.AuthWrapper(tags.Page, dep))
+	http.HandleFunc("/tags/", session.AuthWrapper(tags.Page, dep))
+	http.HandleFunc(tags.SuggestPath, session.AuthWrapper(tags.Suggest, dep))
 	http.HandleFunc("/users", session.AuthWrapper(users.Page, dep))
+	http.HandleFunc("/users/", session.AuthWrapper(users.Profile, dep))
+	http.HandleFunc("/sessions", session.AuthWrapper(sessions.Page, dep))
+	http.HandleFunc("/tokens", session.AuthWrapper(tokens.Page, dep))
+	http.HandleFunc("/2fa", session.AuthWrapper(twofactor.Page, dep))
+	http.HandleFunc("/password", session.AuthWrapper(registration.Password, dep))
+	http.HandleFunc("/settings", session.AuthWrapper(registration.Settings, dep))
+	http.HandleFunc("/admin", session.AdminWrapper(admin.Page, dep))
+	http.HandleFunc(admin.CategoriesPath, session.AdminWrapper(admin.Categories, dep))
+	http.HandleFunc(api.Prefix, api.Wrapper(dep))
+	http.HandleFunc(api.OpenAPIPath, api.OpenAPI)
 
 	fmt.Println("Starting server at :8080")
 	http.ListenAndServe(":8080", nil)
 }
 
+// migrate applies not applied migrations of database schema.
+// Set AUTO_MIGRATE=false to apply them only by "fhs-admin migrate up".
+func migrate(db *sql.DB) {
+	if os.Getenv("AUTO_MIGRATE") == "false" {
+		fmt.Println("Automatic migrations are disabled")
+		return
+	}
+	applied, err := migrations.Up(db, migrations.All)
+	if err != nil {
+		panic(err)
+	}
+	for _, m := range applied {
+		fmt.Printf("Applied migration %04d %s\n", m.Version, m.Name)
+	}
+}
+
 func connectToDBs() session.Dependency {
 	// connecting to mySQL database
 	db, err := sql.Open("mysql", mySQLAddr+"/fileHostingSite?parseTime=true") // ?parseTime=true asks the driver to scan DATE and DATETIME automatically to time.Time
@@ -54,6 +107,7 @@ func connectToDBs() session.Dependency {
 		panic(err)
 	}
 	fmt.Println("Successfully connected to MySql database")
+	migrate(db)
 
 	// connecting to Redis
 	redisConn, err := redis.Dial("tcp", redisAddr)
@@ -63,5 +117,81 @@ func connectToDBs() session.Dependency {
 	fmt.Println("Successfully connected to Redis")
 
 	// Connections to databases will be closed after program exit
-	return session.Dependency{Db: db, Redis: redisConn}
+	return session.Dependency{
+		Db:      db,
+		Redis:   redisConn,
+		Storage: newStorage(),
+		Mail:    newMailSender(),
+		Search:  newSearchIndex(db),
+		SiteURL: newSiteURL(),
+		// site served over TLS should send session cookie only over HTTPS
+		SecureCookie: os.Getenv("COOKIE_SECURE") == "true",
+	}
+}
+
+// newStorage returns S3-compatible storage if S3_ENDPOINT environment variable is set,
+// otherwise uploaded files will be stored in local directory
+func newStorage() storage.Backend {
+	cfg, ok := storage.S3ConfigFromEnv()
+	if !ok {
+		fmt.Println("Using local storage: " + filesDir)
+		return storage.NewLocal(filesDir)
+	}
+
+	fmt.Println("Using S3 storage: " + cfg.Endpoint)
+	return storage.NewS3(cfg)
+}
+
+// newMailSender returns sender which delivers emails through SMTP server if SMTP_ADDR environment variable is set,
+// otherwise emails will be written to files in local directory
+func newMailSender() mail.Sender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "noreply@localhost"
+	}
+
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		fmt.Println("Writing emails to directory: " + mailDir)
+		return mail.NewFile(mailDir, from)
+	}
+
+	fmt.Println("Sending emails through SMTP server: " + addr)
+	return mail.NewSMTP(mail.SMTPConfig{
+		Addr:     addr,
+		From:     from,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	})
+}
+
+// newSiteURL returns public address of site from SITE_URL environment variable
+func newSiteURL() string {
+	url := os.Getenv("SITE_URL")
+	if url == "" {
+		return siteURL
+	}
+	return strings.TrimRight(url, "/")
+}
+
+// newSearchIndex returns search index loaded from file set by SEARCH_INDEX environment variable.
+// Index is saved to file every minute if it was changed.
+// If SEARCH_INDEX=off search index isn't used and search page uses MySQL FULLTEXT index.
+func newSearchIndex(db *sql.DB) *searchindex.Index {
+	path := os.Getenv("SEARCH_INDEX")
+	if path == "off" {
+		fmt.Println("Search index is disabled, using MySQL FULLTEXT index")
+		return nil
+	}
+	if path == "" {
+		path = indexPath
+	}
+
+	idx, err := searchindex.Open(db, path)
+	if err != nil {
+		panic(err)
+	}
+	go idx.AutoSave(path, time.Minute)
+	fmt.Printf("Search index loaded from %s: %d files\n", path, idx.Len())
+	return idx
 }
@@ -0,0 +1,241 @@
+package maintenance
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+// Dataset is gzipped tar archive with entries:
+// 1) "tables/<table>.jsonl" - rows of table, one JSON object per line,
+// 2) "files/<key>" - stored object with files data.
+// Transient data (partial uploads, failed logins and everything stored in Redis) isn't exported.
+const (
+	tablesDir = "tables/"
+	filesDir  = "files/"
+)
+
+// tables are exported tables of database
+var tables = []string{"users", "categories", "files", "filesRating", "tags", "file_tags", "blobs", "recoveryCodes", "apiTokens"}
+
+// columnRegexp matches names of columns, names are inserted to queries, so they can't be passed as arguments
+var columnRegexp = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+const (
+	countAllUsers = "SELECT COUNT(*) FROM users;"
+
+	countAllFiles = "SELECT COUNT(*) FROM files;"
+)
+
+// ErrNotEmpty returns by Import when database already has users or files
+var ErrNotEmpty = errors.New("Database is not empty, dataset can be imported only to new database")
+
+// Export writes all rows of exported tables and all stored objects to dataset archive
+func Export(db *sql.DB, backend storage.Backend, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	for _, table := range tables {
+		data, err := exportTable(db, table)
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: tablesDir + table + ".jsonl", Mode: 0644, Size: int64(len(data)), ModTime: now})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	keys, err := backend.List()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err = exportObject(backend, tw, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// exportTable returns rows of table in JSON lines format
+func exportTable(db *sql.DB, table string) ([]byte, error) {
+	rows, err := db.Query("SELECT * FROM " + table + ";")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, err
+		}
+		row := map[string]interface{}{}
+		for i, column := range columns {
+			switch v := values[i].(type) {
+			case []byte:
+				row[column] = string(v)
+			case time.Time:
+				row[column] = v.UTC().Format("2006-01-02 15:04:05")
+			default:
+				row[column] = v
+			}
+		}
+		err = enc.Encode(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), rows.Err()
+}
+
+// exportObject writes stored object to archive
+func exportObject(backend storage.Backend, tw *tar.Writer, key string) error {
+	info, err := backend.Stat(key)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: filesDir + key, Mode: 0644, Size: info.Size, ModTime: info.ModTime})
+	if err != nil {
+		return err
+	}
+
+	r, err := backend.Get(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// Import loads dataset archive created by Export to empty database and storage.
+// Rows are inserted in one transaction, so database stays empty if import fails.
+func Import(db *sql.DB, backend storage.Backend, r io.Reader) error {
+	for _, query := range []string{countAllUsers, countAllFiles} {
+		count := 0
+		err := db.QueryRow(query).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrNotEmpty
+		}
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		switch {
+		case strings.HasPrefix(header.Name, tablesDir):
+			err = importTable(tx, strings.TrimSuffix(header.Name[len(tablesDir):], ".jsonl"), tr)
+		case strings.HasPrefix(header.Name, filesDir):
+			_, err = backend.Put(header.Name[len(filesDir):], tr)
+		default:
+			err = fmt.Errorf("Unknown entry of dataset %q", header.Name)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// importTable inserts rows from JSON lines to table
+func importTable(tx *sql.Tx, table string, r io.Reader) error {
+	known := false
+	for _, t := range tables {
+		known = known || t == table
+	}
+	if !known {
+		return fmt.Errorf("Unknown table %q", table)
+	}
+
+	dec := json.NewDecoder(r)
+	// numbers are passed to database as strings, so big integers are not rounded
+	dec.UseNumber()
+	for {
+		row := map[string]interface{}{}
+		err := dec.Decode(&row)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		columns := []string{}
+		for column := range row {
+			if !columnRegexp.MatchString(column) {
+				return fmt.Errorf("Incorrect column %q of table %q", column, table)
+			}
+			columns = append(columns, column)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		sort.Strings(columns)
+		args := []interface{}{}
+		for _, column := range columns {
+			args = append(args, row[column])
+		}
+
+		query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (?" + strings.Repeat(", ?", len(columns)-1) + ");"
+		_, err = tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package maintenance
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectExportQueries adds queries of tables, only users and files have rows
+func expectExportQueries(sqlMock sqlmock.Sqlmock) {
+	for _, table := range tables {
+		rows := sqlmock.NewRows([]string{"id"})
+		switch table {
+		case "users":
+			rows = sqlmock.NewRows([]string{"username", "password", "rating", "createdAt"}).
+				AddRow([]byte("username"), []byte("hash"), int64(10), time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC))
+		case "files":
+			rows = sqlmock.NewRows([]string{"id", "label", "hash"}).
+				AddRow(int64(1), []byte("file.txt"), []byte(hash1))
+		case "apiTokens":
+			rows = sqlmock.NewRows([]string{"id", "lastUsed"}).AddRow(int64(2), nil)
+		}
+		sqlMock.ExpectQuery("SELECT \\* FROM " + table + ";").WillReturnRows(rows)
+	}
+}
+
+func TestExportImportSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	backend, _, cleanup := newTestBackend(t, hash1)
+	defer cleanup()
+	expectExportQueries(sqlMock)
+
+	dataset := &bytes.Buffer{}
+	require.NoError(t, Export(db, backend, dataset))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+
+	// importing to new database and storage
+	db, sqlMock, err = sqlmock.New()
+	require.NoError(t, err)
+	newBackend, dir, cleanup := newTestBackend(t)
+	defer cleanup()
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users;").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files;").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO users \\(createdAt, password, rating, username\\) VALUES \\(\\?, \\?, \\?, \\?\\);").WithArgs("2009-11-17 20:34:58", "hash", "10", "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("INSERT INTO files \\(hash, id, label\\)").WithArgs(hash1, "1", "file.txt").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("INSERT INTO apiTokens \\(id, lastUsed\\)").WithArgs("2", nil).WillReturnResult(sqlmock.NewResult(2, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, Import(db, newBackend, dataset))
+
+	data, err := ioutil.ReadFile(dir + "/" + hash1)
+	require.NoError(t, err)
+	assert.Equal(t, "binary data", string(data))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestImportNotEmpty(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	backend, _, cleanup := newTestBackend(t)
+	defer cleanup()
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users;").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	assert.Equal(t, ErrNotEmpty, Import(db, backend, &bytes.Buffer{}))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,44 @@
+package maintenance
+
+import "database/sql"
+
+const (
+	recomputeFilesRating = "UPDATE files SET rating = (SELECT COALESCE(SUM(filesRating.rating), 0) FROM filesRating WHERE filesRating.fileID = files.id);"
+
+	recomputeUsersRating = "UPDATE users SET rating = (SELECT COALESCE(SUM(files.rating), 0) FROM files WHERE files.owner = users.username);"
+)
+
+// RecomputeRatings sets rating of each file to sum of its votes and rating of each user to sum of ratings of files owned by user.
+// Ratings are changed incrementally on voting, so this fixes them if they were broken.
+// It returns count of changed files and users.
+func RecomputeRatings(db *sql.DB) (files, users int64, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := tx.Exec(recomputeFilesRating)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+	files, err = res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	// users rating depends on files rating, so it's recomputed after it
+	res, err = tx.Exec(recomputeUsersRating)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+	users, err = res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	return files, users, tx.Commit()
+}
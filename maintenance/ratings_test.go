@@ -0,0 +1,38 @@
+package maintenance
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecomputeRatingsSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET rating = \\(SELECT COALESCE\\(SUM\\(filesRating.rating\\), 0\\) FROM filesRating").WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec("UPDATE users SET rating = \\(SELECT COALESCE\\(SUM\\(files.rating\\), 0\\) FROM files").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+
+	files, users, err := RecomputeRatings(db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), files)
+	assert.Equal(t, int64(2), users)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRecomputeRatingsDBError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET rating").WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec("UPDATE users SET rating").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	_, _, err = RecomputeRatings(db)
+	assert.EqualError(t, err, "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,173 @@
+package maintenance
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+
+	"github.com/vpoletaev11/fileHostingSite/fileops"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+const (
+	selectFileKeys = "SELECT id, hash FROM files ORDER BY id;"
+
+	selectBlobRefs = "SELECT hash, refs FROM blobs ORDER BY hash;"
+
+	insertBlob = "INSERT INTO blobs (hash, size, refs) VALUES (?, ?, ?);"
+
+	updateBlobRefs = "UPDATE blobs SET refs = ? WHERE hash = ?;"
+
+	deleteBlob = "DELETE FROM blobs WHERE hash = ?;"
+)
+
+// BlobRefs contains reference count of blob which differs from count of files rows with its hash
+type BlobRefs struct {
+	Hash   string
+	Stored int // reference count in blobs table, -1 if blob row doesn't exist
+	Actual int // count of files rows with hash of blob
+}
+
+// Report contains problems of stored files found by Check
+type Report struct {
+	// OrphanedObjects are keys of stored objects which don't belong to any file
+	OrphanedObjects []string
+	// MissingObjects are IDs of files whose data doesn't exist in storage
+	MissingObjects []int
+	// WrongRefs are blobs with wrong reference counts
+	WrongRefs []BlobRefs
+}
+
+// Empty returns true if no problems was found
+func (r Report) Empty() bool {
+	return len(r.OrphanedObjects) == 0 && len(r.MissingObjects) == 0 && len(r.WrongRefs) == 0
+}
+
+// Check compares files table and blobs table with objects in storage backend.
+// Files data is stored by SHA-256 hash, files uploaded before blob store was added are stored by file ID.
+func Check(db *sql.DB, backend storage.Backend) (Report, error) {
+	report := Report{}
+
+	keys, err := backend.List()
+	if err != nil {
+		return Report{}, err
+	}
+	stored := map[string]bool{}
+	for _, key := range keys {
+		stored[key] = true
+	}
+
+	referenced := map[string]bool{}
+	refs := map[string]int{}
+	rows, err := db.Query(selectFileKeys)
+	if err != nil {
+		return Report{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		id := 0
+		hash := ""
+		err = rows.Scan(&id, &hash)
+		if err != nil {
+			return Report{}, err
+		}
+
+		key := hash
+		if hash == "" {
+			key = strconv.Itoa(id)
+		} else {
+			refs[hash]++
+		}
+		referenced[key] = true
+		if !stored[key] {
+			report.MissingObjects = append(report.MissingObjects, id)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return Report{}, err
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !referenced[key] {
+			report.OrphanedObjects = append(report.OrphanedObjects, key)
+		}
+	}
+
+	blobRows, err := db.Query(selectBlobRefs)
+	if err != nil {
+		return Report{}, err
+	}
+	defer blobRows.Close()
+	for blobRows.Next() {
+		br := BlobRefs{}
+		err = blobRows.Scan(&br.Hash, &br.Stored)
+		if err != nil {
+			return Report{}, err
+		}
+		br.Actual = refs[br.Hash]
+		if br.Actual != br.Stored {
+			report.WrongRefs = append(report.WrongRefs, br)
+		}
+		delete(refs, br.Hash)
+	}
+	err = blobRows.Err()
+	if err != nil {
+		return Report{}, err
+	}
+
+	// hashes of files which don't have blob rows
+	hashes := []string{}
+	for hash := range refs {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		report.WrongRefs = append(report.WrongRefs, BlobRefs{Hash: hash, Stored: -1, Actual: refs[hash]})
+	}
+	return report, nil
+}
+
+// Repair fixes problems found by Check:
+// 1) reference counts of blobs are set to count of files rows (blob rows without files are deleted),
+// 2) files whose data is missing are deleted the same way as owner deletes them,
+// 3) orphaned objects are deleted from storage.
+// Site should be stopped during repair, otherwise files uploaded after Check can be deleted.
+func Repair(db *sql.DB, backend storage.Backend, report Report) error {
+	for _, br := range report.WrongRefs {
+		var err error
+		switch {
+		case br.Actual == 0:
+			_, err = db.Exec(deleteBlob, br.Hash)
+		case br.Stored == -1:
+			// size of missing blob is unknown, its file will be deleted on the next step
+			size := int64(0)
+			info, statErr := backend.Stat(br.Hash)
+			if statErr == nil {
+				size = info.Size
+			}
+			_, err = db.Exec(insertBlob, br.Hash, size, br.Actual)
+		default:
+			_, err = db.Exec(updateBlobRefs, br.Actual, br.Hash)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range report.MissingObjects {
+		err := fileops.Delete(db, backend, strconv.Itoa(id))
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	for _, key := range report.OrphanedObjects {
+		err := backend.Delete(key)
+		if err != nil && err != storage.ErrNotExist {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package maintenance
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+)
+
+const (
+	hash1 = "1111111111111111111111111111111111111111111111111111111111111111"
+	hash2 = "2222222222222222222222222222222222222222222222222222222222222222"
+	hash3 = "3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+// newTestBackend returns local storage backend in temporary directory with inputted objects
+func newTestBackend(t *testing.T, keys ...string) (storage.Backend, string, func()) {
+	dir, err := ioutil.TempDir("", "maintenance-test-")
+	require.NoError(t, err)
+	for _, key := range keys {
+		require.NoError(t, ioutil.WriteFile(dir+"/"+key, []byte("binary data"), 0644))
+	}
+	return storage.NewLocal(dir), dir, func() { os.RemoveAll(dir) }
+}
+
+// Storage state used in tests:
+// file 1 - legacy file stored by ID,
+// file 2 - legacy file whose data is missing,
+// files 3 and 4 - the same data with hash1, but blob row has 1 reference,
+// file 5 - data with hash2 is missing and blob row doesn't exist,
+// object 6 and blob hash3 don't belong to any file, blob row of hash3 has 1 reference.
+func expectCheckQueries(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT id, hash FROM files").WillReturnRows(sqlmock.NewRows([]string{"id", "hash"}).
+		AddRow(1, "").
+		AddRow(2, "").
+		AddRow(3, hash1).
+		AddRow(4, hash1).
+		AddRow(5, hash2))
+	sqlMock.ExpectQuery("SELECT hash, refs FROM blobs").WillReturnRows(sqlmock.NewRows([]string{"hash", "refs"}).
+		AddRow(hash1, 1).
+		AddRow(hash3, 1))
+}
+
+var expectedReport = Report{
+	OrphanedObjects: []string{hash3, "6"},
+	MissingObjects:  []int{2, 5},
+	WrongRefs: []BlobRefs{
+		{Hash: hash1, Stored: 1, Actual: 2},
+		{Hash: hash3, Stored: 1, Actual: 0},
+		{Hash: hash2, Stored: -1, Actual: 1},
+	},
+}
+
+func TestCheckSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	backend, _, cleanup := newTestBackend(t, "1", "6", hash1, hash3)
+	defer cleanup()
+	expectCheckQueries(sqlMock)
+
+	report, err := Check(db, backend)
+	require.NoError(t, err)
+	assert.Equal(t, expectedReport, report)
+	assert.False(t, report.Empty())
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRepairSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	backend, dir, cleanup := newTestBackend(t, "1", "6", hash1, hash3)
+	defer cleanup()
+
+	// fixing of reference counts
+	sqlMock.ExpectExec("UPDATE blobs SET refs = \\? WHERE hash = \\?;").WithArgs(2, hash1).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM blobs WHERE hash = \\?;").WithArgs(hash3).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("INSERT INTO blobs \\(hash, size, refs\\)").WithArgs(hash2, 0, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	// deleting of files with missing data
+	for _, file := range []struct{ id, hash string }{{"2", ""}, {"5", hash2}} {
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs(file.id).WillReturnRows(sqlmock.NewRows([]string{"owner", "rating", "hash"}).AddRow("owner", 0, file.hash))
+		sqlMock.ExpectExec("DELETE FROM filesRating").WithArgs(file.id).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec("DELETE FROM file_tags").WithArgs(file.id).WillReturnResult(sqlmock.NewResult(0, 0))
+		sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, "owner").WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs(file.id).WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+	}
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs").WithArgs(hash2).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(hash2).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	require.NoError(t, Repair(db, backend, expectedReport))
+
+	keys, err := backend.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", hash1}, keys)
+	_, err = os.Stat(dir + "/6")
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
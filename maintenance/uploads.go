@@ -0,0 +1,80 @@
+package maintenance
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	selectExpiredUploads = "SELECT id FROM partialUploads WHERE createdAt < ?;"
+
+	deleteExpiredUpload = "DELETE FROM partialUploads WHERE id = ?;"
+
+	selectUploadIDs = "SELECT id FROM partialUploads;"
+)
+
+// ExpireUploads deletes resumable uploads created before inputted time and their partially uploaded files from dir.
+// Partial files without resumable upload (e.g. they weren't removed after upload was finished) are removed
+// if they weren't changed after inputted time. It returns count of deleted uploads and removed files without uploads.
+func ExpireUploads(db *sql.DB, dir string, before time.Time) (uploads, files int, err error) {
+	expired, err := uploadIDs(db, selectExpiredUploads, before.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, 0, err
+	}
+	for id := range expired {
+		_, err = db.Exec(deleteExpiredUpload, id)
+		if err != nil {
+			return uploads, 0, err
+		}
+		err = os.Remove(filepath.Join(dir, id))
+		if err != nil && !os.IsNotExist(err) {
+			return uploads, 0, err
+		}
+		uploads++
+	}
+
+	active, err := uploadIDs(db, selectUploadIDs)
+	if err != nil {
+		return uploads, 0, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return uploads, 0, err
+	}
+	for _, info := range infos {
+		// .gitignore and other hidden files aren't partial files
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") || active[info.Name()] || !info.ModTime().Before(before) {
+			continue
+		}
+		err = os.Remove(filepath.Join(dir, info.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return uploads, files, err
+		}
+		files++
+	}
+	return uploads, files, nil
+}
+
+// uploadIDs returns set of resumable uploads IDs selected by query
+func uploadIDs(db *sql.DB, query string, args ...interface{}) (map[string]bool, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]bool{}
+	for rows.Next() {
+		id := ""
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,47 @@
+package maintenance
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireUploadsSuccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maintenance-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	before := time.Date(2020, 5, 10, 12, 0, 0, 0, time.UTC)
+	old := before.Add(-time.Hour)
+	// "expired" - expired upload, "active" - not expired upload,
+	// "finished" - old file without upload, "fresh" - new file without upload (its upload can be created right now)
+	for _, name := range []string{"expired", "active", "finished", "fresh", ".gitignore"} {
+		require.NoError(t, ioutil.WriteFile(dir+"/"+name, []byte("binary"), 0644))
+		require.NoError(t, os.Chtimes(dir+"/"+name, old, old))
+	}
+	require.NoError(t, os.Chtimes(dir+"/fresh", before, before))
+
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT id FROM partialUploads WHERE createdAt < ?").WithArgs("2020-05-10 12:00:00").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("expired"))
+	sqlMock.ExpectExec("DELETE FROM partialUploads WHERE id = ?").WithArgs("expired").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery("SELECT id FROM partialUploads;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("active"))
+
+	uploads, files, err := ExpireUploads(db, dir, before)
+	require.NoError(t, err)
+	assert.Equal(t, 1, uploads)
+	assert.Equal(t, 1, files)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	assert.Equal(t, []string{".gitignore", "active", "fresh"}, names)
+}
@@ -0,0 +1,133 @@
+// Package maintenance contains operations of admin command-line tool (fhs-admin):
+// management of users, recomputing of ratings, checking of stored files, expiry of resumable uploads
+// and export/import of dataset.
+package maintenance
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/credentials"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+)
+
+const (
+	createUser = "INSERT INTO users (username, password, timezone, email, isAdmin, createdAt) VALUES (?, ?, ?, ?, ?, ?);"
+
+	countUsers = "SELECT COUNT(*) FROM users WHERE username = ?;"
+
+	updatePassword = "UPDATE users SET password = ? WHERE username = ?;"
+
+	updateBanned = "UPDATE users SET banned = ? WHERE username = ?;"
+)
+
+// ErrUserNotFound returns when user with inputted username doesn't exist
+var ErrUserNotFound = errors.New("User doesn't exist")
+
+// CreateUser registers user the same way as registration page does. Admin tool can also create admins.
+func CreateUser(db *sql.DB, username, password, timezone, email string, isAdmin bool) error {
+	err := credentials.ValidateUsername(username)
+	if err != nil {
+		return err
+	}
+	err = credentials.ValidatePassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		return fmt.Errorf("Incorrect timezone")
+	}
+
+	hashedPass, err := credentials.Hash(password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(createUser, username, hashedPass, timezone, email, isAdmin, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		// username is primary key
+		if strings.Contains(err.Error(), "Error 1062") {
+			return fmt.Errorf("Username already used")
+		}
+		return err
+	}
+	return nil
+}
+
+// ResetPassword sets new password of user.
+// All sessions and API tokens of user are removed and login lockout is removed, the same as after reset by email link.
+func ResetPassword(db *sql.DB, redisConn redis.Conn, username, password string) error {
+	err := credentials.ValidatePassword(password)
+	if err != nil {
+		return err
+	}
+	err = checkUser(db, username)
+	if err != nil {
+		return err
+	}
+
+	hashedPass, err := credentials.Hash(password)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(updatePassword, hashedPass, username)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = apitokens.RevokeAll(tx, username)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	err = session.KillSessions(redisConn, username)
+	if err != nil {
+		return err
+	}
+	return throttle.Reset(redisConn, throttle.LoginByUsername, username)
+}
+
+// SetBanned bans or unbans user. Banned user loses access immediately, so all sessions of user are closed.
+func SetBanned(db *sql.DB, redisConn redis.Conn, username string, banned bool) error {
+	err := checkUser(db, username)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(updateBanned, banned, username)
+	if err != nil {
+		return err
+	}
+	if !banned {
+		return nil
+	}
+	return session.KillSessions(redisConn, username)
+}
+
+// checkUser returns ErrUserNotFound if user doesn't exist
+func checkUser(db *sql.DB, username string) error {
+	count := 0
+	err := db.QueryRow(countUsers, username).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package maintenance
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordHash matches bcrypt hash of password
+type passwordHash string
+
+func (p passwordHash) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && bcrypt.CompareHashAndPassword([]byte(s), []byte(p)) == nil
+}
+
+func TestCreateUserSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("INSERT INTO users \\(username, password, timezone, email, isAdmin, createdAt\\)").WithArgs("admin", passwordHash("password"), "Europe/Moscow", "", true, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, CreateUser(db, "admin", "password", "Europe/Moscow", "", true))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCreateUserErrors(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("INSERT INTO users").WillReturnError(fmt.Errorf("Error 1062: Duplicate entry 'admin' for key 'PRIMARY'"))
+
+	assert.EqualError(t, CreateUser(db, "Admin", "password", "UTC", "", false), "Please use lower case username")
+	assert.EqualError(t, CreateUser(db, "admin", "", "UTC", "", false), "Password cannot be empty")
+	assert.EqualError(t, CreateUser(db, "admin", "password", "Mars/Olympus", "", false), "Incorrect timezone")
+	assert.EqualError(t, CreateUser(db, "admin", "password", "UTC", "", false), "Username already used")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResetPasswordSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	redisMock := redigomock.NewConn()
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE username").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE users SET password = \\? WHERE username = \\?;").WithArgs(passwordHash("newPassword"), "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE username = \\?;").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{})
+	kill := redisMock.Command("DEL", "sessions:username").Expect(int64(1))
+	unlock := redisMock.Command("DEL", "failures:login:user:username", "lock:login:user:username").Expect(int64(2))
+
+	require.NoError(t, ResetPassword(db, redisMock, "username", "newPassword"))
+	assert.Equal(t, 1, redisMock.Stats(kill))
+	assert.Equal(t, 1, redisMock.Stats(unlock))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResetPasswordUserNotFound(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE username").WithArgs("unknown").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	assert.Equal(t, ErrUserNotFound, ResetPassword(db, redigomock.NewConn(), "unknown", "newPassword"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSetBannedSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	redisMock := redigomock.NewConn()
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE username").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectExec("UPDATE users SET banned = \\? WHERE username = \\?;").WithArgs(true, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE username").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectExec("UPDATE users SET banned = \\? WHERE username = \\?;").WithArgs(false, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{[]byte("id")})
+	killSession := redisMock.Command("DEL", "id", "session:id").Expect(int64(2))
+	redisMock.Command("DEL", "sessions:username").Expect(int64(1))
+
+	require.NoError(t, SetBanned(db, redisMock, "username", true))
+	// sessions are killed only on ban
+	require.NoError(t, SetBanned(db, redisMock, "username", false))
+	assert.Equal(t, 1, redisMock.Stats(killSession))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,17 @@
+package migrations
+
+// addFileMetadata adds MIME type and original filename of uploaded files, they are sent on download
+var addFileMetadata = Migration{
+	Version: 2,
+	Name:    "file_metadata",
+	Up: `
+ALTER TABLE files
+	ADD COLUMN mimeType VARCHAR(255) NOT NULL DEFAULT 'application/octet-stream',
+	ADD COLUMN originalName VARCHAR(255) NOT NULL DEFAULT '';
+`,
+	Down: `
+ALTER TABLE files
+	DROP COLUMN originalName,
+	DROP COLUMN mimeType;
+`,
+}
@@ -0,0 +1,14 @@
+package migrations
+
+// addFileHash adds hash of file content, it's used as ETag on download.
+// Files uploaded before have empty hash, their data is stored by ID
+var addFileHash = Migration{
+	Version: 3,
+	Name:    "file_hash",
+	Up: `
+ALTER TABLE files ADD COLUMN hash CHAR(64) NOT NULL DEFAULT '';
+`,
+	Down: `
+ALTER TABLE files DROP COLUMN hash;
+`,
+}
@@ -0,0 +1,23 @@
+package migrations
+
+// createPartialUploads creates table of resumable uploads
+var createPartialUploads = Migration{
+	Version: 4,
+	Name:    "partial_uploads",
+	Up: `
+CREATE TABLE partialUploads (
+	PRIMARY KEY(id),
+	id CHAR(32) NOT NULL,
+	owner VARCHAR(20) NOT NULL,
+	length BIGINT NOT NULL,
+	label VARCHAR(50) NOT NULL,
+	description VARCHAR(500) NOT NULL,
+	category VARCHAR(20) NOT NULL,
+	originalName VARCHAR(255) NOT NULL,
+	createdAt DATETIME NOT NULL
+);
+`,
+	Down: `
+DROP TABLE partialUploads;
+`,
+}
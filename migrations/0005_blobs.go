@@ -0,0 +1,18 @@
+package migrations
+
+// createBlobs creates table of reference counts of content-addressed blob store
+var createBlobs = Migration{
+	Version: 5,
+	Name:    "blobs",
+	Up: `
+CREATE TABLE blobs (
+	PRIMARY KEY(hash),
+	hash CHAR(64) NOT NULL,
+	size BIGINT NOT NULL,
+	refs INT NOT NULL DEFAULT 0
+);
+`,
+	Down: `
+DROP TABLE blobs;
+`,
+}
@@ -0,0 +1,17 @@
+package migrations
+
+// addAdmin adds admin role and ban of users
+var addAdmin = Migration{
+	Version: 6,
+	Name:    "admin",
+	Up: `
+ALTER TABLE users
+	ADD COLUMN isAdmin BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN banned BOOLEAN NOT NULL DEFAULT FALSE;
+`,
+	Down: `
+ALTER TABLE users
+	DROP COLUMN banned,
+	DROP COLUMN isAdmin;
+`,
+}
@@ -0,0 +1,20 @@
+package migrations
+
+// createFailedLogins creates table of failed login attempts shown to admins
+var createFailedLogins = Migration{
+	Version: 7,
+	Name:    "failed_logins",
+	Up: `
+CREATE TABLE failedLogins (
+	PRIMARY KEY(id),
+	id INT NOT NULL AUTO_INCREMENT,
+	username VARCHAR(20) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	attemptTime DATETIME NOT NULL,
+	INDEX(attemptTime)
+);
+`,
+	Down: `
+DROP TABLE failedLogins;
+`,
+}
@@ -0,0 +1,20 @@
+package migrations
+
+// addTwoFactor adds TOTP secret of users and table of recovery codes
+var addTwoFactor = Migration{
+	Version: 8,
+	Name:    "two_factor",
+	Up: `
+ALTER TABLE users ADD COLUMN totpSecret VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE TABLE recoveryCodes (
+	PRIMARY KEY(username, codeHash),
+	username VARCHAR(20) NOT NULL,
+	codeHash CHAR(64) NOT NULL
+);
+`,
+	Down: `
+DROP TABLE recoveryCodes;
+ALTER TABLE users DROP COLUMN totpSecret;
+`,
+}
@@ -0,0 +1,13 @@
+package migrations
+
+// addUserEmail adds email of users, it's used for password reset
+var addUserEmail = Migration{
+	Version: 9,
+	Name:    "user_email",
+	Up: `
+ALTER TABLE users ADD COLUMN email VARCHAR(254) NOT NULL DEFAULT '';
+`,
+	Down: `
+ALTER TABLE users DROP COLUMN email;
+`,
+}
@@ -0,0 +1,16 @@
+package migrations
+
+// addUserCreatedAt adds registration date of users in UTC shown on profile pages.
+// Users registered before get date of migration, new users get date from site, like dates of other tables
+var addUserCreatedAt = Migration{
+	Version: 10,
+	Name:    "user_created_at",
+	Up: `
+ALTER TABLE users ADD COLUMN createdAt DATETIME;
+UPDATE users SET createdAt = UTC_TIMESTAMP();
+ALTER TABLE users MODIFY COLUMN createdAt DATETIME NOT NULL;
+`,
+	Down: `
+ALTER TABLE users DROP COLUMN createdAt;
+`,
+}
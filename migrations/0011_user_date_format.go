@@ -0,0 +1,13 @@
+package migrations
+
+// addUserDateFormat adds date format chosen by user in account settings
+var addUserDateFormat = Migration{
+	Version: 11,
+	Name:    "user_date_format",
+	Up: `
+ALTER TABLE users ADD COLUMN dateFormat VARCHAR(10) NOT NULL DEFAULT 'iso';
+`,
+	Down: `
+ALTER TABLE users DROP COLUMN dateFormat;
+`,
+}
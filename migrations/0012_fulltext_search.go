@@ -0,0 +1,13 @@
+package migrations
+
+// addFulltextSearch adds FULLTEXT index used by search page
+var addFulltextSearch = Migration{
+	Version: 12,
+	Name:    "fulltext_search",
+	Up: `
+ALTER TABLE files ADD FULLTEXT INDEX fulltextSearch (label, description);
+`,
+	Down: `
+ALTER TABLE files DROP INDEX fulltextSearch;
+`,
+}
@@ -0,0 +1,26 @@
+package migrations
+
+// createTags creates tables of file tags
+var createTags = Migration{
+	Version: 13,
+	Name:    "tags",
+	Up: `
+CREATE TABLE tags (
+	PRIMARY KEY(id),
+	id INT NOT NULL AUTO_INCREMENT,
+	name VARCHAR(30) NOT NULL,
+	UNIQUE(name)
+);
+
+CREATE TABLE file_tags (
+	PRIMARY KEY(fileID, tagID),
+	fileID INT NOT NULL,
+	tagID INT NOT NULL,
+	INDEX(tagID)
+);
+`,
+	Down: `
+DROP TABLE file_tags;
+DROP TABLE tags;
+`,
+}
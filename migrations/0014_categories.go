@@ -0,0 +1,29 @@
+package migrations
+
+// createCategories creates table of file categories with categories which were hardcoded before
+var createCategories = Migration{
+	Version: 14,
+	Name:    "categories",
+	Up: `
+CREATE TABLE categories (
+	PRIMARY KEY(slug),
+	slug VARCHAR(20) NOT NULL,
+	name VARCHAR(50) NOT NULL,
+	description VARCHAR(500) NOT NULL DEFAULT '',
+	sortOrder INT NOT NULL DEFAULT 0,
+	parent VARCHAR(20) NULL,
+	retired BOOLEAN NOT NULL DEFAULT FALSE,
+	INDEX(parent)
+);
+
+INSERT INTO categories (slug, name, sortOrder) VALUES
+	('other', 'Other', 10),
+	('games', 'Games', 20),
+	('documents', 'Documents', 30),
+	('projects', 'Projects', 40),
+	('music', 'Music', 50);
+`,
+	Down: `
+DROP TABLE categories;
+`,
+}
@@ -0,0 +1,24 @@
+package migrations
+
+// createAPITokens creates table of personal API tokens
+var createAPITokens = Migration{
+	Version: 15,
+	Name:    "api_tokens",
+	Up: `
+CREATE TABLE apiTokens (
+	PRIMARY KEY(id),
+	id INT NOT NULL AUTO_INCREMENT,
+	username VARCHAR(20) NOT NULL,
+	name VARCHAR(50) NOT NULL,
+	tokenHash CHAR(64) NOT NULL,
+	scopes VARCHAR(50) NOT NULL,
+	createdAt DATETIME NOT NULL,
+	lastUsed DATETIME NULL,
+	UNIQUE(tokenHash),
+	INDEX(username)
+);
+`,
+	Down: `
+DROP TABLE apiTokens;
+`,
+}
@@ -0,0 +1,341 @@
+// Package migrations contains versioned changes of database schema and runner which applies them.
+// Scripts are stored in Go files, so they are embedded to binaries of site and admin tool.
+// Applied migrations are recorded in schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration is versioned change of database schema.
+// Up and Down are SQL scripts: statements are separated by semicolon at the end of line,
+// lines started with "--" are comments.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // applies change
+	Down    string // reverts change
+}
+
+// All contains migrations of site database in order of versions.
+// New migration should be added to the end with the next version, applied migrations should not be changed.
+var All = []Migration{
+	initSchema,
+	addFileMetadata,
+	addFileHash,
+	createPartialUploads,
+	createBlobs,
+	addAdmin,
+	createFailedLogins,
+	addTwoFactor,
+	addUserEmail,
+	addUserCreatedAt,
+	addUserDateFormat,
+	addFulltextSearch,
+	createTags,
+	createCategories,
+	createAPITokens,
+}
+
+const (
+	createMigrationsTable = "CREATE TABLE IF NOT EXISTS schema_migrations (PRIMARY KEY(version), version INT NOT NULL, name VARCHAR(100) NOT NULL, dirty BOOLEAN NOT NULL DEFAULT FALSE, appliedAt DATETIME NOT NULL);"
+
+	selectApplied = "SELECT version, dirty FROM schema_migrations ORDER BY version;"
+
+	insertApplied = "INSERT INTO schema_migrations (version, name, dirty, appliedAt) VALUES (?, ?, TRUE, ?);"
+
+	updateDirty = "UPDATE schema_migrations SET dirty = ? WHERE version = ?;"
+
+	deleteApplied = "DELETE FROM schema_migrations WHERE version = ?;"
+
+	getLock = "SELECT GET_LOCK(?, ?);"
+
+	releaseLock = "SELECT RELEASE_LOCK(?);"
+)
+
+const (
+	lockName    = "fileHostingSite.schema_migrations"
+	lockTimeout = 60 // seconds
+)
+
+// DirtyError returns when migration was interrupted earlier.
+// MySQL commits schema changes immediately, so interrupted migration can be applied partially
+// and schema should be fixed manually before Force.
+type DirtyError struct {
+	Version int
+}
+
+func (e DirtyError) Error() string {
+	return fmt.Sprintf("Migration %04d was interrupted, fix schema manually and mark migration as applied by \"fhs-admin migrate force %d\"", e.Version, e.Version)
+}
+
+// State contains migration and its state in database
+type State struct {
+	Migration
+	Applied bool
+	Dirty   bool
+}
+
+// Up applies all not applied migrations in order of versions and returns applied migrations.
+// Migrations are applied under MySQL named lock, so several instances of site can be started at the same time.
+func Up(db *sql.DB, migrations []Migration) ([]Migration, error) {
+	err := validate(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	err = withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		err = checkDirty(applied)
+		if err != nil {
+			return err
+		}
+		for version := range applied {
+			if _, ok := find(migrations, version); !ok {
+				return fmt.Errorf("Database has unknown migration %04d, it was applied by newer version of site", version)
+			}
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err = run(conn, m, m.Up, func() error {
+				_, err := conn.ExecContext(context.Background(), insertApplied, m.Version, m.Name, time.Now().UTC().Format("2006-01-02 15:04:05"))
+				return err
+			}, func() error {
+				_, err := conn.ExecContext(context.Background(), updateDirty, false, m.Version)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts inputted count of the last applied migrations and returns reverted migrations
+func Down(db *sql.DB, migrations []Migration, steps int) ([]Migration, error) {
+	err := validate(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	err = withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		err = checkDirty(applied)
+		if err != nil {
+			return err
+		}
+		versions := []int{}
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, version := range versions {
+			m, ok := find(migrations, version)
+			if !ok {
+				return fmt.Errorf("Database has unknown migration %04d, it was applied by newer version of site", version)
+			}
+			err = run(conn, m, m.Down, func() error {
+				_, err := conn.ExecContext(context.Background(), updateDirty, true, m.Version)
+				return err
+			}, func() error {
+				_, err := conn.ExecContext(context.Background(), deleteApplied, m.Version)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns state of each migration
+func Status(db *sql.DB, migrations []Migration) ([]State, error) {
+	err := validate(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []State{}
+	err = withConn(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			dirty, ok := applied[m.Version]
+			states = append(states, State{Migration: m, Applied: ok, Dirty: dirty})
+		}
+		return nil
+	})
+	return states, err
+}
+
+// Force marks interrupted migration as successfully applied, it's used after manual fix of schema
+func Force(db *sql.DB, version int) error {
+	return withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if dirty, ok := applied[version]; !ok || !dirty {
+			return fmt.Errorf("Migration %04d wasn't interrupted", version)
+		}
+		_, err = conn.ExecContext(context.Background(), updateDirty, false, version)
+		return err
+	})
+}
+
+// run executes script of migration. Migration is marked as dirty by before() until after() is called,
+// so interrupted migration will be found by the next run.
+func run(conn *sql.Conn, m Migration, script string, before, after func() error) error {
+	err := before()
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements(script) {
+		_, err = conn.ExecContext(context.Background(), statement)
+		if err != nil {
+			return fmt.Errorf("Migration %04d %s failed: %s", m.Version, m.Name, err)
+		}
+	}
+	return after()
+}
+
+// withConn calls f with connection of database after migrations table is created
+func withConn(db *sql.DB, f func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), createMigrationsTable)
+	if err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+// withLock calls f while named lock of migrations is held.
+// Named lock belongs to connection, so all queries of f should use the same connection.
+func withLock(db *sql.DB, f func(conn *sql.Conn) error) error {
+	return withConn(db, func(conn *sql.Conn) error {
+		// GET_LOCK returns 1 if lock is obtained, 0 on timeout and NULL on error
+		locked := sql.NullInt64{}
+		err := conn.QueryRowContext(context.Background(), getLock, lockName, lockTimeout).Scan(&locked)
+		if err != nil {
+			return err
+		}
+		if locked.Int64 != 1 {
+			return fmt.Errorf("Cannot get lock of migrations, probably migrations are run by another process")
+		}
+
+		err = f(conn)
+		released := sql.NullInt64{}
+		releaseErr := conn.QueryRowContext(context.Background(), releaseLock, lockName).Scan(&released)
+		if err != nil {
+			return err
+		}
+		return releaseErr
+	})
+}
+
+// appliedVersions returns versions of applied migrations related with their dirty flags
+func appliedVersions(conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(context.Background(), selectApplied)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		version := 0
+		dirty := false
+		err = rows.Scan(&version, &dirty)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = dirty
+	}
+	return applied, rows.Err()
+}
+
+// checkDirty returns DirtyError if some migration was interrupted
+func checkDirty(applied map[int]bool) error {
+	for version, dirty := range applied {
+		if dirty {
+			return DirtyError{Version: version}
+		}
+	}
+	return nil
+}
+
+// find returns migration with inputted version
+func find(migrations []Migration, version int) (Migration, bool) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// validate checks that versions of migrations are positive and sorted without duplicates
+func validate(migrations []Migration) error {
+	prev := 0
+	for _, m := range migrations {
+		if m.Version <= prev {
+			return fmt.Errorf("Migration %04d %s is out of order", m.Version, m.Name)
+		}
+		prev = m.Version
+	}
+	return nil
+}
+
+// statements splits script to statements, statement ends with semicolon at the end of line
+func statements(script string) []string {
+	result := []string{}
+	current := []string{}
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		result = append(result, strings.Join(current, "\n"))
+	}
+	return result
+}
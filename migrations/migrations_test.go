@@ -0,0 +1,266 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "first",
+		Up:      "-- creating table\nCREATE TABLE first (id INT);",
+		Down:    "DROP TABLE first;",
+	},
+	{
+		Version: 2,
+		Name:    "second",
+		Up:      "CREATE TABLE second (id INT);\nCREATE TABLE third (id INT);",
+		Down:    "DROP TABLE third;\nDROP TABLE second;",
+	},
+}
+
+func expectLock(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery("SELECT GET_LOCK\\(\\?, \\?\\);").WithArgs(lockName, lockTimeout).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+}
+
+func expectRelease(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT RELEASE_LOCK\\(\\?\\);").WithArgs(lockName).WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(1))
+}
+
+func TestUpSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations ORDER BY version;").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	sqlMock.ExpectExec("INSERT INTO schema_migrations").WithArgs(2, "second", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("CREATE TABLE second \\(id INT\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("CREATE TABLE third \\(id INT\\);").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("UPDATE schema_migrations SET dirty = \\? WHERE version = \\?;").WithArgs(false, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(sqlMock)
+
+	applied, err := Up(db, testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{testMigrations[1]}, applied)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpDirty(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, true))
+	expectRelease(sqlMock)
+
+	applied, err := Up(db, testMigrations)
+	assert.Equal(t, DirtyError{Version: 1}, err)
+	assert.Empty(t, applied)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpUnknownMigration(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false).AddRow(3, false))
+	expectRelease(sqlMock)
+
+	_, err = Up(db, testMigrations)
+	assert.EqualError(t, err, "Database has unknown migration 0003, it was applied by newer version of site")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpStatementError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}))
+	sqlMock.ExpectExec("INSERT INTO schema_migrations").WithArgs(1, "first", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("CREATE TABLE first").WillReturnError(fmt.Errorf("testing error"))
+	expectRelease(sqlMock)
+
+	_, err = Up(db, testMigrations)
+	assert.EqualError(t, err, "Migration 0001 first failed: testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpLockTimeout(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery("SELECT GET_LOCK").WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+
+	_, err = Up(db, testMigrations)
+	assert.EqualError(t, err, "Cannot get lock of migrations, probably migrations are run by another process")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUpOutOfOrder(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	_, err = Up(db, []Migration{testMigrations[1], testMigrations[0]})
+	assert.EqualError(t, err, "Migration 0001 first is out of order")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDownSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false).AddRow(2, false))
+	sqlMock.ExpectExec("UPDATE schema_migrations SET dirty = \\? WHERE version = \\?;").WithArgs(true, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DROP TABLE third;").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DROP TABLE second;").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\?;").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(sqlMock)
+
+	reverted, err := Down(db, testMigrations, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Migration{testMigrations[1]}, reverted)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStatusSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+
+	states, err := Status(db, testMigrations)
+	require.NoError(t, err)
+	assert.Equal(t, []State{
+		{Migration: testMigrations[0], Applied: true},
+		{Migration: testMigrations[1]},
+	}, states)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestForceSuccess(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, true))
+	sqlMock.ExpectExec("UPDATE schema_migrations SET dirty = \\? WHERE version = \\?;").WithArgs(false, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRelease(sqlMock)
+
+	err = Force(db, 1)
+	require.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestForceNotDirty(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	expectRelease(sqlMock)
+
+	err = Force(db, 1)
+	assert.EqualError(t, err, "Migration 0001 wasn't interrupted")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- comment\nCREATE TABLE a (\n\tid INT\n);\n\nINSERT INTO a VALUES (1);\nDROP TABLE b"
+	assert.Equal(t, []string{"CREATE TABLE a (\n\tid INT\n);", "INSERT INTO a VALUES (1);", "DROP TABLE b"}, statements(script))
+}
+
+func TestAllMigrations(t *testing.T) {
+	require.NoError(t, validate(All))
+	for _, m := range All {
+		assert.NotEmpty(t, statements(m.Up), "migration %04d has no up statements", m.Version)
+		assert.NotEmpty(t, statements(m.Down), "migration %04d has no down statements", m.Version)
+	}
+}
+
+// TestUpBaselineSchema checks that database created by init.sql of first version of site is upgraded by all next migrations
+func TestUpBaselineSchema(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	expectLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT version, dirty FROM schema_migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	for _, m := range All[1:] {
+		sqlMock.ExpectExec("INSERT INTO schema_migrations").WithArgs(m.Version, m.Name, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		for _, stmt := range statements(m.Up) {
+			sqlMock.ExpectExec(regexp.QuoteMeta(stmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		sqlMock.ExpectExec("UPDATE schema_migrations SET dirty = \\? WHERE version = \\?;").WithArgs(false, m.Version).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectRelease(sqlMock)
+
+	applied, err := Up(db, All)
+	require.NoError(t, err)
+	assert.Equal(t, All[1:], applied)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestMySQL runs all migrations up and down against throwaway database when MYSQL_TEST_DSN is set, e.g.:
+// MYSQL_TEST_DSN="root:@tcp(localhost:3306)/" go test ./migrations
+func TestMySQL(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN is not set")
+	}
+	admin, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+	name := "migrations_test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	_, err = admin.Exec("CREATE DATABASE " + name)
+	require.NoError(t, err)
+	defer admin.Exec("DROP DATABASE " + name)
+
+	db, err := sql.Open("mysql", dsn+name)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// database is created by first version of site and then upgraded with existing data
+	applied, err := Up(db, All[:1])
+	require.NoError(t, err)
+	assert.Equal(t, All[:1], applied)
+	_, err = db.Exec("INSERT INTO users (username, password, timezone) VALUES ('example', 'hash', 'UTC');")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO files (label, filesizeBytes, description, owner, category, uploadDate) VALUES ('label', 1, 'description', 'example', 'other', NOW());")
+	require.NoError(t, err)
+
+	applied, err = Up(db, All)
+	require.NoError(t, err)
+	assert.Equal(t, All[1:], applied)
+	isAdmin, email, dateFormat := false, "", ""
+	err = db.QueryRow("SELECT isAdmin, email, dateFormat FROM users WHERE username = 'example';").Scan(&isAdmin, &email, &dateFormat)
+	require.NoError(t, err)
+	assert.Equal(t, "iso", dateFormat)
+	hash := ""
+	err = db.QueryRow("SELECT hash FROM files WHERE owner = 'example';").Scan(&hash)
+	require.NoError(t, err)
+
+	applied, err = Up(db, All)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	states, err := Status(db, All)
+	require.NoError(t, err)
+	for _, s := range states {
+		assert.True(t, s.Applied)
+		assert.False(t, s.Dirty)
+	}
+
+	reverted, err := Down(db, All, len(All))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(All))
+
+	applied, err = Up(db, All)
+	require.NoError(t, err)
+	assert.Equal(t, All, applied)
+}
@@ -0,0 +1,243 @@
+package admin
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/fileops"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+)
+
+// path to admin[/admin] template file
+const pathTemplateAdmin = "pages/admin/template/admin.html"
+
+const (
+	selectUsers = "SELECT username, rating, isAdmin, banned FROM users ORDER BY username;"
+
+	selectFiles = "SELECT id, label, owner, category, uploadDate FROM files ORDER BY uploadDate DESC LIMIT 100;"
+
+	banUser = "UPDATE users SET banned = TRUE WHERE username = ?;"
+
+	unbanUser = "UPDATE users SET banned = FALSE WHERE username = ?;"
+
+	resetUserRating = "UPDATE users SET rating = 0 WHERE username = ?;"
+
+	selectFailedLogins = "SELECT username, ip, attemptTime FROM failedLogins ORDER BY attemptTime DESC LIMIT 100;"
+)
+
+// TemplateAdmin contains data for admin[/admin] page template
+type TemplateAdmin struct {
+	Warning      template.HTML
+	Username     string
+	Users        []UserInfo
+	Files        []FileInfo
+	FailedLogins []FailedLogin
+}
+
+// UserInfo contains user info showed to admin
+type UserInfo struct {
+	Username string
+	Rating   int
+	IsAdmin  bool
+	Banned   bool
+}
+
+// FileInfo contains file info showed to admin
+type FileInfo struct {
+	ID         int
+	Label      string
+	Owner      string
+	Category   string
+	UploadDate string
+}
+
+// FailedLogin contains info about failed login attempt showed to admin
+type FailedLogin struct {
+	Username    string
+	IP          string
+	AttemptTime string
+}
+
+// Page returns HandleFunc for admin[/admin] page.
+// Page should be wrapped by session.AdminWrapper, so only admins can use it.
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for admin page
+		page, err := tmp.CreateFormTemplate(pathTemplateAdmin, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			data, err := adminData(dep)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			warning, err := doAction(dep, r.FormValue("action"), r.FormValue("username"), r.FormValue("id"))
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			data, err := adminData(dep)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			data.Warning = warning
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// doAction executes admin action and returns warning for admin
+func doAction(dep session.Dependency, action, username, id string) (template.HTML, error) {
+	switch action {
+	case "deleteFile":
+		err := fileops.Delete(dep.Db, dep.Storage, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return "<h2 style=\"color:red\">File not found</h2>", nil
+			}
+			return "", err
+		}
+		if fileID, err := strconv.Atoi(id); err == nil {
+			dep.Search.Remove(fileID)
+		}
+		return "<h2 style=\"color:green\">File deleted</h2>", nil
+
+	case "ban":
+		if username == dep.Username {
+			return "<h2 style=\"color:red\">You cannot ban yourself</h2>", nil
+		}
+		_, err := dep.Db.Exec(banUser, username)
+		if err != nil {
+			return "", err
+		}
+		// banned user should lose access immediately
+		err = session.KillSessions(dep.Redis, username)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">User " + template.HTML(template.HTMLEscapeString(username)) + " banned</h2>", nil
+
+	case "unban":
+		_, err := dep.Db.Exec(unbanUser, username)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">User " + template.HTML(template.HTMLEscapeString(username)) + " unbanned</h2>", nil
+
+	case "resetRating":
+		_, err := dep.Db.Exec(resetUserRating, username)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">Rating of user " + template.HTML(template.HTMLEscapeString(username)) + " reset</h2>", nil
+
+	case "killSessions":
+		err := session.KillSessions(dep.Redis, username)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">Sessions of user " + template.HTML(template.HTMLEscapeString(username)) + " killed</h2>", nil
+
+	case "unlock":
+		err := throttle.Reset(dep.Redis, throttle.LoginByUsername, username)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">Login of user " + template.HTML(template.HTMLEscapeString(username)) + " unlocked</h2>", nil
+	}
+
+	return "<h2 style=\"color:red\">Unknown action</h2>", nil
+}
+
+// adminData returns lists of users, files and failed login attempts for admin page
+func adminData(dep session.Dependency) (TemplateAdmin, error) {
+	data := TemplateAdmin{Username: dep.Username}
+
+	rows, err := dep.Db.Query(selectUsers)
+	if err != nil {
+		return TemplateAdmin{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ui := UserInfo{}
+		err := rows.Scan(&ui.Username, &ui.Rating, &ui.IsAdmin, &ui.Banned)
+		if err != nil {
+			return TemplateAdmin{}, err
+		}
+		data.Users = append(data.Users, ui)
+	}
+	err = rows.Err()
+	if err != nil {
+		return TemplateAdmin{}, err
+	}
+
+	rows, err = dep.Db.Query(selectFiles)
+	if err != nil {
+		return TemplateAdmin{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		fi := FileInfo{}
+		uploadDate := time.Time{}
+		err := rows.Scan(&fi.ID, &fi.Label, &fi.Owner, &fi.Category, &uploadDate)
+		if err != nil {
+			return TemplateAdmin{}, err
+		}
+		fi.UploadDate = uploadDate.UTC().Format("2006-01-02 15:04:05")
+		data.Files = append(data.Files, fi)
+	}
+	err = rows.Err()
+	if err != nil {
+		return TemplateAdmin{}, err
+	}
+
+	rows, err = dep.Db.Query(selectFailedLogins)
+	if err != nil {
+		return TemplateAdmin{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		fl := FailedLogin{}
+		attemptTime := time.Time{}
+		err := rows.Scan(&fl.Username, &fl.IP, &attemptTime)
+		if err != nil {
+			return TemplateAdmin{}, err
+		}
+		fl.AttemptTime = attemptTime.UTC().Format("2006-01-02 15:04:05")
+		data.FailedLogins = append(data.FailedLogins, fl)
+	}
+	err = rows.Err()
+	if err != nil {
+		return TemplateAdmin{}, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,354 @@
+package admin_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/admin"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// expectAdminData adds expectations of queries which collect users, files and failed logins lists
+func expectAdminData(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT username, rating, isAdmin, banned FROM users").WillReturnRows(
+		sqlmock.NewRows([]string{"username", "rating", "isAdmin", "banned"}).
+			AddRow("username", 10, true, false).
+			AddRow("spammer", -5, false, true))
+	sqlMock.ExpectQuery("SELECT id, label, owner, category, uploadDate FROM files").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "label", "owner", "category", "uploadDate"}).
+			AddRow(1, "label", "spammer", "other", time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)))
+	sqlMock.ExpectQuery("SELECT username, ip, attemptTime FROM failedLogins").WillReturnRows(
+		sqlmock.NewRows([]string{"username", "ip", "attemptTime"}).
+			AddRow("username", "192.0.2.1", time.Date(2009, 11, 17, 20, 40, 0, 0, time.UTC)))
+}
+
+// postAction sends POST request with admin action
+func postAction(dep session.Dependency, data url.Values) *httptest.ResponseRecorder {
+	sut := admin.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/admin", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+
+	sut(w, r)
+
+	return w
+}
+
+func TestPageSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectAdminData(sqlMock)
+
+	sut := admin.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/admin", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Admin</title>
+    <link rel="stylesheet" href="assets/css/admin.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="cookieCleaner">
+        <a href="/admin/categories">Manage categories</a>
+        <form action="/admin" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="killSessions">
+            <p>Kill all sessions of user: <input required type="text" maxlength="20" name="username"></p>
+            <input type="submit" value="KILL SESSIONS">
+        </form>
+        
+    </div>
+
+    <div class="userList">
+        <h2>Users</h2>
+        <table border="1" width="100%" cellpadding="5">
+            <tr>
+                <th>Username</th>
+                <th>Rating</th>
+                <th>Role</th>
+                <th>Actions</th>
+            </tr>
+            <tr>
+                <td>username</td>
+                <td>10</td>
+                <td>admin</td>
+                <td>
+                    <form action="/admin" method="post">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="username" value="username">
+                        <button type="submit" name="action" value="ban">BAN</button>
+                        <button type="submit" name="action" value="resetRating">RESET RATING</button>
+                        <button type="submit" name="action" value="killSessions">KILL SESSIONS</button>
+                        <button type="submit" name="action" value="unlock">UNLOCK LOGIN</button>
+                    </form>
+                </td>
+            </tr>
+            <tr>
+                <td>spammer</td>
+                <td>-5</td>
+                <td>user (banned)</td>
+                <td>
+                    <form action="/admin" method="post">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="username" value="spammer">
+                        <button type="submit" name="action" value="unban">UNBAN</button>
+                        <button type="submit" name="action" value="resetRating">RESET RATING</button>
+                        <button type="submit" name="action" value="killSessions">KILL SESSIONS</button>
+                        <button type="submit" name="action" value="unlock">UNLOCK LOGIN</button>
+                    </form>
+                </td>
+            </tr>
+        </table>
+    </div>
+
+    <div class="fileList">
+        <h2>Last uploaded files</h2>
+        <table border="1" width="100%" cellpadding="5">
+            <tr>
+                <th>Filename</th>
+                <th>Owner</th>
+                <th>Category</th>
+                <th>Upload date</th>
+                <th>Actions</th>
+            </tr>
+            <tr>
+                <td><a href="/download?id=1">label</a></td>
+                <td>spammer</td>
+                <td>other</td>
+                <td>2009-11-17 20:34:58</td>
+                <td>
+                    <form action="/admin" method="post" onsubmit="return confirm('Delete file?')">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="id" value="1">
+                        <button type="submit" name="action" value="deleteFile">DELETE</button>
+                    </form>
+                </td>
+            </tr>
+        </table>
+    </div>
+
+    <div class="failedLogins">
+        <h2>Failed login attempts</h2>
+        <table border="1" width="100%" cellpadding="5">
+            <tr>
+                <th>Username</th>
+                <th>IP</th>
+                <th>Time (UTC)</th>
+            </tr>
+            <tr>
+                <td>username</td>
+                <td>192.0.2.1</td>
+                <td>2009-11-17 20:40:00</td>
+            </tr>
+        </table>
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT username, rating, isAdmin, banned FROM users").WillReturnError(fmt.Errorf("testing error"))
+
+	sut := admin.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/admin", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageBanSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	sqlMock.ExpectExec("UPDATE users SET banned = TRUE WHERE username").WithArgs("spammer").WillReturnResult(sqlmock.NewResult(0, 1))
+	redisMock.Command("SMEMBERS", "sessions:spammer").Expect([]interface{}{[]byte("cookie")})
+	del := redisMock.Command("DEL", "cookie", "session:cookie").Expect(int64(2))
+	redisMock.Command("DEL", "sessions:spammer").Expect(int64(1))
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "ban")
+	data.Set("username", "spammer")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">User spammer banned</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(del))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageBanYourselfPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "ban")
+	data.Set("username", "username")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">You cannot ban yourself</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageUnbanSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectExec("UPDATE users SET banned = FALSE WHERE username").WithArgs("spammer").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "unban")
+	data.Set("username", "spammer")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">User spammer unbanned</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageResetRatingSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectExec("UPDATE users SET rating = 0 WHERE username").WithArgs("spammer").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "resetRating")
+	data.Set("username", "spammer")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Rating of user spammer reset</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageKillSessionsSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:spammer").Expect([]interface{}{[]byte("cookie1"), []byte("cookie2")})
+	del1 := redisMock.Command("DEL", "cookie1", "session:cookie1").Expect(int64(2))
+	del2 := redisMock.Command("DEL", "cookie2", "session:cookie2").Expect(int64(2))
+	delIndex := redisMock.Command("DEL", "sessions:spammer").Expect(int64(1))
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "killSessions")
+	data.Set("username", "spammer")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Sessions of user spammer killed</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(del1))
+	assert.Equal(t, 1, redisMock.Stats(del2))
+	assert.Equal(t, 1, redisMock.Stats(delIndex))
+}
+
+func TestPageUnlockSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	reset := redisMock.Command("DEL", "failures:login:user:spammer", "lock:login:user:spammer").Expect(int64(2))
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "unlock")
+	data.Set("username", "spammer")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Login of user spammer unlocked</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(reset))
+}
+
+func TestPageKillSessionsRedisErrorPOST(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:spammer").ExpectError(fmt.Errorf("testing error"))
+
+	data := url.Values{}
+	data.Set("action", "killSessions")
+	data.Set("username", "spammer")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageDeleteFileSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectBegin()
+	// file uploaded before blob store was added, its data isn't stored, so storage should not be touched
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner", "rating", "hash"}).AddRow("spammer", -3, ""))
+	sqlMock.ExpectExec("DELETE FROM filesRating").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(-3, "spammer").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "deleteFile")
+	data.Set("id", "1")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">File deleted</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageDeleteNotExistingFilePOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner", "rating", "hash"}))
+	sqlMock.ExpectRollback()
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "deleteFile")
+	data.Set("id", "1")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">File not found</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageUnknownActionPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectAdminData(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "unknown")
+	w := postAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown action</h2>`)
+}
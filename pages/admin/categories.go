@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// CategoriesPath is path of categories management page
+const CategoriesPath = "/admin/categories"
+
+// path to categories management[/admin/categories] template file
+const pathTemplateCategories = "pages/admin/template/categories.html"
+
+// TemplateCategories contains data for categories management[/admin/categories] page template
+type TemplateCategories struct {
+	Warning    template.HTML
+	Username   string
+	Categories []categories.Category // all categories including retired
+	Parents    []categories.Category // top level categories which can be parents
+}
+
+// Categories returns HandleFunc for categories management[/admin/categories] page.
+// Admin can create, rename, merge, retire and restore categories of files.
+// Categories should be wrapped by session.AdminWrapper, so only admins can use it.
+func Categories(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for categories management page
+		page, err := tmp.CreateFormTemplate(pathTemplateCategories, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		list, err := categories.All(dep.Db)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			err = page.Execute(w, categoriesData(dep, list))
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			warning, err := doCategoryAction(dep, r, list)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			// list of categories is changed by action
+			list, err = categories.All(dep.Db)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			data := categoriesData(dep, list)
+			data.Warning = warning
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// doCategoryAction executes admin action with categories and returns warning for admin
+func doCategoryAction(dep session.Dependency, r *http.Request, list []categories.Category) (template.HTML, error) {
+	slug := strings.TrimSpace(r.FormValue("slug"))
+
+	switch r.FormValue("action") {
+	case "create", "update":
+		isNew := r.FormValue("action") == "create"
+		sortOrder, err := strconv.Atoi(strings.TrimSpace(r.FormValue("sortOrder")))
+		if err != nil {
+			return "<h2 style=\"color:red\">Incorrect sort order</h2>", nil
+		}
+		c := categories.Category{
+			Slug:        slug,
+			Name:        strings.TrimSpace(r.FormValue("name")),
+			Description: strings.TrimSpace(r.FormValue("description")),
+			SortOrder:   sortOrder,
+			Parent:      r.FormValue("parent"),
+		}
+		err = categories.Validate(c, list, isNew)
+		if err != nil {
+			return "<h2 style=\"color:red\">" + template.HTML(template.HTMLEscapeString(err.Error())) + "</h2>", nil
+		}
+
+		if isNew {
+			err = categories.Create(dep.Db, c)
+			if err != nil {
+				return "", err
+			}
+			return "<h2 style=\"color:green\">Category " + template.HTML(template.HTMLEscapeString(slug)) + " created</h2>", nil
+		}
+		err = categories.Update(dep.Db, c)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">Category " + template.HTML(template.HTMLEscapeString(slug)) + " saved</h2>", nil
+
+	case "retire", "restore":
+		if _, ok := categories.Find(list, slug); !ok {
+			return "<h2 style=\"color:red\">Unknown category</h2>", nil
+		}
+		retired := r.FormValue("action") == "retire"
+		err := categories.SetRetired(dep.Db, slug, retired)
+		if err != nil {
+			return "", err
+		}
+		if retired {
+			return "<h2 style=\"color:green\">Category " + template.HTML(template.HTMLEscapeString(slug)) + " retired</h2>", nil
+		}
+		return "<h2 style=\"color:green\">Category " + template.HTML(template.HTMLEscapeString(slug)) + " restored</h2>", nil
+
+	case "merge":
+		from, to := r.FormValue("from"), r.FormValue("to")
+		err := categories.ValidateMerge(from, to, list)
+		if err != nil {
+			return "<h2 style=\"color:red\">" + template.HTML(template.HTMLEscapeString(err.Error())) + "</h2>", nil
+		}
+		err = categories.Merge(dep.Db, from, to)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:green\">Category " + template.HTML(template.HTMLEscapeString(from)) + " merged into " + template.HTML(template.HTMLEscapeString(to)) + "</h2>", nil
+	}
+
+	return "<h2 style=\"color:red\">Unknown action</h2>", nil
+}
+
+// categoriesData returns data for categories management page
+func categoriesData(dep session.Dependency, list []categories.Category) TemplateCategories {
+	data := TemplateCategories{Username: dep.Username, Categories: list}
+	for _, c := range list {
+		if c.Parent == "" {
+			data.Parents = append(data.Parents, c)
+		}
+	}
+	return data
+}
@@ -0,0 +1,231 @@
+package admin_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/admin"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// postCategoryAction sends POST request with admin action on categories
+func postCategoryAction(dep session.Dependency, data url.Values) *httptest.ResponseRecorder {
+	sut := admin.Categories(dep)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/admin/categories", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+
+	sut(w, r)
+
+	return w
+}
+
+func TestCategoriesSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(
+		sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).
+			AddRow("other", "Other", "", 10, "", false).
+			AddRow("music", "Music", "", 20, "", false).
+			AddRow("jazz", "Jazz", "", 30, "music", false).
+			AddRow("old", "Old", "", 40, "", true))
+
+	sut := admin.Categories(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/admin/categories", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `<td><a href="/categories/old">old</a> (retired)</td>`)
+	assert.Contains(t, body, `<button type="submit" name="action" value="restore">RESTORE</button>`)
+	assert.Contains(t, body, `<option value="music" selected>Music</option>`)
+	// subcategory cannot be parent
+	assert.NotContains(t, body, `<option value="jazz">Jazz</option>
+                    </select></td>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnError(fmt.Errorf("testing error"))
+
+	sut := admin.Categories(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/admin/categories", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestCategoriesCreateSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectExec("INSERT INTO categories").WithArgs("jazz", "Jazz", "", 60, "music").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "create")
+	data.Set("slug", "jazz")
+	data.Set("name", "Jazz")
+	data.Set("sortOrder", "60")
+	data.Set("parent", "music")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Category jazz created</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesCreateExistingPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "create")
+	data.Set("slug", "music")
+	data.Set("name", "Music")
+	data.Set("sortOrder", "60")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Category &#34;music&#34; already exists</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesIncorrectSortOrderPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "update")
+	data.Set("slug", "music")
+	data.Set("name", "Music")
+	data.Set("sortOrder", "first")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Incorrect sort order</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesUpdateSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectExec("UPDATE categories SET name").WithArgs("Music and sounds", "", 5, "", "music").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "update")
+	data.Set("slug", "music")
+	data.Set("name", "Music and sounds")
+	data.Set("sortOrder", "5")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Category music saved</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesRetireSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectExec("UPDATE categories SET retired").WithArgs(true, "games").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "retire")
+	data.Set("slug", "games")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Category games retired</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesRetireUnknownPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "retire")
+	data.Set("slug", "unknown")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown category</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesMergeSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET category").WithArgs("other", "games").WillReturnResult(sqlmock.NewResult(0, 3))
+	sqlMock.ExpectExec("UPDATE partialUploads SET category").WithArgs("other", "games").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("UPDATE categories SET parent").WithArgs("other", "games").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM categories").WithArgs("games").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "merge")
+	data.Set("from", "games")
+	data.Set("to", "other")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Category games merged into other</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesMergeIntoItselfPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "merge")
+	data.Set("from", "games")
+	data.Set("to", "games")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Category cannot be merged into itself</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCategoriesDBErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectExec("UPDATE categories SET retired").WithArgs(false, "games").WillReturnError(fmt.Errorf("testing error"))
+
+	data := url.Values{}
+	data.Set("action", "restore")
+	data.Set("slug", "games")
+	w := postCategoryAction(dep, data)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+)
+
+// Prefix is path prefix of all JSON API[/api/v1/] endpoints
+const Prefix = types.Prefix
+
+// handler handles API request, params contains values of {parameters} from route pattern
+type handler func(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string)
+
+// route relates HTTP method and path pattern (relative to Prefix) with handler.
+// Path segments in braces (e.g. {id}) match any non-empty segment.
+// Requests authorized by API token can use route only if token has scope of route.
+// Route without scope is public, its requests are handled without authorization.
+type route struct {
+	method  string
+	pattern string
+	scope   string
+	handler handler
+}
+
+// routes contains all API endpoints, each of them should be described in OpenAPI document
+var routes = []route{
+	{"GET", "/me", apitokens.ScopeRead, me},
+	{"GET", "/files", apitokens.ScopeRead, listFiles},
+	{"POST", "/files", apitokens.ScopeUpload, uploadFile},
+	{"GET", "/files/{id}", apitokens.ScopeRead, getFile},
+	{"DELETE", "/files/{id}", apitokens.ScopeDelete, deleteFile},
+	{"GET", "/files/{id}/content", apitokens.ScopeRead, downloadFile},
+	{"POST", "/files/{id}/vote", apitokens.ScopeVote, voteFile},
+	{"GET", "/search", apitokens.ScopeRead, searchFiles},
+	{"OPTIONS", "/uploads", "", resumableUpload}, // tus clients discover server capabilities before authorization
+	{"POST", "/uploads", apitokens.ScopeUpload, resumableUpload},
+	{"OPTIONS", "/uploads/{id}", "", resumableUpload},
+	{"HEAD", "/uploads/{id}", apitokens.ScopeUpload, resumableUpload},
+	{"PATCH", "/uploads/{id}", apitokens.ScopeUpload, resumableUpload},
+	{"DELETE", "/uploads/{id}", apitokens.ScopeUpload, resumableUpload},
+	{"GET", "/categories", apitokens.ScopeRead, listCategories},
+	{"GET", "/users/{username}", apitokens.ScopeRead, getUser},
+	{"GET", "/leaderboard", apitokens.ScopeRead, leaderboard},
+}
+
+// Wrapper returns HandleFunc for JSON API[/api/v1/*endpoint*] which authorizes requests by session.APIWrapper.
+// Requests to public routes are passed to Handler without authorization.
+func Wrapper(dep session.Dependency) http.HandlerFunc {
+	authorized := session.APIWrapper(Handler, dep)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if public(r) {
+			Handler(dep)(w, r)
+			return
+		}
+		authorized(w, r)
+	}
+}
+
+// public checks if request is sent to route without scope
+func public(r *http.Request) bool {
+	path := "/" + strings.TrimSuffix(r.URL.Path[len(Prefix):], "/")
+	for _, rt := range routes {
+		if _, ok := match(rt.pattern, path); ok && rt.method == r.Method && rt.scope == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns HandleFunc for JSON API[/api/v1/*endpoint*].
+// Handler should be wrapped by Wrapper, so only authorized users can use not public routes.
+func Handler(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := "/" + strings.TrimSuffix(r.URL.Path[len(Prefix):], "/")
+
+		allowed := []string{}
+		for _, rt := range routes {
+			params, ok := match(rt.pattern, path)
+			if !ok {
+				continue
+			}
+			// HEAD request is handled by GET handler if route doesn't have own HEAD handler,
+			// response body will be discarded by server
+			if rt.method == r.Method || (r.Method == "HEAD" && rt.method == "GET") {
+				if !dep.Allowed(rt.scope) {
+					errhand.JSONError(w, http.StatusForbidden, fmt.Sprintf("API token doesn't have %q scope", rt.scope))
+					return
+				}
+				rt.handler(dep, w, r, params)
+				return
+			}
+			allowed = append(allowed, rt.method)
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			errhand.JSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		errhand.JSONError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// match checks if path matches route pattern and returns values of pattern parameters
+func match(pattern, path string) ([]string, bool) {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+
+	params := []string{}
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params = append(params, pathParts[i])
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// writeJSON writes inputted value as JSON response with inputted HTTP status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// me handles GET /me request
+func me(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	writeJSON(w, http.StatusOK, types.Me{Username: dep.Username, CSRFToken: dep.CSRFToken})
+}
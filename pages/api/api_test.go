@@ -0,0 +1,552 @@
+package api_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/api"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+const (
+	// hash of file which creates in files directory for download tests, it differs from real hash of content to avoid collisions with files created by other packages tests
+	testFileHash = "a91e000000000000000000000000000000000000000000000000000000000001"
+	// content of file uploaded by tests
+	uploadData = "api upload data"
+)
+
+var uploadDate = time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)
+
+// fileRows returns rows of file info query with one file
+func fileRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "label", "filesizeBytes", "description", "owner", "category", "uploadDate", "rating"}).
+		AddRow(1, "label", 1024, "description", "owner", "music", uploadDate, 10)
+}
+
+// request sends request to API handler and returns response
+func request(dep session.Dependency, method, path string, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	api.Handler(dep)(w, r)
+
+	return w
+}
+
+func TestMeSuccess(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := request(dep, "GET", "/api/v1/me", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	test.AssertBodyEqual(t, `{"username":"username","csrfToken":"csrf"}`+"\n", w.Body)
+}
+
+func TestUnknownEndpoint(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := request(dep, "GET", "/api/v1/unknown", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":404,"message":"Not found"}}`+"\n", w.Body)
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := request(dep, "DELETE", "/api/v1/files", "")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, POST", w.Header().Get("Allow"))
+	test.AssertBodyEqual(t, `{"error":{"status":405,"message":"Method not allowed"}}`+"\n", w.Body)
+}
+
+func TestAPITokenWithoutScope(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	dep.Scopes = []string{"read"}
+
+	w := request(dep, "POST", "/api/v1/files/1/vote", `{"rating": 5}`)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":403,"message":"API token doesn't have \"vote\" scope"}}`+"\n", w.Body)
+}
+
+func TestListFilesSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files ;").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(16))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files ORDER BY uploadDate DESC LIMIT").WithArgs(15, 15).WillReturnRows(fileRows())
+
+	w := request(dep, "GET", "/api/v1/files?page=2", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"files":[{"id":1,"label":"label","filesizeBytes":1024,"description":"description","owner":"owner","category":"music","uploadDate":"2009-11-17T20:34:58Z","rating":10}],"page":2,"pages":2,"total":16}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestListFilesPopularByCategorySuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows().AddRow("jazz", "Jazz", "", 60, "music", false))
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE rating > 0 AND category IN \\(\\?, \\?\\) AND owner = \\?;").WithArgs("music", "jazz", "owner").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE rating > 0 AND category IN \\(\\?, \\?\\) AND owner = \\? ORDER BY rating DESC, uploadDate DESC LIMIT").WithArgs("music", "jazz", "owner", 0, 15).WillReturnRows(fileRows().AddRow(2, "label2", 10, "", "owner", "jazz", uploadDate, 1))
+
+	w := request(dep, "GET", "/api/v1/files?sort=popular&category=music&owner=owner", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"category":"jazz"`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestListFilesEmpty(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files").WillReturnRows(sqlmock.NewRows([]string{"id", "label", "filesizeBytes", "description", "owner", "category", "uploadDate", "rating"}))
+
+	w := request(dep, "GET", "/api/v1/files", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"files":[],"page":1,"pages":1,"total":0}`+"\n", w.Body)
+}
+
+func TestListFilesBadRequest(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	for query, message := range map[string]string{
+		"sort=oldest":      "Unknown sort order",
+		"page=0":           "Incorrect page number",
+		"page=first":       "Incorrect page number",
+		"category=unknown": "Unknown category",
+	} {
+		w := request(dep, "GET", "/api/v1/files?"+query, "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		test.AssertBodyEqual(t, `{"error":{"status":400,"message":"`+message+`"}}`+"\n", w.Body)
+	}
+}
+
+func TestListFilesDBError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT").WillReturnError(fmt.Errorf("testing error"))
+
+	w := request(dep, "GET", "/api/v1/files", "")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":500,"message":"Internal error. Please try later"}}`+"\n", w.Body)
+}
+
+func TestGetFileSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("1").WillReturnRows(fileRows())
+	sqlMock.ExpectQuery("SELECT tags.name FROM file_tags").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jazz"))
+
+	w := request(dep, "GET", "/api/v1/files/1", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"id":1,"label":"label","filesizeBytes":1024,"description":"description","owner":"owner","category":"music","uploadDate":"2009-11-17T20:34:58Z","rating":10,"tags":["jazz"]}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetFileNotFound(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("2").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	for _, path := range []string{"/api/v1/files/2", "/api/v1/files/abc"} {
+		w := request(dep, "GET", path, "")
+
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		test.AssertBodyEqual(t, `{"error":{"status":404,"message":"File not found"}}`+"\n", w.Body)
+	}
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDownloadFileSuccess(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/api")
+	require.NoError(t, ioutil.WriteFile("files/"+testFileHash, []byte("file content"), 0644))
+	defer os.Remove("files/" + testFileHash)
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"label", "mimeType", "originalName", "hash", "uploadDate"}).AddRow("label", "text/plain; charset=utf-8", "file.txt", testFileHash, uploadDate))
+
+	w := request(dep, "GET", "/api/v1/files/1/content", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="label.txt"; filename*=UTF-8''label.txt`, w.Header().Get("Content-Disposition"))
+	test.AssertBodyEqual(t, "file content", w.Body)
+}
+
+func TestDownloadFileNotFound(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs("2").WillReturnRows(sqlmock.NewRows([]string{"label"}))
+
+	w := request(dep, "GET", "/api/v1/files/2/content", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":404,"message":"File not found"}}`+"\n", w.Body)
+}
+
+// uploadRequest sends multipart upload request with inputted form fields and file
+func uploadRequest(t *testing.T, dep session.Dependency, fields map[string]string) *httptest.ResponseRecorder {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for name, value := range fields {
+		require.NoError(t, mw.WriteField(name, value))
+	}
+	part, err := mw.CreateFormFile("file", "file.txt")
+	require.NoError(t, err)
+	_, err = part.Write([]byte(uploadData))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	r, err := http.NewRequest("POST", "http://localhost/api/v1/files", body)
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	api.Handler(dep)(w, r)
+
+	return w
+}
+
+func TestUploadFileSuccess(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/api")
+	sum := sha256.Sum256([]byte(uploadData))
+	hash := hex.EncodeToString(sum[:])
+	defer os.Remove("files/" + hash)
+
+	dep, sqlMock, _ := test.NewDep(t)
+	dep.Clock = func() time.Time { return uploadDate }
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("music").WillReturnRows(
+		sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).AddRow("music", "Music", "", 50, "", false))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(hash, len(uploadData)).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO files").WithArgs("label", len(uploadData), "description", "username", "music", "2009-11-17 20:34:58", "text/plain; charset=utf-8", "file.txt", hash).WillReturnResult(sqlmock.NewResult(7, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("jazz").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs(int64(7), "jazz").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+
+	w := uploadRequest(t, dep, map[string]string{"label": "label", "description": "description", "category": "music", "tags": "Jazz"})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/api/v1/files/7", w.Header().Get("Location"))
+	test.AssertBodyEqual(t, `{"id":7,"label":"label","filesizeBytes":15,"description":"description","owner":"username","category":"music","uploadDate":"2009-11-17T20:34:58Z","rating":0,"tags":["jazz"]}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestUploadFileTagsDBError tests case when file info isn't saved because tags cannot be saved
+func TestUploadFileTagsDBError(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/api")
+	sum := sha256.Sum256([]byte(uploadData))
+	hash := hex.EncodeToString(sum[:])
+	defer os.Remove("files/" + hash)
+
+	dep, sqlMock, _ := test.NewDep(t)
+	dep.Clock = func() time.Time { return uploadDate }
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("music").WillReturnRows(
+		sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).AddRow("music", "Music", "", 50, "", false))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(hash, len(uploadData)).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO files").WillReturnResult(sqlmock.NewResult(7, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags").WithArgs(int64(7)).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+	// blob reference of not created file is released
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs").WithArgs(hash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(hash).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	w := uploadRequest(t, dep, map[string]string{"label": "label", "description": "description", "category": "music", "tags": "Jazz"})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUploadFileRetiredCategory(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug =").WithArgs("other").WillReturnRows(
+		sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).AddRow("other", "Other", "", 10, "", true))
+
+	w := uploadRequest(t, dep, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":400,"message":"Unknown category"}}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestUploadFileTooLongLabel(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := uploadRequest(t, dep, map[string]string{"label": strings.Repeat("a", 51)})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":400,"message":"Filename are too long"}}`+"\n", w.Body)
+}
+
+func TestUploadFileWithoutFile(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := request(dep, "POST", "/api/v1/files", "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":400,"message":"File is required"}}`+"\n", w.Body)
+}
+
+func TestDeleteFileSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "label", "filesizeBytes", "description", "owner", "category", "uploadDate", "rating"}).
+			AddRow(1, "label", 1024, "description", "username", "music", uploadDate, 0))
+	sqlMock.ExpectBegin()
+	// file uploaded before blob store was added, its data doesn't exist in test storage
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner", "rating", "hash"}).AddRow("username", 0, ""))
+	sqlMock.ExpectExec("DELETE FROM filesRating").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM file_tags").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	w := request(dep, "DELETE", "/api/v1/files/1", "")
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestDeleteFileNotOwner(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("1").WillReturnRows(fileRows())
+
+	w := request(dep, "DELETE", "/api/v1/files/1", "")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":403,"message":"Only owner can delete file"}}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSearchFilesSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+	// search index isn't used, so FULLTEXT index of MySQL is used
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE MATCH(.+) AND owner = \\?;").WithArgs("jazz", "owner").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE MATCH(.+) AND owner = \\? ORDER BY MATCH(.+) LIMIT").WithArgs("jazz", "owner", "jazz", 0, 15).WillReturnRows(fileRows())
+
+	w := request(dep, "GET", "/api/v1/search?q=jazz&owner=owner", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"files":[{"id":1,"label":"label","filesizeBytes":1024,"description":"description","owner":"owner","category":"music","uploadDate":"2009-11-17T20:34:58Z","rating":10}],"page":1,"pages":1,"total":1}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSearchFilesBadRequest(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+
+	for query, message := range map[string]string{
+		"":                     "Please input search parameters",
+		"q=jazz&page=0":        "Incorrect page number",
+		"q=jazz&from=17.11.09": "Incorrect date. Please use format YYYY-MM-DD",
+	} {
+		w := request(dep, "GET", "/api/v1/search?"+query, "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		test.AssertBodyEqual(t, `{"error":{"status":400,"message":"`+message+`"}}`+"\n", w.Body)
+	}
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumableUploadOptionsWithoutAuthorization(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	for _, path := range []string{"/api/v1/uploads", "/api/v1/uploads/unknown"} {
+		r, err := http.NewRequest("OPTIONS", "http://localhost"+path, nil)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+
+		// request doesn't have session cookie or API token
+		api.Wrapper(dep)(w, r)
+
+		assert.Equal(t, http.StatusNoContent, w.Code, path)
+		assert.Equal(t, "1.0.0", w.Header().Get("Tus-Resumable"), path)
+		assert.Equal(t, "1.0.0", w.Header().Get("Tus-Version"), path)
+		assert.Equal(t, "creation,termination", w.Header().Get("Tus-Extension"), path)
+		assert.Equal(t, "1073741824", w.Header().Get("Tus-Max-Size"), path)
+	}
+}
+
+func TestWrapperNotAuthorized(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	r, err := http.NewRequest("POST", "http://localhost/api/v1/uploads", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+
+	api.Wrapper(dep)(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":401,"message":"Unauthorized"}}`+"\n", w.Body)
+}
+
+func TestResumableUploadUnknown(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	r, err := http.NewRequest("HEAD", "http://localhost/api/v1/uploads/unknown", nil)
+	require.NoError(t, err)
+	r.Header.Set("Tus-Resumable", "1.0.0")
+	w := httptest.NewRecorder()
+
+	api.Handler(dep)(w, r)
+
+	// HEAD request is handled by tus handler instead of GET handler
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "1.0.0", w.Header().Get("Tus-Resumable"))
+}
+
+func TestVoteFileSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("1").WillReturnRows(fileRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnError(sql.ErrNoRows)
+	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 5).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 5, "1").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, 5, "owner").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "label", "filesizeBytes", "description", "owner", "category", "uploadDate", "rating"}).
+			AddRow(1, "label", 1024, "description", "owner", "music", uploadDate, 15))
+	sqlMock.ExpectQuery("SELECT tags.name FROM file_tags").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	w := request(dep, "POST", "/api/v1/files/1/vote", `{"rating": 5}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"id":1,"label":"label","filesizeBytes":1024,"description":"description","owner":"owner","category":"music","uploadDate":"2009-11-17T20:34:58Z","rating":15,"tags":[]}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestVoteFileBadRequest(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	for body, message := range map[string]string{
+		`{"rating": 11}`:  "Rating should be from -10 to 10",
+		`{"rating": -11}`: "Rating should be from -10 to 10",
+		`rating=5`:        "Incorrect request body",
+	} {
+		w := request(dep, "POST", "/api/v1/files/1/vote", body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		test.AssertBodyEqual(t, `{"error":{"status":400,"message":"`+message+`"}}`+"\n", w.Body)
+	}
+}
+
+func TestVoteFileNotFound(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id =").WithArgs("2").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := request(dep, "POST", "/api/v1/files/2/vote", `{"rating": 5}`)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":404,"message":"File not found"}}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestListCategoriesSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(
+		sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).
+			AddRow("music", "Music", "Songs", 10, "", false).
+			AddRow("jazz", "Jazz", "", 20, "music", true))
+
+	w := request(dep, "GET", "/api/v1/categories", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"categories":[{"slug":"music","name":"Music","description":"Songs","parent":"","retired":false},{"slug":"jazz","name":"Jazz","description":"","parent":"music","retired":true}]}`+"\n", w.Body)
+}
+
+func TestGetUserSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT rating, createdAt FROM users WHERE username =").WithArgs("owner").WillReturnRows(
+		sqlmock.NewRows([]string{"rating", "createdAt"}).AddRow(25, uploadDate))
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\), COALESCE\\(SUM\\(filesizeBytes\\), 0\\) FROM files WHERE owner =").WithArgs("owner").WillReturnRows(
+		sqlmock.NewRows([]string{"count", "sum"}).AddRow(3, 4096))
+
+	w := request(dep, "GET", "/api/v1/users/owner", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"username":"owner","rating":25,"joinDate":"2009-11-17T20:34:58Z","uploads":3,"uploadedBytes":4096}`+"\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT rating, createdAt FROM users WHERE username =").WithArgs("unknown").WillReturnRows(sqlmock.NewRows([]string{"rating", "createdAt"}))
+
+	w := request(dep, "GET", "/api/v1/users/unknown", "")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":404,"message":"User doesn't exist"}}`+"\n", w.Body)
+}
+
+func TestLeaderboardSuccess(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT username, rating FROM users ORDER BY rating DESC LIMIT 15;").WillReturnRows(
+		sqlmock.NewRows([]string{"username", "rating"}).AddRow("owner", 25).AddRow("username", 3))
+
+	w := request(dep, "GET", "/api/v1/leaderboard", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, `{"users":[{"username":"owner","rating":25},{"username":"username","rating":3}]}`+"\n", w.Body)
+}
+
+func TestLeaderboardRowsError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT username, rating FROM users ORDER BY rating DESC LIMIT 15;").WillReturnRows(
+		sqlmock.NewRows([]string{"username", "rating"}).AddRow("owner", 25).AddRow("username", 3).RowError(1, fmt.Errorf("testing error")))
+
+	w := request(dep, "GET", "/api/v1/leaderboard", "")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":500,"message":"Internal error. Please try later"}}`+"\n", w.Body)
+}
+
+func TestOpenAPI(t *testing.T) {
+	r, err := http.NewRequest("GET", "http://localhost"+api.OpenAPIPath, nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+
+	api.OpenAPI(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"openapi": "3.0.3"`)
+}
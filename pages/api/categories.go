@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+)
+
+// listCategories handles GET /categories request
+func listCategories(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	categoriesList, err := categories.All(dep.Db)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+
+	list := types.CategoryList{Categories: []types.Category{}}
+	for _, c := range categoriesList {
+		list.Categories = append(list.Categories, types.Category{Slug: c.Slug, Name: c.Name, Description: c.Description, Parent: c.Parent, Retired: c.Retired})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,369 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/fileops"
+	"github.com/vpoletaev11/fileHostingSite/pages/file"
+	"github.com/vpoletaev11/fileHostingSite/pages/search"
+	"github.com/vpoletaev11/fileHostingSite/pages/upload"
+	"github.com/vpoletaev11/fileHostingSite/pagination"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+	"github.com/vpoletaev11/fileHostingSite/tags"
+)
+
+const (
+	// %s is replaced by WHERE clause, it can be empty
+	selectFiles = "SELECT " + dbformat.FileInfoColumns + " FROM files %s ORDER BY %s LIMIT ?, ?;"
+
+	countFiles = "SELECT COUNT(*) FROM files %s;"
+
+	// clauses of search query are added to the end
+	selectFilesFrom = "SELECT " + dbformat.FileInfoColumns + " FROM files"
+
+	selectFile = "SELECT " + dbformat.FileInfoColumns + " FROM files WHERE id = ?;"
+)
+
+const filesInPage = 15 // how many files will be returned in one page of files list
+
+// sorts contains ORDER BY clauses of files list sorting orders
+var sorts = map[string]string{
+	"recent":  "uploadDate DESC",
+	"popular": "rating DESC, uploadDate DESC",
+}
+
+// listFiles handles GET /files request.
+// Files can be sorted by upload date (sort=recent) or rating (sort=popular, only files with positive rating)
+// and filtered by category (files of subcategories are included) and owner.
+func listFiles(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	query := r.URL.Query()
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "recent"
+	}
+	orderBy, ok := sorts[sort]
+	if !ok {
+		errhand.JSONError(w, http.StatusBadRequest, "Unknown sort order")
+		return
+	}
+
+	numPage, err := pageNumber(r)
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	if sort == "popular" {
+		conditions = append(conditions, "rating > 0")
+	}
+	if category := query.Get("category"); category != "" {
+		categoriesList, err := categories.All(dep.Db)
+		if err != nil {
+			errhand.JSONInternalError(err, w)
+			return
+		}
+		if _, ok := categories.Find(categoriesList, category); !ok {
+			errhand.JSONError(w, http.StatusBadRequest, categories.ErrUnknown.Error())
+			return
+		}
+		slugs := categories.WithChildren(categoriesList, category)
+		conditions = append(conditions, "category IN (?"+strings.Repeat(", ?", len(slugs)-1)+")")
+		for _, slug := range slugs {
+			args = append(args, slug)
+		}
+	}
+	if owner := query.Get("owner"); owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, owner)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	list := types.FileList{Page: numPage}
+	err = dep.Db.QueryRow(fmt.Sprintf(countFiles, where), args...).Scan(&list.Total)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	list.Pages = pagination.PagesCount(list.Total, filesInPage)
+
+	list.Files, err = dbformat.Files(dep.Db, fmt.Sprintf(selectFiles, where, orderBy), append(args, (numPage-1)*filesInPage, filesInPage)...)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// searchFiles handles GET /search request, it accepts the same parameters as search[/search] page
+// (q, category, owner, minSize, maxSize, from, to, sort) and page number.
+func searchFiles(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	filter := search.NewFilter(r)
+	if filter.Empty() {
+		errhand.JSONError(w, http.StatusBadRequest, "Please input search parameters")
+		return
+	}
+	numPage, err := pageNumber(r)
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	categoriesList, err := categories.All(dep.Db)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	// dates of filter are in timezone of user
+	location, err := dbformat.UserLocation(dep.Db, dep.Username)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+
+	list := types.FileList{Files: []dbformat.File{}, Page: numPage}
+	query, err := search.NewQuery(filter, dep.Search, location, categoriesList)
+	if err == search.ErrNothingFound {
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list.Total, err = query.Count(dep.Db)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	list.Pages = pagination.PagesCount(list.Total, filesInPage)
+
+	clauses, args := query.Page(numPage, filesInPage)
+	list.Files, err = dbformat.Files(dep.Db, selectFilesFrom+clauses, args...)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// pageNumber returns number of files list page from page parameter of request, the first page is used by default
+func pageNumber(r *http.Request) (int, error) {
+	page := r.URL.Query().Get("page")
+	if page == "" {
+		return 1, nil
+	}
+	numPage, err := strconv.Atoi(page)
+	if err != nil || numPage < 1 {
+		return 0, fmt.Errorf("Incorrect page number")
+	}
+	return numPage, nil
+}
+
+// getFile handles GET /files/{id} request
+func getFile(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	f, err := fileWithTags(dep.Db, params[0])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errhand.JSONError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// downloadFile handles GET /files/{id}/content request, it sends uploaded file the same way as file[/file] handler
+func downloadFile(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	err := file.Serve(dep, w, r, params[0])
+	if err != nil {
+		if err == sql.ErrNoRows || err == storage.ErrNotExist {
+			errhand.JSONError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+}
+
+// uploadFile handles POST /files request with multipart form:
+// file - uploaded file, label - name of file (original filename if empty),
+// description, category (default category if empty) and tags separated by commas.
+func uploadFile(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	uploaded, header, err := r.FormFile("file")
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	defer uploaded.Close()
+
+	meta := fileops.Meta{
+		Label:        r.FormValue("label"),
+		Description:  r.FormValue("description"),
+		Category:     r.FormValue("category"),
+		OriginalName: header.Filename,
+	}
+	if meta.Label == "" {
+		meta.Label = header.Filename
+	}
+	if meta.Category == "" {
+		meta.Category = categories.Default
+	}
+
+	// size of uploaded data is checked again while it's stored, header.Size is only used for early rejection
+	err = fileops.Validate(header.Size, meta.Label, meta.Description)
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = categories.Check(dep.Db, meta.Category)
+	if err != nil {
+		if err == categories.ErrUnknown {
+			errhand.JSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	meta.Tags, err = tags.Parse(r.FormValue("tags"))
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// file info and tags are saved in one transaction, so file isn't created without its tags
+	created, err := fileops.Create(dep, meta, uploaded)
+	if err != nil {
+		if err == fileops.ErrTooLarge {
+			errhand.JSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	f := types.File{FileInfo: created, Tags: meta.Tags}
+	if f.Tags == nil {
+		f.Tags = []string{}
+	}
+
+	w.Header().Set("Location", Prefix+"files/"+strconv.Itoa(f.ID))
+	writeJSON(w, http.StatusCreated, f)
+}
+
+// deleteFile handles DELETE /files/{id} request, only owner can delete file
+func deleteFile(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	f, err := oneFile(dep.Db, params[0])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errhand.JSONError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	if f.Owner != dep.Username {
+		errhand.JSONError(w, http.StatusForbidden, "Only owner can delete file")
+		return
+	}
+
+	err = fileops.Delete(dep.Db, dep.Storage, params[0])
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	dep.Search.Remove(f.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resumableUpload handles requests of tus protocol to /uploads and /uploads/{id} the same way as
+// resumable upload[/upload/resumable/] handler does, so errors of these requests are not in JSON format.
+func resumableUpload(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	id := ""
+	if len(params) > 0 {
+		id = params[0]
+	}
+	upload.ServeResumable(dep, w, r, Prefix+"uploads/", id)
+}
+
+// voteFile handles POST /files/{id}/vote request with JSON body {"rating": *rating*}.
+// It returns file info with changed rating.
+func voteFile(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	vote := types.Vote{}
+	err := json.NewDecoder(r.Body).Decode(&vote)
+	if err != nil {
+		errhand.JSONError(w, http.StatusBadRequest, "Incorrect request body")
+		return
+	}
+	if vote.Rating > fileops.MaxRating || vote.Rating < fileops.MinRating {
+		errhand.JSONError(w, http.StatusBadRequest, fmt.Sprintf("Rating should be from %d to %d", fileops.MinRating, fileops.MaxRating))
+		return
+	}
+
+	// file should exist before voting, otherwise vote for not existing file will be stored
+	_, err = oneFile(dep.Db, params[0])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errhand.JSONError(w, http.StatusNotFound, "File not found")
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+
+	err = fileops.Rate(dep.Db, params[0], dep.Username, vote.Rating)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+
+	f, err := fileWithTags(dep.Db, params[0])
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// oneFile returns info of file with inputted id.
+// If id isn't an integer or file doesn't exist oneFile returns sql.ErrNoRows.
+func oneFile(db *sql.DB, id string) (dbformat.File, error) {
+	_, err := strconv.Atoi(id)
+	if err != nil {
+		return dbformat.File{}, sql.ErrNoRows
+	}
+	return dbformat.OneFile(db, selectFile, id)
+}
+
+// fileWithTags returns info and tags of file with inputted id.
+// If file doesn't exist fileWithTags returns sql.ErrNoRows.
+func fileWithTags(db *sql.DB, id string) (types.File, error) {
+	fi, err := oneFile(db, id)
+	if err != nil {
+		return types.File{}, err
+	}
+	fileTags, err := tags.ForFile(db, id)
+	if err != nil {
+		return types.File{}, err
+	}
+	if fileTags == nil {
+		fileTags = []string{}
+	}
+	return types.File{FileInfo: fi, Tags: fileTags}, nil
+}
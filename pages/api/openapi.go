@@ -0,0 +1,413 @@
+package api
+
+import (
+	"net/http"
+)
+
+// OpenAPIPath is path of OpenAPI document of JSON API, it's available without authorization
+const OpenAPIPath = Prefix + "openapi.json"
+
+// openAPIDocument describes all routes of JSON API, tests check that it matches routes list
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "File hosting site API",
+    "version": "1.0.0",
+    "description": "JSON API which mirrors HTML pages of file hosting site. Requests are authorized by session cookie received on login or by personal API token sent in Authorization: Bearer header. POST requests authorized by session cookie should contain CSRF token (see GET /me) in X-CSRF-Token header. API token can be used only for operations which x-scope is granted to token."
+  },
+  "servers": [{"url": "/api/v1"}],
+  "security": [{"sessionCookie": []}, {"bearerAuth": []}],
+  "paths": {
+    "/me": {
+      "get": {
+        "summary": "Authorized user",
+
+        "x-scope": "read",
+        "responses": {
+          "200": {"description": "Username and CSRF token of session", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Me"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/files": {
+      "get": {
+        "summary": "List of files",
+
+        "x-scope": "read",
+        "parameters": [
+          {"name": "sort", "in": "query", "description": "recent (by upload date) or popular (by rating, only files with positive rating)", "schema": {"type": "string", "enum": ["recent", "popular"], "default": "recent"}},
+          {"name": "category", "in": "query", "description": "Slug of category, files of subcategories are included", "schema": {"type": "string"}},
+          {"name": "owner", "in": "query", "description": "Username of files owner", "schema": {"type": "string"}},
+          {"name": "page", "in": "query", "description": "Number of page, each page contains 15 files", "schema": {"type": "integer", "minimum": 1, "default": 1}}
+        ],
+        "responses": {
+          "200": {"description": "Page of files list", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/FileList"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Upload file",
+
+        "x-scope": "upload",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "multipart/form-data": {
+              "schema": {
+                "type": "object",
+                "required": ["file"],
+                "properties": {
+                  "file": {"type": "string", "format": "binary"},
+                  "label": {"type": "string", "maxLength": 50, "description": "Original filename is used if empty"},
+                  "description": {"type": "string", "maxLength": 500},
+                  "category": {"type": "string", "description": "Slug of active category, default category is used if empty"},
+                  "tags": {"type": "string", "description": "Tags separated by commas"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "201": {"description": "Uploaded file", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/File"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/files/{id}": {
+      "get": {
+        "summary": "File info",
+
+        "x-scope": "read",
+        "parameters": [{"$ref": "#/components/parameters/FileID"}],
+        "responses": {
+          "200": {"description": "File info with tags", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/File"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete file",
+        "x-scope": "delete",
+        "parameters": [{"$ref": "#/components/parameters/FileID"}],
+        "responses": {
+          "204": {"description": "File is deleted"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/files/{id}/content": {
+      "get": {
+        "summary": "Download file",
+
+        "x-scope": "read",
+        "parameters": [{"$ref": "#/components/parameters/FileID"}],
+        "responses": {
+          "200": {"description": "File content, Range requests are supported", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+          "206": {"description": "Part of file content", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/files/{id}/vote": {
+      "post": {
+        "summary": "Rate file",
+
+        "x-scope": "vote",
+        "parameters": [{"$ref": "#/components/parameters/FileID"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Vote"}}}},
+        "responses": {
+          "200": {"description": "File info with changed rating", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/File"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/search": {
+      "get": {
+        "summary": "Search files",
+        "x-scope": "read",
+        "description": "Parameters are the same as parameters of search page, at least one of them (except sort and page) should be set.",
+        "parameters": [
+          {"name": "q", "in": "query", "description": "Text searched in label, description and owner of files", "schema": {"type": "string", "maxLength": 100}},
+          {"name": "category", "in": "query", "description": "Slug of category, files of subcategories are included", "schema": {"type": "string"}},
+          {"name": "owner", "in": "query", "description": "Username of files owner", "schema": {"type": "string"}},
+          {"name": "minSize", "in": "query", "description": "Minimal file size in MB", "schema": {"type": "number"}},
+          {"name": "maxSize", "in": "query", "description": "Maximal file size in MB", "schema": {"type": "number"}},
+          {"name": "from", "in": "query", "description": "Files uploaded since date (YYYY-MM-DD) in timezone of user", "schema": {"type": "string", "format": "date"}},
+          {"name": "to", "in": "query", "description": "Files uploaded until date (YYYY-MM-DD) in timezone of user", "schema": {"type": "string", "format": "date"}},
+          {"name": "sort", "in": "query", "description": "relevance is used only with text query, otherwise the newest files are first", "schema": {"type": "string", "enum": ["relevance", "date", "rating"], "default": "relevance"}},
+          {"name": "page", "in": "query", "description": "Number of page, each page contains 15 files", "schema": {"type": "integer", "minimum": 1, "default": 1}}
+        ],
+        "responses": {
+          "200": {"description": "Page of found files", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/FileList"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/uploads": {
+      "options": {
+        "summary": "Capabilities of resumable uploads",
+        "x-scope": "",
+        "description": "Authorization isn't needed, tus clients discover supported protocol versions and extensions before upload.",
+        "security": [],
+        "responses": {
+          "204": {"description": "Supported tus versions are in Tus-Version header, extensions in Tus-Extension header, maximal file size in Tus-Max-Size header"}
+        }
+      },
+      "post": {
+        "summary": "Create resumable upload",
+        "x-scope": "upload",
+        "description": "Resumable uploads implement tus.io 1.0.0 core protocol with creation and termination extensions. Errors of tus requests are returned as plain text.",
+        "parameters": [
+          {"$ref": "#/components/parameters/TusResumable"},
+          {"name": "Upload-Length", "in": "header", "required": true, "schema": {"type": "integer", "maximum": 1073741824}},
+          {"name": "Upload-Metadata", "in": "header", "description": "Comma separated pairs of key and base64 encoded value, keys: filename, label, description, category", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "201": {"description": "Upload is created, its URL is in Location header"},
+          "400": {"description": "Incorrect upload length, metadata or file info"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "412": {"description": "Unsupported version of tus protocol"},
+          "413": {"description": "File is larger than 1GB"}
+        }
+      }
+    },
+    "/uploads/{id}": {
+      "options": {
+        "summary": "Capabilities of resumable uploads",
+        "x-scope": "",
+        "description": "Authorization isn't needed, tus clients discover supported protocol versions and extensions before upload.",
+        "security": [],
+        "parameters": [{"$ref": "#/components/parameters/UploadID"}],
+        "responses": {
+          "204": {"description": "Supported tus versions are in Tus-Version header, extensions in Tus-Extension header, maximal file size in Tus-Max-Size header"}
+        }
+      },
+      "head": {
+        "summary": "Offset of resumable upload",
+        "x-scope": "upload",
+        "parameters": [{"$ref": "#/components/parameters/UploadID"}, {"$ref": "#/components/parameters/TusResumable"}],
+        "responses": {
+          "200": {"description": "Count of received bytes is in Upload-Offset header, file size is in Upload-Length header"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"description": "Upload not found"}
+        }
+      },
+      "patch": {
+        "summary": "Send part of file",
+        "x-scope": "upload",
+        "parameters": [
+          {"$ref": "#/components/parameters/UploadID"},
+          {"$ref": "#/components/parameters/TusResumable"},
+          {"name": "Upload-Offset", "in": "header", "required": true, "description": "Offset of sent data, it should be equal to count of received bytes", "schema": {"type": "integer"}}
+        ],
+        "requestBody": {"required": true, "content": {"application/offset+octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {
+          "204": {"description": "New offset is in Upload-Offset header. When the last part is received file is uploaded and its ID is in Upload-File-ID header"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"description": "Upload not found"},
+          "409": {"description": "Upload-Offset doesn't match count of received bytes"},
+          "415": {"description": "Content-Type isn't application/offset+octet-stream"}
+        }
+      },
+      "delete": {
+        "summary": "Cancel resumable upload",
+        "x-scope": "upload",
+        "parameters": [{"$ref": "#/components/parameters/UploadID"}, {"$ref": "#/components/parameters/TusResumable"}],
+        "responses": {
+          "204": {"description": "Upload is cancelled"},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"description": "Upload not found"}
+        }
+      }
+    },
+    "/categories": {
+      "get": {
+        "summary": "List of categories",
+
+        "x-scope": "read",
+        "responses": {
+          "200": {"description": "All categories including retired", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CategoryList"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/users/{username}": {
+      "get": {
+        "summary": "User profile",
+
+        "x-scope": "read",
+        "parameters": [{"name": "username", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {
+          "200": {"description": "Public information about user", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/leaderboard": {
+      "get": {
+        "summary": "Users with the highest rating",
+
+        "x-scope": "read",
+        "responses": {
+          "200": {"description": "Top 15 users", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Leaderboard"}}}},
+          "401": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "sessionCookie": {"type": "apiKey", "in": "cookie", "name": "session_id"},
+      "bearerAuth": {"type": "http", "scheme": "bearer", "description": "Personal API token created on /tokens page"}
+    },
+    "parameters": {
+      "FileID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "UploadID": {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}},
+      "TusResumable": {"name": "Tus-Resumable", "in": "header", "required": true, "schema": {"type": "string", "enum": ["1.0.0"]}}
+    },
+    "responses": {
+      "Error": {"description": "Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {
+            "type": "object",
+            "required": ["status", "message"],
+            "properties": {
+              "status": {"type": "integer"},
+              "message": {"type": "string"}
+            }
+          }
+        }
+      },
+      "Me": {
+        "type": "object",
+        "required": ["username", "csrfToken"],
+        "properties": {
+          "username": {"type": "string"},
+          "csrfToken": {"type": "string"}
+        }
+      },
+      "FileInfo": {
+        "type": "object",
+        "required": ["id", "label", "filesizeBytes", "description", "owner", "category", "uploadDate", "rating"],
+        "properties": {
+          "id": {"type": "integer"},
+          "label": {"type": "string"},
+          "filesizeBytes": {"type": "integer"},
+          "description": {"type": "string"},
+          "owner": {"type": "string"},
+          "category": {"type": "string"},
+          "uploadDate": {"type": "string", "format": "date-time"},
+          "rating": {"type": "integer"}
+        }
+      },
+      "File": {
+        "allOf": [
+          {"$ref": "#/components/schemas/FileInfo"},
+          {"type": "object", "required": ["tags"], "properties": {"tags": {"type": "array", "items": {"type": "string"}}}}
+        ]
+      },
+      "FileList": {
+        "type": "object",
+        "required": ["files", "page", "pages", "total"],
+        "properties": {
+          "files": {"type": "array", "items": {"$ref": "#/components/schemas/FileInfo"}},
+          "page": {"type": "integer"},
+          "pages": {"type": "integer"},
+          "total": {"type": "integer"}
+        }
+      },
+      "Vote": {
+        "type": "object",
+        "required": ["rating"],
+        "properties": {
+          "rating": {"type": "integer", "minimum": -10, "maximum": 10}
+        }
+      },
+      "Category": {
+        "type": "object",
+        "required": ["slug", "name", "description", "parent", "retired"],
+        "properties": {
+          "slug": {"type": "string"},
+          "name": {"type": "string"},
+          "description": {"type": "string"},
+          "parent": {"type": "string", "description": "Slug of parent category, empty for top level categories"},
+          "retired": {"type": "boolean"}
+        }
+      },
+      "CategoryList": {
+        "type": "object",
+        "required": ["categories"],
+        "properties": {
+          "categories": {"type": "array", "items": {"$ref": "#/components/schemas/Category"}}
+        }
+      },
+      "User": {
+        "type": "object",
+        "required": ["username", "rating", "joinDate", "uploads", "uploadedBytes"],
+        "properties": {
+          "username": {"type": "string"},
+          "rating": {"type": "integer"},
+          "joinDate": {"type": "string", "format": "date-time"},
+          "uploads": {"type": "integer"},
+          "uploadedBytes": {"type": "integer"}
+        }
+      },
+      "Leaderboard": {
+        "type": "object",
+        "required": ["users"],
+        "properties": {
+          "users": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["username", "rating"],
+              "properties": {
+                "username": {"type": "string"},
+                "rating": {"type": "integer"}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// OpenAPI is HandleFunc for OpenAPI document[/api/v1/openapi.json] of JSON API
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(openAPIDocument))
+		return
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+)
+
+// document is part of OpenAPI document checked by tests
+type document struct {
+	OpenAPI    string                              `json:"openapi"`
+	Paths      map[string]map[string]operation     `json:"paths"`
+	Components struct{ Schemas map[string]schema } `json:"components"`
+}
+
+type operation struct {
+	Scope     string                 `json:"x-scope"`
+	Responses map[string]interface{} `json:"responses"`
+}
+
+type schema struct {
+	Required []string `json:"required"`
+	AllOf    []schema `json:"allOf"`
+	Ref      string   `json:"$ref"`
+}
+
+func parseDocument(t *testing.T) document {
+	doc := document{}
+	require.NoError(t, json.Unmarshal([]byte(openAPIDocument), &doc))
+	return doc
+}
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	doc := parseDocument(t)
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	documented := []string{}
+	for path, operations := range doc.Paths {
+		for method, op := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path+" "+op.Scope)
+			assert.NotEmpty(t, op.Responses, "responses of %s %s", method, path)
+		}
+	}
+	implemented := []string{}
+	for _, rt := range routes {
+		implemented = append(implemented, rt.method+" "+rt.pattern+" "+rt.scope)
+	}
+	sort.Strings(documented)
+	sort.Strings(implemented)
+
+	assert.Equal(t, implemented, documented)
+}
+
+func TestOpenAPIReferencesExist(t *testing.T) {
+	raw := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(openAPIDocument), &raw))
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				if key == "$ref" {
+					target := interface{}(raw)
+					for _, part := range strings.Split(strings.TrimPrefix(value.(string), "#/"), "/") {
+						object, ok := target.(map[string]interface{})
+						require.True(t, ok, "reference %s", value)
+						target, ok = object[part]
+						require.True(t, ok, "reference %s", value)
+					}
+					continue
+				}
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(raw)
+}
+
+// requiredFields returns required properties of schema including properties of allOf schemas
+func requiredFields(doc document, s schema) []string {
+	if s.Ref != "" {
+		return requiredFields(doc, doc.Components.Schemas[s.Ref[len("#/components/schemas/"):]])
+	}
+	fields := append([]string{}, s.Required...)
+	for _, sub := range s.AllOf {
+		fields = append(fields, requiredFields(doc, sub)...)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// jsonFields returns names of fields of value encoded to JSON object
+func jsonFields(t *testing.T, v interface{}) []string {
+	encoded, err := json.Marshal(v)
+	require.NoError(t, err)
+	object := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(encoded, &object))
+
+	fields := []string{}
+	for field := range object {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestOpenAPISchemasMatchResponses(t *testing.T) {
+	doc := parseDocument(t)
+
+	for name, v := range map[string]interface{}{
+		"Error":        types.ErrorBody{},
+		"Me":           types.Me{},
+		"FileInfo":     types.FileInfo{},
+		"File":         types.File{},
+		"FileList":     types.FileList{},
+		"Vote":         types.Vote{},
+		"Category":     types.Category{},
+		"CategoryList": types.CategoryList{},
+		"User":         types.User{},
+		"Leaderboard":  types.Leaderboard{},
+	} {
+		s, ok := doc.Components.Schemas[name]
+		require.True(t, ok, "schema %s", name)
+		assert.Equal(t, jsonFields(t, v), requiredFields(doc, s), "schema %s", name)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+)
+
+const (
+	selectUser = "SELECT rating, createdAt FROM users WHERE username = ?;"
+
+	selectUploadsStats = "SELECT COUNT(*), COALESCE(SUM(filesizeBytes), 0) FROM files WHERE owner = ?;"
+
+	selectLeaderboard = "SELECT username, rating FROM users ORDER BY rating DESC LIMIT 15;"
+)
+
+// getUser handles GET /users/{username} request
+func getUser(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	u := types.User{Username: params[0]}
+	err := dep.Db.QueryRow(selectUser, u.Username).Scan(&u.Rating, &u.JoinDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			errhand.JSONError(w, http.StatusNotFound, "User doesn't exist")
+			return
+		}
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	u.JoinDate = u.JoinDate.UTC()
+
+	err = dep.Db.QueryRow(selectUploadsStats, u.Username).Scan(&u.Uploads, &u.UploadedBytes)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// leaderboard handles GET /leaderboard request, it returns the same users as users[/users] page
+func leaderboard(dep session.Dependency, w http.ResponseWriter, r *http.Request, params []string) {
+	rows, err := dep.Db.Query(selectLeaderboard)
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	defer rows.Close()
+
+	board := types.Leaderboard{Users: []types.LeaderboardEntry{}}
+	for rows.Next() {
+		entry := types.LeaderboardEntry{}
+		err := rows.Scan(&entry.Username, &entry.Rating)
+		if err != nil {
+			errhand.JSONInternalError(err, w)
+			return
+		}
+		board.Users = append(board.Users, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		errhand.JSONInternalError(err, w)
+		return
+	}
+	writeJSON(w, http.StatusOK, board)
+}
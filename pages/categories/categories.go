@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
-	"strconv"
+	"strings"
 
+	filecategories "github.com/vpoletaev11/fileHostingSite/categories"
 	"github.com/vpoletaev11/fileHostingSite/dbformat"
 	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/pagination"
 	"github.com/vpoletaev11/fileHostingSite/session"
 	"github.com/vpoletaev11/fileHostingSite/tmp"
 )
@@ -22,20 +24,19 @@ const (
 )
 
 const (
-	selectFileInfo = "SELECT * FROM files WHERE category = ? ORDER BY uploadDate DESC LIMIT ?, ?;"
+	// %s is replaced by placeholders of category and its subcategories
+	selectFileInfo = "SELECT " + dbformat.FileInfoColumns + " FROM files WHERE category IN (%s) ORDER BY uploadDate DESC LIMIT ?, ?;"
 
-	countRows = "SELECT COUNT(*) FROM files WHERE category = ?;"
+	countRows = "SELECT COUNT(*) FROM files WHERE category IN (%s);"
 )
 
-const (
-	rowsInPage       = 15 // how many rows of file info will be displayed on page
-	maxLinksInNavBar = 25 // how many links will be displayed on navigation bar
-)
+const rowsInPage = 15 // how many rows of file info will be displayed on page
 
 // TemplateCategories contains data for categories[/categories/] page template
 type TemplateCategories struct {
-	Warning  template.HTML
-	Username string
+	Warning    template.HTML
+	Username   string
+	Categories []filecategories.Category // tree of active categories
 }
 
 // TemplateAnyCategory contains data for any category[/categories/*any category*] template
@@ -43,16 +44,12 @@ type TemplateAnyCategory struct {
 	Warning       template.HTML
 	Username      string
 	Title         string
-	LinkList      []numLink
+	Description   string
+	Subcategories []filecategories.Category
+	LinkList      []pagination.Link
 	UploadedFiles []dbformat.FileInfo
 }
 
-// numLink contains relations of page number and page link
-type numLink struct {
-	NumPage int
-	Link    string
-}
-
 // anyCategoryPageHandler handling any category[/categories/*any category*] page
 func anyCategoryPageHandler(dep session.Dependency, w http.ResponseWriter, r *http.Request) {
 	page, err := tmp.CreateTemplate(pathTemplateAnyCategory)
@@ -61,25 +58,33 @@ func anyCategoryPageHandler(dep session.Dependency, w http.ResponseWriter, r *ht
 		return
 	}
 
-	// getting category
-	link := r.URL.Path[len("/categories/"):]
-	switch link {
-	case "other", "games", "documents", "projects", "music":
-	default:
+	// getting category, retired categories are still available to view old files
+	categoriesList, err := filecategories.All(dep.Db)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+	category, ok := filecategories.Find(categoriesList, r.URL.Path[len("/categories/"):])
+	if !ok {
 		fmt.Fprintln(w, "ERROR: Incorrect category")
 		return
 	}
-	category := link
+	// files of subcategories are showed together with files of category
+	args := []interface{}{}
+	for _, slug := range filecategories.WithChildren(categoriesList, category.Slug) {
+		args = append(args, slug)
+	}
+	placeholders := "?" + strings.Repeat(", ?", len(args)-1)
 
 	// getting count of pages
-	pagesCount, err := pagesCount(dep.Db, category)
+	pagesCount, err := pagesCount(dep.Db, placeholders, args)
 	if err != nil {
 		errhand.InternalError(err, w)
 		return
 	}
 
 	// getting number of current page
-	numPage, err := numPage(r)
+	numPage, err := pagination.NumPage(r)
 	if err != nil {
 		fmt.Fprintln(w, "ERROR: Incorrect get request")
 		return
@@ -91,14 +96,21 @@ func anyCategoryPageHandler(dep session.Dependency, w http.ResponseWriter, r *ht
 	}
 
 	// getting files info for current page
-	fiCollection, err := dbformat.FormatedFilesInfo(dep.Username, dep.Db, selectFileInfo, category, (numPage-1)*rowsInPage, numPage*rowsInPage)
+	fiCollection, err := dbformat.FormatedFilesInfo(dep.Username, dep.Db, fmt.Sprintf(selectFileInfo, placeholders), append(args, (numPage-1)*rowsInPage, rowsInPage)...)
 	if err != nil {
 		errhand.InternalError(err, w)
 		return
 	}
 
+	data := TemplateAnyCategory{Username: dep.Username, UploadedFiles: fiCollection, Title: category.Name, Description: category.Description}
+	for _, c := range categoriesList {
+		if c.Parent == category.Slug && !c.Retired {
+			data.Subcategories = append(data.Subcategories, c)
+		}
+	}
+
 	if pagesCount == 1 {
-		err := page.Execute(w, TemplateAnyCategory{Username: dep.Username, UploadedFiles: fiCollection, Title: r.URL.Path[len("/categories/"):]})
+		err := page.Execute(w, data)
 		if err != nil {
 			errhand.InternalError(err, w)
 			return
@@ -107,8 +119,8 @@ func anyCategoryPageHandler(dep session.Dependency, w http.ResponseWriter, r *ht
 	}
 
 	// creating navigation bar if count of pages > 1
-	numsLinks := navigationBar(pagesCount, numPage, category)
-	err = page.Execute(w, TemplateAnyCategory{Username: dep.Username, UploadedFiles: fiCollection, LinkList: numsLinks, Title: r.URL.Path[len("/categories/"):]})
+	data.LinkList = pagination.NavigationBar(pagesCount, numPage, "/categories/"+category.Slug+"?p=")
+	err = page.Execute(w, data)
 	if err != nil {
 		errhand.InternalError(err, w)
 		return
@@ -128,7 +140,12 @@ func Page(dep session.Dependency) http.HandlerFunc {
 		switch r.Method {
 		case "GET":
 			if r.URL.Path[len("/categories/"):] == "" {
-				err := page.Execute(w, TemplateCategories{Username: dep.Username})
+				categoriesList, err := filecategories.Active(dep.Db)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				err = page.Execute(w, TemplateCategories{Username: dep.Username, Categories: filecategories.Tree(categoriesList)})
 				if err != nil {
 					errhand.InternalError(err, w)
 				}
@@ -141,75 +158,11 @@ func Page(dep session.Dependency) http.HandlerFunc {
 }
 
 // pagesCount returns pages count calculated from count MySQL database file info rows
-func pagesCount(db *sql.DB, category string) (int, error) {
+func pagesCount(db *sql.DB, placeholders string, args []interface{}) (int, error) {
 	rowsCount := 0
-	err := db.QueryRow(countRows, category).Scan(&rowsCount)
+	err := db.QueryRow(fmt.Sprintf(countRows, placeholders), args...).Scan(&rowsCount)
 	if err != nil {
 		return 0, err
 	}
-	pagesCount := (rowsCount-1)/rowsInPage + 1
-
-	return pagesCount, nil
-}
-
-// numPage gets number of page from GET request
-func numPage(r *http.Request) (int, error) {
-	numPageStr := r.URL.Query().Get("p")
-	if numPageStr == "" {
-		return 1, nil
-	}
-	numPage := 0
-	numPage, err := strconv.Atoi(numPageStr)
-	if err != nil {
-		return 0, err
-	}
-	if numPage <= 0 {
-		return 0, fmt.Errorf("Incorrect page number")
-	}
-
-	return numPage, nil
-}
-
-// navigationBar returns array with relations of page number and page link, where page number == page link
-func navigationBar(pagesCount, numPage int, category string) []numLink {
-	var numsLinks []numLink
-	if pagesCount > maxLinksInNavBar {
-		// add the first link (literally 1)
-		link := "/categories/" + category + "?p=" + "1"
-		numsLinks = append(numsLinks, numLink{NumPage: 1, Link: link})
-
-		switch {
-		case numPage < 10:
-			for i := 2; i <= maxLinksInNavBar; i++ {
-				pageNum := strconv.Itoa(i)
-				link := "/categories/" + category + "?p=" + pageNum
-				numsLinks = append(numsLinks, numLink{NumPage: i, Link: link})
-			}
-		case numPage >= pagesCount-15:
-			for i := numPage - 5; i <= pagesCount-1; i++ {
-				pageNum := strconv.Itoa(i)
-				link := "/categories/" + category + "?p=" + pageNum
-				numsLinks = append(numsLinks, numLink{NumPage: i, Link: link})
-			}
-		default:
-			for i := numPage - 5; i <= numPage+15; i++ {
-				pageNum := strconv.Itoa(i)
-				link := "/categories/" + category + "?p=" + pageNum
-				numsLinks = append(numsLinks, numLink{NumPage: i, Link: link})
-			}
-
-		}
-
-		// add the last link == len(pagesCount)
-		link = "/categories/" + category + "?p=" + strconv.Itoa(pagesCount)
-		numsLinks = append(numsLinks, numLink{NumPage: pagesCount, Link: link})
-
-	} else {
-		for i := 1; i <= pagesCount; i++ {
-			pageNum := strconv.Itoa(i)
-			link := "/categories/" + category + "?p=" + pageNum
-			numsLinks = append(numsLinks, numLink{NumPage: i, Link: link})
-		}
-	}
-	return numsLinks
+	return pagination.PagesCount(rowsCount, rowsInPage), nil
 }
@@ -19,7 +19,8 @@ const (
 
 // TestPageSuccessGET checks workability of GET requests handler in Page()
 func TestPageSuccessGET(t *testing.T) {
-	dep, _, _ := test.NewDep(t)
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE;").WillReturnRows(test.CategoryRows())
 	sut := categories.Page(dep)
 
 	w := httptest.NewRecorder()
@@ -53,6 +54,8 @@ func TestPageSuccessGET(t *testing.T) {
         <li><a href="/categories/documents" class="categoryLink">Documents</a></li>
         <li><a href="/categories/projects" class="categoryLink">Projects</a></li>
         <li><a href="/categories/music" class="categoryLink">Music</a></li>
+        <li><a href="/tags" class="categoryLink">Tags</a></li>
+        <li><a href="/search" class="categoryLink">Search</a></li>
     </ul>
 </body>`, w.Body)
 }
@@ -60,6 +63,7 @@ func TestPageSuccessGET(t *testing.T) {
 // TestPageSuccessGET checks workability of GET requests handler in Page()
 func TestPageAnyCategorySuccessGET(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(1))
 
@@ -74,7 +78,7 @@ func TestPageAnyCategorySuccessGET(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE category =").WithArgs("other", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("other", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -84,7 +88,7 @@ func TestPageAnyCategorySuccessGET(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := categories.Page(dep)
 
@@ -100,7 +104,7 @@ func TestPageAnyCategorySuccessGET(t *testing.T) {
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <title>other</title>
+    <title>Other</title>
     <link rel="stylesheet" href="/assets/css/anyCategory.css">
 <head>
 <body bgcolor=#f1ded3>
@@ -149,6 +153,7 @@ func TestPageAnyCategorySuccessGET(t *testing.T) {
 
 func TestPageAnyCategoryFewPagesInPageBarSuccess(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(rowsInPage * 3))
 
@@ -163,7 +168,7 @@ func TestPageAnyCategoryFewPagesInPageBarSuccess(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE category =").WithArgs("other", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("other", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -173,7 +178,7 @@ func TestPageAnyCategoryFewPagesInPageBarSuccess(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := categories.Page(dep)
 
@@ -189,7 +194,7 @@ func TestPageAnyCategoryFewPagesInPageBarSuccess(t *testing.T) {
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <title>other</title>
+    <title>Other</title>
     <link rel="stylesheet" href="/assets/css/anyCategory.css">
 <head>
 <body bgcolor=#f1ded3>
@@ -244,6 +249,7 @@ func TestPageAnyCategoryFewPagesInPageBarSuccess(t *testing.T) {
 
 func TestPageAnyCategoryAlotPagesInPageBarSuccess(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(rowsInPage * 30))
 
@@ -258,7 +264,7 @@ func TestPageAnyCategoryAlotPagesInPageBarSuccess(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE category =").WithArgs("other", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("other", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -268,7 +274,7 @@ func TestPageAnyCategoryAlotPagesInPageBarSuccess(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := categories.Page(dep)
 
@@ -284,7 +290,7 @@ func TestPageAnyCategoryAlotPagesInPageBarSuccess(t *testing.T) {
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <title>other</title>
+    <title>Other</title>
     <link rel="stylesheet" href="/assets/css/anyCategory.css">
 <head>
 <body bgcolor=#f1ded3>
@@ -385,6 +391,7 @@ func TestPageAnyCategoryAlotPagesInPageBarSuccess(t *testing.T) {
 
 func TestPageAnyCategoryAlotPagesInPageBarDefaultCaseSuccess(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(rowsInPage * 30))
 
@@ -399,7 +406,7 @@ func TestPageAnyCategoryAlotPagesInPageBarDefaultCaseSuccess(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE category =").WithArgs("other", 15*rowsInPage, 16*rowsInPage).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("other", 15*rowsInPage, rowsInPage).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -409,7 +416,7 @@ func TestPageAnyCategoryAlotPagesInPageBarDefaultCaseSuccess(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := categories.Page(dep)
 
@@ -425,7 +432,7 @@ func TestPageAnyCategoryAlotPagesInPageBarDefaultCaseSuccess(t *testing.T) {
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <title>other</title>
+    <title>Other</title>
     <link rel="stylesheet" href="/assets/css/anyCategory.css">
 <head>
 <body bgcolor=#f1ded3>
@@ -516,6 +523,7 @@ func TestPageAnyCategoryAlotPagesInPageBarDefaultCaseSuccess(t *testing.T) {
 
 func TestPageAnyCategoryAlotPagesInPagesBarNumPage1Success(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(rowsInPage * 30))
 
@@ -530,7 +538,7 @@ func TestPageAnyCategoryAlotPagesInPagesBarNumPage1Success(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE category =").WithArgs("other", 10*rowsInPage, 11*rowsInPage).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("other", 10*rowsInPage, rowsInPage).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -540,7 +548,7 @@ func TestPageAnyCategoryAlotPagesInPagesBarNumPage1Success(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := categories.Page(dep)
 
@@ -556,7 +564,7 @@ func TestPageAnyCategoryAlotPagesInPagesBarNumPage1Success(t *testing.T) {
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <title>other</title>
+    <title>Other</title>
     <link rel="stylesheet" href="/assets/css/anyCategory.css">
 <head>
 <body bgcolor=#f1ded3>
@@ -650,7 +658,8 @@ func TestPageAnyCategoryAlotPagesInPagesBarNumPage1Success(t *testing.T) {
 }
 
 func TestPageAnyCategoryWrongCategory(t *testing.T) {
-	dep, _, _ := test.NewDep(t)
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	sut := categories.Page(dep)
 
 	w := httptest.NewRecorder()
@@ -664,6 +673,7 @@ func TestPageAnyCategoryWrongCategory(t *testing.T) {
 
 func TestPageAnyCategoryPagesCountError(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnError(fmt.Errorf("testing error"))
 
 	sut := categories.Page(dep)
@@ -681,6 +691,7 @@ func TestPageAnyCategoryPagesCountError(t *testing.T) {
 
 func TestPageAnyCategoryWrongPage(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(1))
 
@@ -699,6 +710,7 @@ func TestPageAnyCategoryWrongPage(t *testing.T) {
 
 func TestPageAnyCategoryWrongPageLowerThanZero(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(1))
 
@@ -717,6 +729,7 @@ func TestPageAnyCategoryWrongPageLowerThanZero(t *testing.T) {
 
 func TestPageAnyCategoryNumPageBiggerThanPagesCount(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(1))
 
@@ -735,10 +748,11 @@ func TestPageAnyCategoryNumPageBiggerThanPagesCount(t *testing.T) {
 
 func TestPageAnyCategorySuccessFileInfoGatheringError(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
 	row := []string{"count"}
 	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("other").WillReturnRows(sqlmock.NewRows(row).AddRow(1))
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE category =").WithArgs("other", 0, 15).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("other", 0, 15).WillReturnError(fmt.Errorf("testing error"))
 
 	sut := categories.Page(dep)
 
@@ -752,3 +766,28 @@ func TestPageAnyCategorySuccessFileInfoGatheringError(t *testing.T) {
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
 }
+
+func TestPageAnyCategoryWithSubcategoriesSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).
+		AddRow("music", "Music", "Songs and albums", 10, "", false).
+		AddRow("jazz", "Jazz", "", 20, "music", false).
+		AddRow("blues", "Blues", "", 30, "music", true))
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE category IN \\(\\?, \\?, \\?\\);").WithArgs("music", "jazz", "blues").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN").WithArgs("music", "jazz", "blues", 0, 15).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	sut := categories.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/categories/music", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+
+	body := w.Body.String()
+	require.Contains(t, body, `<title>Music</title>`)
+	require.Contains(t, body, `<div class="categoryDescription">Songs and albums</div>`)
+	// retired subcategory isn't showed, but its files are showed
+	require.Contains(t, body, `<div class="subcategories">Subcategories: <a href="/categories/jazz">Jazz</a></div>`)
+}
@@ -1,6 +1,7 @@
 package download_test
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -18,7 +19,7 @@ import (
 
 func TestPageSuccessGET(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE id").WithArgs("1").WillReturnRows(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id").WithArgs("1").WillReturnRows(
 		sqlmock.NewRows([]string{
 			"id",
 			"label",
@@ -39,12 +40,16 @@ func TestPageSuccessGET(t *testing.T) {
 			100,
 		))
 
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username").WithArgs("username").WillReturnRows(
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
 		sqlmock.NewRows([]string{
 			"timezone",
+			"dateFormat",
 		}).AddRow(
 			"Europe/Moscow",
+			"iso",
 		))
+	sqlMock.ExpectQuery("SELECT tags.name FROM file_tags").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"name"}).AddRow("jazz").AddRow("rock"))
 
 	sut := download.Page(dep)
 
@@ -80,11 +85,13 @@ func TestPageSuccessGET(t *testing.T) {
         <div class="description"><h2>Description: description</h2></div>
         <div class="owner"><h2>Owner: owner</h2></div>
         <div class="category"><h2>Category: other</h2></div>
+        <div class="tags"><h2>Tags: <a class="tag" href="/tags/jazz">jazz</a> <a class="tag" href="/tags/rock">rock</a></h2></div>
         <div class="uploadDate"><h2>Upload date: 2009-11-17 23:34:58</h2></div>
         <div class="rating"><h2>Rating: 100</h2></div>
 
         <div class="setRating">
             <form  action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                     Set rating:
                             <select name="rating">
                                 <option value="-10">-10</option>
@@ -113,23 +120,138 @@ func TestPageSuccessGET(t *testing.T) {
         </div>
 
         <div class="download">
-            <a href="/files/1" download=><h1>download</h1></a>
+            <a href="/file?id=1" download=><h1>download</h1></a>
         </div>
     </div>
 </body>`, w.Body)
 }
 
-func TestPageSettingRatingSuccessPOST(t *testing.T) {
+func TestPageOwnerSuccessGET(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id").WithArgs("1").WillReturnRows(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id").WithArgs("1").WillReturnRows(
 		sqlmock.NewRows([]string{
+			"id",
+			"label",
+			"filesizeBytes",
+			"description",
 			"owner",
+			"category",
+			"uploadDate",
+			"rating",
 		}).AddRow(
-			"owner",
+			1,
+			"label",
+			1000,
+			"description",
+			"username",
+			"other",
+			time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
+			100,
 		))
-	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(10, "owner").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{
+			"timezone",
+			"dateFormat",
+		}).AddRow(
+			"Europe/Moscow",
+			"iso",
+		))
+	sqlMock.ExpectQuery("SELECT tags.name FROM file_tags").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"name"}))
+
+	sut := download.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/download?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Download</title>
+    <link rel="stylesheet" href="assets/css/download.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="fileInfo">
+        <div class="filename"><h2>Filename: label</h2></div>
+        <div class="filesize"><h2>Filesize: 0.000954 MB</h2></div>
+        <div class="description"><h2>Description: description</h2></div>
+        <div class="owner"><h2>Owner: username</h2></div>
+        <div class="category"><h2>Category: other</h2></div>
+        <div class="uploadDate"><h2>Upload date: 2009-11-17 23:34:58</h2></div>
+        <div class="rating"><h2>Rating: 100</h2></div>
+
+        <div class="setRating">
+            <form  action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
+                    Set rating:
+                            <select name="rating">
+                                <option value="-10">-10</option>
+                                <option value="-9">-9</option>
+                                <option value="-8">-8</option>
+                                <option value="-7">-7</option>
+                                <option value="-6">-6</option>
+                                <option value="-5">-5</option>
+                                <option value="-4">-4</option>
+                                <option value="-3">-3</option>
+                                <option value="-2">-2</option>
+                                <option value="-1">-1</option>
+                                <option value="1">1</option>
+                                <option value="2">2</option>
+                                <option value="3">3</option>
+                                <option value="4">4</option>
+                                <option value="5">5</option>
+                                <option value="6">6</option>
+                                <option value="7">7</option>
+                                <option value="8">8</option>
+                                <option value="9">9</option>
+                                <option value="10">10</option>
+                            </select>
+                <input type="submit" value="VOTE">
+            </form>
+        </div>
+
+        <div class="download">
+            <a href="/file?id=1" download=><h1>download</h1></a>
+        </div>
+
+        <div class="ownerActions">
+            <a href="/edit?id=1"><h2>edit</h2></a>
+            <form action="/edit?id=1" method="post" onsubmit="return confirm('Delete file?')">
+                <input type="hidden" name="csrf_token" value="csrf">
+                <input type="hidden" name="action" value="delete">
+                <input type="submit" value="DELETE FILE">
+            </form>
+        </div>
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageSettingRatingSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnError(sql.ErrNoRows)
+	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, 10, "owner").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
 
 	sut := download.Page(dep)
 
@@ -145,26 +267,18 @@ func TestPageSettingRatingSuccessPOST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingSuccessPOST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"rating",
-		}).AddRow(
-			"0",
-		))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE filesRating SET rating").WithArgs(10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"owner",
-		}).AddRow(
-			"owner",
-		))
-	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, 10, "owner").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(5))
+	sqlMock.ExpectExec("UPDATE filesRating SET rating=(.+) WHERE fileID=(.+) AND voter").WithArgs(10, "1", "username").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(5, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(5, 10, "owner").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
 
 	sut := download.Page(dep)
 
@@ -180,17 +294,15 @@ func TestPageUpdatingRatingSuccessPOST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingSameRatingSuccessPOST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"rating",
-		}).AddRow(
-			"10",
-		))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(10))
+	sqlMock.ExpectCommit()
 
 	sut := download.Page(dep)
 
@@ -206,12 +318,15 @@ func TestPageUpdatingRatingSameRatingSuccessPOST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingsDBError01POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -227,18 +342,16 @@ func TestPageUpdatingRatingsDBError01POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingsDBError02POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"rating",
-		}).AddRow(
-			"0",
-		))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(5))
+	sqlMock.ExpectExec("UPDATE filesRating SET rating=(.+) WHERE fileID=(.+) AND voter").WithArgs(10, "1", "username").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -254,19 +367,17 @@ func TestPageUpdatingRatingsDBError02POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingsDBError03POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"rating",
-		}).AddRow(
-			"0",
-		))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE filesRating SET rating").WithArgs(10, "1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(5))
+	sqlMock.ExpectExec("UPDATE filesRating SET rating=(.+) WHERE fileID=(.+) AND voter").WithArgs(10, "1", "username").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(5, 10, "1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -282,20 +393,14 @@ func TestPageUpdatingRatingsDBError03POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingsDBError04POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"rating",
-		}).AddRow(
-			"0",
-		))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE filesRating SET rating").WithArgs(10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -311,26 +416,18 @@ func TestPageUpdatingRatingsDBError04POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageUpdatingRatingsDBError05POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("Error 1062"))
-	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"rating",
-		}).AddRow(
-			"0",
-		))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE filesRating SET rating").WithArgs(10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"owner",
-		}).AddRow(
-			"owner",
-		))
-	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, 10, "owner").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(5))
+	sqlMock.ExpectExec("UPDATE filesRating SET rating=(.+) WHERE fileID=(.+) AND voter").WithArgs(10, "1", "username").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(5, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(5, 10, "owner").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -346,11 +443,12 @@ func TestPageUpdatingRatingsDBError05POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageDBFileInfoGatheringErrorGET(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE id").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
 
 	sut := download.Page(dep)
 
@@ -365,7 +463,7 @@ func TestPageDBFileInfoGatheringErrorGET(t *testing.T) {
 
 func TestPageDBFTimezoneGatheringErrorGET(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE id").WithArgs("1").WillReturnRows(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id").WithArgs("1").WillReturnRows(
 		sqlmock.NewRows([]string{
 			"id",
 			"label",
@@ -386,7 +484,7 @@ func TestPageDBFTimezoneGatheringErrorGET(t *testing.T) {
 			100,
 		))
 
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
 
 	sut := download.Page(dep)
 
@@ -455,7 +553,11 @@ func TestPageIncorrectPOSTParameter03(t *testing.T) {
 
 func TestPageSetRatingError01POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnError(sql.ErrNoRows)
 	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -471,12 +573,17 @@ func TestPageSetRatingError01POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageSetRatingError02POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnError(sql.ErrNoRows)
 	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(10, "1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -492,13 +599,12 @@ func TestPageSetRatingError02POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageSetRatingError03POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectBegin().WillReturnError(fmt.Errorf("testing error"))
 
 	sut := download.Page(dep)
 
@@ -514,19 +620,18 @@ func TestPageSetRatingError03POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
 
 func TestPageSetRatingError04POST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id = (.+) FOR UPDATE").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("owner"))
+	sqlMock.ExpectQuery("SELECT rating FROM filesRating WHERE fileID = (.+) AND voter = (.+) FOR UPDATE").WithArgs("1", "username").WillReturnError(sql.ErrNoRows)
 	sqlMock.ExpectExec("INSERT INTO filesRating").WithArgs("1", "username", 10).WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
-	sqlMock.ExpectQuery("SELECT owner FROM files WHERE id").WithArgs("1").WillReturnRows(
-		sqlmock.NewRows([]string{
-			"owner",
-		}).AddRow(
-			"owner",
-		))
-	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(10, "owner").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectExec("UPDATE files SET rating").WithArgs(0, 10, "1").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, 10, "owner").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	sut := download.Page(dep)
 
@@ -542,4 +647,5 @@ func TestPageSetRatingError04POST(t *testing.T) {
 	sut(w, r)
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
 }
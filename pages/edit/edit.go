@@ -0,0 +1,201 @@
+package edit
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/fileops"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tags"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+)
+
+// path to edit[/edit] template file
+const pathTemplateEdit = "pages/edit/template/edit.html"
+
+const (
+	selectFileInfo = "SELECT owner, label, description, category FROM files WHERE id = ?;"
+
+	updateFileInfo = "UPDATE files SET label = ?, description = ?, category = ? WHERE id = ?;"
+)
+
+// TemplateEdit contains data for edit[/edit] page template
+type TemplateEdit struct {
+	Warning     template.HTML
+	Username    string
+	ID          string
+	Label       string
+	Description string
+	Category    string
+	Categories  []categories.Category // tree of categories which can be selected
+	Tags        string                // comma separated
+}
+
+// Page returns HandleFunc for edit[/edit] page.
+// Page allows owner of file to change file label, description, category and tags or delete file.
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for edit page
+		page, err := tmp.CreateFormTemplate(pathTemplateEdit, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		data := TemplateEdit{Username: dep.Username, ID: id}
+		owner := ""
+		err = dep.Db.QueryRow(selectFileInfo, id).Scan(&owner, &data.Label, &data.Description, &data.Category)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintln(w, "ERROR: File not found")
+				return
+			}
+			errhand.InternalError(err, w)
+			return
+		}
+		if owner != dep.Username {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintln(w, "ERROR: Only owner can edit file")
+			return
+		}
+
+		// file can be moved only to active category, but it can stay in retired category
+		categoriesList, err := selectableCategories(dep.Db, data.Category)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+		data.Categories = categories.Tree(categoriesList)
+		oldCategory := data.Category
+
+		switch r.Method {
+		case "GET":
+			fileTags, err := tags.ForFile(dep.Db, id)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			data.Tags = strings.Join(fileTags, ", ")
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			if r.FormValue("action") == "delete" {
+				err = fileops.Delete(dep.Db, dep.Storage, id)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				if fileID, err := strconv.Atoi(id); err == nil {
+					dep.Search.Remove(fileID)
+				}
+				http.Redirect(w, r, "/", 302)
+				return
+			}
+
+			data.Label = r.FormValue("filename")
+			data.Description = r.FormValue("description")
+			data.Category = r.FormValue("category")
+			data.Tags = r.FormValue("tags")
+
+			if data.Label == "" {
+				data.Warning = "<h2 style=\"color:red\">Filename cannot be empty</h2>"
+				err = page.Execute(w, data)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			err = fileops.Validate(0, data.Label, data.Description)
+			if err == nil && data.Category != oldCategory {
+				if _, ok := categories.Find(categoriesList, data.Category); !ok {
+					err = categories.ErrUnknown
+				}
+			}
+			if err != nil {
+				data.Warning = template.HTML("<h2 style=\"color:red\">" + err.Error() + "</h2>")
+				err = page.Execute(w, data)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			fileTags, err := tags.Parse(data.Tags)
+			if err != nil {
+				data.Warning = template.HTML("<h2 style=\"color:red\">" + template.HTMLEscapeString(err.Error()) + "</h2>")
+				err = page.Execute(w, data)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			err = updateFile(dep.Db, id, data, fileTags)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			if fileID, err := strconv.Atoi(id); err == nil {
+				dep.Search.Add(searchindex.Document{ID: fileID, Label: data.Label, Description: data.Description, Owner: owner})
+			}
+
+			http.Redirect(w, r, "/download?id="+id, 302)
+			return
+		}
+	}
+}
+
+// updateFile changes file info and tags of file in one transaction
+func updateFile(db *sql.DB, id string, data TemplateEdit, fileTags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(updateFileInfo, data.Label, data.Description, data.Category, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tags.SetTx(tx, id, fileTags)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// selectableCategories returns active categories and current category of file even if it's retired
+func selectableCategories(db *sql.DB, current string) ([]categories.Category, error) {
+	all, err := categories.All(db)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []categories.Category{}
+	for _, c := range all {
+		if !c.Retired || c.Slug == current {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,394 @@
+package edit_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/edit"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// fileInfoRows returns rows of file info query with inputted owner
+func fileInfoRows(owner string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"owner", "label", "description", "category"}).AddRow(owner, "label", "description", "games")
+}
+
+// postForm sends POST request with inputted form to edit handler
+func postForm(dep session.Dependency, data url.Values) *httptest.ResponseRecorder {
+	sut := edit.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/edit?id=1", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+
+	sut(w, r)
+
+	return w
+}
+
+func TestPageSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectQuery("SELECT tags.name FROM file_tags").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jazz").AddRow("rock"))
+
+	sut := edit.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/edit?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Edit file</title>
+    <link rel="stylesheet" href="assets/css/edit.css">
+    <script src="assets/js/tags.js"></script>
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="editFormBox">
+        <div class="editFormContent">
+        <form action="/edit?id=1" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="save">
+            <p>Filename: <input type="text" maxlength="50" name="filename" value="label"></p><br>
+            <p>Description:</p>
+            <textarea cols="80" rows="15" maxlength="500" name="description">description</textarea>
+
+            <p>Category: <select name="category">
+                <option value="other">Other</option>
+                <option selected="selected" value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
+                </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" value="jazz, rock" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
+
+            <p><input type="submit" value="SAVE"></p>
+            
+        </form>
+
+        <form class="deleteForm" action="/edit?id=1" method="post" onsubmit="return confirm('Delete file?')">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="delete">
+            <p><input type="submit" value="DELETE FILE"></p>
+        </form>
+        </div>
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageNotExistingFileGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnError(sql.ErrNoRows)
+
+	sut := edit.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/edit?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, "ERROR: File not found\n", w.Body)
+}
+
+func TestPageNotOwnerGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("owner"))
+
+	sut := edit.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/edit?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Only owner can edit file\n", w.Body)
+}
+
+func TestPageDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+
+	sut := edit.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/edit?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageSaveSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	dep.Search = searchindex.New()
+	dep.Search.Add(searchindex.Document{ID: 1, Label: "old.txt", Description: "", Owner: "username"})
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET label = (.+), description = (.+), category = (.+) WHERE id").WithArgs("new label", "new description", "music", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("blues").WillReturnResult(sqlmock.NewResult(3, 1))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs("1", "blues").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "music")
+	data.Set("tags", " Blues ,")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/download?id=1", w.Header().Get("Location"))
+	assert.Empty(t, dep.Search.Search("old", 0))
+	results := dep.Search.Search("new label", 0)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].ID)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveEmptyFilenamePOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "")
+	data.Set("description", "new description")
+	data.Set("category", "music")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Filename cannot be empty</h2>`)
+	// inputted values should be kept in form
+	assert.Contains(t, w.Body.String(), `name="description">new description</textarea>`)
+	assert.Contains(t, w.Body.String(), `<option selected="selected" value="music">Music</option>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveWrongCategoryPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "unknown")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown category</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveRetiredCategoryPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows().
+		AddRow("old", "Old", "", 60, "", true))
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "old")
+	w := postForm(dep, data)
+
+	// file cannot be moved to retired category
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown category</h2>`)
+	assert.NotContains(t, w.Body.String(), `value="old"`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveKeepRetiredCategoryPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"owner", "label", "description", "category"}).AddRow("username", "label", "description", "old"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows().
+		AddRow("old", "Old", "", 60, "", true))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET label").WithArgs("new label", "new description", "old", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectCommit()
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "old")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveWrongTagsPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "music")
+	data.Set("tags", "rock, ???")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Tag cannot be empty</h2>`)
+	assert.Contains(t, w.Body.String(), `name="tags" value="rock, ???"`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveNotOwnerPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("owner"))
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "music")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Only owner can edit file\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveDBErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET label").WithArgs("new label", "new description", "music", "1").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "music")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageSaveTagsDBErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE files SET label").WithArgs("new label", "new description", "music", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+	// file info isn't changed when tags cannot be saved
+	sqlMock.ExpectRollback()
+
+	data := url.Values{}
+	data.Set("action", "save")
+	data.Set("filename", "new label")
+	data.Set("description", "new description")
+	data.Set("category", "music")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageDeleteSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	dep.Search = searchindex.New()
+	dep.Search.Add(searchindex.Document{ID: 1, Label: "label", Description: "description", Owner: "username"})
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	// file uploaded before blob store was added, its data isn't stored, so storage should not be touched
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner", "rating", "hash"}).AddRow("username", 15, ""))
+	sqlMock.ExpectExec("DELETE FROM filesRating").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(15, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	data := url.Values{}
+	data.Set("action", "delete")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/", w.Header().Get("Location"))
+	assert.Equal(t, 0, dep.Search.Len())
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageDeleteNotOwnerPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("owner"))
+
+	data := url.Values{}
+	data.Set("action", "delete")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Only owner can edit file\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageDeleteDBErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT owner, label, description, category FROM files WHERE id").WithArgs("1").WillReturnRows(fileInfoRows("username"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin().WillReturnError(fmt.Errorf("testing error"))
+
+	data := url.Values{}
+	data.Set("action", "delete")
+	w := postForm(dep, data)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
@@ -0,0 +1,149 @@
+package file
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/storage"
+
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+)
+
+const selectFile = "SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id = ?;"
+
+// fileInfo contains information about file required for sending it to user
+type fileInfo struct {
+	label        string
+	mimeType     string
+	originalName string
+	hash         string
+	uploadDate   time.Time
+}
+
+// Page returns HandleFunc for file[/file] handler which sends uploaded file to user
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD":
+			err := Serve(dep, w, r, r.URL.Query().Get("id"))
+			if err != nil {
+				if err == sql.ErrNoRows || err == storage.ErrNotExist {
+					notFound(w)
+					return
+				}
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+	}
+}
+
+// Serve sends uploaded file with inputted id to user.
+// If file doesn't exist Serve returns sql.ErrNoRows or storage.ErrNotExist and nothing is written to response.
+func Serve(dep session.Dependency, w http.ResponseWriter, r *http.Request, id string) error {
+	fi, err := existingFile(dep, id)
+	if err != nil {
+		return err
+	}
+
+	// files uploaded before blob store was added are stored by id
+	key := fi.hash
+	if key == "" {
+		key = id
+	}
+	f, err := storage.Open(dep.Storage, key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", fi.mimeType)
+	w.Header().Set("Content-Disposition", contentDisposition(downloadFilename(fi.label, fi.originalName)))
+	// files uploaded before content hashing was added don't have hash, they will be validated only by Last-Modified
+	if fi.hash != "" {
+		w.Header().Set("ETag", "\""+fi.hash+"\"")
+	}
+
+	// ServeContent handles Range, If-Range, If-None-Match and If-Modified-Since headers
+	http.ServeContent(w, r, "", fi.uploadDate, f)
+	return nil
+}
+
+// existingFile returns info of file with inputted id if:
+// 1) id is an integer (it also protects from path traversal),
+// 2) file is registered in MySQL database.
+// It doesn't check permissions, any authorized user can download any file.
+// If file doesn't exist existingFile returns sql.ErrNoRows
+func existingFile(dep session.Dependency, id string) (fileInfo, error) {
+	_, err := strconv.Atoi(id)
+	if err != nil {
+		return fileInfo{}, sql.ErrNoRows
+	}
+
+	fi := fileInfo{}
+	err = dep.Db.QueryRow(selectFile, id).Scan(&fi.label, &fi.mimeType, &fi.originalName, &fi.hash, &fi.uploadDate)
+	if err != nil {
+		return fileInfo{}, err
+	}
+
+	return fi, nil
+}
+
+// downloadFilename returns name of file for user's disk.
+// Label will be used as filename, if label doesn't have extension will be added extension of original filename
+func downloadFilename(label, originalName string) string {
+	if filepath.Ext(label) == "" {
+		return label + filepath.Ext(originalName)
+	}
+	return label
+}
+
+// contentDisposition returns Content-Disposition header value with filename encoded by RFC 5987.
+// Also it contains ASCII filename for old browsers
+func contentDisposition(filename string) string {
+	ascii := strings.Builder{}
+	encoded := strings.Builder{}
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			ascii.WriteRune('_')
+		} else {
+			ascii.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return "attachment; filename=\"" + ascii.String() + "\"; filename*=UTF-8''" + encoded.String()
+}
+
+// isAttrChar checks is byte allowed in RFC 5987 ext-value without percent-encoding
+func isAttrChar(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) != -1
+}
+
+// notFound writes not found error in page
+func notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintln(w, "ERROR: File not found")
+}
@@ -0,0 +1,410 @@
+package file_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/file"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+const (
+	// id of file which creates in files directory for tests
+	testFileID = "900000001"
+	// hash of test file, it differs from real hash of content to avoid collisions with files created by other packages tests
+	testFileHash = "f17e000000000000000000000000000000000000000000000000000000000001"
+)
+
+var uploadDate = time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)
+
+// fileInfoRows returns empty rows with columns of file info query
+func fileInfoRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"label", "mimeType", "originalName", "hash", "uploadDate"})
+}
+
+// rangeRequest sends GET request with inputted headers to file handler and returns response
+func rangeRequest(t *testing.T, headers map[string]string) *httptest.ResponseRecorder {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs(testFileID).WillReturnRows(fileInfoRows().AddRow("label", "text/plain; charset=utf-8", "file.txt", testFileHash, uploadDate))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+
+	sut(w, r)
+
+	return w
+}
+
+// createTestFile creates blob in files directory and returns function that removes it
+func createTestFile(t *testing.T, data string) func() {
+	return createStoredFile(t, testFileHash, data)
+}
+
+// createStoredFile creates file with inputted storage key in files directory and returns function that removes it
+func createStoredFile(t *testing.T, key, data string) func() {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	err := ioutil.WriteFile("files/"+key, []byte(data), 0644)
+	require.NoError(t, err)
+
+	return func() {
+		os.Remove("files/" + key)
+		os.Chdir("pages/file")
+	}
+}
+
+func TestPageSuccessGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs(testFileID).WillReturnRows(fileInfoRows().AddRow("label", "text/plain; charset=utf-8", "file.txt", testFileHash, uploadDate))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=\"label.txt\"; filename*=UTF-8''label.txt", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "11", w.Header().Get("Content-Length"))
+	assert.Equal(t, "\""+testFileHash+"\"", w.Header().Get("ETag"))
+	assert.Equal(t, "Tue, 17 Nov 2009 20:34:58 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	test.AssertBodyEqual(t, "binary data", w.Body)
+}
+
+func TestPageSuccessHEAD(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs(testFileID).WillReturnRows(fileInfoRows().AddRow("label", "text/plain; charset=utf-8", "file.txt", testFileHash, uploadDate))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodHead, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "11", w.Header().Get("Content-Length"))
+	test.AssertBodyEqual(t, "", w.Body)
+}
+
+func TestPageIncorrectIDError(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id=../main.go", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, "ERROR: File not found\n", w.Body)
+}
+
+func TestPageMethodNotAllowed(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageUnknownFileError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs("1").WillReturnRows(fileInfoRows())
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, "ERROR: File not found\n", w.Body)
+}
+
+func TestPageDBError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs("1").WillReturnError(fmt.Errorf("testing error"))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id=1", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageMissingFileOnDiskError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs(testFileID).WillReturnRows(fileInfoRows().AddRow("label", "text/plain; charset=utf-8", "file.txt", testFileHash, uploadDate))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	test.AssertBodyEqual(t, "ERROR: File not found\n", w.Body)
+}
+
+func TestPageNonASCIIFilenameGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs(testFileID).WillReturnRows(fileInfoRows().AddRow("отчёт \"final\".pdf", "application/pdf", "report.pdf", testFileHash, uploadDate))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=\"_____ _final_.pdf\"; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82%20%22final%22.pdf", w.Header().Get("Content-Disposition"))
+}
+
+func TestPageRangeGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=0-5"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 0-5/11", w.Header().Get("Content-Range"))
+	assert.Equal(t, "6", w.Header().Get("Content-Length"))
+	test.AssertBodyEqual(t, "binary", w.Body)
+}
+
+func TestPageOpenEndedRangeGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=7-"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 7-10/11", w.Header().Get("Content-Range"))
+	test.AssertBodyEqual(t, "data", w.Body)
+}
+
+func TestPageSuffixRangeGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=-4"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 7-10/11", w.Header().Get("Content-Range"))
+	test.AssertBodyEqual(t, "data", w.Body)
+}
+
+func TestPageMultiRangeGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=0-1,7-10"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	parts := readMultipartRanges(t, w)
+	assert.Equal(t, []string{"bytes 0-1/11", "bytes 7-10/11"}, parts.ranges)
+	assert.Equal(t, []string{"bi", "data"}, parts.bodies)
+}
+
+func TestPageOverlappingRangesGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=0-3,2-5"})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	parts := readMultipartRanges(t, w)
+	assert.Equal(t, []string{"bytes 0-3/11", "bytes 2-5/11"}, parts.ranges)
+	assert.Equal(t, []string{"bina", "nary"}, parts.bodies)
+}
+
+func TestPageOverlappingRangesLargerThanFileGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	// sum of ranges sizes more than file size, so whole file will be sent
+	w := rangeRequest(t, map[string]string{"Range": "bytes=0-8,2-10"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("Content-Range"))
+	test.AssertBodyEqual(t, "binary data", w.Body)
+}
+
+func TestPageUnsatisfiableRangeGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=20-30"})
+
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */11", w.Header().Get("Content-Range"))
+}
+
+func TestPageMalformedRangeGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"Range": "bytes=5-2"})
+
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+}
+
+func TestPageIfNoneMatchGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"If-None-Match": "\"" + testFileHash + "\""})
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	test.AssertBodyEqual(t, "", w.Body)
+}
+
+func TestPageIfNoneMatchChangedGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"If-None-Match": "\"outdated\""})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, "binary data", w.Body)
+}
+
+func TestPageIfModifiedSinceGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{"If-Modified-Since": "Tue, 17 Nov 2009 20:34:58 GMT"})
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	test.AssertBodyEqual(t, "", w.Body)
+}
+
+func TestPageIfRangeMatchedGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	w := rangeRequest(t, map[string]string{
+		"Range":    "bytes=7-",
+		"If-Range": "\"" + testFileHash + "\"",
+	})
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	test.AssertBodyEqual(t, "data", w.Body)
+}
+
+func TestPageIfRangeOutdatedGET(t *testing.T) {
+	cleanup := createTestFile(t, "binary data")
+	defer cleanup()
+
+	// file was changed since previous download, so whole file will be sent
+	w := rangeRequest(t, map[string]string{
+		"Range":    "bytes=7-",
+		"If-Range": "\"outdated\"",
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, "binary data", w.Body)
+}
+
+// multipartRanges contains parts of multipart/byteranges response
+type multipartRanges struct {
+	ranges []string
+	bodies []string
+}
+
+// readMultipartRanges parses multipart/byteranges response
+func readMultipartRanges(t *testing.T, w *httptest.ResponseRecorder) multipartRanges {
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/byteranges", mediaType)
+
+	parts := multipartRanges{}
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain; charset=utf-8", part.Header.Get("Content-Type"))
+
+		body, err := ioutil.ReadAll(part)
+		require.NoError(t, err)
+		parts.ranges = append(parts.ranges, part.Header.Get("Content-Range"))
+		parts.bodies = append(parts.bodies, string(body))
+	}
+	return parts
+}
+
+func TestPageStoredByIDFileGET(t *testing.T) {
+	// files uploaded before blob store was added don't have hash and stored by id
+	cleanup := createStoredFile(t, testFileID, "binary data")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT label, mimeType, originalName, hash, uploadDate FROM files WHERE id =").WithArgs(testFileID).WillReturnRows(fileInfoRows().AddRow("label", "text/plain; charset=utf-8", "file.txt", "", uploadDate))
+
+	sut := file.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/file?id="+testFileID, nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("ETag"))
+	test.AssertBodyEqual(t, "binary data", w.Body)
+}
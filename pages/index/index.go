@@ -14,7 +14,7 @@ import (
 // path to index[/index] template file
 const pathTemplateIndex = "pages/index/template/index.html"
 
-const selectFileInfo = "SELECT * FROM files ORDER BY uploadDate DESC LIMIT 15;"
+const selectFileInfo = "SELECT " + dbformat.FileInfoColumns + " FROM files ORDER BY uploadDate DESC LIMIT 15;"
 
 // TemplateIndex contains data for index[/index] page template
 type TemplateIndex struct {
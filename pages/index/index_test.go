@@ -28,7 +28,7 @@ func TestPageSuccessGet(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files ORDER BY uploadDate DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files ORDER BY uploadDate DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -38,7 +38,7 @@ func TestPageSuccessGet(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := index.Page(dep)
 	w := httptest.NewRecorder()
@@ -104,7 +104,7 @@ func TestPageSuccessGet(t *testing.T) {
 func TestPageDBError01Get(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files ORDER BY uploadDate DESC LIMIT 15;").WithArgs().WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files ORDER BY uploadDate DESC LIMIT 15;").WithArgs().WillReturnError(fmt.Errorf("testing error"))
 
 	sut := index.Page(dep)
 	w := httptest.NewRecorder()
@@ -130,7 +130,7 @@ func TestPageDBError02Get(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files ORDER BY uploadDate DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files ORDER BY uploadDate DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -140,7 +140,7 @@ func TestPageDBError02Get(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
 
 	sut := index.Page(dep)
 	w := httptest.NewRecorder()
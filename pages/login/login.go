@@ -1,13 +1,17 @@
 package login
 
 import (
-	"fmt"
 	"html/template"
+	"math"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
+	"github.com/vpoletaev11/fileHostingSite/credentials"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+	"github.com/vpoletaev11/fileHostingSite/pages/twofactor"
 	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
 	"github.com/vpoletaev11/fileHostingSite/tmp"
 
 	"github.com/vpoletaev11/fileHostingSite/errhand"
@@ -18,14 +22,15 @@ import (
 const pathTemplateLogin = "pages/login/template/login.html"
 
 const (
-	selectPass = "SELECT password FROM users WHERE username = ?;"
-)
+	selectPass = "SELECT password, banned, totpSecret FROM users WHERE username = ?;"
 
-const (
-	maxPasswordLen = 40
-	maxUsernameLen = 20
+	insertFailedLogin = "INSERT INTO failedLogins (username, ip, attemptTime) VALUES (?, ?, ?);"
 )
 
+// dummyHash is compared with password when user doesn't exist,
+// so login of not existing user takes the same time and response time doesn't show which usernames exist
+const dummyHash = "$2a$10$32dVCdsWc7ki.sLuV1qvR.jTdTPVFXaw0HwK7c3r/IQ63EdNXiEXy"
+
 // TemplateLog contain data login[/login] page template
 type TemplateLog struct {
 	Warning template.HTML
@@ -34,8 +39,14 @@ type TemplateLog struct {
 //Page returns HandleFunc for login[/login] page
 func Page(dep session.Dependency) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// getting CSRF token from cookie, user doesn't have session yet
+		csrfToken, err := csrf.Cookie(w, r, dep.SecureCookie)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
 		// creating template for login page
-		page, err := tmp.CreateTemplate(pathTemplateLogin)
+		page, err := tmp.CreateFormTemplate(pathTemplateLogin, csrfToken)
 		if err != nil {
 			errhand.InternalError(err, w)
 			return
@@ -51,11 +62,22 @@ func Page(dep session.Dependency) http.HandlerFunc {
 			}
 			return
 		case "POST":
+			// handling case when form was sent from another site
+			if !csrf.ValidCookie(r) {
+				templateData := TemplateLog{Warning: "<h2 style=\"color:red\">Form expired. Please try again</h2>"}
+				err := page.Execute(w, templateData)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
 			// getting username and password from POST request
 			dep.Username = r.FormValue("username")
 			password := r.FormValue("password")
 
-			err := usernameValidator(dep.Username)
+			err = credentials.ValidateUsername(dep.Username)
 			if err != nil {
 				templateData := TemplateLog{"<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>"}
 				err := page.Execute(w, templateData)
@@ -66,7 +88,7 @@ func Page(dep session.Dependency) http.HandlerFunc {
 				return
 			}
 
-			err = passwordValidator(password)
+			err = credentials.ValidatePassword(password)
 			if err != nil {
 				templateData := TemplateLog{"<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>"}
 				err := page.Execute(w, templateData)
@@ -77,12 +99,37 @@ func Page(dep session.Dependency) http.HandlerFunc {
 				return
 			}
 
+			// handling case when username or IP address are locked out after failed attempts
+			ip := session.RemoteIP(r)
+			wait, err := loginWait(dep, ip)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			if wait > 0 {
+				templateData := TemplateLog{Warning: "<h2 style=\"color:red\">Too many failed login attempts. Please try again in " + template.HTML(waitString(wait)) + "</h2>"}
+				err := page.Execute(w, templateData)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
 			// query to MySQL database to SELECT password for user.
 			// This query also checks is username exist
 			hashPassDB := ""
-			err = dep.Db.QueryRow(selectPass, dep.Username).Scan(&hashPassDB)
+			banned := false
+			totpSecret := ""
+			err = dep.Db.QueryRow(selectPass, dep.Username).Scan(&hashPassDB, &banned, &totpSecret)
 			if err != nil {
 				if err.Error() == "sql: no rows in result set" {
+					comparePasswords(dummyHash, password)
+					err = registerFailure(dep, ip, false)
+					if err != nil {
+						errhand.InternalError(err, w)
+						return
+					}
 					templateData := TemplateLog{"<h2 style=\"color:red\">Wrong username or password</h2>"}
 					err := page.Execute(w, templateData)
 					if err != nil {
@@ -97,6 +144,12 @@ func Page(dep session.Dependency) http.HandlerFunc {
 
 			// handle case when username doesn't exist
 			if hashPassDB == "" {
+				comparePasswords(dummyHash, password)
+				err = registerFailure(dep, ip, false)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
 				templateData := TemplateLog{Warning: "<h2 style=\"color:red\">Wrong username or password</h2>"}
 				err := page.Execute(w, templateData)
 				if err != nil {
@@ -109,6 +162,11 @@ func Page(dep session.Dependency) http.HandlerFunc {
 			// handle case when password for username doesn't match with password from MySQL database
 			err = comparePasswords(hashPassDB, password)
 			if err != nil {
+				err = registerFailure(dep, ip, true)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
 				templateData := TemplateLog{Warning: "<h2 style=\"color:red\">Wrong username or password</h2>"}
 				err := page.Execute(w, templateData)
 				if err != nil {
@@ -118,8 +176,37 @@ func Page(dep session.Dependency) http.HandlerFunc {
 				return
 			}
 
-			// creating cookie
-			cookie, err := session.CreateCookie(dep)
+			// handle case when user was banned by admin
+			if banned {
+				templateData := TemplateLog{Warning: "<h2 style=\"color:red\">Your account is banned</h2>"}
+				err := page.Execute(w, templateData)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			// user with enabled two-factor authentication should enter code before session creation
+			if totpSecret != "" {
+				err = twofactor.StartLogin(dep, w, dep.Username)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				http.Redirect(w, r, twofactor.LoginPath, http.StatusFound)
+				return
+			}
+
+			// successful login resets failed attempts of user
+			err = throttle.Reset(dep.Redis, throttle.LoginByUsername, dep.Username)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			// creating cookie, session which came with request (if any) will be removed to prevent session fixation
+			cookie, err := session.Rotate(dep, r)
 			if err != nil {
 				errhand.InternalError(err, w)
 				return
@@ -133,31 +220,49 @@ func Page(dep session.Dependency) http.HandlerFunc {
 	}
 }
 
-func passwordValidator(password string) error {
-	switch {
-	case password == "":
-		return fmt.Errorf("Password cannot be empty")
-
-	case len(password) > maxPasswordLen:
-		return fmt.Errorf("Password cannot be longer than " + strconv.Itoa(maxPasswordLen) + " characters")
+// loginWait returns time which should pass before next login attempt of user from inputted IP address
+func loginWait(dep session.Dependency, ip string) (time.Duration, error) {
+	waitUser, err := throttle.Wait(dep.Redis, throttle.LoginByUsername, dep.Username)
+	if err != nil {
+		return 0, err
+	}
+	waitIP, err := throttle.Wait(dep.Redis, throttle.LoginByIP, ip)
+	if err != nil {
+		return 0, err
+	}
+	if waitIP > waitUser {
+		return waitIP, nil
 	}
+	return waitUser, nil
+}
 
-	return nil
+// registerFailure counts failed login attempt of user from inputted IP address and writes it to audit trail.
+// Failures of unknown usernames are counted only by IP address, otherwise anyone could lock out
+// account which will be registered with guessed username.
+func registerFailure(dep session.Dependency, ip string, userExists bool) error {
+	if userExists {
+		_, err := throttle.Fail(dep.Redis, throttle.LoginByUsername, dep.Username)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := throttle.Fail(dep.Redis, throttle.LoginByIP, ip)
+	if err != nil {
+		return err
+	}
+	_, err = dep.Db.Exec(insertFailedLogin, dep.Username, ip, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	return err
 }
 
-func usernameValidator(username string) error {
+// waitString returns human readable wait time
+func waitString(wait time.Duration) string {
 	switch {
-	case username == "":
-		return fmt.Errorf("Username cannot be empty")
-
-	case len(username) > maxUsernameLen:
-		return fmt.Errorf("Username cannot be longer than " + strconv.Itoa(maxUsernameLen) + " characters")
-
-	case username != strings.ToLower(username):
-		return fmt.Errorf("Please use lower case username")
+	case wait <= time.Second:
+		return "1 second"
+	case wait < time.Minute:
+		return strconv.Itoa(int(wait.Seconds())) + " seconds"
 	}
-
-	return nil
+	return strconv.Itoa(int(math.Ceil(wait.Minutes()))) + " minutes"
 }
 
 // ComparePasswords compare hashed password with plain.
@@ -17,6 +17,7 @@ import (
 	"github.com/vpoletaev11/fileHostingSite/pages/login"
 	"github.com/vpoletaev11/fileHostingSite/session"
 	"github.com/vpoletaev11/fileHostingSite/test"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
 )
 
 type anyString struct{}
@@ -47,6 +48,27 @@ func (a anyTime) Match(v driver.Value) bool {
 	return ok
 }
 
+// ip is IP address of client in tests
+const ip = "192.0.2.1"
+
+// expectNotLocked adds expectations of commands which check that user and IP address aren't locked out
+func expectNotLocked(redisMock *redigomock.Conn, username string) {
+	redisMock.Command("TTL", "lock:login:user:"+username).Expect(int64(-2))
+	redisMock.Command("TTL", "lock:login:ip:"+ip).Expect(int64(-2))
+}
+
+// expectFailure adds expectations of commands and queries which register the first failed login attempt.
+// Failures of unknown usernames are counted only by IP address.
+func expectFailure(sqlMock sqlmock.Sqlmock, redisMock *redigomock.Conn, username string, userExists bool) {
+	if userExists {
+		redisMock.Command("INCR", "failures:login:user:"+username).Expect(int64(1))
+		redisMock.Command("EXPIRE", "failures:login:user:"+username, throttle.LoginByUsername.Lockout.Seconds())
+	}
+	redisMock.Command("INCR", "failures:login:ip:"+ip).Expect(int64(1))
+	redisMock.Command("EXPIRE", "failures:login:ip:"+ip, throttle.LoginByIP.Lockout.Seconds())
+	sqlMock.ExpectExec("INSERT INTO failedLogins").WithArgs(username, ip, anyTime{}).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 // TestPageSuccessGET checks workability of GET requests handler in Page()
 func TestPageSuccessGET(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
@@ -55,6 +77,7 @@ func TestPageSuccessGET(t *testing.T) {
 	w := httptest.NewRecorder()
 	r, err := http.NewRequest(http.MethodGet, "http://localhost/login", nil)
 	require.NoError(t, err)
+	r.AddCookie(test.CSRFCookie())
 
 	sut(w, r)
 
@@ -68,10 +91,12 @@ func TestPageSuccessGET(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             
         </form>
     </div>
@@ -81,31 +106,44 @@ func TestPageSuccessGET(t *testing.T) {
 // TestPageSuccessPost checks workability of POST requests handler in Page()
 func TestPageSuccessPOST(t *testing.T) {
 	dep, sqlMock, redisMock := test.NewDep(t)
-	row := []string{"password"}
-	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows(row).AddRow("$2a$10$ITkHbQjRK6AWs.InpysH5em2Lx4jwzmyYOpvFSturS7hRe6oxzUAu"))
+	row := []string{"password", "banned", "totpSecret"}
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows(row).AddRow("$2a$10$ITkHbQjRK6AWs.InpysH5em2Lx4jwzmyYOpvFSturS7hRe6oxzUAu", false, ""))
 	sqlMock.ExpectExec("INSERT INTO sessions").WithArgs("username", anyString{}, anyTime{}).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectNotLocked(redisMock, "username")
+	reset := redisMock.Command("DEL", "failures:login:user:username", "lock:login:user:username")
 	redisMock.Command("SET", redigomock.NewAnyData(), "username", "EX", session.CookieLifetime.Seconds())
+	redisMock.Command("HSET", redigomock.NewAnyData(), "created", redigomock.NewAnyInt(), "lastSeen", redigomock.NewAnyInt(), "ip", "192.0.2.1", "userAgent", "test agent")
+	redisMock.Command("EXPIRE", redigomock.NewAnyData(), session.CookieLifetime.Seconds())
+	sadd := redisMock.Command("SADD", "sessions:username", redigomock.NewAnyData())
 
 	data := url.Values{}
 	data.Set("username", "username")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.Header.Set("User-Agent", "test agent")
+	r.RemoteAddr = ip + ":1234"
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
 	sut(w, r)
 
 	assert.Equal(t, http.StatusFound, w.Code)
+	// session should be registered in user sessions index
+	assert.Equal(t, 1, redisMock.Stats(sadd))
+	// successful login should reset failed attempts of user
+	assert.Equal(t, 1, redisMock.Stats(reset))
 	test.AssertBodyEqual(t, "", w.Body)
 	assert.Equal(t, "/", w.Header().Get("Location"))
 
 	fromHandlerCookie := w.Result().Cookies()
 	assert.Equal(t, fromHandlerCookie[0].Name, "session_id")
-	assert.Equal(t, len(fromHandlerCookie[0].Value), 60)
+	assert.Equal(t, len(fromHandlerCookie[0].Value), 43)
 }
 
 // TestPageEmptyUsername tests case when username is empty.
@@ -113,12 +151,14 @@ func TestPageEmptyUsername(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 	data := url.Values{}
 	data.Set("username", "")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -134,10 +174,12 @@ func TestPageEmptyUsername(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Username cannot be empty</h2>
         </form>
     </div>
@@ -149,12 +191,14 @@ func TestPageEmptyPassword(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -170,10 +214,12 @@ func TestPageEmptyPassword(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Password cannot be empty</h2>
         </form>
     </div>
@@ -185,12 +231,14 @@ func TestPageLargerUsername(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 	data := url.Values{}
 	data.Set("username", "example_larger_than_20_characters")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -206,10 +254,12 @@ func TestPageLargerUsername(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Username cannot be longer than 20 characters</h2>
         </form>
     </div>
@@ -221,12 +271,14 @@ func TestPageLargerPassword(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "password_larger_than_40_characters____________________")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -242,10 +294,12 @@ func TestPageLargerPassword(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Password cannot be longer than 40 characters</h2>
         </form>
     </div>
@@ -257,12 +311,14 @@ func TestPageNonLowerCaseUsername(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 	data := url.Values{}
 	data.Set("username", "Example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -278,10 +334,12 @@ func TestPageNonLowerCaseUsername(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Please use lower case username</h2>
         </form>
     </div>
@@ -290,17 +348,21 @@ func TestPageNonLowerCaseUsername(t *testing.T) {
 
 // TestPageQuerySelectErr tests case when SELECT query returns error
 func TestPageQuerySELECTErr(t *testing.T) {
-	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("example").WillReturnError(fmt.Errorf("test error"))
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnError(fmt.Errorf("test error"))
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.RemoteAddr = ip + ":1234"
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -312,18 +374,23 @@ func TestPageQuerySELECTErr(t *testing.T) {
 
 // TestPageSELECTReturnsEmptyPass tests case when SELECT query returns empty password
 func TestPageSELECTReturnsEmptyPass(t *testing.T) {
-	dep, sqlMock, _ := test.NewDep(t)
-	row := []string{"password"}
-	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow(""))
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	row := []string{"password", "banned", "totpSecret"}
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow("", false, ""))
+	expectFailure(sqlMock, redisMock, "example", false)
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.RemoteAddr = ip + ":1234"
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -340,10 +407,12 @@ func TestPageSELECTReturnsEmptyPass(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Wrong username or password</h2>
         </form>
     </div>
@@ -351,22 +420,29 @@ func TestPageSELECTReturnsEmptyPass(t *testing.T) {
 }
 
 func TestPagePassordNotFound(t *testing.T) {
-	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("example").WillReturnError(fmt.Errorf("sql: no rows in result set"))
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnError(fmt.Errorf("sql: no rows in result set"))
+	expectFailure(sqlMock, redisMock, "example", false)
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.RemoteAddr = ip + ":1234"
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
 	sut(w, r)
 
+	// unknown username cannot be locked out
+	assert.Equal(t, 0, redisMock.Stats(redisMock.Command("INCR", "failures:login:user:example")))
 	assert.Equal(t, http.StatusOK, w.Code)
 	test.AssertBodyEqual(t, `<!doctype html>
 <html lang="en">
@@ -378,10 +454,12 @@ func TestPagePassordNotFound(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Wrong username or password</h2>
         </form>
     </div>
@@ -390,18 +468,23 @@ func TestPagePassordNotFound(t *testing.T) {
 
 // TestPageComparePasswordsDoesntMatch tests case when comparePasswords() gets not matched password with hashed password and returns error
 func TestPageComparePasswordsDoesntMatch(t *testing.T) {
-	dep, sqlMock, _ := test.NewDep(t)
-	row := []string{"password"}
-	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow("broken hash"))
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	row := []string{"password", "banned", "totpSecret"}
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow("broken hash", false, ""))
+	expectFailure(sqlMock, redisMock, "example", true)
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password", "example")
 
 	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
 	require.NoError(t, err)
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.RemoteAddr = ip + ":1234"
 	w := httptest.NewRecorder()
 
 	sut := login.Page(dep)
@@ -419,12 +502,219 @@ func TestPageComparePasswordsDoesntMatch(t *testing.T) {
 <body bgcolor=#f1ded3>
     <div class="loginForm">
         <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Username: <input required maxlength="20" type="text" name="username"></p>
             <p>Password: <input required maxlength="40" type="password" name="password"></p>
             <input type="submit" value="Login">
             <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
             <h2 style="color:red">Wrong username or password</h2>
         </form>
     </div>
 </body>`, w.Body)
 }
+
+// TestPageBannedUser tests case when user with correct password was banned by admin
+func TestPageBannedUser(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	row := []string{"password", "banned", "totpSecret"}
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow("$2a$10$ITkHbQjRK6AWs.InpysH5em2Lx4jwzmyYOpvFSturS7hRe6oxzUAu", true, ""))
+
+	data := url.Values{}
+	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
+	data.Add("password", "example")
+
+	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
+	require.NoError(t, err)
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+
+	sut := login.Page(dep)
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Login</title>
+    <link rel="stylesheet" href="assets/css/login.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="loginForm">
+        <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <p>Username: <input required maxlength="20" type="text" name="username"></p>
+            <p>Password: <input required maxlength="40" type="password" name="password"></p>
+            <input type="submit" value="Login">
+            <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
+            <h2 style="color:red">Your account is banned</h2>
+        </form>
+    </div>
+</body>`, w.Body)
+}
+
+// TestPageNewCSRFCookieGET tests case when user opens login page first time.
+func TestPageNewCSRFCookieGET(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := login.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/login", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	fromHandlerCookie := w.Result().Cookies()
+	require.Len(t, fromHandlerCookie, 1)
+	assert.Equal(t, "csrf_token", fromHandlerCookie[0].Name)
+	assert.True(t, fromHandlerCookie[0].HttpOnly)
+	// the same token should be placed in form
+	assert.Contains(t, w.Body.String(), `<input type="hidden" name="csrf_token" value="`+fromHandlerCookie[0].Value+`">`)
+}
+
+// TestPageInvalidCSRFToken tests case when form was sent from another site.
+func TestPageInvalidCSRFToken(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	data := url.Values{}
+	data.Set("username", "username")
+	data.Set("csrf_token", "forged")
+	data.Add("password", "example")
+
+	r, err := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
+	require.NoError(t, err)
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	w := httptest.NewRecorder()
+
+	sut := login.Page(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Login</title>
+    <link rel="stylesheet" href="assets/css/login.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="loginForm">
+        <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <p>Username: <input required maxlength="20" type="text" name="username"></p>
+            <p>Password: <input required maxlength="40" type="password" name="password"></p>
+            <input type="submit" value="Login">
+            <p><a href="/registration" style="color: #c82020">Not registered?</a></p>
+            <p><a href="/password/forgot" style="color: #c82020">Forgot password?</a></p>
+            <h2 style="color:red">Form expired. Please try again</h2>
+        </form>
+    </div>
+</body>`, w.Body)
+}
+
+// sendLogin sends login form with correct CSRF token from client with test IP address
+func sendLogin(dep session.Dependency) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
+	data.Add("password", "example")
+
+	r, _ := http.NewRequest("POST", "http://localhost/login", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	r.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+
+	sut := login.Page(dep)
+	sut(w, r)
+
+	return w
+}
+
+// TestPageUsernameLockedOut tests case when user is locked out after failed attempts.
+// Password shouldn't be checked even if it's correct.
+func TestPageUsernameLockedOut(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:example").Expect(int64(600))
+	redisMock.Command("TTL", "lock:login:ip:"+ip).Expect(int64(-2))
+
+	w := sendLogin(dep)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Too many failed login attempts. Please try again in 10 minutes</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageIPLockedOut tests case when IP address is locked out after failed attempts.
+func TestPageIPLockedOut(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:example").Expect(int64(2))
+	redisMock.Command("TTL", "lock:login:ip:"+ip).Expect(int64(8))
+
+	w := sendLogin(dep)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Too many failed login attempts. Please try again in 8 seconds</h2>`)
+}
+
+// TestPageLockoutRedisError tests case when redis returns error while lockout checking.
+func TestPageLockoutRedisError(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:example").ExpectError(fmt.Errorf("test error"))
+
+	w := sendLogin(dep)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+// TestPageFailureStartsBackoff tests case when failed attempt locks out username for some time.
+func TestPageFailureStartsBackoff(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	row := []string{"password", "banned", "totpSecret"}
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow("broken hash", false, ""))
+	redisMock.Command("INCR", "failures:login:user:example").Expect(int64(5))
+	redisMock.Command("EXPIRE", "failures:login:user:example", throttle.LoginByUsername.Lockout.Seconds())
+	lock := redisMock.Command("SET", "lock:login:user:example", 5, "EX", float64(4))
+	redisMock.Command("INCR", "failures:login:ip:"+ip).Expect(int64(1))
+	redisMock.Command("EXPIRE", "failures:login:ip:"+ip, throttle.LoginByIP.Lockout.Seconds())
+	sqlMock.ExpectExec("INSERT INTO failedLogins").WithArgs("example", ip, anyTime{}).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w := sendLogin(dep)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong username or password</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(lock))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageTwoFactorRedirect tests case when user with enabled two-factor authentication enters correct password.
+// Session shouldn't be created until user enters code.
+func TestPageTwoFactorRedirect(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectNotLocked(redisMock, "example")
+	row := []string{"password", "banned", "totpSecret"}
+	sqlMock.ExpectQuery("SELECT password, banned, totpSecret FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows(row).AddRow("$2a$10$ITkHbQjRK6AWs.InpysH5em2Lx4jwzmyYOpvFSturS7hRe6oxzUAu", false, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"))
+	pending := redisMock.Command("SET", redigomock.NewAnyData(), "example", "EX", float64(300))
+
+	w := sendLogin(dep)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login/2fa", w.Header().Get("Location"))
+	assert.Equal(t, 1, redisMock.Stats(pending))
+
+	fromHandlerCookie := w.Result().Cookies()
+	require.Len(t, fromHandlerCookie, 1)
+	assert.Equal(t, "login_2fa", fromHandlerCookie[0].Name)
+	assert.Equal(t, "/login", fromHandlerCookie[0].Path)
+}
@@ -10,23 +10,20 @@ import (
 // Page returns HandleFunc that removes user cookie and redirect to login page
 func Page(dep session.Dependency) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_id")
+		cookie, err := r.Cookie(session.CookieName)
 		if err != nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
 
-		_, err = dep.Redis.Do("DEL", cookie.Value)
+		// removing cookie together with its entries in user sessions index
+		err = session.Delete(dep.Redis, cookie.Value)
 		if err != nil {
 			errhand.InternalError(err, w)
 			return
 		}
 
-		newCookie := &http.Cookie{
-			Name:   "session_id",
-			MaxAge: -1,
-		}
-		http.SetCookie(w, newCookie)
+		http.SetCookie(w, session.ExpiredCookie(dep))
 		http.Redirect(w, r, "/login", http.StatusFound)
 	}
 }
@@ -11,13 +11,17 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vpoletaev11/fileHostingSite/pages/logout"
+	"github.com/vpoletaev11/fileHostingSite/session"
 	"github.com/vpoletaev11/fileHostingSite/test"
 )
 
 // TestSuccess checks workability Page()
 func TestSuccess(t *testing.T) {
 	dep, _, redisMock := test.NewDep(t)
-	redisMock.Command("DEL", redigomock.NewAnyData())
+	id := session.ID("test")
+	redisMock.Command("GET", id).Expect("username")
+	del := redisMock.Command("DEL", id, "session:"+id)
+	srem := redisMock.Command("SREM", "sessions:username", id)
 
 	sut := logout.Page(dep)
 
@@ -39,12 +43,17 @@ func TestSuccess(t *testing.T) {
 	fromHandlerCookie := w.Result().Cookies()
 	assert.Equal(t, fromHandlerCookie[0].Name, "session_id")
 	assert.Equal(t, fromHandlerCookie[0].MaxAge, -1)
+
+	// session should be removed from user sessions index
+	assert.Equal(t, 1, redisMock.Stats(del))
+	assert.Equal(t, 1, redisMock.Stats(srem))
 }
 
 // TestDBError checks workability of error handler for database queryer
 func TestDBError(t *testing.T) {
 	dep, _, redisMock := test.NewDep(t)
-	redisMock.Command("DEL", redigomock.NewAnyData()).ExpectError(fmt.Errorf("Testing error"))
+	redisMock.Command("GET", session.ID("test")).Expect("username")
+	redisMock.Command("DEL", redigomock.NewAnyData(), redigomock.NewAnyData()).ExpectError(fmt.Errorf("Testing error"))
 
 	sut := logout.Page(dep)
 
@@ -27,7 +27,7 @@ func TestPageSuccessGet(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE rating >0 ORDER BY rating DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE rating >0 ORDER BY rating DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -37,7 +37,7 @@ func TestPageSuccessGet(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Moscow"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
 
 	sut := popular.Page(dep)
 	w := httptest.NewRecorder()
@@ -100,7 +100,7 @@ func TestPageSuccessGet(t *testing.T) {
 func TestPageDBError01Get(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE rating >0 ORDER BY rating DESC LIMIT 15;").WithArgs().WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE rating >0 ORDER BY rating DESC LIMIT 15;").WithArgs().WillReturnError(fmt.Errorf("testing error"))
 
 	sut := popular.Page(dep)
 	w := httptest.NewRecorder()
@@ -126,7 +126,7 @@ func TestPageDBError02Get(t *testing.T) {
 		"rating",
 	}
 
-	sqlMock.ExpectQuery("SELECT \\* FROM files WHERE rating >0 ORDER BY rating DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE rating >0 ORDER BY rating DESC LIMIT 15;").WithArgs().WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
 		1,
 		"label",
 		1024,
@@ -136,7 +136,7 @@ func TestPageDBError02Get(t *testing.T) {
 		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
 		1000,
 	))
-	sqlMock.ExpectQuery("SELECT timezone FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
 
 	sut := popular.Page(dep)
 	w := httptest.NewRecorder()
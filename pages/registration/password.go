@@ -0,0 +1,150 @@
+package registration
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/credentials"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// path to change password[/password] template file
+const pathTemplatePassword = "pages/registration/template/password.html"
+
+const (
+	selectPassword = "SELECT password FROM users WHERE username = ?;"
+
+	updatePassword = "UPDATE users SET password = ? WHERE username = ?;"
+)
+
+// TemplatePassword contains data for change password[/password] page template
+type TemplatePassword struct {
+	Warning  template.HTML
+	Username string
+}
+
+// Password returns HandleFunc for change password[/password] page
+func Password(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for change password page
+		page, err := tmp.CreateFormTemplate(pathTemplatePassword, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			err = page.Execute(w, TemplatePassword{Username: dep.Username})
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			warning, err := changePassword(dep, r)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			err = page.Execute(w, TemplatePassword{Warning: warning, Username: dep.Username})
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// changePassword replaces password of user if current password is correct and returns warning for user.
+// All sessions of user except current one are closed and API tokens of user are revoked after password change.
+func changePassword(dep session.Dependency, r *http.Request) (template.HTML, error) {
+	password1 := r.FormValue("password1")
+	password2 := r.FormValue("password2")
+	err := passwordsValidator(password1, password2)
+	if err != nil {
+		return "<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>", nil
+	}
+
+	warning, err := checkPassword(dep, r.FormValue("current"))
+	if err != nil || warning != "" {
+		return warning, err
+	}
+
+	newHash, err := credentials.Hash(password1)
+	if err != nil {
+		return "", err
+	}
+	err = setPassword(dep.Db, dep.Username, newHash)
+	if err != nil {
+		return "", err
+	}
+
+	// AuthWrapper doesn't call page without session cookie
+	cookie, err := r.Cookie(session.CookieName)
+	if err != nil {
+		return "", err
+	}
+	err = session.KillOtherSessions(dep.Redis, dep.Username, cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	return "<h2 style=\"color:green\">Password changed. All other sessions were closed and API tokens were revoked</h2>", nil
+}
+
+// setPassword replaces password hash of user and revokes API tokens of user in one transaction.
+// Tokens could be created by someone who knew old password, so they shouldn't outlive it.
+func setPassword(db *sql.DB, username, hashedPass string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(updatePassword, hashedPass, username)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = apitokens.RevokeAll(tx, username)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkPassword checks current password of user before changing of account.
+// If password cannot be accepted checkPassword returns warning for user.
+func checkPassword(dep session.Dependency, password string) (template.HTML, error) {
+	// current password is throttled as on login, otherwise stolen session allows to guess it
+	wait, err := throttle.Wait(dep.Redis, throttle.LoginByUsername, dep.Username)
+	if err != nil {
+		return "", err
+	}
+	if wait > 0 {
+		return "<h2 style=\"color:red\">Too many failed attempts. Please try again later</h2>", nil
+	}
+
+	hashedPass := ""
+	err = dep.Db.QueryRow(selectPassword, dep.Username).Scan(&hashedPass)
+	if err != nil {
+		return "", err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPass), []byte(password))
+	if err != nil {
+		_, err = throttle.Fail(dep.Redis, throttle.LoginByUsername, dep.Username)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:red\">Wrong current password</h2>", nil
+	}
+	return "", nil
+}
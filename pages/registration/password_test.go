@@ -0,0 +1,179 @@
+package registration_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/registration"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+)
+
+// hashedExample is bcrypt hash of "example" password
+const hashedExample = "$2a$10$ITkHbQjRK6AWs.InpysH5em2Lx4jwzmyYOpvFSturS7hRe6oxzUAu"
+
+// sendPassword sends POST request with change password form
+func sendPassword(dep session.Dependency, current, password1, password2 string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("csrf_token", test.CSRFToken)
+	data.Set("current", current)
+	data.Set("password1", password1)
+	data.Set("password2", password2)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/password", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(&http.Cookie{Name: session.CookieName, Value: "current"})
+
+	sut := registration.Password(dep)
+	sut(w, r)
+
+	return w
+}
+
+// TestPasswordSuccessGET checks workability of GET requests handler in Password()
+func TestPasswordSuccessGET(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/password", nil)
+	require.NoError(t, err)
+
+	sut := registration.Password(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Change password</title>
+    <link rel="stylesheet" href="assets/css/password.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="passwordForm">
+        <h2>Change password</h2>
+        <form action="/password" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <p>Current password: <input required maxlength="40" type="password" name="current"></p>
+            <p>New password: <input required maxlength="40" type="password" name="password1"></p>
+            <p>Repeat new password: <input required maxlength="40" type="password" name="password2"></p>
+            <input type="submit" value="CHANGE PASSWORD">
+        </form>
+        
+    </div>
+</body>`, w.Body)
+}
+
+// TestPasswordSuccessPOST tests case when password is changed and other sessions of user are closed
+func TestPasswordSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE users SET password").WithArgs(anyString{}, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	// tokens created with old password stop working
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE username = \\?;").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectCommit()
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{[]byte("other"), []byte(session.ID("current"))})
+	killOther := redisMock.Command("DEL", "other", "session:other").Expect(int64(2))
+	redisMock.Command("SREM", "sessions:username", "other").Expect(int64(1))
+	killCurrent := redisMock.Command("DEL", session.ID("current"), "session:"+session.ID("current"))
+
+	w := sendPassword(dep, "example", "new password", "new password")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Password changed. All other sessions were closed and API tokens were revoked</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(killOther))
+	assert.Equal(t, 0, redisMock.Stats(killCurrent))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPasswordWrongCurrentPOST tests case when current password is wrong
+func TestPasswordWrongCurrentPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	fail := redisMock.Command("INCR", "failures:login:user:username").Expect(int64(1))
+	redisMock.Command("EXPIRE", "failures:login:user:username", throttle.LoginByUsername.Lockout.Seconds())
+
+	w := sendPassword(dep, "wrong", "new password", "new password")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong current password</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(fail))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPasswordMismatchingPasswordsPOST tests case when new passwords doesn't match
+func TestPasswordMismatchingPasswordsPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+
+	w := sendPassword(dep, "example", "new password", "another password")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Passwords doesn't match</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPasswordLockedOutPOST tests case when user had too many failed attempts
+func TestPasswordLockedOutPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(60))
+
+	w := sendPassword(dep, "example", "new password", "new password")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Too many failed attempts. Please try again later</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPasswordDBErrorPOST tests case when database returns error
+func TestPasswordDBErrorPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("Testing error"))
+
+	w := sendPassword(dep, "example", "new password", "new password")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+// TestPasswordRevokeTokensDBErrorPOST tests case when API tokens cannot be revoked, password shouldn't be changed too
+func TestPasswordRevokeTokensDBErrorPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE users SET password").WithArgs(anyString{}, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE username").WithArgs("username").WillReturnError(fmt.Errorf("Testing error"))
+	sqlMock.ExpectRollback()
+
+	w := sendPassword(dep, "example", "new password", "new password")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
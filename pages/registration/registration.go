@@ -1,29 +1,31 @@
 package registration
 
 import (
-	"database/sql"
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/mail"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/vpoletaev11/fileHostingSite/credentials"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+	"github.com/vpoletaev11/fileHostingSite/session"
 	"github.com/vpoletaev11/fileHostingSite/tmp"
 
 	"github.com/vpoletaev11/fileHostingSite/errhand"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // path to registration[/registration] template file
 const pathTemplateRegistration = "pages/registration/template/register.html"
 
-const createUser = "INSERT INTO users(username, password, timezone) VALUES(?, ?, ?);"
+// path to shared template file with list of timezones used by registration and account settings pages
+const pathTemplateTimezones = "pages/registration/template/timezones.html"
 
-const (
-	maxPasswordLen = 40
-	maxUsernameLen = 20
-)
+const createUser = "INSERT INTO users(username, password, timezone, email, createdAt) VALUES(?, ?, ?, ?, ?);"
+
+const maxEmailLen = 254
 
 // TemplateReg contains data for registration[/registration] page template
 type TemplateReg struct {
@@ -31,10 +33,16 @@ type TemplateReg struct {
 }
 
 // Page returns HandleFunc for registration[/registration] page
-func Page(db *sql.DB) http.HandlerFunc {
+func Page(dep session.Dependency) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// getting CSRF token from cookie, user doesn't have session yet
+		csrfToken, err := csrf.Cookie(w, r, dep.SecureCookie)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
 		// creating template for register page
-		page, err := tmp.CreateTemplate(pathTemplateRegistration)
+		page, err := tmp.CreateFormTemplate(pathTemplateRegistration, csrfToken, pathTemplateTimezones)
 		if err != nil {
 			errhand.InternalError(err, w)
 			return
@@ -52,13 +60,25 @@ func Page(db *sql.DB) http.HandlerFunc {
 			return
 
 		case "POST":
+			// handling case when form was sent from another site
+			if !csrf.ValidCookie(r) {
+				templateData := TemplateReg{"<h2 style=\"color:red\">Form expired. Please try again</h2>"}
+				err := page.Execute(w, templateData)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
 			// getting username and passwords from POST request
 			username := r.FormValue("username")
 			password1 := r.FormValue("password1")
 			password2 := r.FormValue("password2")
 			timezone := r.FormValue("timezone")
+			email := r.FormValue("email")
 
-			err = usernameValidator(username)
+			err = credentials.ValidateUsername(username)
 			if err != nil {
 				templateData := TemplateReg{"<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>"}
 				err := page.Execute(w, templateData)
@@ -91,8 +111,19 @@ func Page(db *sql.DB) http.HandlerFunc {
 				return
 			}
 
+			err = emailValidator(email)
+			if err != nil {
+				templateData := TemplateReg{"<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>"}
+				err := page.Execute(w, templateData)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
 			// creating salted hash from password
-			hashedPass, err := hashAndSalt(password1)
+			hashedPass, err := credentials.Hash(password1)
 			if err != nil {
 				templateData := TemplateReg{"<h2 style=\"color:red\">INTERNAL ERROR. Please try later</h2>"}
 				err := page.Execute(w, templateData)
@@ -105,7 +136,7 @@ func Page(db *sql.DB) http.HandlerFunc {
 
 			// writing username and salted hashed password to MySQL database
 			// MySQL database does not allow to enter not unique usernames (username is primary key)
-			_, err = db.Exec(createUser, username, hashedPass, timezone)
+			_, err = dep.Db.Exec(createUser, username, hashedPass, timezone, email, dep.Now().UTC().Format("2006-01-02 15:04:05"))
 			if err != nil {
 				// handling case when username is not unique
 				if strings.Contains(err.Error(), "Error 1062") {
@@ -149,48 +180,35 @@ func timezoneValidator(timezone string) error {
 	return nil
 }
 
-func usernameValidator(username string) error {
-	switch {
-	case len(username) == 0:
-		return fmt.Errorf("Username cannot be empty")
-
-	case len(username) > maxUsernameLen:
-		return fmt.Errorf("Username cannot be longer than " + strconv.Itoa(maxUsernameLen) + " characters")
-
-	case username != strings.ToLower(username):
-		return fmt.Errorf("Please use lower case username")
+// emailValidator checks email which is used to reset password.
+// Email is optional, but user without email cannot reset forgotten password.
+func emailValidator(email string) error {
+	if email == "" {
+		return nil
+	}
+	if len(email) > maxEmailLen {
+		return fmt.Errorf("Email cannot be longer than " + strconv.Itoa(maxEmailLen) + " characters")
+	}
+	addr, err := mail.ParseAddress(email)
+	// address with name (e.g. "John <john@example.com>") isn't allowed
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("Incorrect email")
 	}
-
 	return nil
 }
 
+// passwordsValidator checks password and its confirmation
 func passwordsValidator(password1, password2 string) error {
-	switch {
-	case len(password1) == 0:
-		return fmt.Errorf("Password cannot be empty")
-
-	case len(password2) == 0:
-		return fmt.Errorf("Password cannot be empty")
-
-	case len(password1) > maxPasswordLen:
-		return fmt.Errorf("Password cannot be longer than " + strconv.Itoa(maxPasswordLen) + " characters")
-
-	case len(password2) > maxPasswordLen:
-		return fmt.Errorf("Password cannot be longer than " + strconv.Itoa(maxPasswordLen) + " characters")
-
-	case password1 != password2:
-		return fmt.Errorf("Passwords doesn't match")
+	err := credentials.ValidatePassword(password1)
+	if err != nil {
+		return err
 	}
-
-	return nil
-}
-
-// hashAndSalt creates salted hash from password
-func hashAndSalt(pwd string) (string, error) {
-	pwdByte := []byte(pwd)
-	hash, err := bcrypt.GenerateFromPassword(pwdByte, bcrypt.DefaultCost)
+	err = credentials.ValidatePassword(password2)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(hash), err
+	if password1 != password2 {
+		return fmt.Errorf("Passwords doesn't match")
+	}
+	return nil
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vpoletaev11/fileHostingSite/pages/registration"
+	"github.com/vpoletaev11/fileHostingSite/session"
 	"github.com/vpoletaev11/fileHostingSite/test"
 )
 
@@ -33,10 +34,11 @@ func (a anyString) Match(v driver.Value) bool {
 
 // TestPageSuccessGET checks workability of GET requests handler in Page()
 func TestPageSuccessGET(t *testing.T) {
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	w := httptest.NewRecorder()
 	r, err := http.NewRequest(http.MethodGet, "http://localhost/registration", nil)
 	require.NoError(t, err)
+	r.AddCookie(test.CSRFCookie())
 
 	sut(w, r)
 
@@ -53,9 +55,11 @@ func TestPageSuccessGET(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -496,10 +500,11 @@ func TestPageSuccessGET(t *testing.T) {
 func TestPageSuccessPost(t *testing.T) {
 	db, sqlMock, err := sqlmock.New()
 	require.NoError(t, err)
-	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 	data.Add("timezone", "Europe/Moscow")
@@ -509,9 +514,10 @@ func TestPageSuccessPost(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(db)
+	sut := registration.Page(session.Dependency{Db: db})
 	sut(w, r)
 
 	assert.Equal(t, "/login", w.Header().Get("Location"))
@@ -521,6 +527,7 @@ func TestPageSuccessPost(t *testing.T) {
 func TestPageEmptyUsername(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 
@@ -529,9 +536,10 @@ func TestPageEmptyUsername(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -547,9 +555,11 @@ func TestPageEmptyUsername(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -990,6 +1000,7 @@ func TestPageEmptyUsername(t *testing.T) {
 func TestPageEmptyPassword1(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "")
 	data.Add("password2", "example")
 
@@ -998,9 +1009,10 @@ func TestPageEmptyPassword1(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -1016,9 +1028,11 @@ func TestPageEmptyPassword1(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -1459,6 +1473,7 @@ func TestPageEmptyPassword1(t *testing.T) {
 func TestPageEmptyPassword2(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "")
 
@@ -1467,9 +1482,10 @@ func TestPageEmptyPassword2(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -1485,9 +1501,11 @@ func TestPageEmptyPassword2(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -1928,6 +1946,7 @@ func TestPageEmptyPassword2(t *testing.T) {
 func TestPageLargerUsername(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example_larger_than_20_characters")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 
@@ -1936,9 +1955,10 @@ func TestPageLargerUsername(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -1954,9 +1974,11 @@ func TestPageLargerUsername(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -2397,6 +2419,7 @@ func TestPageLargerUsername(t *testing.T) {
 func TestPageLargerPassword1(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example_larger_than_40_characters_example_larger_than_40_characters")
 	data.Add("password2", "example")
 
@@ -2405,9 +2428,10 @@ func TestPageLargerPassword1(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -2423,9 +2447,11 @@ func TestPageLargerPassword1(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -2866,6 +2892,7 @@ func TestPageLargerPassword1(t *testing.T) {
 func TestPageLargerPassword2(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example_larger_than_40_characters_example_larger_than_40_characters")
 
@@ -2874,9 +2901,10 @@ func TestPageLargerPassword2(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -2892,9 +2920,11 @@ func TestPageLargerPassword2(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -3335,6 +3365,7 @@ func TestPageLargerPassword2(t *testing.T) {
 func TestPageNonLowerCaseUsernam(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "Example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 
@@ -3343,9 +3374,10 @@ func TestPageNonLowerCaseUsernam(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -3361,9 +3393,11 @@ func TestPageNonLowerCaseUsernam(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -3804,6 +3838,7 @@ func TestPageNonLowerCaseUsernam(t *testing.T) {
 func TestPageMismatchingPasswords(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example1")
 	data.Add("password2", "example2")
 
@@ -3812,9 +3847,10 @@ func TestPageMismatchingPasswords(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -3830,9 +3866,11 @@ func TestPageMismatchingPasswords(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -4273,10 +4311,11 @@ func TestPageMismatchingPasswords(t *testing.T) {
 func TestPageNotUniqueUsername(t *testing.T) {
 	db, sqlMock, err := sqlmock.New()
 	require.NoError(t, err)
-	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow").WillReturnError(fmt.Errorf("Error 1062"))
+	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow", "", sqlmock.AnyArg()).WillReturnError(fmt.Errorf("Error 1062"))
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 	data.Add("timezone", "Europe/Moscow")
@@ -4286,9 +4325,10 @@ func TestPageNotUniqueUsername(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(db)
+	sut := registration.Page(session.Dependency{Db: db})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -4304,9 +4344,11 @@ func TestPageNotUniqueUsername(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -4747,10 +4789,11 @@ func TestPageNotUniqueUsername(t *testing.T) {
 func TestPageUsernameInsertionDBInternalError(t *testing.T) {
 	db, sqlMock, err := sqlmock.New()
 	require.NoError(t, err)
-	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow").WillReturnError(fmt.Errorf("Testing error"))
+	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow", "", sqlmock.AnyArg()).WillReturnError(fmt.Errorf("Testing error"))
 
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 	data.Add("timezone", "Europe/Moscow")
@@ -4760,9 +4803,10 @@ func TestPageUsernameInsertionDBInternalError(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(db)
+	sut := registration.Page(session.Dependency{Db: db})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -4778,9 +4822,11 @@ func TestPageUsernameInsertionDBInternalError(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -5220,6 +5266,7 @@ func TestPageUsernameInsertionDBInternalError(t *testing.T) {
 func TestPageNonSelectedTimezoneError(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 	data.Add("timezone", "empty")
@@ -5229,9 +5276,10 @@ func TestPageNonSelectedTimezoneError(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -5247,9 +5295,11 @@ func TestPageNonSelectedTimezoneError(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -5689,6 +5739,7 @@ func TestPageNonSelectedTimezoneError(t *testing.T) {
 func TestPageEmptyTimezoneError(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 	data.Add("timezone", "")
@@ -5698,9 +5749,10 @@ func TestPageEmptyTimezoneError(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -5716,9 +5768,11 @@ func TestPageEmptyTimezoneError(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -6158,6 +6212,7 @@ func TestPageEmptyTimezoneError(t *testing.T) {
 func TestPageWrongTimezoneError(t *testing.T) {
 	data := url.Values{}
 	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
 	data.Add("password1", "example")
 	data.Add("password2", "example")
 	data.Add("timezone", "wrong timezone")
@@ -6167,9 +6222,10 @@ func TestPageWrongTimezoneError(t *testing.T) {
 
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
 	w := httptest.NewRecorder()
 
-	sut := registration.Page(nil)
+	sut := registration.Page(session.Dependency{})
 	sut(w, r)
 
 	test.AssertBodyEqual(t, `<!doctype html>
@@ -6185,9 +6241,11 @@ func TestPageWrongTimezoneError(t *testing.T) {
     <body bgcolor=#f1ded3>
         <div class="registerForm">
             <form action="" method="post">
+                <input type="hidden" name="csrf_token" value="csrf">
                 <p>Create username: <input required maxlength="20" type="text" name="username"></p>
                 <p>Create password: <input required maxlength="40" type="password" name="password1"></p>
                 <p>Repeat password: <input required maxlength="40" type="password" name="password2"></p>
+                <p>Email (for password reset): <input maxlength="254" type="email" name="email"></p>
                 <p>Set Timezone: <select name="timezone">
                     <option value="empty">Choose timezone</option>
                     <optgroup label="Africa">
@@ -6623,3 +6681,59 @@ func TestPageWrongTimezoneError(t *testing.T) {
         </div>
     </body>`, w.Body)
 }
+
+// TestPageSuccessPostWithEmail tests case when user sets email for password reset.
+func TestPageSuccessPostWithEmail(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectExec("INSERT INTO users").WithArgs("example", anyString{}, "Europe/Moscow", "example@example.com", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	data := url.Values{}
+	data.Set("username", "example")
+	data.Set("csrf_token", test.CSRFToken)
+	data.Add("password1", "example")
+	data.Add("password2", "example")
+	data.Add("timezone", "Europe/Moscow")
+	data.Add("email", "example@example.com")
+
+	r, err := http.NewRequest("POST", "http://localhost/registration", strings.NewReader(data.Encode()))
+	require.NoError(t, err)
+
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+	w := httptest.NewRecorder()
+
+	sut := registration.Page(session.Dependency{Db: db})
+	sut(w, r)
+
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageWrongEmailError tests case when email is incorrect.
+func TestPageWrongEmailError(t *testing.T) {
+	for _, email := range []string{"example", "Example <example@example.com>", strings.Repeat("a", 250) + "@example.com"} {
+		data := url.Values{}
+		data.Set("username", "example")
+		data.Set("csrf_token", test.CSRFToken)
+		data.Add("password1", "example")
+		data.Add("password2", "example")
+		data.Add("timezone", "Europe/Moscow")
+		data.Add("email", email)
+
+		r, err := http.NewRequest("POST", "http://localhost/registration", strings.NewReader(data.Encode()))
+		require.NoError(t, err)
+
+		r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+		r.AddCookie(test.CSRFCookie())
+		w := httptest.NewRecorder()
+
+		sut := registration.Page(session.Dependency{})
+		sut(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Regexp(t, `<h2 style="color:red">(Incorrect email|Email cannot be longer than 254 characters)</h2>`, w.Body.String())
+	}
+}
@@ -0,0 +1,254 @@
+package registration
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/credentials"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/mail"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// Paths of forgot password[/password/forgot] and password reset[/password/reset] pages
+const (
+	ForgotPath = "/password/forgot"
+	ResetPath  = "/password/reset"
+)
+
+// paths to forgot password and password reset template files
+const (
+	pathTemplateForgot = "pages/registration/template/forgot.html"
+	pathTemplateReset  = "pages/registration/template/reset.html"
+)
+
+const selectEmail = "SELECT email FROM users WHERE username = ?;"
+
+// Redis keys:
+// 1) "reset:<hash of token>" contains username until password is reset or link expires,
+// 2) "resetSent:<username>" exists after link was sent, so user's mailbox cannot be flooded by links.
+const (
+	resetPrefix     = "reset:"
+	resetSentPrefix = "resetSent:"
+	resetLifetime   = time.Hour
+	resetInterval   = 5 * time.Minute
+	resetTokenLen   = 32 // length of reset token in bytes
+)
+
+// TemplateForgot contains data for forgot password[/password/forgot] page template
+type TemplateForgot struct {
+	Warning template.HTML
+}
+
+// TemplateReset contains data for password reset[/password/reset] page template
+type TemplateReset struct {
+	Warning template.HTML
+	Token   string
+	Expired bool
+}
+
+// Forgot returns HandleFunc for forgot password[/password/forgot] page
+func Forgot(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// getting CSRF token from cookie, user doesn't have session
+		csrfToken, err := csrf.Cookie(w, r, dep.SecureCookie)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+		// creating template for forgot password page
+		page, err := tmp.CreateFormTemplate(pathTemplateForgot, csrfToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			err = page.Execute(w, TemplateForgot{})
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			// handling case when form was sent from another site
+			if !csrf.ValidCookie(r) {
+				err = page.Execute(w, TemplateForgot{"<h2 style=\"color:red\">Form expired. Please try again</h2>"})
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			err = sendResetLink(dep, r.FormValue("username"))
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			// the same answer is returned for every username, so it cannot be used to find out users emails
+			err = page.Execute(w, TemplateForgot{"<h2 style=\"color:green\">If user has email, link to reset password was sent to it</h2>"})
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// sendResetLink sends link to password reset page to email of user.
+// Nothing is sent if user doesn't exist, doesn't have email or link was sent recently.
+func sendResetLink(dep session.Dependency, username string) error {
+	email := ""
+	err := dep.Db.QueryRow(selectEmail, username).Scan(&email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if email == "" {
+		return nil
+	}
+
+	_, err = redis.String(dep.Redis.Do("SET", resetSentPrefix+username, 1, "EX", resetInterval.Seconds(), "NX"))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil
+		}
+		return err
+	}
+
+	token := make([]byte, resetTokenLen)
+	_, err = rand.Read(token)
+	if err != nil {
+		return err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+	// only hash of token is stored, so token cannot be stolen from redis
+	_, err = dep.Redis.Do("SET", resetPrefix+session.ID(encoded), username, "EX", resetLifetime.Seconds())
+	if err != nil {
+		return err
+	}
+
+	return dep.Mail.Send(mail.Message{
+		To:      email,
+		Subject: "Password reset",
+		Body: "Hello, " + username + "!\n\n" +
+			"To reset your password open link:\n" +
+			dep.SiteURL + ResetPath + "?token=" + url.QueryEscape(encoded) + "\n\n" +
+			"Link expires in 1 hour. If you didn't request password reset just ignore this email.\n",
+	})
+}
+
+// Reset returns HandleFunc for password reset[/password/reset] page
+func Reset(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// link contains token, so it shouldn't be leaked to another sites
+		w.Header().Set("Referrer-Policy", "no-referrer")
+
+		// getting CSRF token from cookie, user doesn't have session
+		csrfToken, err := csrf.Cookie(w, r, dep.SecureCookie)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+		// creating template for password reset page
+		page, err := tmp.CreateFormTemplate(pathTemplateReset, csrfToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		token := r.FormValue("token")
+		username, err := redis.String(dep.Redis.Do("GET", resetPrefix+session.ID(token)))
+		if err != nil {
+			if err == redis.ErrNil {
+				err = page.Execute(w, TemplateReset{Warning: "<h2 style=\"color:red\">Link expired. Please request new one</h2>", Expired: true})
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			err = page.Execute(w, TemplateReset{Token: token})
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			// handling case when form was sent from another site
+			if !csrf.ValidCookie(r) {
+				err = page.Execute(w, TemplateReset{Warning: "<h2 style=\"color:red\">Form expired. Please try again</h2>", Token: token})
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			password1 := r.FormValue("password1")
+			err = passwordsValidator(password1, r.FormValue("password2"))
+			if err != nil {
+				err = page.Execute(w, TemplateReset{Warning: "<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>", Token: token})
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			err = resetPassword(dep, username, token, password1)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+	}
+}
+
+// resetPassword replaces password of user and removes reset token.
+// All sessions and API tokens of user are removed, because they could be opened by someone who knew old password.
+func resetPassword(dep session.Dependency, username, token, password string) error {
+	hashedPass, err := credentials.Hash(password)
+	if err != nil {
+		return err
+	}
+	err = setPassword(dep.Db, username, hashedPass)
+	if err != nil {
+		return err
+	}
+
+	_, err = dep.Redis.Do("DEL", resetPrefix+session.ID(token))
+	if err != nil {
+		return err
+	}
+	err = session.KillSessions(dep.Redis, username)
+	if err != nil {
+		return err
+	}
+	return throttle.Reset(dep.Redis, throttle.LoginByUsername, username)
+}
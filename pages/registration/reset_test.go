@@ -0,0 +1,322 @@
+package registration_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/mail"
+	"github.com/vpoletaev11/fileHostingSite/pages/registration"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// mailbox is mail.Sender which keeps sent messages
+type mailbox struct {
+	messages []mail.Message
+}
+
+func (m *mailbox) Send(msg mail.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// resetToken is token of password reset link in tests
+const resetToken = "token"
+
+// sendForgot sends POST request with forgot password form
+func sendForgot(dep session.Dependency, username string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("csrf_token", test.CSRFToken)
+	data.Set("username", username)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/password/forgot", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+
+	sut := registration.Forgot(dep)
+	sut(w, r)
+
+	return w
+}
+
+// sendReset sends POST request with password reset form
+func sendReset(dep session.Dependency, password1, password2 string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("csrf_token", test.CSRFToken)
+	data.Set("token", resetToken)
+	data.Set("password1", password1)
+	data.Set("password2", password2)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/password/reset", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(test.CSRFCookie())
+
+	sut := registration.Reset(dep)
+	sut(w, r)
+
+	return w
+}
+
+// TestForgotSuccessGET checks workability of GET requests handler in Forgot()
+func TestForgotSuccessGET(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/password/forgot", nil)
+	require.NoError(t, err)
+	r.AddCookie(test.CSRFCookie())
+
+	sut := registration.Forgot(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Forgot password</title>
+    <link rel="stylesheet" href="/assets/css/login.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="loginForm">
+        <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <p>Username: <input required maxlength="20" type="text" name="username"></p>
+            <input type="submit" value="Send reset link">
+            <p><a href="/login" style="color: #c82020">Back to login</a></p>
+            
+        </form>
+    </div>
+</body>`, w.Body)
+}
+
+// TestForgotSuccessPOST tests case when reset link is sent to email of user
+func TestForgotSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	box := &mailbox{}
+	dep.Mail = box
+	dep.SiteURL = "https://example.com"
+	sqlMock.ExpectQuery("SELECT email FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("example@example.com"))
+	redisMock.Command("SET", "resetSent:example", 1, "EX", float64(300), "NX").Expect("OK")
+	token := redisMock.Command("SET", redigomock.NewAnyData(), "example", "EX", float64(3600)).Expect("OK")
+
+	w := sendForgot(dep, "example")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">If user has email, link to reset password was sent to it</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(token))
+	require.Len(t, box.messages, 1)
+	assert.Equal(t, "example@example.com", box.messages[0].To)
+	assert.Equal(t, "Password reset", box.messages[0].Subject)
+	assert.Contains(t, box.messages[0].Body, "https://example.com/password/reset?token=")
+}
+
+// TestForgotUnknownUserPOST tests case when user doesn't exist, answer shouldn't differ from success case
+func TestForgotUnknownUserPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	box := &mailbox{}
+	dep.Mail = box
+	sqlMock.ExpectQuery("SELECT email FROM users WHERE username =").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+
+	w := sendForgot(dep, "unknown")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">If user has email, link to reset password was sent to it</h2>`)
+	assert.Len(t, box.messages, 0)
+}
+
+// TestForgotWithoutEmailPOST tests case when user doesn't have email
+func TestForgotWithoutEmailPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	box := &mailbox{}
+	dep.Mail = box
+	sqlMock.ExpectQuery("SELECT email FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow(""))
+
+	w := sendForgot(dep, "example")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, box.messages, 0)
+}
+
+// TestForgotSentRecentlyPOST tests case when link was sent to user a few minutes ago
+func TestForgotSentRecentlyPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	box := &mailbox{}
+	dep.Mail = box
+	sqlMock.ExpectQuery("SELECT email FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow("example@example.com"))
+	redisMock.Command("SET", "resetSent:example", 1, "EX", float64(300), "NX").Expect(nil)
+
+	w := sendForgot(dep, "example")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">If user has email, link to reset password was sent to it</h2>`)
+	assert.Len(t, box.messages, 0)
+}
+
+// TestForgotInvalidCSRFToken tests case when form was sent from another site
+func TestForgotInvalidCSRFToken(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+
+	data := url.Values{}
+	data.Set("csrf_token", "wrong")
+	data.Set("username", "example")
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/password/forgot", strings.NewReader(data.Encode()))
+	require.NoError(t, err)
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(test.CSRFCookie())
+
+	sut := registration.Forgot(dep)
+	sut(w, r)
+
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Form expired. Please try again</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestResetSuccessGET checks workability of GET requests handler in Reset()
+func TestResetSuccessGET(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", "reset:"+session.ID(resetToken)).Expect("example")
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/password/reset?token="+resetToken, nil)
+	require.NoError(t, err)
+	r.AddCookie(test.CSRFCookie())
+
+	sut := registration.Reset(dep)
+	sut(w, r)
+
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Password reset</title>
+    <link rel="stylesheet" href="/assets/css/login.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="loginForm">
+        <form action="/password/reset" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="token" value="token">
+            <p>New password: <input required maxlength="40" type="password" name="password1"></p>
+            <p>Repeat new password: <input required maxlength="40" type="password" name="password2"></p>
+            <input type="submit" value="Reset password">
+        </form>
+        
+    </div>
+</body>`, w.Body)
+}
+
+// TestResetExpiredGET tests case when reset link is expired or already used
+func TestResetExpiredGET(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", "reset:"+session.ID(resetToken)).Expect(nil)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/password/reset?token="+resetToken, nil)
+	require.NoError(t, err)
+	r.AddCookie(test.CSRFCookie())
+
+	sut := registration.Reset(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Password reset</title>
+    <link rel="stylesheet" href="/assets/css/login.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="loginForm">
+        <p><a href="/password/forgot" style="color: #c82020">Request new link</a></p>
+        <h2 style="color:red">Link expired. Please request new one</h2>
+    </div>
+</body>`, w.Body)
+}
+
+// TestResetSuccessPOST tests case when password is reset, all sessions of user are closed and API tokens stop working
+func TestResetSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("GET", "reset:"+session.ID(resetToken)).Expect("example")
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE users SET password").WithArgs(anyString{}, "example").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE username = \\?;").WithArgs("example").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	used := redisMock.Command("DEL", "reset:"+session.ID(resetToken)).Expect(int64(1))
+	redisMock.Command("SMEMBERS", "sessions:example").Expect([]interface{}{[]byte("id1")})
+	kill := redisMock.Command("DEL", "id1", "session:id1").Expect(int64(2))
+	redisMock.Command("DEL", "sessions:example").Expect(int64(1))
+	unlock := redisMock.Command("DEL", "failures:login:user:example", "lock:login:user:example")
+
+	w := sendReset(dep, "new password", "new password")
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	assert.Equal(t, 1, redisMock.Stats(used))
+	assert.Equal(t, 1, redisMock.Stats(kill))
+	assert.Equal(t, 1, redisMock.Stats(unlock))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestResetRevokesAPITokensPOST tests case when API token created before reset is used after reset
+func TestResetRevokesAPITokensPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("GET", "reset:"+session.ID(resetToken)).Expect("example")
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("UPDATE users SET password").WithArgs(anyString{}, "example").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE username = \\?;").WithArgs("example").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	redisMock.Command("DEL", "reset:"+session.ID(resetToken)).Expect(int64(1))
+	redisMock.Command("SMEMBERS", "sessions:example").Expect([]interface{}{})
+	redisMock.Command("DEL", "sessions:example").Expect(int64(1))
+	redisMock.Command("DEL", "failures:login:user:example", "lock:login:user:example")
+
+	w := sendReset(dep, "new password", "new password")
+	require.Equal(t, http.StatusSeeOther, w.Code)
+
+	// revoked token isn't found anymore
+	sqlMock.ExpectQuery("SELECT (.+) FROM apiTokens").WithArgs(apitokens.Hash("fhs_token")).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "username", "scopes"}))
+	handler := func(dep session.Dependency) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler is called with revoked token")
+		}
+	}
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/files", nil)
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer fhs_token")
+	w = httptest.NewRecorder()
+	session.APIWrapper(handler, dep)(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestResetMismatchingPasswordsPOST tests case when new passwords doesn't match
+func TestResetMismatchingPasswordsPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("GET", "reset:"+session.ID(resetToken)).Expect("example")
+
+	w := sendReset(dep, "new password", "another password")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Passwords doesn't match</h2>`)
+	assert.Contains(t, w.Body.String(), `<input type="hidden" name="token" value="token">`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
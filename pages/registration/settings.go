@@ -0,0 +1,299 @@
+package registration
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/fileops"
+	"github.com/vpoletaev11/fileHostingSite/pages/twofactor"
+	"github.com/vpoletaev11/fileHostingSite/pages/upload"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// path to account settings[/settings] template file
+const pathTemplateSettings = "pages/registration/template/settings.html"
+
+const (
+	selectSettings = "SELECT timezone, dateFormat FROM users WHERE username = ?;"
+
+	updateSettings = "UPDATE users SET timezone = ?, dateFormat = ? WHERE username = ?;"
+
+	selectUserExists = "SELECT COUNT(*) FROM users WHERE username = ?;"
+
+	selectUserFiles = "SELECT id FROM files WHERE owner = ?;"
+
+	deleteUserRecoveryCodes = "DELETE FROM recoveryCodes WHERE username = ?;"
+
+	deleteUserFailedLogins = "DELETE FROM failedLogins WHERE username = ?;"
+
+	deleteUser = "DELETE FROM users WHERE username = ?;"
+)
+
+// TemplateSettings contains data for account settings[/settings] page template
+type TemplateSettings struct {
+	Warning     template.HTML
+	Username    string
+	Timezone    string
+	DateFormat  string
+	DateFormats []dbformat.DateFormat
+}
+
+// Settings returns HandleFunc for account settings[/settings] page
+func Settings(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for account settings page
+		page, err := tmp.CreateFormTemplate(pathTemplateSettings, dep.CSRFToken, pathTemplateTimezones)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			data, err := settingsData(dep)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			warning := template.HTML("")
+			switch r.FormValue("action") {
+			case "preferences":
+				warning, err = savePreferences(dep, r.FormValue("timezone"), r.FormValue("dateFormat"))
+
+			case "delete":
+				deleted := false
+				warning, deleted, err = deleteAccount(dep, r)
+				if err == nil && deleted {
+					// user doesn't exist anymore, so sessions of user are removed and browser forgets session cookie
+					err = session.KillSessions(dep.Redis, dep.Username)
+					if err != nil {
+						errhand.InternalError(err, w)
+						return
+					}
+					http.SetCookie(w, session.ExpiredCookie(dep))
+					http.Redirect(w, r, "/login", http.StatusSeeOther)
+					return
+				}
+
+			default:
+				warning = "<h2 style=\"color:red\">Unknown action</h2>"
+			}
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			data, err := settingsData(dep)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			data.Warning = warning
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// settingsData returns current settings of user
+func settingsData(dep session.Dependency) (TemplateSettings, error) {
+	data := TemplateSettings{Username: dep.Username, DateFormats: dbformat.DateFormats}
+	err := dep.Db.QueryRow(selectSettings, dep.Username).Scan(&data.Timezone, &data.DateFormat)
+	if err != nil {
+		return TemplateSettings{}, err
+	}
+	return data, nil
+}
+
+// savePreferences changes timezone and date format of user and returns warning for user
+func savePreferences(dep session.Dependency, timezone, dateFormat string) (template.HTML, error) {
+	err := timezoneValidator(timezone)
+	if err != nil {
+		return "<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>", nil
+	}
+	err = dateFormatValidator(dateFormat)
+	if err != nil {
+		return "<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>", nil
+	}
+
+	_, err = dep.Db.Exec(updateSettings, timezone, dateFormat, dep.Username)
+	if err != nil {
+		return "", err
+	}
+	return "<h2 style=\"color:green\">Settings saved</h2>", nil
+}
+
+// dateFormatValidator checks if date format is one of dbformat.DateFormats
+func dateFormatValidator(dateFormat string) error {
+	for _, f := range dbformat.DateFormats {
+		if f.Name == dateFormat {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown date format")
+}
+
+// deleteAccount removes user if password is correct and returns true when user was removed.
+// Files of user are removed or given to another user, votes of user are removed from files ratings.
+// All rows of user are removed in one transaction, so account cannot be removed partially.
+// If account cannot be removed deleteAccount returns warning for user.
+func deleteAccount(dep session.Dependency, r *http.Request) (template.HTML, bool, error) {
+	files := r.FormValue("files")
+	recipient := r.FormValue("recipient")
+	switch files {
+	case "delete":
+	case "reassign":
+		if recipient == "" || recipient == dep.Username {
+			return "<h2 style=\"color:red\">Please choose another user to give files</h2>", false, nil
+		}
+		count := 0
+		err := dep.Db.QueryRow(selectUserExists, recipient).Scan(&count)
+		if err != nil {
+			return "", false, err
+		}
+		if count == 0 {
+			return "<h2 style=\"color:red\">User " + template.HTML(template.HTMLEscapeString(recipient)) + " doesn't exist</h2>", false, nil
+		}
+	default:
+		return "<h2 style=\"color:red\">Please choose what to do with files</h2>", false, nil
+	}
+
+	warning, err := checkPassword(dep, r.FormValue("password"))
+	if err != nil || warning != "" {
+		return warning, false, err
+	}
+
+	tx, err := dep.Db.Begin()
+	if err != nil {
+		return "", false, err
+	}
+	deleted := map[string]string{} // hashes of deleted files by their IDs
+	if files == "reassign" {
+		err = fileops.ReassignTx(tx, dep.Username, recipient)
+	} else {
+		deleted, err = deleteUserFiles(tx, dep.Username)
+	}
+	if err != nil {
+		tx.Rollback()
+		return "", false, err
+	}
+	uploads, err := deleteUserRows(tx, dep.Username)
+	if err != nil {
+		tx.Rollback()
+		return "", false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", false, err
+	}
+
+	// account is already removed, so errors of cleanup are only logged:
+	// stored data is fixed by "fhs-admin check-storage -repair" and partial files by "fhs-admin expire-uploads"
+	if files == "reassign" {
+		dep.Search.ReplaceOwner(dep.Username, recipient)
+	}
+	for id, hash := range deleted {
+		err = fileops.ReleaseData(dep.Db, dep.Storage, id, hash)
+		if err != nil {
+			log.Println("settings: removing data of file", id, "error:", err)
+		}
+		if fileID, err := strconv.Atoi(id); err == nil {
+			dep.Search.Remove(fileID)
+		}
+	}
+	upload.RemovePartialFiles(uploads)
+	err = twofactor.Forget(dep.Redis, dep.Username)
+	if err != nil {
+		log.Println("settings: removing two-factor authentication data error:", err)
+	}
+	err = throttle.Reset(dep.Redis, throttle.LoginByUsername, dep.Username)
+	if err != nil {
+		log.Println("settings: removing failed login attempts error:", err)
+	}
+	return "", true, nil
+}
+
+// deleteUserRows removes votes, recovery codes, API tokens, failed logins, resumable uploads and row of user
+// and returns IDs of removed resumable uploads
+func deleteUserRows(tx *sql.Tx, username string) ([]string, error) {
+	err := fileops.RemoveVotesTx(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(deleteUserRecoveryCodes, username)
+	if err != nil {
+		return nil, err
+	}
+	err = apitokens.RevokeAll(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(deleteUserFailedLogins, username)
+	if err != nil {
+		return nil, err
+	}
+	uploads, err := upload.DeleteUploadsTx(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(deleteUser, username)
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// deleteUserFiles removes info of all files of user and returns hashes of their data by IDs of files
+func deleteUserFiles(tx *sql.Tx, username string) (map[string]string, error) {
+	rows, err := tx.Query(selectUserFiles, username)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		id := ""
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := map[string]string{}
+	for _, id := range ids {
+		deleted[id], err = fileops.DeleteTx(tx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return deleted, nil
+}
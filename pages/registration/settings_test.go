@@ -0,0 +1,253 @@
+package registration_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/registration"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+)
+
+// expectSettings adds expectation of query which selects current settings of user
+func expectSettings(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "eu"))
+}
+
+// sendSettings sends POST request with account settings form
+func sendSettings(dep session.Dependency, data url.Values) *httptest.ResponseRecorder {
+	data.Set("csrf_token", test.CSRFToken)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/settings", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+
+	sut := registration.Settings(dep)
+	sut(w, r)
+
+	return w
+}
+
+// TestSettingsSuccessGET checks workability of GET requests handler in Settings()
+func TestSettingsSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectSettings(sqlMock)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/settings", nil)
+	require.NoError(t, err)
+
+	sut := registration.Settings(dep)
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `<div class="username">Welcome, username</div>`)
+	assert.Contains(t, body, `<option value="Europe/Moscow" selected>Europe/Moscow</option>`)
+	assert.Contains(t, body, `<option value="iso">YYYY-MM-DD hh:mm:ss</option>`)
+	assert.Contains(t, body, `<option value="eu" selected>DD.MM.YYYY hh:mm:ss</option>`)
+	assert.Contains(t, body, `<input type="hidden" name="action" value="delete">`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsPreferencesSuccessPOST tests case when timezone and date format are changed
+func TestSettingsPreferencesSuccessPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectExec("UPDATE users SET timezone = (.+), dateFormat = (.+) WHERE username").WithArgs("Europe/Moscow", "eu", "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"preferences"}, "timezone": {"Europe/Moscow"}, "dateFormat": {"eu"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Settings saved</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsPreferencesWrongTimezonePOST tests case when inputted timezone is unknown
+func TestSettingsPreferencesWrongTimezonePOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"preferences"}, "timezone": {"Mars/Olympus"}, "dateFormat": {"eu"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">`)
+	assert.NotContains(t, w.Body.String(), "Settings saved")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsPreferencesWrongDateFormatPOST tests case when inputted date format is unknown
+func TestSettingsPreferencesWrongDateFormatPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"preferences"}, "timezone": {"Europe/Moscow"}, "dateFormat": {"unknown"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown date format</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsPreferencesDBErrorPOST tests case when database returns error
+func TestSettingsPreferencesDBErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectExec("UPDATE users SET timezone").WillReturnError(fmt.Errorf("Testing error"))
+
+	w := sendSettings(dep, url.Values{"action": {"preferences"}, "timezone": {"Europe/Moscow"}, "dateFormat": {"eu"}})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+// expectDeleteUserRows adds expectations of queries which remove rows of user inside of account deletion transaction
+func expectDeleteUserRows(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT filesRating.fileID").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"fileID", "rating", "owner"}))
+	sqlMock.ExpectExec("DELETE FROM filesRating WHERE voter").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM failedLogins WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectQuery("SELECT id FROM partialUploads WHERE owner = (.+) FOR UPDATE").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("0123456789abcdef0123456789abcdef"))
+	sqlMock.ExpectExec("DELETE FROM partialUploads WHERE owner").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM users WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// TestSettingsDeleteSuccessPOST tests case when account is removed together with files, votes and other data of user
+func TestSettingsDeleteSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT id FROM files WHERE owner =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	sqlMock.ExpectQuery("SELECT owner, rating, hash FROM files").WithArgs("1").WillReturnRows(sqlmock.NewRows([]string{"owner", "rating", "hash"}).AddRow("username", 0, ""))
+	sqlMock.ExpectExec("DELETE FROM filesRating WHERE fileID").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM file_tags").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("UPDATE users SET rating").WithArgs(0, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM files WHERE id").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectDeleteUserRows(sqlMock)
+	sqlMock.ExpectCommit()
+	forget := redisMock.Command("DEL", "totp:pending:username", "totp:used:username").Expect(int64(1))
+	reset := redisMock.Command("DEL", "failures:login:user:username", "lock:login:user:username").Expect(int64(1))
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{[]byte("id")})
+	kill := redisMock.Command("DEL", "id", "session:id").Expect(int64(2))
+	redisMock.Command("DEL", "sessions:username").Expect(int64(1))
+
+	w := sendSettings(dep, url.Values{"action": {"delete"}, "files": {"delete"}, "password": {"example"}})
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	assert.Equal(t, 1, redisMock.Stats(forget))
+	assert.Equal(t, 1, redisMock.Stats(reset))
+	assert.Equal(t, 1, redisMock.Stats(kill))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, session.CookieName, cookies[0].Name)
+	assert.Equal(t, -1, cookies[0].MaxAge)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsDeleteReassignSuccessPOST tests case when files of removed account are given to another user
+func TestSettingsDeleteReassignSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT(.+) FROM users WHERE username =").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT COALESCE(.+) FROM files WHERE owner").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"rating"}).AddRow(25))
+	sqlMock.ExpectExec("UPDATE files SET owner").WithArgs("example", "username").WillReturnResult(sqlmock.NewResult(0, 2))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating - (.+) WHERE username").WithArgs(25, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("UPDATE users SET rating = rating \\+ (.+) WHERE username").WithArgs(25, "example").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectDeleteUserRows(sqlMock)
+	sqlMock.ExpectCommit()
+	redisMock.Command("DEL", "totp:pending:username", "totp:used:username").Expect(int64(0))
+	redisMock.Command("DEL", "failures:login:user:username", "lock:login:user:username").Expect(int64(0))
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{})
+	redisMock.Command("DEL", "sessions:username").Expect(int64(0))
+
+	w := sendSettings(dep, url.Values{"action": {"delete"}, "files": {"reassign"}, "recipient": {"example"}, "password": {"example"}})
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsDeleteDBErrorPOST tests case when account deletion transaction is rolled back because of database error
+func TestSettingsDeleteDBErrorPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT id FROM files WHERE owner =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery("SELECT filesRating.fileID").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"fileID", "rating", "owner"}))
+	sqlMock.ExpectExec("DELETE FROM filesRating WHERE voter").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username").WithArgs("username").WillReturnError(fmt.Errorf("Testing error"))
+	sqlMock.ExpectRollback()
+
+	w := sendSettings(dep, url.Values{"action": {"delete"}, "files": {"delete"}, "password": {"example"}})
+
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsDeleteNotExistingRecipientPOST tests case when files are given to user which doesn't exist
+func TestSettingsDeleteNotExistingRecipientPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT(.+) FROM users WHERE username =").WithArgs("<b>nobody</b>").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"delete"}, "files": {"reassign"}, "recipient": {"<b>nobody</b>"}, "password": {"example"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">User &lt;b&gt;nobody&lt;/b&gt; doesn't exist</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsDeleteWithoutFilesChoicePOST tests case when user didn't choose what to do with files
+func TestSettingsDeleteWithoutFilesChoicePOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"delete"}, "password": {"example"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Please choose what to do with files</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsDeleteWrongPasswordPOST tests case when account isn't removed because of wrong password
+func TestSettingsDeleteWrongPasswordPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("TTL", "lock:login:user:username").Expect(int64(-2))
+	sqlMock.ExpectQuery("SELECT password FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow(hashedExample))
+	redisMock.Command("INCR", "failures:login:user:username").Expect(int64(1))
+	redisMock.Command("EXPIRE", "failures:login:user:username", throttle.LoginByUsername.Lockout.Seconds())
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"delete"}, "files": {"delete"}, "password": {"wrong"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong current password</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestSettingsUnknownActionPOST tests case when action of form is unknown
+func TestSettingsUnknownActionPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectSettings(sqlMock)
+
+	w := sendSettings(dep, url.Values{"action": {"unknown"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown action</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
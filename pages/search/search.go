@@ -0,0 +1,367 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/pagination"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// path to search[/search] template file
+const pathTemplateSearch = "pages/search/template/search.html"
+
+const (
+	// match uses FULLTEXT index on files.label and files.description
+	match = "MATCH(label, description) AGAINST(? IN NATURAL LANGUAGE MODE)"
+
+	selectFileInfo = "SELECT " + dbformat.FileInfoColumns + " FROM files"
+
+	countRows = "SELECT COUNT(*) FROM files"
+)
+
+const rowsInPage = 15 // how many rows of file info will be displayed on page
+
+// maxQueryLen is max length of search query
+const maxQueryLen = 100
+
+// maxIndexResults is max count of files found by search index, the least relevant files are ignored
+const maxIndexResults = 1000
+
+// dateLayout is layout of dates in date range filter
+const dateLayout = "2006-01-02"
+
+// ErrNothingFound returns by NewQuery when search index didn't find files matched search query
+var ErrNothingFound = errors.New("Nothing found")
+
+// Sorts contains available sort orders of search results
+var Sorts = []string{"relevance", "date", "rating"}
+
+// TemplateSearch contains data for search[/search] page template
+type TemplateSearch struct {
+	Warning       template.HTML
+	Username      string
+	Filter        Filter
+	Categories    []categories.Category // tree of all categories including retired
+	Sorts         []string
+	Found         int
+	LinkList      []pagination.Link
+	UploadedFiles []dbformat.FileInfo
+}
+
+// Filter contains search parameters getted from GET request
+type Filter struct {
+	Query    string
+	Category string
+	Owner    string
+	MinSize  string // in MB
+	MaxSize  string // in MB
+	From     string // date in format YYYY-MM-DD
+	To       string // date in format YYYY-MM-DD
+	Sort     string
+}
+
+// Query contains clauses of SQL query which selects files matched search parameters
+type Query struct {
+	Where     string // empty if there are no search conditions
+	WhereArgs []interface{}
+	OrderBy   string
+	OrderArgs []interface{}
+}
+
+// Page returns HandleFunc for search[/search] page
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for search page
+		page, err := tmp.CreateTemplate(pathTemplateSearch)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			filter := NewFilter(r)
+			categoriesList, err := categories.All(dep.Db)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			data := TemplateSearch{Username: dep.Username, Filter: filter, Categories: categories.Tree(categoriesList), Sorts: Sorts}
+
+			// empty form is showed without results
+			if filter.Empty() {
+				err = page.Execute(w, data)
+				if err != nil {
+					errhand.InternalError(err, w)
+				}
+				return
+			}
+
+			location, err := dbformat.UserLocation(dep.Db, dep.Username)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			query, err := NewQuery(filter, dep.Search, location, categoriesList)
+			if err == ErrNothingFound {
+				data.Warning = "<h2 style=\"color:red\">Nothing found</h2>"
+				err = page.Execute(w, data)
+				if err != nil {
+					errhand.InternalError(err, w)
+				}
+				return
+			}
+			if err != nil {
+				data.Warning = "<h2 style=\"color:red\">" + template.HTML(template.HTMLEscapeString(err.Error())) + "</h2>"
+				err = page.Execute(w, data)
+				if err != nil {
+					errhand.InternalError(err, w)
+				}
+				return
+			}
+
+			// getting count of pages
+			data.Found, err = query.Count(dep.Db)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			if data.Found == 0 {
+				data.Warning = "<h2 style=\"color:red\">Nothing found</h2>"
+			}
+			pagesCount := pagination.PagesCount(data.Found, rowsInPage)
+
+			// getting number of current page
+			numPage, err := pagination.NumPage(r)
+			if err != nil || numPage > pagesCount {
+				fmt.Fprintln(w, "ERROR: Incorrect get request")
+				return
+			}
+
+			// getting files info for current page
+			clauses, args := query.Page(numPage, rowsInPage)
+			data.UploadedFiles, err = dbformat.FormatedFilesInfo(dep.Username, dep.Db, selectFileInfo+clauses, args...)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			// creating navigation bar if count of pages > 1
+			if pagesCount > 1 {
+				data.LinkList = pagination.NavigationBar(pagesCount, numPage, "/search?"+filter.values().Encode()+"&p=")
+			}
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// NewFilter gets search parameters from GET request
+func NewFilter(r *http.Request) Filter {
+	q := r.URL.Query()
+	return Filter{
+		Query:    strings.TrimSpace(q.Get("q")),
+		Category: q.Get("category"),
+		Owner:    strings.TrimSpace(q.Get("owner")),
+		MinSize:  strings.TrimSpace(q.Get("minSize")),
+		MaxSize:  strings.TrimSpace(q.Get("maxSize")),
+		From:     strings.TrimSpace(q.Get("from")),
+		To:       strings.TrimSpace(q.Get("to")),
+		Sort:     q.Get("sort"),
+	}
+}
+
+// Empty returns true if no search parameters was inputted
+func (f Filter) Empty() bool {
+	return f.Query == "" && f.Category == "" && f.Owner == "" && f.MinSize == "" && f.MaxSize == "" && f.From == "" && f.To == ""
+}
+
+// values returns search parameters as url values, used to create links of navigation bar
+func (f Filter) values() url.Values {
+	v := url.Values{}
+	add := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	add("q", f.Query)
+	add("category", f.Category)
+	add("owner", f.Owner)
+	add("minSize", f.MinSize)
+	add("maxSize", f.MaxSize)
+	add("from", f.From)
+	add("to", f.To)
+	add("sort", f.Sort)
+	return v
+}
+
+// NewQuery returns clauses of SQL query which selects files matched filter.
+// Text of query is searched in search index if index isn't nil, otherwise FULLTEXT index of MySQL is used.
+// If search parameters are incorrect NewQuery returns error with message for user.
+func NewQuery(f Filter, index *searchindex.Index, location *time.Location, categoriesList []categories.Category) (Query, error) {
+	var ids []int
+	if index != nil && f.Query != "" {
+		ids = []int{}
+		for _, result := range index.Search(f.Query, maxIndexResults) {
+			ids = append(ids, result.ID)
+		}
+	}
+	where, args, err := f.where(location, ids, categoriesList)
+	if err != nil {
+		return Query{}, err
+	}
+	orderBy, orderArgs := f.orderBy(ids)
+	return Query{Where: where, WhereArgs: args, OrderBy: orderBy, OrderArgs: orderArgs}, nil
+}
+
+// where returns WHERE clause of search query and its arguments.
+// If ids isn't nil, text of query was found by search index and only files with these IDs are selected.
+// Files of subcategories are found together with files of selected category.
+// Dates are parsed in timezone of user and converted to UTC, because upload dates are stored in UTC.
+// If search parameters are incorrect where returns error with message for user.
+// If search index didn't find any file where returns ErrNothingFound.
+func (f Filter) where(location *time.Location, ids []int, categoriesList []categories.Category) (string, []interface{}, error) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if f.Query != "" {
+		if len(f.Query) > maxQueryLen {
+			return "", nil, fmt.Errorf("Search query are too long")
+		}
+		switch {
+		case ids == nil:
+			conditions = append(conditions, match)
+			args = append(args, f.Query)
+		case len(ids) == 0:
+			return "", nil, ErrNothingFound
+		default:
+			conditions = append(conditions, "id IN (?"+strings.Repeat(", ?", len(ids)-1)+")")
+			for _, id := range ids {
+				args = append(args, id)
+			}
+		}
+	}
+
+	if f.Category != "" {
+		if _, ok := categories.Find(categoriesList, f.Category); !ok {
+			return "", nil, categories.ErrUnknown
+		}
+		slugs := categories.WithChildren(categoriesList, f.Category)
+		conditions = append(conditions, "category IN (?"+strings.Repeat(", ?", len(slugs)-1)+")")
+		for _, slug := range slugs {
+			args = append(args, slug)
+		}
+	}
+
+	if f.Owner != "" {
+		conditions = append(conditions, "owner = ?")
+		args = append(args, f.Owner)
+	}
+
+	if f.MinSize != "" {
+		size, err := sizeBytes(f.MinSize)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "filesizeBytes >= ?")
+		args = append(args, size)
+	}
+
+	if f.MaxSize != "" {
+		size, err := sizeBytes(f.MaxSize)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "filesizeBytes <= ?")
+		args = append(args, size)
+	}
+
+	if f.From != "" {
+		from, err := time.ParseInLocation(dateLayout, f.From, location)
+		if err != nil {
+			return "", nil, fmt.Errorf("Incorrect date. Please use format YYYY-MM-DD")
+		}
+		conditions = append(conditions, "uploadDate >= ?")
+		args = append(args, from.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	if f.To != "" {
+		to, err := time.ParseInLocation(dateLayout, f.To, location)
+		if err != nil {
+			return "", nil, fmt.Errorf("Incorrect date. Please use format YYYY-MM-DD")
+		}
+		// files uploaded during the last day of range are included
+		conditions = append(conditions, "uploadDate < ?")
+		args = append(args, to.AddDate(0, 0, 1).UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// orderBy returns ORDER BY clause of search query and its arguments.
+// Sorting by relevance is possible only with search query, otherwise newest files are showed first.
+// If ids isn't nil, they are sorted by relevance already, so files are showed in order of ids.
+func (f Filter) orderBy(ids []int) (string, []interface{}) {
+	switch f.Sort {
+	case "rating":
+		return " ORDER BY rating DESC, uploadDate DESC", nil
+	case "date":
+		return " ORDER BY uploadDate DESC", nil
+	}
+	if f.Query == "" {
+		return " ORDER BY uploadDate DESC", nil
+	}
+	if ids != nil {
+		args := []interface{}{}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		return " ORDER BY FIELD(id" + strings.Repeat(", ?", len(ids)) + ")", args
+	}
+	return " ORDER BY " + match + " DESC, uploadDate DESC", []interface{}{f.Query}
+}
+
+// sizeBytes converts size in MB to bytes
+func sizeBytes(sizeMb string) (int64, error) {
+	size, err := strconv.ParseFloat(sizeMb, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("Incorrect file size")
+	}
+	return int64(size * 1024 * 1024), nil
+}
+
+// Count returns count of files matched search parameters
+func (q Query) Count(db *sql.DB) (int, error) {
+	count := 0
+	err := db.QueryRow(countRows+q.Where+";", q.WhereArgs...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Page returns clauses of query which selects one page of found files (they should be added after FROM) and their arguments
+func (q Query) Page(numPage, rowsInPage int) (string, []interface{}) {
+	args := append(append([]interface{}{}, q.WhereArgs...), q.OrderArgs...)
+	return q.Where + q.OrderBy + " LIMIT ?, ?;", append(args, (numPage-1)*rowsInPage, rowsInPage)
+}
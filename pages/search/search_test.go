@@ -0,0 +1,219 @@
+package search_test
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/search"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// fileInfoRows contains columns of files table returned by files info query
+var fileInfoRows = []string{
+	"id",
+	"label",
+	"filesizeBytes",
+	"description",
+	"owner",
+	"category",
+	"uploadDate",
+	"rating",
+}
+
+// expectUserPreferences adds expectation of query which returns timezone and date format of user
+func expectUserPreferences(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+}
+
+// sendSearch sends GET request with search parameters
+func sendSearch(t *testing.T, dep session.Dependency, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/search?"+query, nil)
+	require.NoError(t, err)
+
+	sut := search.Page(dep)
+	sut(w, r)
+
+	return w
+}
+
+// TestPageEmptyFormGET tests case when search page is opened without search parameters
+func TestPageEmptyFormGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+
+	w := sendSearch(t, dep, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<input autofocus maxlength="100" type="text" name="q" value="" placeholder="Search files">`)
+	assert.Contains(t, w.Body.String(), `<option value="games">Games</option>`)
+	assert.NotContains(t, w.Body.String(), "Found files")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageQuerySuccessGET tests case when files are searched by query and sorted by relevance
+func TestPageQuerySuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	expectUserPreferences(sqlMock)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE MATCH\\(label, description\\) AGAINST\\(\\? IN NATURAL LANGUAGE MODE\\);").WithArgs("cat").WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE MATCH(.+) ORDER BY MATCH(.+) DESC, uploadDate DESC LIMIT").WithArgs("cat", "cat", 0, 15).WillReturnRows(
+		sqlmock.NewRows(fileInfoRows).AddRow(1, "cat.png", 1024, "funny cat", "example", "other", time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC), 10))
+	expectUserPreferences(sqlMock)
+
+	w := sendSearch(t, dep, "q=cat")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<p>Found files: 1</p>")
+	assert.Contains(t, w.Body.String(), `<a href=/download?id&#61;1>cat.png</a>`)
+	assert.Contains(t, w.Body.String(), "2009-11-17 23:34:58")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageFiltersSuccessGET tests case when all filters are used and files are sorted by rating
+func TestPageFiltersSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	expectUserPreferences(sqlMock)
+	args := []driver.Value{"cat", "music", "example", int64(1048576), int64(3145728), "2020-01-31 21:00:00", "2020-02-29 21:00:00"}
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE MATCH(.+) AND category IN \\(\\?\\) AND owner = \\? AND filesizeBytes >= \\? AND filesizeBytes <= \\? AND uploadDate >= \\? AND uploadDate < \\?;").WithArgs(args...).WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE (.+) ORDER BY rating DESC, uploadDate DESC LIMIT").WithArgs(append(args, 0, 15)...).WillReturnRows(
+		sqlmock.NewRows(fileInfoRows))
+
+	w := sendSearch(t, dep, "q=cat&category=music&owner=example&minSize=1&maxSize=3&from=2020-02-01&to=2020-02-29&sort=rating")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Nothing found</h2>`)
+	assert.Contains(t, w.Body.String(), `<option value="music" selected>Music</option>`)
+	assert.Contains(t, w.Body.String(), `<option value="rating" selected>rating</option>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageNavigationBarGET tests case when search results are showed on several pages
+func TestPageNavigationBarGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	expectUserPreferences(sqlMock)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE owner = \\?;").WithArgs("example").WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(31))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE owner = \\? ORDER BY uploadDate DESC LIMIT").WithArgs("example", 15, 15).WillReturnRows(
+		sqlmock.NewRows(fileInfoRows))
+
+	w := sendSearch(t, dep, "owner=example&sort=date&p=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href=/search?owner&#61;example&amp;sort&#61;date&amp;p&#61;1>1</a>`)
+	assert.Contains(t, w.Body.String(), `<a href=/search?owner&#61;example&amp;sort&#61;date&amp;p&#61;3>3</a>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageSubcategoriesGET tests case when files of subcategories are found together with files of selected category
+func TestPageSubcategoriesGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows().AddRow("jazz", "Jazz", "", 60, "music", false))
+	expectUserPreferences(sqlMock)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE category IN \\(\\?, \\?\\);").WithArgs("music", "jazz").WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE category IN (.+) ORDER BY uploadDate DESC LIMIT").WithArgs("music", "jazz", 0, 15).WillReturnRows(
+		sqlmock.NewRows(fileInfoRows))
+
+	w := sendSearch(t, dep, "category=music")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<option value="jazz">&nbsp;&nbsp;Jazz</option>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageIncorrectFiltersGET tests cases when search parameters are incorrect
+func TestPageIncorrectFiltersGET(t *testing.T) {
+	for query, warning := range map[string]string{
+		"category=unknown": "Unknown category",
+		"minSize=big":      "Incorrect file size",
+		"maxSize=-1":       "Incorrect file size",
+		"from=31.01.2020":  "Incorrect date. Please use format YYYY-MM-DD",
+	} {
+		dep, sqlMock, _ := test.NewDep(t)
+		sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+		expectUserPreferences(sqlMock)
+
+		w := sendSearch(t, dep, query)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<h2 style="color:red">`+warning+`</h2>`, query)
+		require.NoError(t, sqlMock.ExpectationsWereMet())
+	}
+}
+
+// TestPageIncorrectPageNumberGET tests case when number of page more than count of pages
+func TestPageIncorrectPageNumberGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	expectUserPreferences(sqlMock)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("example").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	w := sendSearch(t, dep, "owner=example&p=2")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Incorrect get request\n", w.Body)
+}
+
+// TestPageDBErrorGET tests case when database returns error
+func TestPageDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	expectUserPreferences(sqlMock)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("cat").WillReturnError(fmt.Errorf("testing error"))
+
+	w := sendSearch(t, dep, "q=cat")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+// TestPageSearchIndexSuccessGET tests case when text of query is searched by search index
+func TestPageSearchIndexSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	dep.Search = searchindex.New()
+	dep.Search.Add(searchindex.Document{ID: 1, Label: "cat.png", Description: "funny cat", Owner: "example"})
+	dep.Search.Add(searchindex.Document{ID: 2, Label: "cats.zip", Description: "photos", Owner: "example"})
+	dep.Search.Add(searchindex.Document{ID: 3, Label: "dog.png", Description: "", Owner: "example"})
+	expectUserPreferences(sqlMock)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE id IN \\(\\?, \\?\\) AND category IN \\(\\?\\);").WithArgs(1, 2, "other").WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id IN (.+) ORDER BY FIELD\\(id, \\?, \\?\\) LIMIT").WithArgs(1, 2, "other", 1, 2, 0, 15).WillReturnRows(
+		sqlmock.NewRows(fileInfoRows).AddRow(1, "cat.png", 1024, "funny cat", "example", "other", time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC), 10))
+	expectUserPreferences(sqlMock)
+
+	w := sendSearch(t, dep, "q=cat&category=other")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<p>Found files: 1</p>")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+// TestPageSearchIndexNothingFoundGET tests case when search index didn't find any file
+func TestPageSearchIndexNothingFoundGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories;").WillReturnRows(test.CategoryRows())
+	dep.Search = searchindex.New()
+	expectUserPreferences(sqlMock)
+
+	w := sendSearch(t, dep, "q=cat")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Nothing found</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,145 @@
+package sessions
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+)
+
+// path to sessions[/sessions] template file
+const pathTemplateSessions = "pages/sessions/template/sessions.html"
+
+// TemplateSessions contains data for sessions[/sessions] page template
+type TemplateSessions struct {
+	Warning  template.HTML
+	Username string
+	Sessions []SessionInfo
+}
+
+// SessionInfo contains formatted info about active session of user
+type SessionInfo struct {
+	ID        string
+	Created   string
+	LastSeen  string
+	IP        string
+	UserAgent string
+	Current   bool // session used by user at the moment
+}
+
+// Page returns HandleFunc for sessions[/sessions] page.
+// Page shows active sessions of user and allows to log out any of them or all at once.
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for sessions page
+		page, err := tmp.CreateFormTemplate(pathTemplateSessions, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		currentID := ""
+		cookie, err := r.Cookie(session.CookieName)
+		if err == nil {
+			currentID = session.ID(cookie.Value)
+		}
+
+		warning := template.HTML("")
+		switch r.Method {
+		case "GET":
+
+		case "POST":
+			switch r.FormValue("action") {
+			case "logout":
+				id := r.FormValue("session")
+				err = session.Kill(dep.Redis, dep.Username, id)
+				if err != nil {
+					if err == redis.ErrNil {
+						warning = "<h2 style=\"color:red\">Session not found</h2>"
+						break
+					}
+					errhand.InternalError(err, w)
+					return
+				}
+				if id == currentID {
+					logoutCurrent(dep, w, r)
+					return
+				}
+				warning = "<h2 style=\"color:green\">Session closed</h2>"
+
+			case "logoutAll":
+				err = session.KillSessions(dep.Redis, dep.Username)
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				logoutCurrent(dep, w, r)
+				return
+
+			default:
+				warning = "<h2 style=\"color:red\">Unknown action</h2>"
+			}
+
+		default:
+			return
+		}
+
+		sessionsInfo, err := formatedSessions(dep, currentID)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		err = page.Execute(w, TemplateSessions{Warning: warning, Username: dep.Username, Sessions: sessionsInfo})
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+	}
+}
+
+// logoutCurrent removes session cookie from browser and redirects to login page
+func logoutCurrent(dep session.Dependency, w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, session.ExpiredCookie(dep))
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+// formatedSessions returns active sessions of user with times in user timezone
+func formatedSessions(dep session.Dependency, currentID string) ([]SessionInfo, error) {
+	sessions, err := session.List(dep.Redis, dep.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	location, layout, err := dbformat.UserPreferences(dep.Db, dep.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionsInfo := []SessionInfo{}
+	for _, s := range sessions {
+		sessionsInfo = append(sessionsInfo, SessionInfo{
+			ID:        s.ID,
+			Created:   formatTime(s.Created, location, layout),
+			LastSeen:  formatTime(s.LastSeen, location, layout),
+			IP:        s.IP,
+			UserAgent: s.UserAgent,
+			Current:   s.ID == currentID,
+		})
+	}
+	return sessionsInfo, nil
+}
+
+// formatTime returns time in user timezone and date format or "unknown" if time wasn't recorded
+func formatTime(t time.Time, location *time.Location, layout string) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.In(location).Format(layout)
+}
@@ -0,0 +1,242 @@
+package sessions_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/sessions"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+const (
+	currentCookie = "current"
+	otherCookie   = "other"
+)
+
+var (
+	currentID = session.ID(currentCookie)
+	otherID   = session.ID(otherCookie)
+)
+
+// expectSessionsList adds expectations of commands and queries which collect list of sessions
+func expectSessionsList(sqlMock sqlmock.Sqlmock, redisMock *redigomock.Conn, ids ...string) {
+	members := []interface{}{}
+	for _, id := range ids {
+		members = append(members, []byte(id))
+	}
+	redisMock.Command("SMEMBERS", "sessions:username").Expect(members)
+	redisMock.Command("HGETALL", "session:"+currentID).ExpectMap(map[string]string{
+		"created":   "1258490098",
+		"lastSeen":  "1258490200",
+		"ip":        "192.0.2.1",
+		"userAgent": "current agent",
+	})
+	redisMock.Command("HGETALL", "session:"+otherID).ExpectMap(map[string]string{
+		"created":   "1258490000",
+		"lastSeen":  "1258490100",
+		"ip":        "192.0.2.2",
+		"userAgent": "other agent",
+	})
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+}
+
+// sendRequest sends request with current session cookie to sessions handler
+func sendRequest(dep session.Dependency, method string, data url.Values) *httptest.ResponseRecorder {
+	sut := sessions.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, "http://localhost/sessions", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: currentCookie})
+
+	sut(w, r)
+
+	return w
+}
+
+func TestPageSuccessGET(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	expectSessionsList(sqlMock, redisMock, currentID, otherID)
+
+	w := sendRequest(dep, http.MethodGet, url.Values{})
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Sessions</title>
+    <link rel="stylesheet" href="assets/css/sessions.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="sessionList">
+        <h2>Active sessions</h2>
+        <table border="1" width="100%" cellpadding="5">
+            <tr>
+                <th>Created</th>
+                <th>Last seen</th>
+                <th>IP</th>
+                <th>Browser</th>
+                <th></th>
+            </tr>
+            <tr>
+                <td>2009-11-17 23:34:58</td>
+                <td>2009-11-17 23:36:40</td>
+                <td>192.0.2.1</td>
+                <td>current agent</td>
+                <td>
+                    <form action="/sessions" method="post">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="action" value="logout">
+                        <input type="hidden" name="session" value="`+currentID+`">
+                        <input type="submit" value="LOG OUT (current)">
+                    </form>
+                </td>
+            </tr>
+            <tr>
+                <td>2009-11-17 23:33:20</td>
+                <td>2009-11-17 23:35:00</td>
+                <td>192.0.2.2</td>
+                <td>other agent</td>
+                <td>
+                    <form action="/sessions" method="post">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="action" value="logout">
+                        <input type="hidden" name="session" value="`+otherID+`">
+                        <input type="submit" value="LOG OUT">
+                    </form>
+                </td>
+            </tr>
+        </table>
+
+        <form class="logoutAll" action="/sessions" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="logoutAll">
+            <input type="submit" value="LOG OUT EVERYWHERE">
+        </form>
+        
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageRedisErrorGET(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:username").ExpectError(fmt.Errorf("testing error"))
+
+	w := sendRequest(dep, http.MethodGet, url.Values{})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageLogoutOtherSessionPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	// killing of session
+	redisMock.Command("SISMEMBER", "sessions:username", otherID).Expect(int64(1))
+	redisMock.Command("GET", otherID).Expect("username")
+	del := redisMock.Command("DEL", otherID, "session:"+otherID).Expect(int64(2))
+	redisMock.Command("SREM", "sessions:username", otherID).Expect(int64(1))
+	// listing of remaining sessions
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{[]byte(currentID), []byte(otherID)})
+	redisMock.Command("HGETALL", "session:"+currentID).ExpectMap(map[string]string{"created": "1258490098", "lastSeen": "1258490200"})
+	redisMock.Command("HGETALL", "session:"+otherID).Expect([]interface{}{})
+	redisMock.Command("SREM", "sessions:username", otherID).Expect(int64(0))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+
+	data := url.Values{}
+	data.Set("action", "logout")
+	data.Set("session", otherID)
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, redisMock.Stats(del))
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Session closed</h2>`)
+	assert.NotContains(t, w.Body.String(), otherID)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageLogoutCurrentSessionPOST(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SISMEMBER", "sessions:username", currentID).Expect(int64(1))
+	redisMock.Command("GET", currentID).Expect("username")
+	del := redisMock.Command("DEL", currentID, "session:"+currentID).Expect(int64(2))
+	redisMock.Command("SREM", "sessions:username", currentID).Expect(int64(1))
+
+	data := url.Values{}
+	data.Set("action", "logout")
+	data.Set("session", currentID)
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	assert.Equal(t, 1, redisMock.Stats(del))
+	assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+func TestPageLogoutUnknownSessionPOST(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("SISMEMBER", "sessions:username", "unknown").Expect(int64(0))
+	expectSessionsList(sqlMock, redisMock, currentID, otherID)
+
+	data := url.Values{}
+	data.Set("action", "logout")
+	data.Set("session", "unknown")
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Session not found</h2>`)
+}
+
+func TestPageLogoutEverywherePOST(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:username").Expect([]interface{}{[]byte(currentID), []byte(otherID)})
+	delCurrent := redisMock.Command("DEL", currentID, "session:"+currentID).Expect(int64(2))
+	delOther := redisMock.Command("DEL", otherID, "session:"+otherID).Expect(int64(2))
+	delIndex := redisMock.Command("DEL", "sessions:username").Expect(int64(1))
+
+	data := url.Values{}
+	data.Set("action", "logoutAll")
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	assert.Equal(t, 1, redisMock.Stats(delCurrent))
+	assert.Equal(t, 1, redisMock.Stats(delOther))
+	assert.Equal(t, 1, redisMock.Stats(delIndex))
+}
+
+func TestPageLogoutEverywhereRedisErrorPOST(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:username").ExpectError(fmt.Errorf("testing error"))
+
+	data := url.Values{}
+	data.Set("action", "logoutAll")
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
@@ -0,0 +1,180 @@
+package tags
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/pagination"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	filetags "github.com/vpoletaev11/fileHostingSite/tags"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+const (
+	// path to tag cloud[/tags] template file
+	pathTemplateCloud = "pages/tags/template/cloud.html"
+
+	// path to any tag[/tags/*tag*] template file
+	pathTemplateAnyTag = "pages/tags/template/anyTag.html"
+)
+
+// SuggestPath is path of autocomplete of tags, it returns JSON array with names of tags
+const SuggestPath = "/tags-suggest"
+
+const (
+	taggedFiles = "SELECT file_tags.fileID FROM file_tags JOIN tags ON tags.id = file_tags.tagID WHERE tags.name = ?"
+
+	selectFileInfo = "SELECT " + dbformat.FileInfoColumns + " FROM files WHERE id IN (" + taggedFiles + ") ORDER BY uploadDate DESC LIMIT ?, ?;"
+
+	countRows = "SELECT COUNT(*) FROM files WHERE id IN (" + taggedFiles + ");"
+)
+
+const (
+	rowsInPage = 15 // how many rows of file info will be displayed on page
+
+	cloudLimit = 100 // how many the most popular tags will be displayed in tag cloud
+
+	suggestLimit = 10 // how many tags will be suggested by autocomplete
+)
+
+// TemplateCloud contains data for tag cloud[/tags] page template
+type TemplateCloud struct {
+	Warning  template.HTML
+	Username string
+	Cloud    []filetags.CloudTag
+}
+
+// TemplateAnyTag contains data for any tag[/tags/*tag*] page template
+type TemplateAnyTag struct {
+	Warning       template.HTML
+	Username      string
+	Tag           string
+	LinkList      []pagination.Link
+	UploadedFiles []dbformat.FileInfo
+}
+
+// Page returns HandleFunc for tag cloud[/tags] and any tag[/tags/*tag*] pages
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			tag := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/tags"), "/")
+			if tag == "" {
+				cloudPageHandler(dep, w)
+				return
+			}
+			anyTagPageHandler(dep, w, r, tag)
+			return
+		}
+	}
+}
+
+// cloudPageHandler handling tag cloud[/tags] page
+func cloudPageHandler(dep session.Dependency, w http.ResponseWriter) {
+	page, err := tmp.CreateTemplate(pathTemplateCloud)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	cloud, err := filetags.Cloud(dep.Db, cloudLimit)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	data := TemplateCloud{Username: dep.Username, Cloud: cloud}
+	if len(cloud) == 0 {
+		data.Warning = "<h2 style=\"color:red\">No tags yet</h2>"
+	}
+	err = page.Execute(w, data)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+}
+
+// anyTagPageHandler handling any tag[/tags/*tag*] page.
+// If tag isn't in canonical form user is redirected to page of normalized tag (e.g. /tags/Rock -> /tags/rock).
+func anyTagPageHandler(dep session.Dependency, w http.ResponseWriter, r *http.Request, tag string) {
+	page, err := tmp.CreateTemplate(pathTemplateAnyTag)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	normalized := filetags.Normalize(tag)
+	if filetags.Validate(normalized) != nil {
+		fmt.Fprintln(w, "ERROR: Incorrect tag")
+		return
+	}
+	if normalized != tag {
+		http.Redirect(w, r, "/tags/"+normalized, http.StatusFound)
+		return
+	}
+
+	// getting count of pages
+	rowsCount := 0
+	err = dep.Db.QueryRow(countRows, tag).Scan(&rowsCount)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+	pagesCount := pagination.PagesCount(rowsCount, rowsInPage)
+
+	// getting number of current page
+	numPage, err := pagination.NumPage(r)
+	if err != nil || numPage > pagesCount {
+		fmt.Fprintln(w, "ERROR: Incorrect get request")
+		return
+	}
+
+	// getting files info for current page
+	fiCollection, err := dbformat.FormatedFilesInfo(dep.Username, dep.Db, selectFileInfo, tag, (numPage-1)*rowsInPage, rowsInPage)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	data := TemplateAnyTag{Username: dep.Username, Tag: tag, UploadedFiles: fiCollection}
+	if rowsCount == 0 {
+		data.Warning = "<h2 style=\"color:red\">No files with this tag</h2>"
+	}
+	// creating navigation bar if count of pages > 1
+	if pagesCount > 1 {
+		data.LinkList = pagination.NavigationBar(pagesCount, numPage, "/tags/"+tag+"?p=")
+	}
+	err = page.Execute(w, data)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+}
+
+// Suggest returns HandleFunc for autocomplete of tags[/tags-suggest?q=*prefix*].
+// It returns JSON array with the most popular tags which starts with inputted prefix.
+func Suggest(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			suggestions, err := filetags.Suggest(dep.Db, r.URL.Query().Get("q"), suggestLimit)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(w).Encode(suggestions)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package tags_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/tags"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// fileInfoRows contains columns of files table returned by files info query
+var fileInfoRows = []string{
+	"id",
+	"label",
+	"filesizeBytes",
+	"description",
+	"owner",
+	"category",
+	"uploadDate",
+	"rating",
+}
+
+// expectUserPreferences adds expectation of query which returns timezone and date format of user
+func expectUserPreferences(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+}
+
+// sendGET sends GET request to handler
+func sendGET(t *testing.T, handler func(session.Dependency) http.HandlerFunc, dep session.Dependency, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, target, nil)
+	require.NoError(t, err)
+
+	sut := handler(dep)
+	sut(w, r)
+
+	return w
+}
+
+func TestPageCloudSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT tags.name, COUNT").WithArgs(100).WillReturnRows(
+		sqlmock.NewRows([]string{"name", "filesCount"}).AddRow("rock", 5).AddRow("jazz", 1))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags")
+
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Tags</title>
+    <link rel="stylesheet" href="/assets/css/tags.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="tagCloud">
+        <h1>Tags</h1>
+        
+        <a class="tag size1" href="/tags/jazz" title="1 files">jazz</a>
+        <a class="tag size5" href="/tags/rock" title="5 files">rock</a>
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageEmptyCloudGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT tags.name, COUNT").WithArgs(100).WillReturnRows(sqlmock.NewRows([]string{"name", "filesCount"}))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/")
+
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">No tags yet</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageCloudDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT tags.name, COUNT").WithArgs(100).WillReturnError(fmt.Errorf("testing error"))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageAnyTagSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM files WHERE id IN \\(SELECT file_tags.fileID FROM file_tags JOIN tags (.+) WHERE tags.name = \\?\\);").WithArgs("rock").WillReturnRows(
+		sqlmock.NewRows([]string{"count"}).AddRow(1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id IN (.+) ORDER BY uploadDate DESC LIMIT").WithArgs("rock", 0, 15).WillReturnRows(
+		sqlmock.NewRows(fileInfoRows).AddRow(1, "song.mp3", 1024, "description", "example", "music", time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC), 10))
+	expectUserPreferences(sqlMock)
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/rock")
+
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h1>Files tagged "rock"</h1>`)
+	assert.Contains(t, w.Body.String(), `<a href=/download?id&#61;1>song.mp3</a>`)
+	assert.Contains(t, w.Body.String(), "2009-11-17 23:34:58")
+	assert.NotContains(t, w.Body.String(), "No files with this tag")
+}
+
+func TestPageAnyTagNavigationBarGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("rock").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(31))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id IN").WithArgs("rock", 15, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/rock?p=2")
+
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.Contains(t, w.Body.String(), `<a href=/tags/rock?p&#61;1>1</a>`)
+	assert.Contains(t, w.Body.String(), `<a href=/tags/rock?p&#61;3>3</a>`)
+}
+
+func TestPageAnyTagNoFilesGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("rock").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE id IN").WithArgs("rock", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/rock")
+
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">No files with this tag</h2>`)
+}
+
+func TestPageAnyTagRedirectGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/Rock_n_Roll")
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/tags/rock-n-roll", w.Header().Get("Location"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageAnyTagIncorrectGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/---")
+
+	test.AssertBodyEqual(t, "ERROR: Incorrect tag\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageAnyTagIncorrectPageNumberGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("rock").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/rock?p=2")
+
+	test.AssertBodyEqual(t, "ERROR: Incorrect get request\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageAnyTagDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("rock").WillReturnError(fmt.Errorf("testing error"))
+
+	w := sendGET(t, tags.Page, dep, "http://localhost/tags/rock")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestSuggestSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT tags.name FROM tags JOIN file_tags").WithArgs("ro%", 10).WillReturnRows(
+		sqlmock.NewRows([]string{"name"}).AddRow("rock").AddRow("rock-n-roll"))
+
+	w := sendGET(t, tags.Suggest, dep, "http://localhost/tags-suggest?q=Ro")
+
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	test.AssertBodyEqual(t, "[\"rock\",\"rock-n-roll\"]\n", w.Body)
+}
+
+func TestSuggestEmptyPrefixGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+
+	w := sendGET(t, tags.Suggest, dep, "http://localhost/tags-suggest?q=")
+
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+	test.AssertBodyEqual(t, "[]\n", w.Body)
+}
+
+func TestSuggestDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT tags.name FROM tags JOIN file_tags").WithArgs("ro%", 10).WillReturnError(fmt.Errorf("testing error"))
+
+	w := sendGET(t, tags.Suggest, dep, "http://localhost/tags-suggest?q=ro")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
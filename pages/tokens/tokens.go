@@ -0,0 +1,148 @@
+package tokens
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// path to tokens[/tokens] template file
+const pathTemplateTokens = "pages/tokens/template/tokens.html"
+
+// TemplateTokens contains data for tokens[/tokens] page template
+type TemplateTokens struct {
+	Warning  template.HTML
+	Username string
+	// NewToken is token created by request, it's shown only once
+	NewToken string
+	Scopes   []string
+	Tokens   []TokenInfo
+}
+
+// TokenInfo contains formatted info about API token of user
+type TokenInfo struct {
+	ID        int
+	Name      string
+	Scopes    string
+	CreatedAt string
+	LastUsed  string
+}
+
+// Page returns HandleFunc for tokens[/tokens] page.
+// Page shows API tokens of user and allows to create new tokens and revoke existing ones.
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for tokens page
+		page, err := tmp.CreateFormTemplate(pathTemplateTokens, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		warning := template.HTML("")
+		newToken := ""
+		switch r.Method {
+		case "GET":
+
+		case "POST":
+			switch r.FormValue("action") {
+			case "create":
+				name := r.FormValue("name")
+				scopes := r.Form["scope"]
+				err = apitokens.Validate(name, scopes)
+				if err != nil {
+					warning = template.HTML("<h2 style=\"color:red\">" + template.HTMLEscapeString(err.Error()) + "</h2>")
+					break
+				}
+				newToken, err = apitokens.Create(dep.Db, dep.Username, name, scopes, dep.Now())
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				warning = "<h2 style=\"color:green\">Token created. Copy it now, it will not be shown again</h2>"
+
+			case "revoke":
+				id, err := strconv.Atoi(r.FormValue("id"))
+				if err != nil {
+					warning = "<h2 style=\"color:red\">Token not found</h2>"
+					break
+				}
+				err = apitokens.Revoke(dep.Db, dep.Username, id)
+				if err != nil {
+					if err == apitokens.ErrNotFound {
+						warning = "<h2 style=\"color:red\">Token not found</h2>"
+						break
+					}
+					errhand.InternalError(err, w)
+					return
+				}
+				warning = "<h2 style=\"color:green\">Token revoked</h2>"
+
+			default:
+				warning = "<h2 style=\"color:red\">Unknown action</h2>"
+			}
+
+		default:
+			return
+		}
+
+		tokensInfo, err := formatedTokens(dep)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		err = page.Execute(w, TemplateTokens{
+			Warning:  warning,
+			Username: dep.Username,
+			NewToken: newToken,
+			Scopes:   apitokens.Scopes,
+			Tokens:   tokensInfo,
+		})
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+	}
+}
+
+// formatedTokens returns API tokens of user with times in user timezone
+func formatedTokens(dep session.Dependency) ([]TokenInfo, error) {
+	tokens, err := apitokens.List(dep.Db, dep.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	location, layout, err := dbformat.UserPreferences(dep.Db, dep.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	tokensInfo := []TokenInfo{}
+	for _, t := range tokens {
+		tokensInfo = append(tokensInfo, TokenInfo{
+			ID:        t.ID,
+			Name:      t.Name,
+			Scopes:    strings.Join(t.Scopes, ", "),
+			CreatedAt: formatTime(t.CreatedAt, location, layout),
+			LastUsed:  formatTime(t.LastUsed, location, layout),
+		})
+	}
+	return tokensInfo, nil
+}
+
+// formatTime returns time in user timezone and date format or "never" if token wasn't used
+func formatTime(t time.Time, location *time.Location, layout string) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.In(location).Format(layout)
+}
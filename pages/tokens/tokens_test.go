@@ -0,0 +1,210 @@
+package tokens_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/tokens"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+var createdAt = time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC)
+
+// expectTokensList adds expectations of queries which collect list of tokens
+func expectTokensList(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT id, name, scopes, createdAt, lastUsed FROM apiTokens WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "scopes", "createdAt", "lastUsed"}).
+			AddRow(2, "backup script", "read", createdAt, createdAt.Add(time.Hour)).
+			AddRow(1, "uploader", "read,upload", createdAt, nil))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+}
+
+// sendRequest sends request to tokens handler
+func sendRequest(dep session.Dependency, method string, data url.Values) *httptest.ResponseRecorder {
+	sut := tokens.Page(dep)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, "http://localhost/tokens", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+
+	sut(w, r)
+
+	return w
+}
+
+func TestPageSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectTokensList(sqlMock)
+
+	w := sendRequest(dep, http.MethodGet, url.Values{})
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>API tokens</title>
+    <link rel="stylesheet" href="assets/css/tokens.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="tokenList">
+        <h2>API tokens</h2>
+        <p>API tokens allow scripts to use <a href="/api/v1/openapi.json">JSON API</a> without password. Send token in "Authorization: Bearer" header.</p>
+        
+        <table border="1" width="100%" cellpadding="5">
+            <tr>
+                <th>Name</th>
+                <th>Scopes</th>
+                <th>Created</th>
+                <th>Last used</th>
+                <th></th>
+            </tr>
+            <tr>
+                <td>backup script</td>
+                <td>read</td>
+                <td>2009-11-17 23:34:58</td>
+                <td>2009-11-18 00:34:58</td>
+                <td>
+                    <form action="/tokens" method="post">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="action" value="revoke">
+                        <input type="hidden" name="id" value="2">
+                        <input type="submit" value="REVOKE">
+                    </form>
+                </td>
+            </tr>
+            <tr>
+                <td>uploader</td>
+                <td>read, upload</td>
+                <td>2009-11-17 23:34:58</td>
+                <td>never</td>
+                <td>
+                    <form action="/tokens" method="post">
+                        <input type="hidden" name="csrf_token" value="csrf">
+                        <input type="hidden" name="action" value="revoke">
+                        <input type="hidden" name="id" value="1">
+                        <input type="submit" value="REVOKE">
+                    </form>
+                </td>
+            </tr>
+        </table>
+
+        <form class="createToken" action="/tokens" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="create">
+            <input type="text" name="name" placeholder="Token name" maxlength="50">
+            <label><input type="checkbox" name="scope" value="read">read</label>
+            <label><input type="checkbox" name="scope" value="upload">upload</label>
+            <label><input type="checkbox" name="scope" value="vote">vote</label>
+            <label><input type="checkbox" name="scope" value="delete">delete</label>
+            <input type="submit" value="CREATE TOKEN">
+        </form>
+    </div>
+</body>`, w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT id, name, scopes, createdAt, lastUsed FROM apiTokens").WillReturnError(fmt.Errorf("testing error"))
+
+	w := sendRequest(dep, http.MethodGet, url.Values{})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageCreatePOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	dep.Clock = func() time.Time { return createdAt }
+	sqlMock.ExpectExec("INSERT INTO apiTokens").WithArgs("username", "deploy", sqlmock.AnyArg(), "read,upload", "2009-11-17 20:34:58").WillReturnResult(sqlmock.NewResult(3, 1))
+	expectTokensList(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "create")
+	data.Set("name", "deploy")
+	data["scope"] = []string{"read", "upload"}
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Token created. Copy it now, it will not be shown again</h2>`)
+	assert.Contains(t, w.Body.String(), `<div class="newToken">fhs_`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageCreateInvalidPOST(t *testing.T) {
+	for name, scopes := range map[string][]string{
+		"":       {"read"},
+		"deploy": nil,
+	} {
+		dep, sqlMock, _ := test.NewDep(t)
+		expectTokensList(sqlMock)
+
+		data := url.Values{}
+		data.Set("action", "create")
+		data.Set("name", name)
+		data["scope"] = scopes
+		w := sendRequest(dep, http.MethodPost, data)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<h2 style="color:red">`)
+		assert.NotContains(t, w.Body.String(), `class="newToken"`)
+		require.NoError(t, sqlMock.ExpectationsWereMet())
+	}
+}
+
+func TestPageRevokePOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectExec("DELETE FROM apiTokens WHERE id = \\? AND username = \\?;").WithArgs(3, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTokensList(sqlMock)
+
+	data := url.Values{}
+	data.Set("action", "revoke")
+	data.Set("id", "3")
+	w := sendRequest(dep, http.MethodPost, data)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Token revoked</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageRevokeUnknownPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectExec("DELETE FROM apiTokens").WithArgs(3, "username").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTokensList(sqlMock)
+	expectTokensList(sqlMock)
+
+	for _, id := range []string{"3", "abc"} {
+		data := url.Values{}
+		data.Set("action", "revoke")
+		data.Set("id", id)
+		w := sendRequest(dep, http.MethodPost, data)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<h2 style="color:red">Token not found</h2>`)
+	}
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,199 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// LoginPath is path of second login step[/login/2fa] page
+const LoginPath = types.TwoFactorLoginPath
+
+// path to second login step[/login/2fa] template file
+const pathTemplateLogin = "pages/twofactor/template/login.html"
+
+// User which entered correct password gets cookie with token of pending login.
+// Redis key "login2fa:<hash of token>" contains username until user enters code or login expires.
+const (
+	loginCookieName = "login_2fa"
+	loginPrefix     = "login2fa:"
+	loginLifetime   = 5 * time.Minute
+	loginTokenLen   = 32 // length of pending login token in bytes
+)
+
+// TemplateLogin contains data for second login step[/login/2fa] page template
+type TemplateLogin struct {
+	Warning template.HTML
+}
+
+// StartLogin starts second login step for user with correct password.
+// After StartLogin user should be redirected to LoginPath.
+func StartLogin(dep session.Dependency, w http.ResponseWriter, username string) error {
+	token := make([]byte, loginTokenLen)
+	_, err := rand.Read(token)
+	if err != nil {
+		return err
+	}
+	cookie := loginCookie(dep, base64.RawURLEncoding.EncodeToString(token))
+
+	_, err = dep.Redis.Do("SET", loginPrefix+session.ID(cookie.Value), username, "EX", loginLifetime.Seconds())
+	if err != nil {
+		return err
+	}
+	cookie.MaxAge = int(loginLifetime.Seconds())
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// loginCookie returns cookie of pending login
+func loginCookie(dep session.Dependency, token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     loginCookieName,
+		Path:     "/login",
+		Value:    token,
+		HttpOnly: true,
+		Secure:   dep.SecureCookie,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// Login returns HandleFunc for second login step[/login/2fa] page
+func Login(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// getting username of pending login
+		cookie, err := r.Cookie(loginCookieName)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		username, err := redis.String(dep.Redis.Do("GET", loginPrefix+session.ID(cookie.Value)))
+		if err != nil {
+			if err == redis.ErrNil {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			errhand.InternalError(err, w)
+			return
+		}
+
+		// getting CSRF token from cookie, user doesn't have session yet
+		csrfToken, err := csrf.Cookie(w, r, dep.SecureCookie)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+		// creating template for second login step page
+		page, err := tmp.CreateFormTemplate(pathTemplateLogin, csrfToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			err = page.Execute(w, TemplateLogin{})
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			warning, err := loginWarning(dep, r, username)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			if warning != "" {
+				err = page.Execute(w, TemplateLogin{Warning: warning})
+				if err != nil {
+					errhand.InternalError(err, w)
+					return
+				}
+				return
+			}
+
+			// code is correct, pending login can be finished
+			_, err = dep.Redis.Do("DEL", loginPrefix+session.ID(cookie.Value))
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			err = throttle.Reset(dep.Redis, throttle.LoginByUsername, username)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			// creating cookie, session which came with request (if any) will be removed to prevent session fixation
+			dep.Username = username
+			sessionCookie, err := session.Rotate(dep, r)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			http.SetCookie(w, &sessionCookie)
+			expired := loginCookie(dep, "")
+			expired.MaxAge = -1
+			http.SetCookie(w, expired)
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+	}
+}
+
+// loginWarning checks code sent by user on second login step.
+// If code cannot be accepted loginWarning returns warning for user.
+func loginWarning(dep session.Dependency, r *http.Request, username string) (template.HTML, error) {
+	// handling case when form was sent from another site
+	if !csrf.ValidCookie(r) {
+		return "<h2 style=\"color:red\">Form expired. Please try again</h2>", nil
+	}
+
+	// codes are throttled as passwords, otherwise 6 digit code can be guessed.
+	// IP address is throttled too, so one client cannot guess codes of many accounts with known passwords
+	ip := session.RemoteIP(r)
+	wait, err := throttle.Wait(dep.Redis, throttle.LoginByUsername, username)
+	if err != nil {
+		return "", err
+	}
+	waitIP, err := throttle.Wait(dep.Redis, throttle.LoginByIP, ip)
+	if err != nil {
+		return "", err
+	}
+	if wait > 0 || waitIP > 0 {
+		return "<h2 style=\"color:red\">Too many failed login attempts. Please try again later</h2>", nil
+	}
+
+	secret := ""
+	err = dep.Db.QueryRow(selectSecret, username).Scan(&secret)
+	if err != nil {
+		return "", err
+	}
+	ok, err := verify(dep, username, secret, r.FormValue("code"))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		_, err = throttle.Fail(dep.Redis, throttle.LoginByUsername, username)
+		if err != nil {
+			return "", err
+		}
+		_, err = throttle.Fail(dep.Redis, throttle.LoginByIP, ip)
+		if err != nil {
+			return "", err
+		}
+		return "<h2 style=\"color:red\">Wrong code</h2>", nil
+	}
+	return "", nil
+}
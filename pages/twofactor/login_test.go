@@ -0,0 +1,196 @@
+package twofactor_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/twofactor"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+)
+
+// pendingToken is token of pending login in tests
+const pendingToken = "pending"
+
+// expectPendingLogin adds expectation of command which returns username of pending login
+func expectPendingLogin(redisMock *redigomock.Conn) {
+	redisMock.Command("GET", "login2fa:"+session.ID(pendingToken)).Expect("example")
+}
+
+// sendCode sends POST request with code on second login step
+func sendCode(dep session.Dependency, code, csrfToken string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("csrf_token", csrfToken)
+	data.Set("code", code)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/login/2fa", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	r.Header.Set("User-Agent", "test agent")
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.AddCookie(&http.Cookie{Name: "login_2fa", Value: pendingToken})
+	r.AddCookie(test.CSRFCookie())
+
+	sut := twofactor.Login(dep)
+	sut(w, r)
+
+	return w
+}
+
+func TestLoginWithoutCookie(t *testing.T) {
+	dep, _, _ := newDep(t)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/login/2fa", nil)
+	require.NoError(t, err)
+
+	sut := twofactor.Login(dep)
+	sut(w, r)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+}
+
+func TestLoginExpired(t *testing.T) {
+	dep, _, redisMock := newDep(t)
+	redisMock.Command("GET", "login2fa:"+session.ID(pendingToken)).Expect(nil)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/login/2fa", nil)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{Name: "login_2fa", Value: pendingToken})
+
+	sut := twofactor.Login(dep)
+	sut(w, r)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+}
+
+func TestLoginSuccessGET(t *testing.T) {
+	dep, _, redisMock := newDep(t)
+	expectPendingLogin(redisMock)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/login/2fa", nil)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{Name: "login_2fa", Value: pendingToken})
+	r.AddCookie(test.CSRFCookie())
+
+	sut := twofactor.Login(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Two-factor authentication</title>
+    <link rel="stylesheet" href="/assets/css/login.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="loginForm">
+        <form action="" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <p>Code from authenticator app or recovery code:</p>
+            <p><input required autofocus maxlength="11" type="text" name="code" autocomplete="one-time-code"></p>
+            <input type="submit" value="Confirm">
+            <p><a href="/login" style="color: #c82020">Back to login</a></p>
+            
+        </form>
+    </div>
+</body>`, w.Body)
+}
+
+func TestLoginSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	expectPendingLogin(redisMock)
+	redisMock.Command("TTL", "lock:login:user:example").Expect(int64(-2))
+	redisMock.Command("TTL", "lock:login:ip:192.0.2.1").Expect(int64(-2))
+	expectSecret(sqlMock, "example", secret)
+	expectCodeAccepted(redisMock, "example")
+	finish := redisMock.Command("DEL", "login2fa:"+session.ID(pendingToken))
+	reset := redisMock.Command("DEL", "failures:login:user:example", "lock:login:user:example")
+	redisMock.Command("SET", redigomock.NewAnyData(), "example", "EX", session.CookieLifetime.Seconds())
+	redisMock.Command("HSET", redigomock.NewAnyData(), "created", redigomock.NewAnyInt(), "lastSeen", redigomock.NewAnyInt(), "ip", "192.0.2.1", "userAgent", "test agent")
+	redisMock.Command("EXPIRE", redigomock.NewAnyData(), session.CookieLifetime.Seconds())
+	sadd := redisMock.Command("SADD", "sessions:example", redigomock.NewAnyData())
+
+	w := sendCode(dep, code, test.CSRFToken)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/", w.Header().Get("Location"))
+	assert.Equal(t, 1, redisMock.Stats(finish))
+	assert.Equal(t, 1, redisMock.Stats(reset))
+	assert.Equal(t, 1, redisMock.Stats(sadd))
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 2)
+	assert.Equal(t, session.CookieName, cookies[0].Name)
+	assert.Equal(t, "login_2fa", cookies[1].Name)
+	assert.Equal(t, -1, cookies[1].MaxAge)
+}
+
+func TestLoginWrongCodePOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	expectPendingLogin(redisMock)
+	redisMock.Command("TTL", "lock:login:user:example").Expect(int64(-2))
+	redisMock.Command("TTL", "lock:login:ip:192.0.2.1").Expect(int64(-2))
+	expectSecret(sqlMock, "example", secret)
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username = \\? AND codeHash").WithArgs("example", hash("123456")).WillReturnResult(sqlmock.NewResult(0, 0))
+	fail := redisMock.Command("INCR", "failures:login:user:example").Expect(int64(1))
+	redisMock.Command("EXPIRE", "failures:login:user:example", throttle.LoginByUsername.Lockout.Seconds())
+	failIP := redisMock.Command("INCR", "failures:login:ip:192.0.2.1").Expect(int64(1))
+	redisMock.Command("EXPIRE", "failures:login:ip:192.0.2.1", throttle.LoginByIP.Lockout.Seconds())
+
+	w := sendCode(dep, "123456", test.CSRFToken)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong code</h2>`)
+	assert.Equal(t, 1, redisMock.Stats(fail))
+	assert.Equal(t, 1, redisMock.Stats(failIP))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestLoginLockedOutPOST(t *testing.T) {
+	dep, _, redisMock := newDep(t)
+	expectPendingLogin(redisMock)
+	redisMock.Command("TTL", "lock:login:user:example").Expect(int64(60))
+	redisMock.Command("TTL", "lock:login:ip:192.0.2.1").Expect(int64(-2))
+
+	w := sendCode(dep, code, test.CSRFToken)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Too many failed login attempts. Please try again later</h2>`)
+}
+
+func TestLoginIPLockedOutPOST(t *testing.T) {
+	dep, _, redisMock := newDep(t)
+	expectPendingLogin(redisMock)
+	redisMock.Command("TTL", "lock:login:user:example").Expect(int64(-2))
+	redisMock.Command("TTL", "lock:login:ip:192.0.2.1").Expect(int64(60))
+
+	w := sendCode(dep, code, test.CSRFToken)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Too many failed login attempts. Please try again later</h2>`)
+}
+
+func TestLoginInvalidCSRFTokenPOST(t *testing.T) {
+	dep, _, redisMock := newDep(t)
+	expectPendingLogin(redisMock)
+
+	w := sendCode(dep, code, "wrong")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Form expired. Please try again</h2>`)
+}
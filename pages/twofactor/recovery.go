@@ -0,0 +1,99 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	recoveryCodesCount = 10
+	recoveryCodeLen    = 6 // length of recovery code in bytes, it's 10 characters in base32
+)
+
+const (
+	deleteRecoveryCodes = "DELETE FROM recoveryCodes WHERE username = ?;"
+
+	insertRecoveryCode = "INSERT INTO recoveryCodes (username, codeHash) VALUES (?, ?);"
+
+	useRecoveryCode = "DELETE FROM recoveryCodes WHERE username = ? AND codeHash = ?;"
+
+	countRecoveryCodes = "SELECT COUNT(*) FROM recoveryCodes WHERE username = ?;"
+)
+
+// recoveryEncoding is lower case base32 without padding, so recovery codes are easy to retype
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCode returns random recovery code in xxxxx-xxxxx format
+func newRecoveryCode() (string, error) {
+	code := make([]byte, recoveryCodeLen)
+	_, err := rand.Read(code)
+	if err != nil {
+		return "", err
+	}
+	encoded := recoveryEncoding.EncodeToString(code)
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+// hashRecoveryCode returns hash of recovery code which is stored in database instead of code.
+// Recovery codes are random, so SHA-256 without salt is enough to protect them.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	code = strings.Replace(code, " ", "", -1)
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// replaceRecoveryCodes removes recovery codes of user and creates new ones.
+// Codes are returned to show them to user once, database contains only their hashes.
+func replaceRecoveryCodes(db *sql.DB, username string) ([]string, error) {
+	codes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(deleteRecoveryCodes, username)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, code := range codes {
+		_, err = tx.Exec(insertRecoveryCode, username, hashRecoveryCode(code))
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// redeemRecoveryCode removes recovery code of user and returns true if user had such code
+func redeemRecoveryCode(db *sql.DB, username, code string) (bool, error) {
+	res, err := db.Exec(useRecoveryCode, username, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
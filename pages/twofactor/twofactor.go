@@ -0,0 +1,259 @@
+package twofactor
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+	"github.com/vpoletaev11/fileHostingSite/totp"
+)
+
+// path to two-factor authentication settings[/2fa] template file
+const pathTemplateTwoFactor = "pages/twofactor/template/twofactor.html"
+
+// issuer is name of site showed in authenticator apps
+const issuer = "fileHostingSite"
+
+const (
+	selectSecret = "SELECT totpSecret FROM users WHERE username = ?;"
+
+	updateSecret = "UPDATE users SET totpSecret = ? WHERE username = ?;"
+)
+
+// Redis keys:
+// 1) "totp:pending:<username>" contains secret which is showed to user but isn't confirmed by code yet,
+// 2) "totp:used:<username>" contains number of period of the last accepted code, so code cannot be used twice.
+const (
+	pendingPrefix = "totp:pending:"
+	usedPrefix    = "totp:used:"
+
+	pendingLifetime = 10 * time.Minute
+)
+
+// TemplateTwoFactor contains data for two-factor authentication settings[/2fa] page template
+type TemplateTwoFactor struct {
+	Warning       template.HTML
+	Username      string
+	Enabled       bool
+	Secret        string
+	URI           template.URL
+	RecoveryCodes []string
+	RecoveryLeft  int
+}
+
+// Forget removes two-factor authentication data of user from Redis, it's used on account deletion
+func Forget(redisConn redis.Conn, username string) error {
+	_, err := redisConn.Do("DEL", pendingPrefix+username, usedPrefix+username)
+	return err
+}
+
+// Page returns HandleFunc for two-factor authentication settings[/2fa] page
+func Page(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for two-factor authentication settings page
+		page, err := tmp.CreateFormTemplate(pathTemplateTwoFactor, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			data, err := settingsData(dep)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+
+		case "POST":
+			warning, codes, err := doAction(dep, r.FormValue("action"), r.FormValue("code"))
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			data, err := settingsData(dep)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			data.Warning = warning
+			data.RecoveryCodes = codes
+
+			err = page.Execute(w, data)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// doAction changes two-factor authentication settings and returns warning for user.
+// If new recovery codes were created doAction returns them.
+func doAction(dep session.Dependency, action, code string) (template.HTML, []string, error) {
+	switch action {
+	case "enable":
+		secret, err := redis.String(dep.Redis.Do("GET", pendingPrefix+dep.Username))
+		if err != nil {
+			if err == redis.ErrNil {
+				return "<h2 style=\"color:red\">Secret expired. Please add new secret to authenticator app</h2>", nil, nil
+			}
+			return "", nil, err
+		}
+		ok, err := checkCode(dep, dep.Username, secret, code)
+		if err != nil {
+			return "", nil, err
+		}
+		if !ok {
+			return "<h2 style=\"color:red\">Wrong code</h2>", nil, nil
+		}
+
+		_, err = dep.Db.Exec(updateSecret, secret, dep.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		_, err = dep.Redis.Do("DEL", pendingPrefix+dep.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		codes, err := replaceRecoveryCodes(dep.Db, dep.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		return "<h2 style=\"color:green\">Two-factor authentication enabled</h2>", codes, nil
+
+	case "disable":
+		secret := ""
+		err := dep.Db.QueryRow(selectSecret, dep.Username).Scan(&secret)
+		if err != nil {
+			return "", nil, err
+		}
+		ok, err := verify(dep, dep.Username, secret, code)
+		if err != nil {
+			return "", nil, err
+		}
+		if !ok {
+			return "<h2 style=\"color:red\">Wrong code</h2>", nil, nil
+		}
+
+		_, err = dep.Db.Exec(updateSecret, "", dep.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		_, err = dep.Db.Exec(deleteRecoveryCodes, dep.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		return "<h2 style=\"color:green\">Two-factor authentication disabled</h2>", nil, nil
+
+	case "recoveryCodes":
+		secret := ""
+		err := dep.Db.QueryRow(selectSecret, dep.Username).Scan(&secret)
+		if err != nil {
+			return "", nil, err
+		}
+		ok, err := checkCode(dep, dep.Username, secret, code)
+		if err != nil {
+			return "", nil, err
+		}
+		if !ok {
+			return "<h2 style=\"color:red\">Wrong code</h2>", nil, nil
+		}
+
+		codes, err := replaceRecoveryCodes(dep.Db, dep.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		return "<h2 style=\"color:green\">New recovery codes created</h2>", codes, nil
+	}
+
+	return "<h2 style=\"color:red\">Unknown action</h2>", nil, nil
+}
+
+// settingsData returns current two-factor authentication settings of user.
+// If two-factor authentication is disabled settingsData returns secret for enrollment.
+func settingsData(dep session.Dependency) (TemplateTwoFactor, error) {
+	data := TemplateTwoFactor{Username: dep.Username}
+
+	secret := ""
+	err := dep.Db.QueryRow(selectSecret, dep.Username).Scan(&secret)
+	if err != nil {
+		return TemplateTwoFactor{}, err
+	}
+	if secret != "" {
+		data.Enabled = true
+		err = dep.Db.QueryRow(countRecoveryCodes, dep.Username).Scan(&data.RecoveryLeft)
+		if err != nil {
+			return TemplateTwoFactor{}, err
+		}
+		return data, nil
+	}
+
+	// the same secret is showed until it expires, so page reloading doesn't break enrollment
+	secret, err = redis.String(dep.Redis.Do("GET", pendingPrefix+dep.Username))
+	if err == redis.ErrNil {
+		secret, err = totp.NewSecret()
+		if err != nil {
+			return TemplateTwoFactor{}, err
+		}
+		_, err = dep.Redis.Do("SET", pendingPrefix+dep.Username, secret, "EX", pendingLifetime.Seconds())
+	}
+	if err != nil {
+		return TemplateTwoFactor{}, err
+	}
+
+	data.Secret = secret
+	// otpauth scheme isn't allowed by html/template in links, URI is built by totp.URI so it's safe
+	data.URI = template.URL(totp.URI(issuer, dep.Username, secret))
+	return data, nil
+}
+
+// checkCode returns true if code from authenticator app is valid and wasn't used before
+func checkCode(dep session.Dependency, username, secret, code string) (bool, error) {
+	if secret == "" {
+		return false, nil
+	}
+	step, ok := totp.Verify(secret, code, dep.Now())
+	if !ok {
+		return false, nil
+	}
+
+	lastStep, err := redis.Int64(dep.Redis.Do("GET", usedPrefix+username))
+	if err != nil && err != redis.ErrNil {
+		return false, err
+	}
+	if step <= lastStep {
+		return false, nil
+	}
+	// codes are accepted during several periods, so used period should be kept a bit longer
+	_, err = dep.Redis.Do("SET", usedPrefix+username, step, "EX", (3 * totp.Period).Seconds())
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verify returns true if code is valid code from authenticator app or unused recovery code of user
+func verify(dep session.Dependency, username, secret, code string) (bool, error) {
+	if secret == "" {
+		return false, nil
+	}
+	ok, err := checkCode(dep, username, secret, code)
+	if err != nil || ok {
+		return ok, err
+	}
+	return redeemRecoveryCode(dep.Db, username, code)
+}
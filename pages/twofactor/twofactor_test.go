@@ -0,0 +1,336 @@
+package twofactor_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/twofactor"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+const (
+	// secret is base32 encoded secret from RFC 6238 test vectors
+	secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	// code is valid code of secret at testTime
+	code = "050471"
+	// step is number of period of testTime
+	step = int64(1111111111 / 30)
+)
+
+var testTime = time.Unix(1111111111, 0)
+
+// newDep returns dependencies with clock which always returns testTime
+func newDep(t *testing.T) (session.Dependency, sqlmock.Sqlmock, *redigomock.Conn) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	dep.Clock = func() time.Time {
+		return testTime
+	}
+	return dep, sqlMock, redisMock
+}
+
+// expectCodeAccepted adds expectations of commands which protect code from reusing
+func expectCodeAccepted(redisMock *redigomock.Conn, username string) *redigomock.Cmd {
+	redisMock.Command("GET", "totp:used:"+username).Expect(nil)
+	return redisMock.Command("SET", "totp:used:"+username, step, "EX", float64(90))
+}
+
+// expectSecret adds expectation of query which selects secret of user
+func expectSecret(sqlMock sqlmock.Sqlmock, username, secret string) {
+	sqlMock.ExpectQuery("SELECT totpSecret FROM users WHERE username =").WithArgs(username).WillReturnRows(
+		sqlmock.NewRows([]string{"totpSecret"}).AddRow(secret))
+}
+
+// hash returns hash of recovery code
+func hash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// postAction sends POST request with two-factor authentication settings action
+func postAction(dep session.Dependency, action, code string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set("action", action)
+	data.Set("code", code)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/2fa", strings.NewReader(data.Encode()))
+	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+
+	sut := twofactor.Page(dep)
+	sut(w, r)
+
+	return w
+}
+
+func TestPageEnrollmentGET(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	expectSecret(sqlMock, "username", "")
+	redisMock.Command("GET", "totp:pending:username").Expect(secret)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/2fa", nil)
+	require.NoError(t, err)
+
+	sut := twofactor.Page(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Two-factor authentication</title>
+    <link rel="stylesheet" href="assets/css/twofactor.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="twoFactor">
+        <h2>Two-factor authentication</h2>
+        
+        <p>Add account to authenticator app by link: <a href="otpauth://totp/fileHostingSite:username?algorithm=SHA1&amp;digits=6&amp;issuer=fileHostingSite&amp;period=30&amp;secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ">otpauth://totp/fileHostingSite:username?algorithm=SHA1&amp;digits=6&amp;issuer=fileHostingSite&amp;period=30&amp;secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ</a></p>
+        <p>or enter secret manually: <code>GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ</code></p>
+        <form action="/2fa" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="enable">
+            <p>Code from authenticator app: <input required maxlength="6" type="text" name="code" autocomplete="one-time-code"></p>
+            <input type="submit" value="ENABLE">
+        </form>
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageNewSecretGET(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	expectSecret(sqlMock, "username", "")
+	redisMock.Command("GET", "totp:pending:username").Expect(nil)
+	pending := redisMock.Command("SET", "totp:pending:username", redigomock.NewAnyData(), "EX", float64(600))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/2fa", nil)
+	require.NoError(t, err)
+
+	sut := twofactor.Page(dep)
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, redisMock.Stats(pending))
+	assert.Contains(t, w.Body.String(), `<a href="otpauth://totp/fileHostingSite:username?`)
+}
+
+func TestPageEnabledGET(t *testing.T) {
+	dep, sqlMock, _ := newDep(t)
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/2fa", nil)
+	require.NoError(t, err)
+
+	sut := twofactor.Page(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Two-factor authentication</title>
+    <link rel="stylesheet" href="assets/css/twofactor.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="twoFactor">
+        <h2>Two-factor authentication</h2>
+        
+        <p>Two-factor authentication is enabled. Recovery codes left: 7</p>
+        <form action="/2fa" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="recoveryCodes">
+            <p>Code from authenticator app: <input required maxlength="6" type="text" name="code" autocomplete="one-time-code"></p>
+            <input type="submit" value="CREATE NEW RECOVERY CODES">
+        </form>
+        <form action="/2fa" method="post">
+            <input type="hidden" name="csrf_token" value="csrf">
+            <input type="hidden" name="action" value="disable">
+            <p>Code from authenticator app or recovery code: <input required maxlength="11" type="text" name="code" autocomplete="one-time-code"></p>
+            <input type="submit" value="DISABLE">
+        </form>
+    </div>
+</body>`, w.Body)
+}
+
+func TestPageDBErrorGET(t *testing.T) {
+	dep, sqlMock, _ := newDep(t)
+	sqlMock.ExpectQuery("SELECT totpSecret FROM users WHERE username =").WithArgs("username").WillReturnError(fmt.Errorf("testing error"))
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/2fa", nil)
+	require.NoError(t, err)
+
+	sut := twofactor.Page(dep)
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
+
+func TestPageEnableSuccessPOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	redisMock.Command("GET", "totp:pending:username").Expect(secret)
+	used := expectCodeAccepted(redisMock, "username")
+	sqlMock.ExpectExec("UPDATE users SET totpSecret").WithArgs(secret, "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	delPending := redisMock.Command("DEL", "totp:pending:username").Expect(int64(1))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < 10; i++ {
+		sqlMock.ExpectExec("INSERT INTO recoveryCodes").WithArgs("username", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	sqlMock.ExpectCommit()
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+	w := postAction(dep, "enable", code)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Two-factor authentication enabled</h2>`)
+	assert.Contains(t, w.Body.String(), `Recovery codes left: 10`)
+	assert.Equal(t, 10, strings.Count(w.Body.String(), "<li><code>"))
+	assert.Equal(t, 1, redisMock.Stats(used))
+	assert.Equal(t, 1, redisMock.Stats(delPending))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageEnableWrongCodePOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	redisMock.Command("GET", "totp:pending:username").Expect(secret)
+	expectSecret(sqlMock, "username", "")
+
+	w := postAction(dep, "enable", "123456")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong code</h2>`)
+	assert.Contains(t, w.Body.String(), `<input type="hidden" name="action" value="enable">`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageEnableReusedCodePOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	redisMock.Command("GET", "totp:pending:username").Expect(secret)
+	redisMock.Command("GET", "totp:used:username").Expect(step)
+	expectSecret(sqlMock, "username", "")
+
+	w := postAction(dep, "enable", code)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong code</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageEnableExpiredSecretPOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	redisMock.Command("GET", "totp:pending:username").Expect(nil)
+	expectSecret(sqlMock, "username", "")
+	redisMock.Command("SET", "totp:pending:username", redigomock.NewAnyData(), "EX", float64(600))
+
+	w := postAction(dep, "enable", code)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Secret expired. Please add new secret to authenticator app</h2>`)
+}
+
+func TestPageDisableByRecoveryCodePOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username = \\? AND codeHash").WithArgs("username", hash("abcdefghij")).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("UPDATE users SET totpSecret").WithArgs("", "username").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 9))
+	expectSecret(sqlMock, "username", "")
+	redisMock.Command("GET", "totp:pending:username").Expect(nil)
+	redisMock.Command("SET", "totp:pending:username", redigomock.NewAnyData(), "EX", float64(600))
+
+	w := postAction(dep, "disable", "ABCDE-FGHIJ")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">Two-factor authentication disabled</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageDisableWrongCodePOST(t *testing.T) {
+	dep, sqlMock, _ := newDep(t)
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username = \\? AND codeHash").WithArgs("username", hash("abcdefghij")).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+	w := postAction(dep, "disable", "abcde-fghij")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Wrong code</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageNewRecoveryCodesPOST(t *testing.T) {
+	dep, sqlMock, redisMock := newDep(t)
+	expectSecret(sqlMock, "username", secret)
+	expectCodeAccepted(redisMock, "username")
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("DELETE FROM recoveryCodes WHERE username").WithArgs("username").WillReturnResult(sqlmock.NewResult(0, 3))
+	for i := 0; i < 10; i++ {
+		sqlMock.ExpectExec("INSERT INTO recoveryCodes").WithArgs("username", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	sqlMock.ExpectCommit()
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+	w := postAction(dep, "recoveryCodes", code)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:green">New recovery codes created</h2>`)
+	assert.Equal(t, 10, strings.Count(w.Body.String(), "<li><code>"))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageUnknownActionPOST(t *testing.T) {
+	dep, sqlMock, _ := newDep(t)
+	expectSecret(sqlMock, "username", secret)
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+	w := postAction(dep, "unknown", code)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2 style="color:red">Unknown action</h2>`)
+}
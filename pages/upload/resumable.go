@@ -0,0 +1,456 @@
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/fileops"
+	"github.com/vpoletaev11/fileHostingSite/session"
+)
+
+// ResumablePath is path prefix of resumable upload[/upload/resumable/] handler
+const ResumablePath = "/upload/resumable/"
+
+// path to directory with partially uploaded files
+const partialDir = "partial/"
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination"
+	uploadIDLen   = 32
+)
+
+const (
+	createPartialUpload = "INSERT INTO partialUploads (id, owner, length, label, description, category, originalName, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+
+	selectPartialUpload = "SELECT length, label, description, category, originalName FROM partialUploads WHERE id = ? AND owner = ?;"
+
+	deletePartialUpload = "DELETE FROM partialUploads WHERE id = ?;"
+
+	selectUserUploads = "SELECT id FROM partialUploads WHERE owner = ? FOR UPDATE;"
+
+	deleteUserUploads = "DELETE FROM partialUploads WHERE owner = ?;"
+
+	// timeout is 0, so request is rejected immediately if upload is locked by another request
+	getUploadLock = "SELECT GET_LOCK(?, 0);"
+
+	releaseUploadLock = "SELECT RELEASE_LOCK(?);"
+)
+
+// partialUpload contains information about file uploading by tus protocol
+type partialUpload struct {
+	id           string
+	length       int64
+	label        string
+	description  string
+	category     string
+	originalName string
+}
+
+// Resumable returns HandleFunc for resumable upload[/upload/resumable/] handler.
+// Handler implements tus.io core protocol with creation and termination extensions.
+// Handler is wrapped by session.AuthWrapper, so POST, PATCH and DELETE requests should contain X-CSRF-Token header.
+func Resumable(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ServeResumable(dep, w, r, ResumablePath, r.URL.Path[len(ResumablePath):])
+	}
+}
+
+// ServeResumable handles tus request to resumable upload with inputted id (id is empty for creation of upload).
+// path is prefix of uploads URLs, it's used in Location header of created upload.
+// When upload is finished the last PATCH response contains ID of uploaded file in Upload-File-ID header.
+func ServeResumable(dep session.Dependency, w http.ResponseWriter, r *http.Request, path, id string) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.Itoa(fileops.MaxFilesize))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		if id != "" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		createUpload(dep, w, r, path)
+		return
+	case "HEAD", "PATCH", "DELETE":
+		// PATCH and DELETE requests of upload are serialized, concurrent request receives 423 Locked.
+		// Upload is read after lock is got, so it's not changed or finalized by another request.
+		if r.Method != "HEAD" && validUploadID(id) {
+			unlock, locked, err := lockUpload(dep.Db, id)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			if !locked {
+				w.WriteHeader(http.StatusLocked)
+				return
+			}
+			defer unlock()
+		}
+
+		pu, err := getPartialUpload(dep, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "HEAD":
+			offset, err := uploadOffset(pu.id)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+			w.Header().Set("Upload-Length", strconv.FormatInt(pu.length, 10))
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+		case "PATCH":
+			patchUpload(dep, pu, w, r)
+		case "DELETE":
+			_, err := dep.Db.Exec(deletePartialUpload, pu.id)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			err = os.Remove(partialDir + pu.id)
+			if err != nil && !os.IsNotExist(err) {
+				errhand.InternalError(err, w)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// createUpload handles creation of new resumable upload
+func createUpload(dep session.Dependency, w http.ResponseWriter, r *http.Request, path string) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "Incorrect Upload-Length")
+		return
+	}
+	if length > fileops.MaxFilesize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		fmt.Fprintln(w, "Filesize cannot be more than 1GB")
+		return
+	}
+
+	metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, "Incorrect Upload-Metadata")
+		return
+	}
+
+	pu := partialUpload{
+		length:       length,
+		label:        metadata["label"],
+		description:  metadata["description"],
+		category:     metadata["category"],
+		originalName: metadata["filename"],
+	}
+	// if label is empty will be used original filename
+	if pu.label == "" {
+		pu.label = pu.originalName
+	}
+
+	err = fileops.Validate(pu.length, pu.label, pu.description)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	err = categories.Check(dep.Db, pu.category)
+	if err == categories.ErrUnknown {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintln(w, err.Error())
+		return
+	}
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	pu.id, err = newUploadID()
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	f, err := os.Create(partialDir + pu.id)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+	f.Close()
+
+	_, err = dep.Db.Exec(createPartialUpload, pu.id, dep.Username, pu.length, pu.label, pu.description, pu.category, pu.originalName, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		os.Remove(partialDir + pu.id)
+		errhand.InternalError(err, w)
+		return
+	}
+
+	w.Header().Set("Location", path+pu.id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// patchUpload appends request body to partially uploaded file.
+// When last chunk of file arrives file will be moved to storage
+func patchUpload(dep session.Dependency, pu partialUpload, w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := uploadOffset(pu.id)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+
+	reqOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if reqOffset != offset {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	f, err := os.OpenFile(partialDir+pu.id, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		errhand.InternalError(err, w)
+		return
+	}
+	// data which is out of declared Upload-Length will be ignored
+	n, err := io.Copy(f, io.LimitReader(r.Body, pu.length-offset))
+	f.Close()
+	offset += n
+	// connection interruption is not an error: client will continue upload from saved offset
+	if err != nil && offset != pu.length {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if offset == pu.length {
+		fileID, err := finalizeUpload(dep, pu)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+		w.Header().Set("Upload-File-ID", strconv.Itoa(fileID))
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finalizeUpload moves fully uploaded file to blob store, sends information about it to MySQL database
+// and returns ID of uploaded file.
+// File is created and resumable upload is deleted in one transaction, so file isn't created twice if request is retried.
+func finalizeUpload(dep session.Dependency, pu partialUpload) (int, error) {
+	f, err := os.Open(partialDir + pu.id)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	meta := fileops.Meta{
+		Label:        pu.label,
+		Description:  pu.description,
+		Category:     pu.category,
+		OriginalName: pu.originalName,
+		Finish: func(tx *sql.Tx) error {
+			_, err := tx.Exec(deletePartialUpload, pu.id)
+			return err
+		},
+	}
+	created, err := fileops.Create(dep, meta, f)
+	if err != nil {
+		return 0, err
+	}
+
+	// file is already created, so partial file which isn't removed is left for "fhs-admin expire-uploads"
+	os.Remove(partialDir + pu.id)
+	return created.ID, nil
+}
+
+// lockUpload gets MySQL named lock of resumable upload.
+// Named lock belongs to connection, so connection is kept until returned unlock function is called.
+// If upload is locked by another request lockUpload returns false.
+func lockUpload(db *sql.DB, id string) (unlock func(), locked bool, err error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, false, err
+	}
+	// GET_LOCK returns 1 if lock is obtained, 0 if lock is held by another connection and NULL on error
+	result := sql.NullInt64{}
+	err = conn.QueryRowContext(context.Background(), getUploadLock, uploadLockName(id)).Scan(&result)
+	if err != nil || result.Int64 != 1 {
+		conn.Close()
+		return nil, false, err
+	}
+
+	unlock = func() {
+		released := sql.NullInt64{}
+		conn.QueryRowContext(context.Background(), releaseUploadLock, uploadLockName(id)).Scan(&released)
+		conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// uploadLockName returns name of MySQL lock of resumable upload
+func uploadLockName(id string) string {
+	return "fileHostingSite.partialUploads." + id
+}
+
+// DeleteUploadsTx deletes resumable uploads of user inside of transaction, it's used on account deletion.
+// It returns IDs of deleted uploads, their partial files should be removed by RemovePartialFiles after commit.
+// Transaction isn't rolled back on error.
+func DeleteUploadsTx(tx *sql.Tx, owner string) ([]string, error) {
+	rows, err := tx.Query(selectUserUploads, owner)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		id := ""
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(deleteUserUploads, owner)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// RemovePartialFiles removes partially uploaded files of deleted resumable uploads.
+// Files which cannot be removed are left for "fhs-admin expire-uploads".
+func RemovePartialFiles(ids []string) {
+	for _, id := range ids {
+		if validUploadID(id) {
+			os.Remove(partialDir + id)
+		}
+	}
+}
+
+// getPartialUpload returns information about resumable upload owned by user
+func getPartialUpload(dep session.Dependency, id string) (partialUpload, error) {
+	if !validUploadID(id) {
+		return partialUpload{}, sql.ErrNoRows
+	}
+
+	pu := partialUpload{id: id}
+	err := dep.Db.QueryRow(selectPartialUpload, id, dep.Username).Scan(
+		&pu.length,
+		&pu.label,
+		&pu.description,
+		&pu.category,
+		&pu.originalName,
+	)
+	if err != nil {
+		return partialUpload{}, err
+	}
+	return pu, nil
+}
+
+// uploadOffset returns count of already uploaded bytes
+func uploadOffset(id string) (int64, error) {
+	stat, err := os.Stat(partialDir + id)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// parseMetadata parses Upload-Metadata header: comma separated pairs of key and base64 encoded value
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("Incorrect metadata pair: %q", pair)
+		}
+	}
+	return metadata, nil
+}
+
+// newUploadID returns random identifier of resumable upload
+func newUploadID() (string, error) {
+	b := make([]byte, uploadIDLen/2)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validUploadID checks is id can be identifier of resumable upload (it also protects from path traversal)
+func validUploadID(id string) bool {
+	if len(id) != uploadIDLen || id != strings.ToLower(id) {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,439 @@
+package upload_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/upload"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// id of resumable upload which creates in partial directory for tests
+const testUploadID = "0123456789abcdef0123456789abcdef"
+
+type anyUploadID struct{}
+
+// ()Match() checks is input value are resumable upload id
+func (a anyUploadID) Match(v driver.Value) bool {
+	id, ok := v.(string)
+	if !ok {
+		return false
+	}
+	return len(id) == 32
+}
+
+// partialUploadRows returns rows with information about test resumable upload
+func partialUploadRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"length",
+		"label",
+		"description",
+		"category",
+		"originalName",
+	}).AddRow(
+		11,
+		"label",
+		"description",
+		"other",
+		"file.txt",
+	)
+}
+
+// createPartialFile creates partially uploaded file and returns function that removes it
+func createPartialFile(t *testing.T, data string) func() {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	err := ioutil.WriteFile("partial/"+testUploadID, []byte(data), 0644)
+	require.NoError(t, err)
+
+	return func() {
+		os.Remove("partial/" + testUploadID)
+		os.Chdir("pages/upload")
+	}
+}
+
+// expectUploadLock adds expectation of successful locking of test resumable upload
+func expectUploadLock(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT GET_LOCK").WithArgs("fileHostingSite.partialUploads." + testUploadID).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(1))
+}
+
+// expectUploadUnlock adds expectation of releasing of test resumable upload lock
+func expectUploadUnlock(sqlMock sqlmock.Sqlmock) {
+	sqlMock.ExpectQuery("SELECT RELEASE_LOCK").WithArgs("fileHostingSite.partialUploads." + testUploadID).WillReturnRows(sqlmock.NewRows([]string{"released"}).AddRow(1))
+}
+
+// tusRequest creates request to resumable upload handler
+func tusRequest(t *testing.T, method, path, body string) *http.Request {
+	r, err := http.NewRequest(method, "http://localhost"+path, strings.NewReader(body))
+	require.NoError(t, err)
+	r.Header.Set("Tus-Resumable", "1.0.0")
+	return r
+}
+
+// metadata encodes key-value pair for Upload-Metadata header
+func metadata(key, value string) string {
+	return key + " " + base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func TestResumableOPTIONS(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodOptions, "http://localhost/upload/resumable/", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "1.0.0", w.Header().Get("Tus-Resumable"))
+	assert.Equal(t, "1.0.0", w.Header().Get("Tus-Version"))
+	assert.Equal(t, "creation,termination", w.Header().Get("Tus-Extension"))
+	assert.Equal(t, "1073741824", w.Header().Get("Tus-Max-Size"))
+}
+
+func TestResumableUnsupportedVersion(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPost, "/upload/resumable/", "")
+	r.Header.Set("Tus-Resumable", "0.2.2")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, "1.0.0", w.Header().Get("Tus-Version"))
+}
+
+func TestResumableCreationSuccess(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/upload")
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug").WithArgs("other").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectExec("INSERT INTO partialUploads").WithArgs(
+		anyUploadID{},
+		"username",
+		11,
+		"label",
+		"description",
+		"other",
+		"file.txt",
+		anyTime{},
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPost, "/upload/resumable/", "")
+	r.Header.Set("Upload-Length", "11")
+	r.Header.Set("Upload-Metadata", strings.Join([]string{
+		metadata("filename", "file.txt"),
+		metadata("label", "label"),
+		metadata("description", "description"),
+		metadata("category", "other"),
+	}, ","))
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	location := w.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/upload/resumable/"))
+
+	id := location[len("/upload/resumable/"):]
+	defer os.Remove("partial/" + id)
+	stat, err := os.Stat("partial/" + id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stat.Size())
+}
+
+func TestResumableCreationWrongCategoryError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE slug").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPost, "/upload/resumable/", "")
+	r.Header.Set("Upload-Length", "11")
+	r.Header.Set("Upload-Metadata", strings.Join([]string{
+		metadata("filename", "file.txt"),
+		metadata("category", "unknown"),
+	}, ","))
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	test.AssertBodyEqual(t, "Unknown category\n", w.Body)
+}
+
+func TestResumableCreationLargeFileError(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPost, "/upload/resumable/", "")
+	r.Header.Set("Upload-Length", "1073741825")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestResumableCreationIncorrectMetadataError(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPost, "/upload/resumable/", "")
+	r.Header.Set("Upload-Length", "11")
+	r.Header.Set("Upload-Metadata", "filename not_base64!")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	test.AssertBodyEqual(t, "Incorrect Upload-Metadata\n", w.Body)
+}
+
+func TestResumableHEADSuccess(t *testing.T) {
+	cleanup := createPartialFile(t, "binary ")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(partialUploadRows())
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodHead, "/upload/resumable/"+testUploadID, "")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "7", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "11", w.Header().Get("Upload-Length"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
+
+func TestResumableHEADUnknownUploadError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(sqlmock.NewRows([]string{"length"}))
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodHead, "/upload/resumable/"+testUploadID, "")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumableIncorrectUploadIDError(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodHead, "/upload/resumable/../../main.go", "")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumablePATCHChunkSuccess(t *testing.T) {
+	cleanup := createPartialFile(t, "bin")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	expectUploadLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(partialUploadRows())
+	expectUploadUnlock(sqlMock)
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPatch, "/upload/resumable/"+testUploadID, "ary ")
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", "3")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "7", w.Header().Get("Upload-Offset"))
+
+	data, err := ioutil.ReadFile("partial/" + testUploadID)
+	require.NoError(t, err)
+	assert.Equal(t, "binary ", string(data))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumablePATCHLastChunkSuccess(t *testing.T) {
+	cleanup := createPartialFile(t, "binary ")
+	defer cleanup()
+	defer os.Remove("files/" + binaryDataHash)
+
+	dep, sqlMock, _ := test.NewDep(t)
+	expectUploadLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(partialUploadRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO files").WithArgs(
+		"label",
+		11,
+		"description",
+		"username",
+		"other",
+		anyTime{},
+		"text/plain; charset=utf-8",
+		"file.txt",
+		binaryDataHash,
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("DELETE FROM partialUploads").WithArgs(testUploadID).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+	expectUploadUnlock(sqlMock)
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	// data which is out of Upload-Length should be ignored
+	r := tusRequest(t, http.MethodPatch, "/upload/resumable/"+testUploadID, "data and garbage")
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", "7")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "11", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "1", w.Header().Get("Upload-File-ID"))
+
+	data, err := ioutil.ReadFile("files/" + binaryDataHash)
+	require.NoError(t, err)
+	assert.Equal(t, "binary data", string(data))
+
+	_, err = os.Stat("partial/" + testUploadID)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumablePATCHOffsetConflictError(t *testing.T) {
+	cleanup := createPartialFile(t, "binary ")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	expectUploadLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(partialUploadRows())
+	expectUploadUnlock(sqlMock)
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPatch, "/upload/resumable/"+testUploadID, "data")
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", "3")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumablePATCHLockedError(t *testing.T) {
+	cleanup := createPartialFile(t, "binary ")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT GET_LOCK").WithArgs("fileHostingSite.partialUploads." + testUploadID).WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(0))
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPatch, "/upload/resumable/"+testUploadID, "data")
+	r.Header.Set("Content-Type", "application/offset+octet-stream")
+	r.Header.Set("Upload-Offset", "7")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusLocked, w.Code)
+	data, err := ioutil.ReadFile("partial/" + testUploadID)
+	require.NoError(t, err)
+	assert.Equal(t, "binary ", string(data))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumablePATCHWrongContentTypeError(t *testing.T) {
+	cleanup := createPartialFile(t, "binary ")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	expectUploadLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(partialUploadRows())
+	expectUploadUnlock(sqlMock)
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodPatch, "/upload/resumable/"+testUploadID, "data")
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("Upload-Offset", "7")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumableDELETESuccess(t *testing.T) {
+	cleanup := createPartialFile(t, "binary ")
+	defer cleanup()
+
+	dep, sqlMock, _ := test.NewDep(t)
+	expectUploadLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnRows(partialUploadRows())
+	sqlMock.ExpectExec("DELETE FROM partialUploads").WithArgs(testUploadID).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectUploadUnlock(sqlMock)
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodDelete, "/upload/resumable/"+testUploadID, "")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err := os.Stat("partial/" + testUploadID)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestResumableDBError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectUploadLock(sqlMock)
+	sqlMock.ExpectQuery("SELECT length, label, description, category, originalName FROM partialUploads").WithArgs(testUploadID, "username").WillReturnError(fmt.Errorf("testing error"))
+	expectUploadUnlock(sqlMock)
+
+	sut := upload.Resumable(dep)
+
+	w := httptest.NewRecorder()
+	r := tusRequest(t, http.MethodDelete, "/upload/resumable/"+testUploadID, "")
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
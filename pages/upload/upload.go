@@ -1,15 +1,13 @@
 package upload
 
 import (
-	"fmt"
 	"html/template"
-	"io"
 	"net/http"
-	"os"
-	"strconv"
-	"time"
 
+	"github.com/vpoletaev11/fileHostingSite/categories"
+	"github.com/vpoletaev11/fileHostingSite/fileops"
 	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tags"
 	"github.com/vpoletaev11/fileHostingSite/tmp"
 
 	"github.com/vpoletaev11/fileHostingSite/errhand"
@@ -18,56 +16,33 @@ import (
 // path to upload[/upload] template file
 const pathTemplateUpload = "pages/upload/template/upload.html"
 
-const (
-	sendFileInfoToDB = "INSERT INTO files (label, filesizeBytes, description, owner, category, uploadDate) VALUES (?, ?, ?, ?, ?, ?);"
-
-	deleteFileInfoFromDB = "DELETE FROM files WHERE id = ?"
-)
-
-const (
-	maxFilenameLen    = 50
-	maxDescriptionLen = 500
-	maxFilesize       = 1024 * 1024 * 1024
-)
-
 // TemplateUpload contains data for login[/login] page template
 type TemplateUpload struct {
-	Warning  template.HTML
-	Username string
-}
-
-// PassThru contains reader and total writted on disk bytes
-type PassThru struct {
-	io.Reader
-	total int64 // Total # of bytes transferred
-}
-
-// Read 'overrides' the underlying io.Reader's Read method.
-// This is the one that will be called by io.Copy().
-// This is used while copying to check is the uploaded file size larger than 1GB.
-func (pt *PassThru) Read(p []byte) (int, error) {
-	n, err := pt.Reader.Read(p)
-	pt.total += int64(n)
-
-	if pt.total == 1024*1024*1024 {
-		return 0, fmt.Errorf("File more than 1GB")
-	}
-
-	return n, err
+	Warning         template.HTML
+	Username        string
+	Categories      []categories.Category // tree of active categories
+	DefaultCategory string
 }
 
 // Page returns HandleFunc for upload[/upload] file page
 func Page(dep session.Dependency) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		page, err := tmp.CreateTemplate(pathTemplateUpload)
+		page, err := tmp.CreateFormTemplate(pathTemplateUpload, dep.CSRFToken)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		categoriesList, err := categories.Active(dep.Db)
 		if err != nil {
 			errhand.InternalError(err, w)
 			return
 		}
+		data := TemplateUpload{Username: dep.Username, Categories: categories.Tree(categoriesList), DefaultCategory: categories.Default}
 
 		switch r.Method {
 		case "GET":
-			err := page.Execute(w, TemplateUpload{Username: dep.Username})
+			err := page.Execute(w, data)
 			if err != nil {
 				errhand.InternalError(err, w)
 				return
@@ -91,26 +66,16 @@ func Page(dep session.Dependency) http.HandlerFunc {
 				filename = header.Filename
 			}
 
-			err = fileInfoValidator(header.Size, filename, description, category)
-			if err != nil {
-				err := page.Execute(w, TemplateUpload{Warning: "<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>", Username: dep.Username})
-				if err != nil {
-					errhand.InternalError(err, w)
-					return
+			err = fileops.Validate(header.Size, filename, description)
+			if err == nil {
+				// files cannot be uploaded to retired categories, so only active categories are checked
+				if _, ok := categories.Find(categoriesList, category); !ok {
+					err = categories.ErrUnknown
 				}
-				return
 			}
-
-			// todo: timezone utc
-			// sending information about uploaded file to MySQL server
-			loc, err := time.LoadLocation("UTC")
-			if err != nil {
-				errhand.InternalError(err, w)
-				return
-			}
-			res, err := dep.Db.Exec(sendFileInfoToDB, filename, header.Size, description, dep.Username, category, time.Now().In(loc).Format("2006-01-02 15:04:05"))
 			if err != nil {
-				err := page.Execute(w, TemplateUpload{Warning: "<h2 style=\"color:red\">INTERNAL ERROR. Please try later</h2>", Username: dep.Username})
+				data.Warning = "<h2 style=\"color:red\">" + template.HTML(err.Error()) + "</h2>"
+				err := page.Execute(w, data)
 				if err != nil {
 					errhand.InternalError(err, w)
 					return
@@ -118,37 +83,32 @@ func Page(dep session.Dependency) http.HandlerFunc {
 				return
 			}
 
-			// getting id of uploaded file from exec
-			idInt, err := res.LastInsertId()
+			fileTags, err := tags.Parse(r.FormValue("tags"))
 			if err != nil {
-				err := page.Execute(w, TemplateUpload{Warning: "<h2 style=\"color:red\">INTERNAL ERROR. Please try later</h2>", Username: dep.Username})
+				data.Warning = "<h2 style=\"color:red\">" + template.HTML(template.HTMLEscapeString(err.Error())) + "</h2>"
+				err := page.Execute(w, data)
 				if err != nil {
 					errhand.InternalError(err, w)
 					return
 				}
 				return
 			}
-			id := strconv.FormatInt(idInt, 10)
 
-			// writting data to file on disk from uploaded file
-			f, err := os.Create("files/" + id)
+			// file info and tags are saved in one transaction, so file isn't created without its tags
+			meta := fileops.Meta{Label: filename, Description: description, Category: category, OriginalName: header.Filename, Tags: fileTags}
+			_, err = fileops.Create(dep, meta, file)
 			if err != nil {
-				errhand.InternalError(err, w)
-				return
-			}
-
-			_, err = io.Copy(f, &PassThru{Reader: file})
-			if err != nil {
-				err := os.Remove(f.Name())
-				if err != nil {
-					errhand.InternalError(err, w)
+				if err == fileops.ErrTooLarge {
+					data.Warning = "<h2 style=\"color:red\">Filesize more than 1GB</h2>"
+					page.Execute(w, data)
 					return
 				}
-				page.Execute(w, TemplateUpload{Warning: "<h2 style=\"color:red\">Filesize more than 1GB</h2>", Username: dep.Username})
+				errhand.InternalError(err, w)
 				return
 			}
 
-			err = page.Execute(w, TemplateUpload{Warning: "<h2 style=\"color:green\">FILE SUCCEEDED UPLOADED</h2>", Username: dep.Username})
+			data.Warning = "<h2 style=\"color:green\">FILE SUCCEEDED UPLOADED</h2>"
+			err = page.Execute(w, data)
 			if err != nil {
 				errhand.InternalError(err, w)
 				return
@@ -157,24 +117,3 @@ func Page(dep session.Dependency) http.HandlerFunc {
 		}
 	}
 }
-
-func fileInfoValidator(filesize int64, filename, description, category string) error {
-	switch {
-	case filesize > maxFilesize:
-		return fmt.Errorf("Filesize cannot be more than 1GB")
-
-	case len(filename) > maxFilenameLen:
-		return fmt.Errorf("Filename are too long")
-
-	case len(description) > maxDescriptionLen:
-		return fmt.Errorf("Description are too long")
-	}
-
-	switch category {
-	case "other", "games", "documents", "projects", "music":
-	default:
-		return fmt.Errorf("Unknown category")
-	}
-
-	return nil
-}
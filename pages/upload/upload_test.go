@@ -16,6 +16,9 @@ import (
 	"github.com/vpoletaev11/fileHostingSite/test"
 )
 
+// sha256 hash of uploaded "binary data"
+const binaryDataHash = "9cb63cb779e8c571db3199b783a36cc43cd9e7c076beeb496c39e9cc06196dc5"
+
 type anyTime struct{}
 
 // ()Match() checks is input value are time
@@ -31,7 +34,8 @@ func (a anyTime) Match(v driver.Value) bool {
 }
 
 func TestPageSuccessGET(t *testing.T) {
-	dep, _, _ := test.NewDep(t)
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
 	sut := upload.Page(dep)
 	w := httptest.NewRecorder()
 	r, err := http.NewRequest(http.MethodGet, "http://localhost/upload", nil)
@@ -45,6 +49,7 @@ func TestPageSuccessGET(t *testing.T) {
     <meta charset="UTF-8">
     <title>Upload file</title>
     <link rel="stylesheet" href="assets/css/upload.css">
+    <script src="assets/js/tags.js"></script>
 <head>
 <body bgcolor=#f1ded3>
     <div class="menu">
@@ -61,17 +66,21 @@ func TestPageSuccessGET(t *testing.T) {
     <div class="uploadFormBox">
         <div class="uploadFormContent">
         <form action="" method="post" enctype="multipart/form-data">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
             <p>Input description for uploading file:</p>
             <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
     
             <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
+                <option selected="selected" value="other">Other</option>
+                <option value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
                 </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
                    
             <p><input required type="file" name="uploaded_file"></input></p>
 
@@ -87,8 +96,14 @@ func TestPageSuccessPOST(t *testing.T) {
 	// changing directory because of test are not containing in root folder
 	os.Chdir("../../")
 	defer os.Chdir("pages/upload")
+	defer os.Remove("files/" + binaryDataHash)
 
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
 	sqlMock.ExpectExec("INSERT INTO files").WithArgs(
 		"filename",
 		11,
@@ -96,7 +111,11 @@ func TestPageSuccessPOST(t *testing.T) {
 		"username",
 		"other",
 		anyTime{},
+		"text/plain; charset=utf-8",
+		"file",
+		binaryDataHash,
 	).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
 
 	postData :=
 		`--xxx
@@ -136,6 +155,7 @@ binary data
     <meta charset="UTF-8">
     <title>Upload file</title>
     <link rel="stylesheet" href="assets/css/upload.css">
+    <script src="assets/js/tags.js"></script>
 <head>
 <body bgcolor=#f1ded3>
     <div class="menu">
@@ -152,17 +172,21 @@ binary data
     <div class="uploadFormBox">
         <div class="uploadFormContent">
         <form action="" method="post" enctype="multipart/form-data">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
             <p>Input description for uploading file:</p>
             <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
     
             <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
+                <option selected="selected" value="other">Other</option>
+                <option value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
                 </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
                    
             <p><input required type="file" name="uploaded_file"></input></p>
 
@@ -176,6 +200,7 @@ binary data
 
 func TestPageErrorFileReceptionPOST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
 	// changing directory because of test are not containing in root folder
 	os.Chdir("../../")
 	defer os.Chdir("pages/upload")
@@ -187,6 +212,8 @@ func TestPageErrorFileReceptionPOST(t *testing.T) {
 		"username",
 		"other",
 		anyTime{},
+		"text/plain; charset=utf-8",
+		"file",
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	postData :=
@@ -220,10 +247,11 @@ other
 
 func TestPageEmptyFilenameSuccessPOST(t *testing.T) {
 	dep, sqlMock, _ := test.NewDep(t)
-	// changing directory because of test are not containing in root folder
-	os.Chdir("../../")
-	defer os.Chdir("pages/upload")
-
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
 	sqlMock.ExpectExec("INSERT INTO files").WithArgs(
 		"file",
 		11,
@@ -231,7 +259,15 @@ func TestPageEmptyFilenameSuccessPOST(t *testing.T) {
 		"username",
 		"other",
 		anyTime{},
+		"text/plain; charset=utf-8",
+		"file",
+		binaryDataHash,
 	).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectCommit()
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/upload")
+	defer os.Remove("files/" + binaryDataHash)
 
 	postData :=
 		`--xxx
@@ -271,6 +307,7 @@ binary data
     <meta charset="UTF-8">
     <title>Upload file</title>
     <link rel="stylesheet" href="assets/css/upload.css">
+    <script src="assets/js/tags.js"></script>
 <head>
 <body bgcolor=#f1ded3>
     <div class="menu">
@@ -287,17 +324,21 @@ binary data
     <div class="uploadFormBox">
         <div class="uploadFormContent">
         <form action="" method="post" enctype="multipart/form-data">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
             <p>Input description for uploading file:</p>
             <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
     
             <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
+                <option selected="selected" value="other">Other</option>
+                <option value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
                 </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
                    
             <p><input required type="file" name="uploaded_file"></input></p>
 
@@ -310,7 +351,8 @@ binary data
 }
 
 func TestPageLargeFilenameErrorPOST(t *testing.T) {
-	dep, _, _ := test.NewDep(t)
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
 	postData :=
 		`--xxx
 Content-Disposition: form-data; name="filename"
@@ -349,6 +391,7 @@ binary data
     <meta charset="UTF-8">
     <title>Upload file</title>
     <link rel="stylesheet" href="assets/css/upload.css">
+    <script src="assets/js/tags.js"></script>
 <head>
 <body bgcolor=#f1ded3>
     <div class="menu">
@@ -365,17 +408,21 @@ binary data
     <div class="uploadFormBox">
         <div class="uploadFormContent">
         <form action="" method="post" enctype="multipart/form-data">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
             <p>Input description for uploading file:</p>
             <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
     
             <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
+                <option selected="selected" value="other">Other</option>
+                <option value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
                 </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
                    
             <p><input required type="file" name="uploaded_file"></input></p>
 
@@ -388,7 +435,8 @@ binary data
 }
 
 func TestPageLargeDescriptionErrorPOST(t *testing.T) {
-	dep, _, _ := test.NewDep(t)
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
 	postData :=
 		`--xxx
 Content-Disposition: form-data; name="filename"
@@ -427,6 +475,7 @@ binary data
     <meta charset="UTF-8">
     <title>Upload file</title>
     <link rel="stylesheet" href="assets/css/upload.css">
+    <script src="assets/js/tags.js"></script>
 <head>
 <body bgcolor=#f1ded3>
     <div class="menu">
@@ -443,17 +492,21 @@ binary data
     <div class="uploadFormBox">
         <div class="uploadFormContent">
         <form action="" method="post" enctype="multipart/form-data">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
             <p>Input description for uploading file:</p>
             <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
     
             <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
+                <option selected="selected" value="other">Other</option>
+                <option value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
                 </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
                    
             <p><input required type="file" name="uploaded_file"></input></p>
 
@@ -466,7 +519,8 @@ binary data
 }
 
 func TestPageWrongCategoryErrorPOST(t *testing.T) {
-	dep, _, _ := test.NewDep(t)
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
 	postData :=
 		`--xxx
 Content-Disposition: form-data; name="filename"
@@ -505,6 +559,7 @@ binary data
     <meta charset="UTF-8">
     <title>Upload file</title>
     <link rel="stylesheet" href="assets/css/upload.css">
+    <script src="assets/js/tags.js"></script>
 <head>
 <body bgcolor=#f1ded3>
     <div class="menu">
@@ -521,17 +576,21 @@ binary data
     <div class="uploadFormBox">
         <div class="uploadFormContent">
         <form action="" method="post" enctype="multipart/form-data">
+            <input type="hidden" name="csrf_token" value="csrf">
             <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
             <p>Input description for uploading file:</p>
             <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
     
             <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
+                <option selected="selected" value="other">Other</option>
+                <option value="games">Games</option>
+                <option value="documents">Documents</option>
+                <option value="projects">Projects</option>
+                <option value="music">Music</option>
                 </select></p>
+
+            <p>Tags: <input type="text" maxlength="330" name="tags" list="tagSuggestions" placeholder="comma separated, e.g. rock, jazz" oninput="suggestTags(this)"></p>
+            <datalist id="tagSuggestions"></datalist>
                    
             <p><input required type="file" name="uploaded_file"></input></p>
 
@@ -544,7 +603,16 @@ binary data
 }
 
 func TestPageDBInsertionErrorPOST(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/upload")
+
 	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
 	sqlMock.ExpectExec("INSERT INTO files").WithArgs(
 		"filename",
 		11,
@@ -552,7 +620,15 @@ func TestPageDBInsertionErrorPOST(t *testing.T) {
 		"username",
 		"other",
 		anyTime{},
+		"text/plain; charset=utf-8",
+		"file",
+		binaryDataHash,
 	).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs").WithArgs(binaryDataHash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(binaryDataHash).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
 
 	postData :=
 		`--xxx
@@ -586,60 +662,63 @@ binary data
 	sut := upload.Page(dep)
 	sut(w, r)
 
-	test.AssertBodyEqual(t, `<!doctype html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <title>Upload file</title>
-    <link rel="stylesheet" href="assets/css/upload.css">
-<head>
-<body bgcolor=#f1ded3>
-    <div class="menu">
-        <ul class="nav">
-            <li><a href="/">Home</a></li>
-            <li><a href="/categories">Categories</a></li>
-            <li><a href="/popular">Most popular</a></li>
-            <li><a href="/users">Users</a></li>
-            <li><a href="/logout">Logout</a></li>
-        </ul>
-    </div>
-    <div class="username">Welcome, username</div>
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
 
-    <div class="uploadFormBox">
-        <div class="uploadFormContent">
-        <form action="" method="post" enctype="multipart/form-data">
-            <p>Filename: <input type="text" maxlength="50" name="filename"></p><br>
-            <p>Input description for uploading file:</p>
-            <textarea cols="80" rows="15" maxlength="500" name="description"></textarea>
-    
-            <p>Category: <select name="category">
-                <option selected="selected" value="other">other</option>
-                <option value="games">games</option>
-                <option value="documents">documents</option>
-                <option value="projects">projects</option>
-                <option value="music">music</option>
-                </select></p>
-                   
-            <p><input required type="file" name="uploaded_file"></input></p>
+func TestPageCreatingFileErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	// files directory doesn't exist in working directory of test, so storing of blob will fail
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectRollback()
 
-            <p><input type="submit" value="UPLOAD"></p>
-            <h2 style="color:red">INTERNAL ERROR. Please try later</h2>
-        </form>
-        </div>
-    </div>
-</body>`, w.Body)
+	postData :=
+		`--xxx
+Content-Disposition: form-data; name="filename"
+
+filename
+--xxx
+Content-Disposition: form-data; name="description"
+
+description
+--xxx
+Content-Disposition: form-data; name="category"
+
+other
+--xxx
+Content-Disposition: form-data; name="uploaded_file"; filename="file"
+Content-Type: application/octet-stream
+Content-Transfer-Encoding: binary
+
+binary data
+--xxx--
+`
+	r := &http.Request{
+		Method: "POST",
+		Header: http.Header{"Content-Type": {`multipart/form-data; boundary=xxx`}},
+		Body:   ioutil.NopCloser(strings.NewReader(postData)),
+	}
+
+	w := httptest.NewRecorder()
+
+	sut := upload.Page(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
 }
 
-func TestPageCreatingFileErrorPOST(t *testing.T) {
+func TestPageBlobStoreErrorPOST(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/upload")
+
 	dep, sqlMock, _ := test.NewDep(t)
-	sqlMock.ExpectExec("INSERT INTO files").WithArgs(
-		"filename",
-		11,
-		"description",
-		"username",
-		"other",
-		anyTime{},
-	).WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
 
 	postData :=
 		`--xxx
@@ -675,3 +754,155 @@ binary data
 
 	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
 }
+
+func TestPageTagsSuccessPOST(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/upload")
+	defer os.Remove("files/" + binaryDataHash)
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO files").WillReturnResult(sqlmock.NewResult(7, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("rock-n-roll").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs(7, "rock-n-roll").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("jazz").WillReturnResult(sqlmock.NewResult(2, 1))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs(7, "jazz").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	postData :=
+		`--xxx
+Content-Disposition: form-data; name="filename"
+
+filename
+--xxx
+Content-Disposition: form-data; name="category"
+
+other
+--xxx
+Content-Disposition: form-data; name="tags"
+
+Rock n Roll, jazz, JAZZ
+--xxx
+Content-Disposition: form-data; name="uploaded_file"; filename="file"
+Content-Type: application/octet-stream
+Content-Transfer-Encoding: binary
+
+binary data
+--xxx--
+`
+	r := &http.Request{
+		Method: "POST",
+		Header: http.Header{"Content-Type": {`multipart/form-data; boundary=xxx`}},
+		Body:   ioutil.NopCloser(strings.NewReader(postData)),
+	}
+
+	w := httptest.NewRecorder()
+
+	sut := upload.Page(dep)
+	sut(w, r)
+
+	require.Contains(t, w.Body.String(), `<h2 style="color:green">FILE SUCCEEDED UPLOADED</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageTagsDBErrorPOST(t *testing.T) {
+	// changing directory because of test are not containing in root folder
+	os.Chdir("../../")
+	defer os.Chdir("pages/upload")
+	defer os.Remove("files/" + binaryDataHash)
+
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO blobs").WithArgs(binaryDataHash, 11).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("INSERT INTO files").WillReturnResult(sqlmock.NewResult(7, 1))
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("rock-n-roll").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery("SELECT refs FROM blobs").WithArgs(binaryDataHash).WillReturnRows(sqlmock.NewRows([]string{"refs"}).AddRow(1))
+	sqlMock.ExpectExec("DELETE FROM blobs").WithArgs(binaryDataHash).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	postData :=
+		`--xxx
+Content-Disposition: form-data; name="filename"
+
+filename
+--xxx
+Content-Disposition: form-data; name="category"
+
+other
+--xxx
+Content-Disposition: form-data; name="tags"
+
+Rock n Roll, jazz, JAZZ
+--xxx
+Content-Disposition: form-data; name="uploaded_file"; filename="file"
+Content-Type: application/octet-stream
+Content-Transfer-Encoding: binary
+
+binary data
+--xxx--
+`
+	r := &http.Request{
+		Method: "POST",
+		Header: http.Header{"Content-Type": {`multipart/form-data; boundary=xxx`}},
+		Body:   ioutil.NopCloser(strings.NewReader(postData)),
+	}
+
+	w := httptest.NewRecorder()
+
+	sut := upload.Page(dep)
+	sut(w, r)
+
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestPageTooManyTagsErrorPOST(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM categories WHERE retired = FALSE").WillReturnRows(test.CategoryRows())
+	postData :=
+		`--xxx
+Content-Disposition: form-data; name="filename"
+
+filename
+--xxx
+Content-Disposition: form-data; name="category"
+
+other
+--xxx
+Content-Disposition: form-data; name="tags"
+
+a,b,c,d,e,f,g,h,i,j,k
+--xxx
+Content-Disposition: form-data; name="uploaded_file"; filename="file";
+Content-Type: application/octet-stream
+Content-Transfer-Encoding: binary
+
+binary data
+--xxx--
+`
+	r := &http.Request{
+		Method: "POST",
+		Header: http.Header{"Content-Type": {`multipart/form-data; boundary=xxx`}},
+		Body:   ioutil.NopCloser(strings.NewReader(postData)),
+	}
+
+	w := httptest.NewRecorder()
+
+	sut := upload.Page(dep)
+	sut(w, r)
+
+	require.Contains(t, w.Body.String(), `<h2 style="color:red">File cannot have more than 10 tags</h2>`)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
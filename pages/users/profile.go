@@ -0,0 +1,139 @@
+package users
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vpoletaev11/fileHostingSite/dbformat"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/pagination"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/tmp"
+)
+
+// path to user profile[/users/*username*] template file
+const pathTemplateProfile = "pages/users/template/profile.html"
+
+const (
+	selectProfile = "SELECT rating, createdAt, timezone FROM users WHERE username = ?;"
+
+	selectUploadsStats = "SELECT COUNT(*), COALESCE(SUM(filesizeBytes), 0) FROM files WHERE owner = ?;"
+
+	selectUserFiles = "SELECT " + dbformat.FileInfoColumns + " FROM files WHERE owner = ? ORDER BY uploadDate DESC LIMIT ?, ?;"
+)
+
+const rowsInPage = 15 // how many rows of file info will be displayed on page
+
+// TemplateProfile contains data for user profile[/users/*username*] page template
+type TemplateProfile struct {
+	Warning       template.HTML
+	Username      string
+	Profile       ProfileInfo
+	LinkList      []pagination.Link
+	UploadedFiles []dbformat.FileInfo
+}
+
+// ProfileInfo contains information about user showed on profile page
+type ProfileInfo struct {
+	Username   string
+	Rating     int
+	JoinDate   string
+	Timezone   string
+	Uploads    int
+	UploadedMb string
+}
+
+// Profile returns HandleFunc for user profile[/users/*username*] page
+func Profile(dep session.Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// creating template for user profile page
+		page, err := tmp.CreateTemplate(pathTemplateProfile)
+		if err != nil {
+			errhand.InternalError(err, w)
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			// getting username of profile owner
+			username := r.URL.Path[len("/users/"):]
+			if username == "" {
+				http.Redirect(w, r, "/users", http.StatusFound)
+				return
+			}
+			if strings.Contains(username, "/") {
+				fmt.Fprintln(w, "ERROR: User doesn't exist")
+				return
+			}
+
+			profile, err := profileInfo(dep, username)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					fmt.Fprintln(w, "ERROR: User doesn't exist")
+					return
+				}
+				errhand.InternalError(err, w)
+				return
+			}
+
+			// getting number of current page
+			pagesCount := pagination.PagesCount(profile.Uploads, rowsInPage)
+			numPage, err := pagination.NumPage(r)
+			if err != nil || numPage > pagesCount {
+				fmt.Fprintln(w, "ERROR: Incorrect get request")
+				return
+			}
+
+			// getting files info for current page
+			fiCollection, err := dbformat.FormatedFilesInfo(dep.Username, dep.Db, selectUserFiles, username, (numPage-1)*rowsInPage, rowsInPage)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+
+			templateData := TemplateProfile{Username: dep.Username, Profile: profile, UploadedFiles: fiCollection}
+			// creating navigation bar if count of pages > 1
+			if pagesCount > 1 {
+				templateData.LinkList = pagination.NavigationBar(pagesCount, numPage, "/users/"+url.PathEscape(username)+"?p=")
+			}
+			err = page.Execute(w, templateData)
+			if err != nil {
+				errhand.InternalError(err, w)
+				return
+			}
+			return
+		}
+	}
+}
+
+// profileInfo returns information about user with inputted username.
+// Join date is converted to timezone of user who views profile.
+// If user doesn't exist profileInfo returns sql.ErrNoRows.
+func profileInfo(dep session.Dependency, username string) (ProfileInfo, error) {
+	profile := ProfileInfo{Username: username}
+	var createdAt time.Time
+	err := dep.Db.QueryRow(selectProfile, username).Scan(&profile.Rating, &createdAt, &profile.Timezone)
+	if err != nil {
+		return ProfileInfo{}, err
+	}
+
+	uploadedBytes := int64(0)
+	err = dep.Db.QueryRow(selectUploadsStats, username).Scan(&profile.Uploads, &uploadedBytes)
+	if err != nil {
+		return ProfileInfo{}, err
+	}
+	profile.UploadedMb = fmt.Sprintf("%.4f", float64(uploadedBytes)/1024/1024) + " MB"
+
+	location, err := dbformat.UserLocation(dep.Db, dep.Username)
+	if err != nil {
+		return ProfileInfo{}, err
+	}
+	profile.JoinDate = createdAt.In(location).Format("2006-01-02")
+
+	return profile, nil
+}
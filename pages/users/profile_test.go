@@ -0,0 +1,225 @@
+package users_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pages/users"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+// fileInfoRows contains columns of files table returned by files info query
+var fileInfoRows = []string{
+	"id",
+	"label",
+	"filesizeBytes",
+	"description",
+	"owner",
+	"category",
+	"uploadDate",
+	"rating",
+}
+
+// expectProfile adds expectations of queries which return profile info of user "example"
+func expectProfile(sqlMock sqlmock.Sqlmock, uploads int) {
+	sqlMock.ExpectQuery("SELECT rating, createdAt, timezone FROM users WHERE username =").WithArgs("example").WillReturnRows(
+		sqlmock.NewRows([]string{"rating", "createdAt", "timezone"}).AddRow(100, time.Date(2020, 1, 31, 22, 0, 0, 0, time.UTC), "Europe/Berlin"))
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("example").WillReturnRows(
+		sqlmock.NewRows([]string{"count", "sum"}).AddRow(uploads, 2097152))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+}
+
+func TestProfileSuccessGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectProfile(sqlMock, 1)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE owner =").WithArgs("example", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows).AddRow(
+		1,
+		"label",
+		1024,
+		"description",
+		"example",
+		"other",
+		time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC),
+		1000,
+	))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/example", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+
+	test.AssertBodyEqual(t, `<!doctype html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>example</title>
+    <link rel="stylesheet" href="/assets/css/profile.css">
+<head>
+<body bgcolor=#f1ded3>
+    <div class="menu">
+        <ul class="nav">
+            <li><a href="/">Home</a></li>
+            <li><a href="/upload">Upload file</a></li>
+            <li><a href="/categories">Categories</a></li>
+            <li><a href="/popular">Most popular</a></li>
+            <li><a href="/users">Users</a></li>
+            <li><a href="/logout">Logout</a></li>
+        </ul>
+    </div>
+    <div class="username">Welcome, username</div>
+
+    <div class="profile">
+        <h2>example</h2>
+        <table border="1" width="100%" cellpadding="5">
+            <tr>
+                <th>User rating</th>
+                <th>Joined</th>
+                <th>Timezone</th>
+                <th>Uploads</th>
+                <th>Uploaded</th>
+            </tr>
+            <tr>
+                <td>100</td>
+                <td>2020-02-01</td>
+                <td>Europe/Berlin</td>
+                <td>1</td>
+                <td>2.0000 MB</td>
+            </tr>
+        </table>
+    </div>
+
+    <div class = "newlyUploadedBox">
+                    <table border="1" width="100%" cellpadding="5">
+                        <tr>
+                            <th>Filename</th>
+                            <th>Filesize</th>
+                            <th>Description</th>
+                            <th>Category</th>
+                            <th>Upload date</th>
+                            <th>Rating</th>
+                        </tr>
+                        
+                        <tr>
+                            <td width="15%" title=label><a href=/download?id&#61;1>label</a></td>
+                            <td width="10%" title=1024&#32;Bytes>0.0010 MB</td>
+                            <td width="25%" title=description>description</td>
+                            <td width="15%">other</td>
+                            <td width="15%">2009-11-17 23:34:58</td>
+                            <td width="10%">1000</td>
+                        </tr>
+                        
+                    </table>
+    </div>
+
+    <div class="pagesNums">
+        
+    </div>
+</body>`, w.Body)
+}
+
+func TestProfileSecondPageGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectProfile(sqlMock, 45)
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE owner =").WithArgs("example", 15, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows))
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/example?p=2", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+
+	assert.Contains(t, w.Body.String(), `<a href=/users/example?p&#61;1>1</a>`)
+	assert.Contains(t, w.Body.String(), `<a href=/users/example?p&#61;3>3</a>`)
+}
+
+func TestProfileEscapedUsernameGET(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT rating, createdAt, timezone FROM users WHERE username =").WithArgs("a#b").WillReturnRows(
+		sqlmock.NewRows([]string{"rating", "createdAt", "timezone"}).AddRow(100, time.Date(2020, 1, 31, 22, 0, 0, 0, time.UTC), "Europe/Berlin"))
+	sqlMock.ExpectQuery("SELECT COUNT").WithArgs("a#b").WillReturnRows(
+		sqlmock.NewRows([]string{"count", "sum"}).AddRow(45, 2097152))
+	sqlMock.ExpectQuery("SELECT timezone, dateFormat FROM users WHERE username =").WithArgs("username").WillReturnRows(
+		sqlmock.NewRows([]string{"timezone", "dateFormat"}).AddRow("Europe/Moscow", "iso"))
+	sqlMock.ExpectQuery("SELECT (.+) FROM files WHERE owner =").WithArgs("a#b", 0, 15).WillReturnRows(sqlmock.NewRows(fileInfoRows))
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/a%23b", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+
+	assert.Contains(t, w.Body.String(), `<a href=/users/a%23b?p&#61;2>2</a>`)
+}
+
+func TestProfileUserDoesntExist(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT rating, createdAt, timezone FROM users WHERE username =").WithArgs("unknown").WillReturnError(sql.ErrNoRows)
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/unknown", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	test.AssertBodyEqual(t, "ERROR: User doesn't exist\n", w.Body)
+}
+
+func TestProfileEmptyUsername(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/users", w.Header().Get("Location"))
+}
+
+func TestProfileWrongPage(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	expectProfile(sqlMock, 1)
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/example?p=2", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	test.AssertBodyEqual(t, "ERROR: Incorrect get request\n", w.Body)
+}
+
+func TestProfileDBError(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT rating, createdAt, timezone FROM users WHERE username =").WithArgs("example").WillReturnError(fmt.Errorf("Testing error"))
+
+	sut := users.Profile(dep)
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/users/example", nil)
+	require.NoError(t, err)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later\n", w.Body)
+}
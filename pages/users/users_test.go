@@ -63,7 +63,7 @@ func TestPageSuccessGet(t *testing.T) {
             </tr>
             
             <tr>
-                <td width="70%">user</td>
+                <td width="70%"><a href="/users/user">user</a></td>
                 <td width="30%">1000</td>
             </tr>
             
@@ -0,0 +1,80 @@
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// MaxLinksInNavBar is how many links will be displayed on navigation bar
+const MaxLinksInNavBar = 25
+
+// Link contains relations of page number and page link
+type Link struct {
+	NumPage int
+	Link    string
+}
+
+// PagesCount returns pages count calculated from count of rows
+func PagesCount(rowsCount, rowsInPage int) int {
+	return (rowsCount-1)/rowsInPage + 1
+}
+
+// NumPage gets number of page from GET request
+func NumPage(r *http.Request) (int, error) {
+	numPageStr := r.URL.Query().Get("p")
+	if numPageStr == "" {
+		return 1, nil
+	}
+	numPage := 0
+	numPage, err := strconv.Atoi(numPageStr)
+	if err != nil {
+		return 0, err
+	}
+	if numPage <= 0 {
+		return 0, fmt.Errorf("Incorrect page number")
+	}
+
+	return numPage, nil
+}
+
+// NavigationBar returns array with relations of page number and page link, where page number == page link.
+// Links are created by adding page number to baseLink (e.g. "/categories/music?p=").
+func NavigationBar(pagesCount, numPage int, baseLink string) []Link {
+	var numsLinks []Link
+	if pagesCount > MaxLinksInNavBar {
+		// add the first link (literally 1)
+		link := baseLink + "1"
+		numsLinks = append(numsLinks, Link{NumPage: 1, Link: link})
+
+		switch {
+		case numPage < 10:
+			for i := 2; i <= MaxLinksInNavBar; i++ {
+				link := baseLink + strconv.Itoa(i)
+				numsLinks = append(numsLinks, Link{NumPage: i, Link: link})
+			}
+		case numPage >= pagesCount-15:
+			for i := numPage - 5; i <= pagesCount-1; i++ {
+				link := baseLink + strconv.Itoa(i)
+				numsLinks = append(numsLinks, Link{NumPage: i, Link: link})
+			}
+		default:
+			for i := numPage - 5; i <= numPage+15; i++ {
+				link := baseLink + strconv.Itoa(i)
+				numsLinks = append(numsLinks, Link{NumPage: i, Link: link})
+			}
+
+		}
+
+		// add the last link == len(pagesCount)
+		link = baseLink + strconv.Itoa(pagesCount)
+		numsLinks = append(numsLinks, Link{NumPage: pagesCount, Link: link})
+
+	} else {
+		for i := 1; i <= pagesCount; i++ {
+			link := baseLink + strconv.Itoa(i)
+			numsLinks = append(numsLinks, Link{NumPage: i, Link: link})
+		}
+	}
+	return numsLinks
+}
@@ -0,0 +1,69 @@
+package pagination_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/pagination"
+)
+
+func TestPagesCount(t *testing.T) {
+	assert.Equal(t, 1, pagination.PagesCount(0, 15))
+	assert.Equal(t, 1, pagination.PagesCount(15, 15))
+	assert.Equal(t, 2, pagination.PagesCount(16, 15))
+	assert.Equal(t, 3, pagination.PagesCount(45, 15))
+}
+
+func TestNumPage(t *testing.T) {
+	for query, expected := range map[string]int{"": 1, "?p=1": 1, "?p=7": 7} {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/users/user"+query, nil)
+		require.NoError(t, err)
+
+		numPage, err := pagination.NumPage(r)
+		require.NoError(t, err)
+		assert.Equal(t, expected, numPage, "query %q", query)
+	}
+}
+
+func TestNumPageIncorrect(t *testing.T) {
+	for _, query := range []string{"?p=abc", "?p=0", "?p=-1"} {
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/users/user"+query, nil)
+		require.NoError(t, err)
+
+		_, err = pagination.NumPage(r)
+		assert.Error(t, err, "query %q", query)
+	}
+}
+
+func TestNavigationBarFewPages(t *testing.T) {
+	assert.Equal(t, []pagination.Link{
+		{NumPage: 1, Link: "/users/user?p=1"},
+		{NumPage: 2, Link: "/users/user?p=2"},
+		{NumPage: 3, Link: "/users/user?p=3"},
+	}, pagination.NavigationBar(3, 1, "/users/user?p="))
+}
+
+func TestNavigationBarAlotPages(t *testing.T) {
+	// the first pages
+	links := pagination.NavigationBar(100, 1, "?p=")
+	require.Len(t, links, pagination.MaxLinksInNavBar+1)
+	assert.Equal(t, pagination.Link{NumPage: 1, Link: "?p=1"}, links[0])
+	assert.Equal(t, pagination.Link{NumPage: 25, Link: "?p=25"}, links[24])
+	assert.Equal(t, pagination.Link{NumPage: 100, Link: "?p=100"}, links[25])
+
+	// pages in the middle
+	links = pagination.NavigationBar(100, 50, "?p=")
+	require.Len(t, links, 23)
+	assert.Equal(t, 45, links[1].NumPage)
+	assert.Equal(t, 65, links[21].NumPage)
+	assert.Equal(t, 100, links[22].NumPage)
+
+	// the last pages
+	links = pagination.NavigationBar(100, 90, "?p=")
+	require.Len(t, links, 17)
+	assert.Equal(t, 85, links[1].NumPage)
+	assert.Equal(t, 99, links[15].NumPage)
+	assert.Equal(t, 100, links[16].NumPage)
+}
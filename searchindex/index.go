@@ -0,0 +1,327 @@
+// Package searchindex contains in-process inverted index of files used by search page.
+// Index covers labels, descriptions and owner names of files and ranks results with BM25.
+package searchindex
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// k1 and b are parameters of BM25 ranking function
+	k1 = 1.2
+	b  = 0.75
+
+	// prefixWeight is weight of terms which starts with query term (e.g. "doc" -> "documents")
+	prefixWeight = 0.8
+	// fuzzyWeight is weight of terms which differ from query term by few letters (typos)
+	fuzzyWeight = 0.5
+
+	// minPrefixLen is min length of query term for prefix matching
+	minPrefixLen = 2
+	// minFuzzyLen is min length of query term for typo-tolerant matching
+	minFuzzyLen = 4
+)
+
+// Document contains indexed information about file
+type Document struct {
+	ID          int
+	Label       string
+	Description string
+	Owner       string
+}
+
+// Result contains ID of found file and its relevance
+type Result struct {
+	ID    int
+	Score float64
+}
+
+// Index is inverted index of files. It's safe for concurrent use.
+// Methods of nil Index do nothing, so handlers can be used without index.
+type Index struct {
+	mu sync.RWMutex
+	// docs contains indexed documents by ID
+	docs map[int]Document
+	// lengths contains count of terms in documents
+	lengths map[int]int
+	// totalLen is sum of lengths of all documents
+	totalLen int
+	// postings contains frequencies of term in documents: term -> document ID -> frequency
+	postings map[string]map[int]int
+	// terms contains sorted list of all terms, it's used for prefix matching
+	terms []string
+	// dirty is true when index was changed after last saving to disk
+	dirty bool
+}
+
+// New returns empty index
+func New() *Index {
+	return &Index{
+		docs:     map[int]Document{},
+		lengths:  map[int]int{},
+		postings: map[string]map[int]int{},
+	}
+}
+
+// Tokenize splits text into lowercase terms. Any symbol except letters and digits is separator.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// terms returns all terms of document
+func (doc Document) terms() []string {
+	terms := Tokenize(doc.Label)
+	terms = append(terms, Tokenize(doc.Description)...)
+	return append(terms, Tokenize(doc.Owner)...)
+}
+
+// Add adds document to index. If document with the same ID already exists it will be replaced.
+func (idx *Index) Add(doc Document) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(doc.ID)
+	idx.add(doc)
+	idx.dirty = true
+}
+
+// Remove removes document with inputted ID from index
+func (idx *Index) Remove(id int) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.docs[id]; ok {
+		idx.remove(id)
+		idx.dirty = true
+	}
+}
+
+// ReplaceOwner changes owner of all documents owned by user "from" to user "to"
+func (idx *Index) ReplaceOwner(from, to string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for id, doc := range idx.docs {
+		if doc.Owner != from {
+			continue
+		}
+		idx.remove(id)
+		doc.Owner = to
+		idx.add(doc)
+		idx.dirty = true
+	}
+}
+
+// Len returns count of documents in index
+func (idx *Index) Len() int {
+	if idx == nil {
+		return 0
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// add adds document which doesn't exist in index, mutex should be locked by caller
+func (idx *Index) add(doc Document) {
+	terms := doc.terms()
+	idx.docs[doc.ID] = doc
+	idx.lengths[doc.ID] = len(terms)
+	idx.totalLen += len(terms)
+
+	for _, term := range terms {
+		posting, ok := idx.postings[term]
+		if !ok {
+			posting = map[int]int{}
+			idx.postings[term] = posting
+			// keeping list of terms sorted
+			i := sort.SearchStrings(idx.terms, term)
+			idx.terms = append(idx.terms, "")
+			copy(idx.terms[i+1:], idx.terms[i:])
+			idx.terms[i] = term
+		}
+		posting[doc.ID]++
+	}
+}
+
+// remove removes document from index if it exists, mutex should be locked by caller
+func (idx *Index) remove(id int) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms() {
+		posting := idx.postings[term]
+		delete(posting, id)
+		if len(posting) == 0 {
+			delete(idx.postings, term)
+			i := sort.SearchStrings(idx.terms, term)
+			if i < len(idx.terms) && idx.terms[i] == term {
+				idx.terms = append(idx.terms[:i], idx.terms[i+1:]...)
+			}
+		}
+	}
+	idx.totalLen -= idx.lengths[id]
+	delete(idx.lengths, id)
+	delete(idx.docs, id)
+}
+
+// Search returns IDs of documents matched query sorted by relevance (most relevant first).
+// Document is matched if it contains any term of query, term which starts with query term or term with typo.
+// Exact matches are ranked higher than prefix and typo-tolerant matches.
+// If limit > 0 count of results will be no more than limit.
+func (idx *Index) Search(query string, limit int) []Result {
+	if idx == nil {
+		return nil
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(idx.docs) == 0 {
+		return nil
+	}
+	avgLen := float64(idx.totalLen) / float64(len(idx.docs))
+
+	scores := map[int]float64{}
+	for _, queryTerm := range Tokenize(query) {
+		// score of every query term is counted once per document by the best matched term
+		best := map[int]float64{}
+		for term, weight := range idx.expand(queryTerm) {
+			posting := idx.postings[term]
+			idf := math.Log(1 + (float64(len(idx.docs))-float64(len(posting))+0.5)/(float64(len(posting))+0.5))
+			for id, freq := range posting {
+				tf := float64(freq)
+				norm := 1 - b + b*float64(idx.lengths[id])/avgLen
+				score := weight * idf * tf * (k1 + 1) / (tf + k1*norm)
+				if score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{ID: id, Score: score})
+	}
+	// newer files (with bigger ID) are showed first if relevance is equal
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// expand returns terms of index matched query term with their weights, mutex should be locked by caller
+func (idx *Index) expand(queryTerm string) map[string]float64 {
+	matched := map[string]float64{}
+	if _, ok := idx.postings[queryTerm]; ok {
+		matched[queryTerm] = 1
+	}
+
+	if len([]rune(queryTerm)) >= minPrefixLen {
+		for i := sort.SearchStrings(idx.terms, queryTerm); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], queryTerm); i++ {
+			if idx.terms[i] != queryTerm {
+				matched[idx.terms[i]] = prefixWeight
+			}
+		}
+	}
+
+	if len([]rune(queryTerm)) >= minFuzzyLen {
+		maxDistance := maxTypos(queryTerm)
+		for _, term := range idx.terms {
+			if _, ok := matched[term]; ok {
+				continue
+			}
+			if distance(queryTerm, term, maxDistance) <= maxDistance {
+				matched[term] = fuzzyWeight
+			}
+		}
+	}
+	return matched
+}
+
+// maxTypos returns how many typos are allowed in query term: longer terms can contain more typos
+func maxTypos(term string) int {
+	if len([]rune(term)) >= 8 {
+		return 2
+	}
+	return 1
+}
+
+// distance returns Levenshtein distance between a and b.
+// If distance is more than max, distance returns max + 1 without full calculation.
+func distance(a, b string, max int) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
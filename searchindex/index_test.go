@@ -0,0 +1,118 @@
+package searchindex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIndex returns index with few documents
+func newTestIndex() *Index {
+	idx := New()
+	idx.Add(Document{ID: 1, Label: "holiday_photos.zip", Description: "Photos from summer holiday", Owner: "alice"})
+	idx.Add(Document{ID: 2, Label: "report.pdf", Description: "Annual report about photosynthesis", Owner: "bob"})
+	idx.Add(Document{ID: 3, Label: "music.mp3", Description: "My favourite song", Owner: "alice"})
+	return idx
+}
+
+// ids returns IDs of search results
+func ids(results []Result) []int {
+	list := []int{}
+	for _, r := range results {
+		list = append(list, r.ID)
+	}
+	return list
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"holiday", "photos", "zip"}, Tokenize("Holiday_Photos.ZIP"))
+	assert.Equal(t, []string{"привет", "мир", "2020"}, Tokenize("Привет, мир! 2020"))
+	assert.Empty(t, Tokenize(" -- "))
+}
+
+func TestSearchExactMatch(t *testing.T) {
+	idx := newTestIndex()
+
+	assert.Equal(t, []int{2}, ids(idx.Search("annual", 0)))
+	assert.Equal(t, []int{3, 1}, ids(idx.Search("alice", 0)))
+	assert.Empty(t, idx.Search("nothing", 0))
+	assert.Empty(t, idx.Search("", 0))
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := newTestIndex()
+
+	// "photos" matches document 1 exactly and document 2 by prefix ("photosynthesis")
+	assert.Equal(t, []int{1, 2}, ids(idx.Search("photos", 0)))
+	// document which matches more terms of query is more relevant
+	assert.Equal(t, []int{3, 1}, ids(idx.Search("alice song", 0)))
+	assert.Equal(t, []int{3}, ids(idx.Search("alice song", 1)))
+}
+
+func TestSearchPrefixMatch(t *testing.T) {
+	idx := newTestIndex()
+
+	assert.Equal(t, []int{1}, ids(idx.Search("holi", 0)))
+	assert.Equal(t, []int{2}, ids(idx.Search("rep", 0)))
+	// too short query term isn't used for prefix matching
+	assert.Empty(t, idx.Search("r", 0))
+}
+
+func TestSearchTypos(t *testing.T) {
+	idx := newTestIndex()
+
+	assert.Equal(t, []int{3}, ids(idx.Search("favorite", 0)))
+	assert.Equal(t, []int{1}, ids(idx.Search("holyday", 0)))
+	assert.Equal(t, []int{2}, ids(idx.Search("anual", 0)))
+	// too many typos
+	assert.Empty(t, idx.Search("hlydy", 0))
+}
+
+func TestAddReplacesDocument(t *testing.T) {
+	idx := newTestIndex()
+	idx.Add(Document{ID: 2, Label: "presentation.ppt", Description: "Slides", Owner: "bob"})
+
+	assert.Equal(t, 3, idx.Len())
+	assert.Empty(t, idx.Search("annual", 0))
+	assert.Equal(t, []int{2}, ids(idx.Search("slides", 0)))
+}
+
+func TestRemove(t *testing.T) {
+	idx := newTestIndex()
+	idx.Remove(1)
+	idx.Remove(100)
+
+	assert.Equal(t, 2, idx.Len())
+	assert.Empty(t, idx.Search("holiday", 0))
+	assert.NotContains(t, idx.terms, "holiday")
+	assert.Equal(t, []int{3}, ids(idx.Search("alice", 0)))
+}
+
+func TestReplaceOwner(t *testing.T) {
+	idx := newTestIndex()
+	idx.ReplaceOwner("alice", "carol")
+
+	assert.Empty(t, idx.Search("alice", 0))
+	assert.Equal(t, []int{3, 1}, ids(idx.Search("carol", 0)))
+	assert.Equal(t, []int{2}, ids(idx.Search("bob", 0)))
+}
+
+func TestNilIndex(t *testing.T) {
+	var idx *Index
+	idx.Add(Document{ID: 1, Label: "label"})
+	idx.Remove(1)
+	idx.ReplaceOwner("alice", "bob")
+
+	assert.Equal(t, 0, idx.Len())
+	assert.Empty(t, idx.Search("label", 0))
+}
+
+func TestDistance(t *testing.T) {
+	require.Equal(t, 0, distance("photo", "photo", 2))
+	require.Equal(t, 1, distance("photo", "phot", 2))
+	require.Equal(t, 3, distance("kitten", "sitting", 3))
+	// calculation is stopped when distance is more than max
+	require.Equal(t, 2, distance("kitten", "sitting", 1))
+	require.Equal(t, 2, distance("a", "abcdef", 1))
+}
@@ -0,0 +1,198 @@
+package searchindex
+
+import (
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	selectDocuments = "SELECT id, label, description, owner FROM files;"
+
+	// checksum is XOR of CRC32 of indexed fields of every file, it's computed the same way by Index.checksum()
+	selectFilesStats = "SELECT COUNT(*), COALESCE(BIT_XOR(CRC32(CONCAT_WS(CHAR(31), id, label, description, owner))), 0) FROM files;"
+)
+
+// fieldSeparator separates fields of document in checksum, it's CHAR(31) in selectFilesStats
+const fieldSeparator = "\x1f"
+
+// formatVersion is version of index file format, index files with another version are ignored
+const formatVersion = 1
+
+// snapshot contains data of index saved to disk.
+// Only documents are saved, postings are recreated on loading.
+type snapshot struct {
+	Version   int
+	Documents []Document
+}
+
+// Rebuild returns index created from files table
+func Rebuild(db *sql.DB) (*Index, error) {
+	rows, err := db.Query(selectDocuments)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	idx := New()
+	for rows.Next() {
+		doc := Document{}
+		err = rows.Scan(&doc.ID, &doc.Label, &doc.Description, &doc.Owner)
+		if err != nil {
+			return nil, err
+		}
+		idx.add(doc)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	idx.dirty = true
+	return idx, nil
+}
+
+// Load returns index saved to file by Save()
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	snap := snapshot{}
+	err = gob.NewDecoder(f).Decode(&snap)
+	if err != nil {
+		return nil, err
+	}
+	if snap.Version != formatVersion {
+		return nil, fmt.Errorf("searchindex: unsupported format version %d", snap.Version)
+	}
+
+	idx := New()
+	for _, doc := range snap.Documents {
+		idx.add(doc)
+	}
+	return idx, nil
+}
+
+// Save writes documents of index to file. File is replaced atomically, so index file is never half-written.
+func (idx *Index) Save(path string) error {
+	// index is locked only while documents are copied, so search isn't blocked by writing to disk
+	idx.mu.Lock()
+	snap := snapshot{Version: formatVersion, Documents: make([]Document, 0, len(idx.docs))}
+	for _, doc := range idx.docs {
+		snap.Documents = append(snap.Documents, doc)
+	}
+	idx.dirty = false
+	idx.mu.Unlock()
+
+	err := writeSnapshot(path, snap)
+	if err != nil {
+		// changes should be saved on the next try
+		idx.mu.Lock()
+		idx.dirty = true
+		idx.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// writeSnapshot writes snapshot to temporary file and renames it to path
+func writeSnapshot(path string, snap snapshot) error {
+	sort.Slice(snap.Documents, func(i, j int) bool {
+		return snap.Documents[i].ID < snap.Documents[j].ID
+	})
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(snap)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Open loads index from file and checks that it matches files table.
+// If index file doesn't exist, is broken or outdated, index is rebuilt from files table and saved to file.
+func Open(db *sql.DB, path string) (*Index, error) {
+	idx, err := Load(path)
+	if err == nil {
+		var fresh bool
+		fresh, err = idx.matches(db)
+		if err != nil {
+			return nil, err
+		}
+		if fresh {
+			return idx, nil
+		}
+	}
+
+	idx, err = Rebuild(db)
+	if err != nil {
+		return nil, err
+	}
+	err = idx.Save(path)
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// matches returns true if count of documents and checksum of indexed fields are equal to the same values of files table.
+// It finds files uploaded, deleted or changed (e.g. by "fhs-admin import") after last saving of index
+// without transfer of all files from database.
+func (idx *Index) matches(db *sql.DB) (bool, error) {
+	count := 0
+	var sum uint32
+	err := db.QueryRow(selectFilesStats).Scan(&count, &sum)
+	if err != nil {
+		return false, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return count == len(idx.docs) && sum == idx.checksum(), nil
+}
+
+// checksum returns XOR of CRC32 of indexed fields of all documents, order of documents doesn't matter.
+// Index should be locked by caller.
+func (idx *Index) checksum() uint32 {
+	var sum uint32
+	for _, doc := range idx.docs {
+		sum ^= crc32.ChecksumIEEE([]byte(strconv.Itoa(doc.ID) + fieldSeparator + doc.Label + fieldSeparator + doc.Description + fieldSeparator + doc.Owner))
+	}
+	return sum
+}
+
+// AutoSave saves index to file every interval if index was changed. It never returns, so it should be run in goroutine.
+func (idx *Index) AutoSave(path string, interval time.Duration) {
+	for range time.Tick(interval) {
+		idx.mu.RLock()
+		dirty := idx.dirty
+		idx.mu.RUnlock()
+		if !dirty {
+			continue
+		}
+
+		err := idx.Save(path)
+		if err != nil {
+			log.Println("searchindex: saving error:", err)
+		}
+	}
+}
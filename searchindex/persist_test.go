@@ -0,0 +1,152 @@
+package searchindex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tempPath returns path of index file in temporary directory
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "searchindex-test-")
+	require.NoError(t, err)
+	return filepath.Join(dir, "index.gob"), func() { os.RemoveAll(dir) }
+}
+
+// documentRows returns rows of files table with documents of newTestIndex()
+func documentRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "label", "description", "owner"}).
+		AddRow(1, "holiday_photos.zip", "Photos from summer holiday", "alice").
+		AddRow(2, "report.pdf", "Annual report about photosynthesis", "bob").
+		AddRow(3, "music.mp3", "My favourite song", "alice")
+}
+
+func TestRebuild(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT id, label, description, owner FROM files").WillReturnRows(documentRows())
+
+	idx, err := Rebuild(db)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, idx.Len())
+	assert.Equal(t, []int{1, 2}, ids(idx.Search("photos", 0)))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestRebuildDBError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT id, label, description, owner FROM files").WillReturnError(fmt.Errorf("testing error"))
+
+	_, err = Rebuild(db)
+	assert.EqualError(t, err, "testing error")
+}
+
+func TestSaveLoad(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	idx := newTestIndex()
+	require.True(t, idx.dirty)
+	require.NoError(t, idx.Save(path))
+	assert.False(t, idx.dirty)
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, idx.docs, loaded.docs)
+	assert.Equal(t, idx.terms, loaded.terms)
+	assert.Equal(t, idx.Search("alice photos", 0), loaded.Search("alice photos", 0))
+}
+
+func TestLoadBrokenFile(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	require.NoError(t, ioutil.WriteFile(path, []byte("broken"), 0644))
+
+	_, err := Load(path)
+	assert.Error(t, err)
+}
+
+func TestOpenFreshIndex(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	require.NoError(t, newTestIndex().Save(path))
+
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT COUNT(.+), COALESCE(.+) FROM files").WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(3, newTestIndex().checksum()))
+
+	idx, err := Open(db, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, idx.Len())
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOpenChangedIndex(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	require.NoError(t, newTestIndex().Save(path))
+
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	// description of file was changed after saving of index, count of files is the same
+	changed := newTestIndex()
+	changed.Add(Document{ID: 3, Label: "music.mp3", Description: "Recording of concert", Owner: "alice"})
+	sqlMock.ExpectQuery("SELECT COUNT(.+), COALESCE(.+) FROM files").WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(3, changed.checksum()))
+	sqlMock.ExpectQuery("SELECT id, label, description, owner FROM files").WillReturnRows(sqlmock.NewRows([]string{"id", "label", "description", "owner"}).
+		AddRow(1, "holiday_photos.zip", "Photos from summer holiday", "alice").
+		AddRow(2, "report.pdf", "Annual report about photosynthesis", "bob").
+		AddRow(3, "music.mp3", "Recording of concert", "alice"))
+
+	idx, err := Open(db, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{3}, ids(idx.Search("concert", 10)))
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOpenOutdatedIndex(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+	require.NoError(t, newTestIndex().Save(path))
+
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	// file with ID 4 was uploaded after saving of index
+	sqlMock.ExpectQuery("SELECT COUNT(.+), COALESCE(.+) FROM files").WillReturnRows(sqlmock.NewRows([]string{"count", "checksum"}).AddRow(4, 1234))
+	sqlMock.ExpectQuery("SELECT id, label, description, owner FROM files").WillReturnRows(documentRows().AddRow(4, "new.txt", "", "bob"))
+
+	idx, err := Open(db, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, idx.Len())
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 4, loaded.Len())
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestOpenWithoutIndexFile(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT id, label, description, owner FROM files").WillReturnRows(documentRows())
+
+	idx, err := Open(db, path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, idx.Len())
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,279 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+)
+
+// Every session besides <session ID> -> username key has:
+// 1) hash "session:<session ID>" with created, lastSeen, ip, userAgent and csrf fields,
+// 2) membership in set "sessions:<username>" which indexes all sessions of user.
+// Hash expires together with session, set expires when the last active session of user expires.
+// Members of set whose sessions are expired are removed when sessions of user are listed.
+const (
+	sessionInfoPrefix  = "session:"
+	userSessionsPrefix = "sessions:"
+)
+
+// Info contains information about active session of user
+type Info struct {
+	ID        string // ID identifies session without disclosing its token
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+}
+
+// ID returns identifier of session with inputted token.
+// ID is SHA-256 hash of token, it used as redis key of session.
+func ID(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// addToIndex registers new session of user in index
+func addToIndex(redisConn redis.Conn, username, id string, r *http.Request) error {
+	now := time.Now().Unix()
+	_, err := redisConn.Do("HSET", sessionInfoPrefix+id, "created", now, "lastSeen", now, "ip", RemoteIP(r), "userAgent", r.UserAgent())
+	if err != nil {
+		return err
+	}
+	_, err = redisConn.Do("EXPIRE", sessionInfoPrefix+id, CookieLifetime.Seconds())
+	if err != nil {
+		return err
+	}
+	_, err = redisConn.Do("SADD", userSessionsPrefix+username, id)
+	if err != nil {
+		return err
+	}
+	_, err = redisConn.Do("EXPIRE", userSessionsPrefix+username, CookieLifetime.Seconds())
+	return err
+}
+
+// touch updates last seen time of session and extends lifetime of its index entries
+func touch(redisConn redis.Conn, username, id string) error {
+	_, err := redisConn.Do("HSET", sessionInfoPrefix+id, "lastSeen", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	_, err = redisConn.Do("EXPIRE", sessionInfoPrefix+id, CookieLifetime.Seconds())
+	if err != nil {
+		return err
+	}
+	_, err = redisConn.Do("EXPIRE", userSessionsPrefix+username, CookieLifetime.Seconds())
+	return err
+}
+
+// csrfToken returns CSRF token of session with inputted ID.
+// Token is created on first use and stored in session info hash, so it lives as long as session.
+func csrfToken(redisConn redis.Conn, id string) (string, error) {
+	token, err := redis.String(redisConn.Do("HGET", sessionInfoPrefix+id, "csrf"))
+	if err == nil {
+		return token, nil
+	}
+	if err != redis.ErrNil {
+		return "", err
+	}
+
+	token, err = csrf.NewToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = redisConn.Do("HSET", sessionInfoPrefix+id, "csrf", token)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RemoteIP returns IP address of client without port
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// List returns active sessions of user sorted by last seen time (the most recent first).
+// Expired sessions are removed from index.
+func List(redisConn redis.Conn, username string) ([]Info, error) {
+	ids, err := redis.Strings(redisConn.Do("SMEMBERS", userSessionsPrefix+username))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Info{}
+	for _, id := range ids {
+		fields, err := redis.StringMap(redisConn.Do("HGETALL", sessionInfoPrefix+id))
+		if err != nil {
+			return nil, err
+		}
+		// session info expires together with session
+		if len(fields) == 0 {
+			_, err = redisConn.Do("SREM", userSessionsPrefix+username, id)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		sessions = append(sessions, Info{
+			ID:        id,
+			Created:   unixTime(fields["created"]),
+			LastSeen:  unixTime(fields["lastSeen"]),
+			IP:        fields["ip"],
+			UserAgent: fields["userAgent"],
+		})
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// unixTime converts unix time stored in redis to time.Time
+func unixTime(value string) time.Time {
+	sec, err := redis.Int64([]byte(value), nil)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Delete removes session with inputted token and its index entries
+func Delete(redisConn redis.Conn, token string) error {
+	return deleteSession(redisConn, ID(token))
+}
+
+// deleteSession removes session with inputted ID and its index entries
+func deleteSession(redisConn redis.Conn, id string) error {
+	username, err := redis.String(redisConn.Do("GET", id))
+	if err != nil && err != redis.ErrNil {
+		return err
+	}
+
+	_, err = redisConn.Do("DEL", id, sessionInfoPrefix+id)
+	if err != nil {
+		return err
+	}
+	if username == "" {
+		return nil
+	}
+	_, err = redisConn.Do("SREM", userSessionsPrefix+username, id)
+	return err
+}
+
+// Kill removes session of user with inputted ID.
+// If user doesn't have session with such ID Kill returns redis.ErrNil
+func Kill(redisConn redis.Conn, username, id string) error {
+	isMember, err := redis.Bool(redisConn.Do("SISMEMBER", userSessionsPrefix+username, id))
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return redis.ErrNil
+	}
+	return deleteSession(redisConn, id)
+}
+
+// KillSessions removes all sessions of user
+func KillSessions(redisConn redis.Conn, username string) error {
+	ids, err := redis.Strings(redisConn.Do("SMEMBERS", userSessionsPrefix+username))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err = redisConn.Do("DEL", id, sessionInfoPrefix+id)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = redisConn.Do("DEL", userSessionsPrefix+username)
+	return err
+}
+
+// KillOtherSessions removes all sessions of user except session with inputted token
+func KillOtherSessions(redisConn redis.Conn, username, token string) error {
+	ids, err := redis.Strings(redisConn.Do("SMEMBERS", userSessionsPrefix+username))
+	if err != nil {
+		return err
+	}
+
+	keep := ID(token)
+	for _, id := range ids {
+		if id == keep {
+			continue
+		}
+		_, err = redisConn.Do("DEL", id, sessionInfoPrefix+id)
+		if err != nil {
+			return err
+		}
+		_, err = redisConn.Do("SREM", userSessionsPrefix+username, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeExpired removes index entries of expired sessions of all users and returns count of removed entries.
+// Entries are also removed when sessions of user are listed, PurgeExpired is used by admin tool
+// to clean up index of users who don't open sessions page.
+func PurgeExpired(redisConn redis.Conn) (int, error) {
+	keys := []string{}
+	cursor := "0"
+	for {
+		reply, err := redis.Values(redisConn.Do("SCAN", cursor, "MATCH", userSessionsPrefix+"*", "COUNT", 100))
+		if err != nil {
+			return 0, err
+		}
+		var page []string
+		_, err = redis.Scan(reply, &cursor, &page)
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, page...)
+		if cursor == "0" {
+			break
+		}
+	}
+
+	purged := 0
+	for _, key := range keys {
+		ids, err := redis.Strings(redisConn.Do("SMEMBERS", key))
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			// session is alive while key <session ID> -> username exists
+			alive, err := redis.Bool(redisConn.Do("EXISTS", id))
+			if err != nil {
+				return 0, err
+			}
+			if alive {
+				continue
+			}
+			_, err = redisConn.Do("DEL", sessionInfoPrefix+id)
+			if err != nil {
+				return 0, err
+			}
+			_, err = redisConn.Do("SREM", key, id)
+			if err != nil {
+				return 0, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,197 @@
+package session_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/test"
+)
+
+func TestListSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:"+username).Expect([]interface{}{[]byte("id1"), []byte("id2"), []byte("expired")})
+	redisMock.Command("HGETALL", "session:id1").ExpectMap(map[string]string{
+		"created":   "1258490098",
+		"lastSeen":  "1258490100",
+		"ip":        "192.0.2.1",
+		"userAgent": "agent1",
+	})
+	redisMock.Command("HGETALL", "session:id2").ExpectMap(map[string]string{
+		"created":   "1258490000",
+		"lastSeen":  "1258490200",
+		"ip":        "192.0.2.2",
+		"userAgent": "agent2",
+	})
+	redisMock.Command("HGETALL", "session:expired").Expect([]interface{}{})
+	srem := redisMock.Command("SREM", "sessions:"+username, "expired").Expect(int64(1))
+
+	sessions, err := session.List(dep.Redis, username)
+	require.NoError(t, err)
+
+	assert.Equal(t, []session.Info{
+		{
+			ID:        "id2",
+			Created:   time.Unix(1258490000, 0),
+			LastSeen:  time.Unix(1258490200, 0),
+			IP:        "192.0.2.2",
+			UserAgent: "agent2",
+		},
+		{
+			ID:        "id1",
+			Created:   time.Unix(1258490098, 0),
+			LastSeen:  time.Unix(1258490100, 0),
+			IP:        "192.0.2.1",
+			UserAgent: "agent1",
+		},
+	}, sessions)
+	// expired session should be removed from index
+	assert.Equal(t, 1, redisMock.Stats(srem))
+}
+
+func TestListRedisError(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:"+username).ExpectError(fmt.Errorf("Testing error"))
+
+	_, err := session.List(dep.Redis, username)
+	assert.EqualError(t, err, "Testing error")
+}
+
+func TestDeleteSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	del := redisMock.Command("DEL", sessionID, "session:"+sessionID).Expect(int64(2))
+	srem := redisMock.Command("SREM", "sessions:"+username, sessionID).Expect(int64(1))
+
+	require.NoError(t, session.Delete(dep.Redis, cookieVal))
+
+	assert.Equal(t, 1, redisMock.Stats(del))
+	assert.Equal(t, 1, redisMock.Stats(srem))
+}
+
+func TestDeleteExpiredCookie(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(nil)
+	del := redisMock.Command("DEL", sessionID, "session:"+sessionID).Expect(int64(0))
+
+	require.NoError(t, session.Delete(dep.Redis, cookieVal))
+
+	assert.Equal(t, 1, redisMock.Stats(del))
+}
+
+func TestKillSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SISMEMBER", "sessions:"+username, sessionID).Expect(int64(1))
+	redisMock.Command("GET", sessionID).Expect(username)
+	del := redisMock.Command("DEL", sessionID, "session:"+sessionID).Expect(int64(2))
+	redisMock.Command("SREM", "sessions:"+username, sessionID).Expect(int64(1))
+
+	require.NoError(t, session.Kill(dep.Redis, username, sessionID))
+
+	assert.Equal(t, 1, redisMock.Stats(del))
+}
+
+func TestKillUnknownSession(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SISMEMBER", "sessions:"+username, sessionID).Expect(int64(0))
+
+	assert.Equal(t, redis.ErrNil, session.Kill(dep.Redis, username, sessionID))
+}
+
+func TestKillSessionsSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:"+username).Expect([]interface{}{[]byte("id1"), []byte("id2")})
+	del1 := redisMock.Command("DEL", "id1", "session:id1").Expect(int64(2))
+	del2 := redisMock.Command("DEL", "id2", "session:id2").Expect(int64(2))
+	delIndex := redisMock.Command("DEL", "sessions:"+username).Expect(int64(1))
+
+	require.NoError(t, session.KillSessions(dep.Redis, username))
+
+	assert.Equal(t, 1, redisMock.Stats(del1))
+	assert.Equal(t, 1, redisMock.Stats(del2))
+	assert.Equal(t, 1, redisMock.Stats(delIndex))
+}
+
+func TestKillSessionsRedisError(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:"+username).ExpectError(fmt.Errorf("Testing error"))
+
+	assert.EqualError(t, session.KillSessions(dep.Redis, username), "Testing error")
+}
+
+func TestKillOtherSessionsSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SMEMBERS", "sessions:"+username).Expect([]interface{}{[]byte("id1"), []byte(sessionID)})
+	del := redisMock.Command("DEL", "id1", "session:id1").Expect(int64(2))
+	srem := redisMock.Command("SREM", "sessions:"+username, "id1").Expect(int64(1))
+	keep := redisMock.Command("DEL", sessionID, "session:"+sessionID)
+
+	require.NoError(t, session.KillOtherSessions(dep.Redis, username, cookieVal))
+
+	assert.Equal(t, 1, redisMock.Stats(del))
+	assert.Equal(t, 1, redisMock.Stats(srem))
+	// current session should stay alive
+	assert.Equal(t, 0, redisMock.Stats(keep))
+}
+
+func TestAuthWrapperUpdatingSessionInfoError(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	redisMock.Command("HSET", "session:"+sessionID, "lastSeen", redigomock.NewAnyInt()).ExpectError(fmt.Errorf("Testing error"))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{
+		Name:  "session_id",
+		Value: cookieVal,
+	})
+	w := httptest.NewRecorder()
+
+	sut := session.AuthWrapper(testHandler, dep)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later.\n", w.Body)
+}
+
+func TestPurgeExpiredSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	// keys are returned by two pages of SCAN
+	redisMock.Command("SCAN", "0", "MATCH", "sessions:*", "COUNT", 100).Expect([]interface{}{[]byte("5"), []interface{}{[]byte("sessions:user1")}})
+	redisMock.Command("SCAN", "5", "MATCH", "sessions:*", "COUNT", 100).Expect([]interface{}{[]byte("0"), []interface{}{[]byte("sessions:user2")}})
+	redisMock.Command("SMEMBERS", "sessions:user1").Expect([]interface{}{[]byte("alive"), []byte("expired1")})
+	redisMock.Command("SMEMBERS", "sessions:user2").Expect([]interface{}{[]byte("expired2")})
+	redisMock.Command("EXISTS", "alive").Expect(int64(1))
+	redisMock.Command("EXISTS", "expired1").Expect(int64(0))
+	redisMock.Command("EXISTS", "expired2").Expect(int64(0))
+	del1 := redisMock.Command("DEL", "session:expired1").Expect(int64(0))
+	srem1 := redisMock.Command("SREM", "sessions:user1", "expired1").Expect(int64(1))
+	del2 := redisMock.Command("DEL", "session:expired2").Expect(int64(0))
+	srem2 := redisMock.Command("SREM", "sessions:user2", "expired2").Expect(int64(1))
+
+	purged, err := session.PurgeExpired(dep.Redis)
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	for _, cmd := range []*redigomock.Cmd{del1, srem1, del2, srem2} {
+		assert.Equal(t, 1, redisMock.Stats(cmd))
+	}
+}
+
+func TestPurgeExpiredRedisError(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("SCAN", "0", "MATCH", "sessions:*", "COUNT", 100).ExpectError(fmt.Errorf("Testing error"))
+
+	_, err := session.PurgeExpired(dep.Redis)
+	assert.EqualError(t, err, "Testing error")
+}
@@ -1,46 +1,126 @@
 package session
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
+	"github.com/vpoletaev11/fileHostingSite/api/types"
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
+	"github.com/vpoletaev11/fileHostingSite/errhand"
+	"github.com/vpoletaev11/fileHostingSite/mail"
+	"github.com/vpoletaev11/fileHostingSite/searchindex"
+	"github.com/vpoletaev11/fileHostingSite/storage"
 )
 
 const (
 	CookieLifetime = 30 * time.Minute
+	CookieName     = types.SessionCookie
+	tokenLen       = 32 // length of session token in bytes
 )
 
+const selectIsAdmin = "SELECT isAdmin FROM users WHERE username = ?;"
+
 type Dependency struct {
-	Db       *sql.DB
-	Redis    redis.Conn
-	Username string
+	Db      *sql.DB
+	Redis   redis.Conn
+	Storage storage.Backend
+	Mail    mail.Sender
+	// Search is index of files used by search page, it should be updated when files are uploaded, edited or deleted
+	Search *searchindex.Index
+	// SiteURL is public address of site (e.g. https://example.com), it's used in links sent by email
+	SiteURL string
+	// SecureCookie adds Secure attribute to session cookie, it should be enabled when site is served over TLS
+	SecureCookie bool
+	Username     string
+	// CSRFToken is CSRF token of user session, it's set by AuthWrapper
+	CSRFToken string
+	// Scopes are scopes of API token which authorized request, it's nil for requests authorized by session cookie
+	Scopes []string
+	// Clock returns current time, if it's nil time.Now is used (tests set it to get deterministic time)
+	Clock func() time.Time
+}
+
+// Now returns current time from Clock of dependencies
+func (dep Dependency) Now() time.Time {
+	if dep.Clock == nil {
+		return time.Now()
+	}
+	return dep.Clock()
+}
+
+// Allowed checks if request is allowed to use scope of JSON API.
+// Requests authorized by session cookie are allowed to use all scopes.
+func (dep Dependency) Allowed(scope string) bool {
+	if dep.Scopes == nil {
+		return true
+	}
+	for _, s := range dep.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 type page func(dep Dependency) http.HandlerFunc
 
-// CreateCookie creates cookie for user
-func CreateCookie(dep Dependency) (http.Cookie, error) {
-	var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
-	cookieVal := make([]rune, 60)
+// newToken returns random session token
+func newToken() (string, error) {
+	token := make([]byte, tokenLen)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
 
-	rand.Seed(time.Now().UTC().UnixNano())
-	for i := range cookieVal {
-		cookieVal[i] = letters[rand.Intn(len(letters))]
+// newCookie returns session cookie with hardened attributes:
+// HttpOnly hides cookie from scripts, SameSite=Lax prevents sending of cookie with cross-site POST requests
+// and Secure (if enabled) prevents sending of cookie over plain HTTP.
+func newCookie(token string, secure bool) http.Cookie {
+	return http.Cookie{
+		Name:     CookieName,
+		Path:     "/",
+		Value:    token,
+		Expires:  time.Now().Add(CookieLifetime),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
 	}
+}
 
-	// creating cookie with lifetime
-	cookie := http.Cookie{
-		Name:    "session_id",
-		Path:    "/",
-		Value:   string(cookieVal),
-		Expires: time.Now().Add(CookieLifetime),
+// ExpiredCookie returns cookie which removes session cookie from browser
+func ExpiredCookie(dep Dependency) *http.Cookie {
+	cookie := newCookie("", dep.SecureCookie)
+	cookie.Expires = time.Time{}
+	cookie.MaxAge = -1
+	return &cookie
+}
+
+// CreateCookie creates session for user and returns cookie with session token.
+// Redis stores only hash of token (session ID), so redis data can't be used to hijack sessions.
+func CreateCookie(dep Dependency, r *http.Request) (http.Cookie, error) {
+	token, err := newToken()
+	if err != nil {
+		return http.Cookie{}, err
 	}
+	cookie := newCookie(token, dep.SecureCookie)
+	id := ID(token)
 
-	_, err := dep.Redis.Do("SET", cookie.Value, dep.Username, "EX", CookieLifetime.Seconds())
+	_, err = dep.Redis.Do("SET", id, dep.Username, "EX", CookieLifetime.Seconds())
+	if err != nil {
+		return http.Cookie{}, err
+	}
+
+	err = addToIndex(dep.Redis, dep.Username, id, r)
 	if err != nil {
 		return http.Cookie{}, err
 	}
@@ -48,47 +128,98 @@ func CreateCookie(dep Dependency) (http.Cookie, error) {
 	return cookie, nil
 }
 
-// cookieValidator returns username and cookie when cookie are:
+// Rotate removes session which came with request and creates new session for user.
+// It's used on login to prevent session fixation: token known before login becomes useless after it.
+func Rotate(dep Dependency, r *http.Request) (http.Cookie, error) {
+	oldCookie, err := r.Cookie(CookieName)
+	if err == nil {
+		err = Delete(dep.Redis, oldCookie.Value)
+		if err != nil {
+			return http.Cookie{}, err
+		}
+	}
+
+	return CreateCookie(dep, r)
+}
+
+// cookieValidator returns username and session token when cookie are:
 // 1) came on input,
 // 2) doesn't out of date,
-// 3) contains in redis.
-func cookieValidator(redisConn redis.Conn, r *http.Request) (string, http.Cookie) {
+// 3) hash of its token contains in redis.
+func cookieValidator(redisConn redis.Conn, r *http.Request) (string, string) {
 	// handling case when cookie doesn't came to input
-	cookie, err := r.Cookie("session_id")
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", ""
+	}
+
+	username, err := redis.String(redisConn.Do("GET", ID(cookie.Value)))
 	if err != nil {
-		return "", http.Cookie{}
+		return "", ""
+	}
+
+	return username, cookie.Value
+}
+
+var (
+	// errUnauthorized returns by authorize when request doesn't have valid session cookie
+	errUnauthorized = errors.New("Unauthorized")
+	// errInvalidCSRF returns by authorize when state-changing request doesn't have valid CSRF token
+	errInvalidCSRF = errors.New("Invalid CSRF token")
+)
+
+// authorize checks session cookie and CSRF token of request and extends session lifetime.
+// It returns dependencies with username and CSRF token of user and session token from cookie.
+func authorize(dep Dependency, r *http.Request) (Dependency, string, error) {
+	// checking cookie validity
+	token := ""
+	dep.Username, token = cookieValidator(dep.Redis, r)
+	if dep.Username == "" {
+		return dep, "", errUnauthorized
 	}
 
-	username, err := redis.String(redisConn.Do("GET", cookie.Value))
+	// checking CSRF token of state-changing requests
+	id := ID(token)
+	var err error
+	dep.CSRFToken, err = csrfToken(dep.Redis, id)
 	if err != nil {
-		return "", http.Cookie{}
+		return dep, "", err
+	}
+	if !csrf.SafeMethod(r.Method) && !csrf.Valid(dep.CSRFToken, r) {
+		return dep, "", errInvalidCSRF
 	}
 
-	return username, *cookie
+	// extending cookie lifetime
+	_, err = dep.Redis.Do("EXPIRE", id, CookieLifetime.Seconds())
+	if err != nil {
+		return dep, "", err
+	}
+	err = touch(dep.Redis, dep.Username, id)
+	if err != nil {
+		return dep, "", err
+	}
+	return dep, token, nil
 }
 
 // AuthWrapper grants access to pagehandler and extends cookie lifetime if inputted cookie are valid
 func AuthWrapper(pageHandler page, dep Dependency) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// checking cookie validity
-		cookie := http.Cookie{}
-		dep.Username, cookie = cookieValidator(dep.Redis, r)
-
-		// handling case when cookie invalid
-		if dep.Username == "" {
+		dep, token, err := authorize(dep, r)
+		switch err {
+		case nil:
+		case errUnauthorized:
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
-		}
-
-		// extending cookie lifetime
-		cookie.Expires = time.Now().Add(CookieLifetime)
-		cookie.Path = "/"
-		_, err := dep.Redis.Do("EXPIRE", cookie.Value, CookieLifetime.Seconds())
-		if err != nil {
+		case errInvalidCSRF:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintln(w, "ERROR: Invalid CSRF token")
+			return
+		default:
 			w.WriteHeader(500)
 			fmt.Fprintln(w, "INTERNAL ERROR. Please try later.")
 			return
 		}
+		cookie := newCookie(token, dep.SecureCookie)
 		http.SetCookie(w, &cookie)
 
 		pageHandler := pageHandler(dep)
@@ -96,3 +227,77 @@ func AuthWrapper(pageHandler page, dep Dependency) http.HandlerFunc {
 		pageHandler.ServeHTTP(w, r)
 	})
 }
+
+// bearerToken returns token from "Authorization: Bearer <token>" header of request
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")), true
+}
+
+// APIWrapper grants access to JSON API handler for authorized users.
+// Unlike AuthWrapper it doesn't redirect to login page, all errors are written in JSON format.
+// Requests with API token in Authorization header don't need session cookie and CSRF token,
+// handler should check scopes of token by dep.Allowed().
+func APIWrapper(apiHandler page, dep Dependency) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiToken, ok := bearerToken(r); ok {
+			username, scopes, err := apitokens.Authenticate(dep.Db, apiToken, dep.Now())
+			switch err {
+			case nil:
+			case apitokens.ErrInvalid:
+				errhand.JSONError(w, http.StatusUnauthorized, err.Error())
+				return
+			default:
+				errhand.JSONInternalError(err, w)
+				return
+			}
+			dep.Username = username
+			dep.Scopes = scopes
+			apiHandler(dep).ServeHTTP(w, r)
+			return
+		}
+
+		dep, token, err := authorize(dep, r)
+		switch err {
+		case nil:
+		case errUnauthorized:
+			errhand.JSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		case errInvalidCSRF:
+			errhand.JSONError(w, http.StatusForbidden, "Invalid CSRF token")
+			return
+		default:
+			errhand.JSONInternalError(err, w)
+			return
+		}
+		cookie := newCookie(token, dep.SecureCookie)
+		http.SetCookie(w, &cookie)
+
+		apiHandler(dep).ServeHTTP(w, r)
+	})
+}
+
+// AdminWrapper grants access to pagehandler only for authorized users with admin role
+func AdminWrapper(pageHandler page, dep Dependency) http.HandlerFunc {
+	return AuthWrapper(func(dep Dependency) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			isAdmin := false
+			err := dep.Db.QueryRow(selectIsAdmin, dep.Username).Scan(&isAdmin)
+			if err != nil {
+				w.WriteHeader(500)
+				fmt.Fprintln(w, "INTERNAL ERROR. Please try later.")
+				return
+			}
+			if !isAdmin {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprintln(w, "ERROR: Access denied")
+				return
+			}
+
+			pageHandler(dep).ServeHTTP(w, r)
+		}
+	}, dep)
+}
@@ -6,12 +6,15 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/rafaeljusto/redigomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/apitokens"
 	"github.com/vpoletaev11/fileHostingSite/session"
 	"github.com/vpoletaev11/fileHostingSite/test"
 )
@@ -19,10 +22,26 @@ import (
 const (
 	cookieVal = "D8SgghMYJQSo9PXuH7wihJlrRFP18RKBzITHDwXou8VGqaVHW1Yi9KWyIrUu"
 	username  = "username"
+	csrfToken = "csrfToken"
 )
 
+// sessionID is redis key of session with cookieVal token
+var sessionID = session.ID(cookieVal)
+
 type anyString struct{}
 
+// expectTouch adds expectations of commands which update session info on every request
+func expectTouch(redisMock *redigomock.Conn) {
+	redisMock.Command("HSET", "session:"+sessionID, "lastSeen", redigomock.NewAnyInt())
+	redisMock.Command("EXPIRE", "session:"+sessionID, session.CookieLifetime.Seconds())
+	redisMock.Command("EXPIRE", "sessions:"+username, session.CookieLifetime.Seconds())
+}
+
+// expectCSRFToken adds expectation of command which gets CSRF token of session
+func expectCSRFToken(redisMock *redigomock.Conn) {
+	redisMock.Command("HGET", "session:"+sessionID, "csrf").Expect(csrfToken)
+}
+
 func testHandler(dep session.Dependency) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, dep.Username)
@@ -32,11 +51,20 @@ func testHandler(dep session.Dependency) http.HandlerFunc {
 func TestCreateCookieSuccess(t *testing.T) {
 	dep, _, redisMock := test.NewDep(t)
 	redisMock.Command("SET", redigomock.NewAnyData(), username, "EX", session.CookieLifetime.Seconds())
+	redisMock.Command("HSET", redigomock.NewAnyData(), "created", redigomock.NewAnyInt(), "lastSeen", redigomock.NewAnyInt(), "ip", "192.0.2.1", "userAgent", "test agent")
+	redisMock.Command("EXPIRE", redigomock.NewAnyData(), session.CookieLifetime.Seconds())
+	sadd := redisMock.Command("SADD", "sessions:"+username, redigomock.NewAnyData())
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/login", nil)
+	require.NoError(t, err)
+	r.Header.Set("User-Agent", "test agent")
+	r.RemoteAddr = "192.0.2.1:1234"
 
-	cookie1, err := session.CreateCookie(dep)
+	cookie1, err := session.CreateCookie(dep, r)
 	assert.NoError(t, err)
-	cookie2, err := session.CreateCookie(dep)
+	cookie2, err := session.CreateCookie(dep, r)
 	assert.NoError(t, err)
+	assert.Equal(t, 2, redisMock.Stats(sadd))
 
 	if cookie1.Expires.After(time.Now().Add(30*time.Minute + 1*time.Second)) {
 		t.Error("cookie.Expires > 30 min. cookie.Expires = " + cookie1.Expires.String())
@@ -45,10 +73,14 @@ func TestCreateCookieSuccess(t *testing.T) {
 		t.Error("cookie.Expires < 30 min. cookie.Expires = " + cookie1.Expires.String())
 	}
 
-	if len(cookie1.Value) != 60 {
-		t.Errorf("CreateCookie() creates cookie with invalid cookie.Value (len(cookie.Value) < 60)")
+	if len(cookie1.Value) != 43 {
+		t.Errorf("CreateCookie() creates cookie with invalid cookie.Value (len(cookie.Value) != 43)")
 	}
 
+	assert.True(t, cookie1.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie1.SameSite)
+	assert.False(t, cookie1.Secure)
+
 	if cookie1.Value == cookie2.Value {
 		t.Errorf("CreateCookie() creates not unique cookie value")
 	}
@@ -60,8 +92,10 @@ func TestCreateCookieSuccess(t *testing.T) {
 
 func TestAuthWrapperSuccess(t *testing.T) {
 	dep, _, redisMock := test.NewDep(t)
-	redisMock.Command("GET", cookieVal).Expect(username)
-	redisMock.Command("EXPIRE", cookieVal, session.CookieLifetime.Seconds())
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
 
 	r, err := http.NewRequest(http.MethodPost, "http://localhost/", nil)
 	require.NoError(t, err)
@@ -71,6 +105,7 @@ func TestAuthWrapperSuccess(t *testing.T) {
 		Value: cookieVal,
 	}
 	r.AddCookie(inHandlerCookie)
+	r.Header.Set("X-CSRF-Token", csrfToken)
 
 	sut := session.AuthWrapper(testHandler, dep)
 
@@ -89,11 +124,40 @@ func TestCreateCookieSendToRedisError(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 	dep.Redis.Close()
 
-	cookie, err := session.CreateCookie(dep)
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/login", nil)
+	require.NoError(t, err)
+
+	cookie, err := session.CreateCookie(dep, r)
 	assert.Equal(t, http.Cookie{}, cookie)
 	assert.NotNil(t, err)
 }
 
+func TestRotateDeletesOldSession(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	del := redisMock.Command("DEL", sessionID, "session:"+sessionID).Expect(int64(2))
+	redisMock.Command("SREM", "sessions:"+username, sessionID).Expect(int64(1))
+	redisMock.Command("SET", redigomock.NewAnyData(), username, "EX", session.CookieLifetime.Seconds())
+	redisMock.Command("HSET", redigomock.NewAnyData(), "created", redigomock.NewAnyInt(), "lastSeen", redigomock.NewAnyInt(), "ip", "192.0.2.1", "userAgent", "")
+	redisMock.Command("EXPIRE", redigomock.NewAnyData(), session.CookieLifetime.Seconds())
+	redisMock.Command("SADD", "sessions:"+username, redigomock.NewAnyData())
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/login", nil)
+	require.NoError(t, err)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.AddCookie(&http.Cookie{
+		Name:  "session_id",
+		Value: cookieVal,
+	})
+
+	cookie, err := session.Rotate(dep, r)
+	require.NoError(t, err)
+
+	// session which came with request should not survive login
+	assert.Equal(t, 1, redisMock.Stats(del))
+	assert.NotEqual(t, cookieVal, cookie.Value)
+}
+
 func TestAuthWrapperEmptyCookieError(t *testing.T) {
 	dep, _, _ := test.NewDep(t)
 
@@ -118,7 +182,7 @@ func TestAuthWrapperEmptyCookieError(t *testing.T) {
 
 func TestAuthWrapperGettingUsernameError(t *testing.T) {
 	dep, _, redisMock := test.NewDep(t)
-	redisMock.Command("GET", cookieVal).ExpectError(fmt.Errorf("Testing Error"))
+	redisMock.Command("GET", sessionID).ExpectError(fmt.Errorf("Testing Error"))
 
 	r, err := http.NewRequest(http.MethodPost, "http://localhost/", nil)
 	require.NoError(t, err)
@@ -147,8 +211,9 @@ func TestAuthWrapperGettingUsernameError(t *testing.T) {
 
 func TestAuthWrapperExtendingCookieLifetimeError(t *testing.T) {
 	dep, _, redisMock := test.NewDep(t)
-	redisMock.Command("GET", cookieVal).Expect(username)
-	redisMock.Command("EXPIRE", cookieVal, session.CookieLifetime.Seconds()).ExpectError(fmt.Errorf("Testing error"))
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds()).ExpectError(fmt.Errorf("Testing error"))
 
 	r, err := http.NewRequest(http.MethodPost, "http://localhost/", nil)
 	require.NoError(t, err)
@@ -158,6 +223,7 @@ func TestAuthWrapperExtendingCookieLifetimeError(t *testing.T) {
 		Value: cookieVal,
 	}
 	r.AddCookie(inHandlerCookie)
+	r.Header.Set("X-CSRF-Token", csrfToken)
 
 	sut := session.AuthWrapper(testHandler, dep)
 
@@ -171,3 +237,249 @@ func TestAuthWrapperExtendingCookieLifetimeError(t *testing.T) {
 
 	assert.Equal(t, "INTERNAL ERROR. Please try later.\n", bodyString)
 }
+
+// adminRequest sends request with valid cookie to AdminWrapper
+func adminRequest(dep session.Dependency) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost/admin", nil)
+	r.AddCookie(&http.Cookie{
+		Name:  "session_id",
+		Value: cookieVal,
+	})
+	w := httptest.NewRecorder()
+
+	sut := session.AdminWrapper(testHandler, dep)
+
+	sut(w, r)
+
+	return w
+}
+
+func TestAdminWrapperSuccess(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
+	sqlMock.ExpectQuery("SELECT isAdmin FROM users WHERE username =").WithArgs(username).WillReturnRows(sqlmock.NewRows([]string{"isAdmin"}).AddRow(true))
+
+	w := adminRequest(dep)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, username, w.Body)
+}
+
+func TestAdminWrapperNotAdmin(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
+	sqlMock.ExpectQuery("SELECT isAdmin FROM users WHERE username =").WithArgs(username).WillReturnRows(sqlmock.NewRows([]string{"isAdmin"}).AddRow(false))
+
+	w := adminRequest(dep)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Access denied\n", w.Body)
+}
+
+func TestAdminWrapperNotAuthorized(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).ExpectError(fmt.Errorf("Testing Error"))
+
+	w := adminRequest(dep)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+}
+
+func TestAdminWrapperDBError(t *testing.T) {
+	dep, sqlMock, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
+	sqlMock.ExpectQuery("SELECT isAdmin FROM users WHERE username =").WithArgs(username).WillReturnError(fmt.Errorf("Testing error"))
+
+	w := adminRequest(dep)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	test.AssertBodyEqual(t, "INTERNAL ERROR. Please try later.\n", w.Body)
+}
+
+// csrfRequest sends POST request with valid cookie and inputted form to AuthWrapper
+func csrfRequest(dep session.Dependency, form string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(http.MethodPost, "http://localhost/", strings.NewReader(form))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{
+		Name:  "session_id",
+		Value: cookieVal,
+	})
+	w := httptest.NewRecorder()
+
+	sut := session.AuthWrapper(testHandler, dep)
+
+	sut(w, r)
+
+	return w
+}
+
+func TestAuthWrapperCSRFTokenInForm(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
+
+	w := csrfRequest(dep, "csrf_token="+csrfToken)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, username, w.Body)
+}
+
+func TestAuthWrapperInvalidCSRFToken(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+
+	w := csrfRequest(dep, "csrf_token=wrong")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Invalid CSRF token\n", w.Body)
+}
+
+func TestAuthWrapperMissingCSRFToken(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+
+	w := csrfRequest(dep, "")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, "ERROR: Invalid CSRF token\n", w.Body)
+}
+
+func TestAuthWrapperCreatesCSRFToken(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	redisMock.Command("HGET", "session:"+sessionID, "csrf").Expect(nil)
+	hset := redisMock.Command("HSET", "session:"+sessionID, "csrf", redigomock.NewAnyData())
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
+
+	r, _ := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	r.AddCookie(&http.Cookie{
+		Name:  "session_id",
+		Value: cookieVal,
+	})
+	w := httptest.NewRecorder()
+
+	sut := session.AuthWrapper(testHandler, dep)
+
+	sut(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, redisMock.Stats(hset))
+}
+
+func TestAPIWrapperSuccess(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+	redisMock.Command("EXPIRE", sessionID, session.CookieLifetime.Seconds())
+	expectTouch(redisMock)
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/files", nil)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: cookieVal})
+	w := httptest.NewRecorder()
+
+	session.APIWrapper(testHandler, dep)(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, username, w.Body)
+}
+
+func TestAPIWrapperNotAuthorized(t *testing.T) {
+	dep, _, _ := test.NewDep(t)
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/files", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+
+	session.APIWrapper(testHandler, dep)(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":401,"message":"Unauthorized"}}`+"\n", w.Body)
+}
+
+func TestAPIWrapperInvalidCSRFToken(t *testing.T) {
+	dep, _, redisMock := test.NewDep(t)
+	redisMock.Command("GET", sessionID).Expect(username)
+	expectCSRFToken(redisMock)
+
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/api/v1/files", nil)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: cookieVal})
+	r.Header.Set("X-CSRF-Token", "wrong")
+	w := httptest.NewRecorder()
+
+	session.APIWrapper(testHandler, dep)(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":403,"message":"Invalid CSRF token"}}`+"\n", w.Body)
+}
+
+func TestAPIWrapperAPIToken(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	dep.Username = ""
+	dep.Clock = func() time.Time { return time.Date(2009, 11, 17, 20, 34, 58, 0, time.UTC) }
+	sqlMock.ExpectQuery("SELECT (.+) FROM apiTokens").WithArgs(apitokens.Hash("fhs_token")).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "username", "scopes"}).AddRow(1, username, "read,upload"))
+	sqlMock.ExpectExec("UPDATE apiTokens SET lastUsed").WithArgs("2009-11-17 20:34:58", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// API token doesn't need CSRF token
+	r, err := http.NewRequest(http.MethodPost, "http://localhost/api/v1/files", nil)
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer fhs_token")
+	w := httptest.NewRecorder()
+
+	session.APIWrapper(func(dep session.Dependency) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, dep.Username, dep.Scopes)
+		}
+	}, dep)(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	test.AssertBodyEqual(t, "username[read upload]", w.Body)
+	// session cookie isn't set for requests authorized by API token
+	assert.Empty(t, w.Result().Cookies())
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestAPIWrapperInvalidAPIToken(t *testing.T) {
+	dep, sqlMock, _ := test.NewDep(t)
+	sqlMock.ExpectQuery("SELECT (.+) FROM apiTokens").WithArgs(apitokens.Hash("fhs_revoked")).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "username", "scopes"}))
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/files", nil)
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer fhs_revoked")
+	// valid session cookie doesn't help when API token is invalid
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: cookieVal})
+	w := httptest.NewRecorder()
+
+	session.APIWrapper(testHandler, dep)(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	test.AssertBodyEqual(t, `{"error":{"status":401,"message":"Invalid API token"}}`+"\n", w.Body)
+}
+
+func TestAllowed(t *testing.T) {
+	dep := session.Dependency{}
+	assert.True(t, dep.Allowed("upload"))
+
+	dep.Scopes = []string{"read", "vote"}
+	assert.True(t, dep.Allowed("read"))
+	assert.True(t, dep.Allowed("vote"))
+	assert.False(t, dep.Allowed("upload"))
+}
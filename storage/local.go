@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local is Backend which stores objects as files in local directory
+type Local struct {
+	dir string
+}
+
+// NewLocal returns Backend which stores objects in inputted directory
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+// path returns path to file of object with inputted key
+func (l *Local) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("storage: incorrect key %q", key)
+	}
+	return filepath.Join(l.dir, key), nil
+}
+
+// Put writes data to temporary file and renames it to object file when writing is finished
+func (l *Local) Put(key string, r io.Reader) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := ioutil.TempFile(l.dir, ".put-")
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return 0, err
+	}
+	err = f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// Get returns reader of object file
+func (l *Local) Get(key string) (io.ReadCloser, error) {
+	return l.RangeReader(key, 0, -1)
+}
+
+// Stat returns information about object file
+func (l *Local) Stat(key string) (Info, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return Info{}, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Info{}, ErrNotExist
+		}
+		return Info{}, err
+	}
+	return Info{Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// Delete removes object file
+func (l *Local) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	return err
+}
+
+// RangeReader returns reader of object file part
+func (l *Local) RangeReader(key string, offset, length int64) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return f, nil
+	}
+	return limitedReadCloser{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+// List returns names of object files, temporary files of unfinished Put() are skipped
+func (l *Local) List() ([]string, error) {
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		keys = append(keys, f.Name())
+	}
+	return keys, nil
+}
+
+// limitedReadCloser closes underlying file of limited reader
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
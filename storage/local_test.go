@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errReader returns error after reading of data
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("testing error")
+}
+
+func newTestLocal(t *testing.T) (*Local, func()) {
+	dir, err := ioutil.TempDir("", "storage-test-")
+	require.NoError(t, err)
+	return NewLocal(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestLocalPutGetSuccess(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+
+	n, err := l.Put("1", strings.NewReader("binary data"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+
+	r, err := l.Get("1")
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "binary data", string(data))
+
+	info, err := l.Stat("1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), info.Size)
+}
+
+func TestLocalPutReaderError(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+
+	_, err := l.Put("1", errReader{})
+	assert.EqualError(t, err, "testing error")
+
+	_, err = l.Stat("1")
+	assert.Equal(t, ErrNotExist, err)
+
+	// temporary file should be removed
+	files, err := ioutil.ReadDir(l.dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestLocalRangeReaderSuccess(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+	_, err := l.Put("1", strings.NewReader("binary data"))
+	require.NoError(t, err)
+
+	r, err := l.RangeReader("1", 2, 4)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "nary", string(data))
+
+	r, err = l.RangeReader("1", 7, -1)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestLocalDeleteSuccess(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+	_, err := l.Put("1", strings.NewReader("binary data"))
+	require.NoError(t, err)
+
+	require.NoError(t, l.Delete("1"))
+	assert.Equal(t, ErrNotExist, l.Delete("1"))
+
+	_, err = l.Get("1")
+	assert.Equal(t, ErrNotExist, err)
+}
+
+func TestLocalListSuccess(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+	for _, key := range []string{"2", "1"} {
+		_, err := l.Put(key, strings.NewReader("binary data"))
+		require.NoError(t, err)
+	}
+	// temporary file of unfinished Put() isn't an object
+	require.NoError(t, ioutil.WriteFile(l.dir+"/.put-123", []byte("binary"), 0644))
+
+	keys, err := l.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, keys)
+}
+
+func TestLocalIncorrectKeyError(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+
+	for _, key := range []string{"", ".", "..", "../1", "dir/1"} {
+		_, err := l.Put(key, strings.NewReader("binary data"))
+		assert.Error(t, err, key)
+		_, err = l.Stat(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestObjectReadSeekSuccess(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+	_, err := l.Put("1", strings.NewReader("binary data"))
+	require.NoError(t, err)
+
+	obj, err := Open(l, "1")
+	require.NoError(t, err)
+	defer obj.Close()
+	assert.Equal(t, int64(11), obj.Size())
+
+	buf := make([]byte, 3)
+	_, err = obj.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "bin", string(buf))
+
+	offset, err := obj.Seek(-4, os.SEEK_END)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), offset)
+	data, err := ioutil.ReadAll(obj)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	_, err = obj.Seek(-1, os.SEEK_SET)
+	assert.Error(t, err)
+}
+
+func TestOpenNotExistError(t *testing.T) {
+	l, cleanup := newTestLocal(t)
+	defer cleanup()
+
+	_, err := Open(l, "1")
+	assert.Equal(t, ErrNotExist, err)
+}
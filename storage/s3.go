@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// S3Config contains settings of S3-compatible storage (AWS S3, MinIO, etc.)
+type S3Config struct {
+	Endpoint  string // e.g. http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3ConfigFromEnv returns settings of S3-compatible storage from environment variables
+// S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY.
+// If S3_ENDPOINT isn't set S3 storage isn't configured and false is returned.
+func S3ConfigFromEnv() (S3Config, bool) {
+	cfg := S3Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	}
+	return cfg, cfg.Endpoint != ""
+}
+
+// S3 is Backend which stores objects in bucket of S3-compatible storage.
+// Requests use path-style addressing and signed by AWS Signature Version 4.
+type S3 struct {
+	cfg    S3Config
+	client *http.Client
+	// now returns current time, it replaced in tests
+	now func() time.Time
+}
+
+// NewS3 returns Backend which stores objects in S3-compatible storage
+func NewS3(cfg S3Config) *S3 {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &S3{cfg: cfg, client: http.DefaultClient, now: time.Now}
+}
+
+// Put uploads object to bucket.
+// S3 requires Content-Length, so data of unknown size will be buffered in temporary file.
+func (s *S3) Put(key string, r io.Reader) (int64, error) {
+	size := int64(-1)
+	if seeker, ok := r.(io.Seeker); ok {
+		cur, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err != nil {
+				return 0, err
+			}
+			_, err = seeker.Seek(cur, io.SeekStart)
+			if err != nil {
+				return 0, err
+			}
+			size = end - cur
+		}
+	}
+
+	if size < 0 {
+		tmp, err := ioutil.TempFile("", "s3-put-")
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		size, err = io.Copy(tmp, r)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tmp.Seek(0, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+		r = tmp
+	}
+
+	req, err := s.newRequest("PUT", key, ioutil.NopCloser(r))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return size, nil
+}
+
+// Get returns reader of whole object
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	return s.RangeReader(key, 0, -1)
+}
+
+// Stat returns information about object from HEAD response
+func (s *S3) Stat(key string) (Info, error) {
+	req, err := s.newRequest("HEAD", key, nil)
+	if err != nil {
+		return Info{}, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return Info{}, err
+	}
+	resp.Body.Close()
+
+	info := Info{Size: resp.ContentLength}
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err == nil {
+		info.ModTime = modTime
+	}
+	return info, nil
+}
+
+// Delete removes object from bucket
+func (s *S3) Delete(key string) error {
+	_, err := s.Stat(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.newRequest("DELETE", key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// RangeReader returns body of GET request with Range header
+func (s *S3) RangeReader(key string, offset, length int64) (io.ReadCloser, error) {
+	req, err := s.newRequest("GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case length == 0:
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"+strconv.FormatInt(offset+length-1, 10))
+	case offset > 0:
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// listBucketResult is response of ListObjectsV2 request
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns keys of objects in bucket. Keys are requested by pages of 1000 keys (limit of S3)
+func (s *S3) List() ([]string, error) {
+	keys := []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequest("GET", s.cfg.Endpoint+"/"+uriEncode(s.cfg.Bucket, false)+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// newRequest creates request to object with inputted key
+func (s *S3) newRequest(method, key string, body io.ReadCloser) (*http.Request, error) {
+	if key == "" {
+		return nil, fmt.Errorf("storage: incorrect key %q", key)
+	}
+	req, err := http.NewRequest(method, s.cfg.Endpoint+"/"+uriEncode(s.cfg.Bucket, false)+"/"+uriEncode(key, false), nil)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Body = body
+	}
+	return req, nil
+}
+
+// do signs and sends request. Responses with non-2xx status will be converted to errors
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	payloadHash := emptyPayloadHash
+	if req.Body != nil && req.Body != http.NoBody {
+		payloadHash = unsignedPayload
+	}
+	signV4(req, payloadHash, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("storage: %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// signV4 adds AWS Signature Version 4 Authorization header to request
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(amzDateFormat)
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// collecting headers which will be signed
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" || lower == "content-md5" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery returns sorted and encoded query string
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes string as required by AWS Signature Version 4.
+// If encodeSlash == false slashes will not be encoded (used for paths)
+func uriEncode(s string, encodeSlash bool) string {
+	encoded := strings.Builder{}
+	for _, b := range []byte(s) {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNotExist returns by Backend when requested object doesn't exist
+var ErrNotExist = errors.New("storage: object does not exist")
+
+// Backend is storage of uploaded files data
+type Backend interface {
+	// Put writes data from reader to object with inputted key and returns count of written bytes.
+	// If reading or writing fails object will not be created.
+	Put(key string, r io.Reader) (int64, error)
+	// Get returns reader of whole object
+	Get(key string) (io.ReadCloser, error)
+	// Stat returns information about object
+	Stat(key string) (Info, error)
+	// Delete removes object
+	Delete(key string) error
+	// RangeReader returns reader of object part started from offset.
+	// If length < 0 reader will return data until end of object.
+	RangeReader(key string, offset, length int64) (io.ReadCloser, error)
+	// List returns keys of all stored objects
+	List() ([]string, error)
+}
+
+// Info contains information about stored object
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Object is io.ReadSeeker for stored object.
+// It opens range readers on demand, so it can be used in http.ServeContent() for any backend.
+type Object struct {
+	backend Backend
+	key     string
+	size    int64
+	offset  int64
+	reader  io.ReadCloser
+}
+
+// Open returns Object for object with inputted key
+func Open(backend Backend, key string) (*Object, error) {
+	info, err := backend.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{backend: backend, key: key, size: info.Size}, nil
+}
+
+// Size returns size of object
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// Read reads data from current offset of object
+func (o *Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.reader == nil {
+		reader, err := o.backend.RangeReader(o.key, o.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		o.reader = reader
+	}
+
+	n, err := o.reader.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+// Seek sets offset for next Read
+func (o *Object) Seek(offset int64, whence int) (int64, error) {
+	var newOffset int64
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = o.offset + offset
+	case io.SeekEnd:
+		newOffset = o.size + offset
+	default:
+		return 0, fmt.Errorf("storage: invalid whence")
+	}
+	if newOffset < 0 {
+		return 0, fmt.Errorf("storage: negative position")
+	}
+
+	if newOffset != o.offset && o.reader != nil {
+		o.reader.Close()
+		o.reader = nil
+	}
+	o.offset = newOffset
+	return o.offset, nil
+}
+
+// Close closes opened range reader
+func (o *Object) Close() error {
+	if o.reader == nil {
+		return nil
+	}
+	err := o.reader.Close()
+	o.reader = nil
+	return err
+}
@@ -0,0 +1,212 @@
+// Package tags contains functions for working with free-form tags of files
+package tags
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	insertTag = "INSERT IGNORE INTO tags (name) VALUES (?);"
+
+	deleteFileTags = "DELETE FROM file_tags WHERE fileID = ?;"
+
+	insertFileTag = "INSERT INTO file_tags (fileID, tagID) SELECT ?, id FROM tags WHERE name = ?;"
+
+	selectFileTags = "SELECT tags.name FROM file_tags JOIN tags ON tags.id = file_tags.tagID WHERE file_tags.fileID = ? ORDER BY tags.name;"
+
+	selectCloud = "SELECT tags.name, COUNT(*) AS filesCount FROM file_tags JOIN tags ON tags.id = file_tags.tagID GROUP BY tags.name ORDER BY filesCount DESC, tags.name LIMIT ?;"
+
+	selectSuggestions = "SELECT tags.name FROM tags JOIN file_tags ON file_tags.tagID = tags.id WHERE tags.name LIKE ? GROUP BY tags.name ORDER BY COUNT(*) DESC, tags.name LIMIT ?;"
+)
+
+const (
+	MaxTags   = 10 // maximal count of tags of one file
+	MaxTagLen = 30 // maximal length of tag name in symbols
+
+	// cloudSizes is count of font sizes in tag cloud
+	cloudSizes = 5
+)
+
+// CloudTag contains tag showed in tag cloud
+type CloudTag struct {
+	Name  string
+	Count int
+	// Size is from 1 (the least popular tags) to 5 (the most popular tags)
+	Size int
+}
+
+// Normalize returns tag name in canonical form: lowercase, words are separated by single hyphen.
+// Any symbol except letters, digits and hyphen is separator of words (e.g. " Rock_n Roll " -> "rock-n-roll").
+func Normalize(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// Validate checks normalized tag name
+func Validate(name string) error {
+	if name == "" {
+		return fmt.Errorf("Tag cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > MaxTagLen {
+		return fmt.Errorf("Tag %q are too long", name)
+	}
+	return nil
+}
+
+// Parse returns normalized tags from comma separated list (e.g. "Rock, jazz,rock" -> ["rock", "jazz"]).
+// Empty items and duplicates are skipped.
+func Parse(input string) ([]string, error) {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, item := range strings.Split(input, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		name := Normalize(item)
+		err := Validate(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	if len(tags) > MaxTags {
+		return nil, fmt.Errorf("File cannot have more than %d tags", MaxTags)
+	}
+	return tags, nil
+}
+
+// Set replaces tags of file by inputted tags. Tags should be parsed by Parse().
+func Set(db *sql.DB, fileID interface{}, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = SetTx(tx, fileID, tags)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetTx replaces tags of file inside of transaction, it's used when tags are saved together with file info.
+// Transaction isn't rolled back on error.
+func SetTx(tx *sql.Tx, fileID interface{}, tags []string) error {
+	_, err := tx.Exec(deleteFileTags, fileID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		_, err = tx.Exec(insertTag, name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(insertFileTag, fileID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForFile returns sorted tags of file
+func ForFile(db *sql.DB, fileID interface{}) ([]string, error) {
+	rows, err := db.Query(selectFileTags, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		name := ""
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+// Cloud returns the most popular tags sorted by name. Size of tag depends on count of files with this tag.
+func Cloud(db *sql.DB, limit int) ([]CloudTag, error) {
+	rows, err := db.Query(selectCloud, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cloud := []CloudTag{}
+	minCount, maxCount := 0, 0
+	for rows.Next() {
+		tag := CloudTag{}
+		err = rows.Scan(&tag.Name, &tag.Count)
+		if err != nil {
+			return nil, err
+		}
+		if len(cloud) == 0 || tag.Count < minCount {
+			minCount = tag.Count
+		}
+		if tag.Count > maxCount {
+			maxCount = tag.Count
+		}
+		cloud = append(cloud, tag)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cloud {
+		cloud[i].Size = 1
+		if maxCount > minCount {
+			cloud[i].Size += (cloud[i].Count - minCount) * (cloudSizes - 1) / (maxCount - minCount)
+		}
+	}
+	sort.Slice(cloud, func(i, j int) bool {
+		return cloud[i].Name < cloud[j].Name
+	})
+	return cloud, nil
+}
+
+// Suggest returns the most popular tags which starts with prefix, it's used for autocomplete of tags
+func Suggest(db *sql.DB, prefix string, limit int) ([]string, error) {
+	prefix = Normalize(prefix)
+	if prefix == "" {
+		return []string{}, nil
+	}
+
+	// symbols with special meaning in LIKE pattern are escaped (normalized tags can contain "_" only as letter of tag)
+	pattern := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(prefix) + "%"
+	rows, err := db.Query(selectSuggestions, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []string{}
+	for rows.Next() {
+		name := ""
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, name)
+	}
+	return suggestions, rows.Err()
+}
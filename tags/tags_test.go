@@ -0,0 +1,123 @@
+package tags
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "rock-n-roll", Normalize(" Rock_n  Roll "))
+	assert.Equal(t, "музыка-2020", Normalize("Музыка 2020"))
+	assert.Equal(t, "", Normalize(" -- "))
+}
+
+func TestParse(t *testing.T) {
+	tags, err := Parse("Rock, jazz,rock, ,Hip Hop")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rock", "jazz", "hip-hop"}, tags)
+
+	tags, err = Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("rock, ---")
+	assert.EqualError(t, err, "Tag cannot be empty")
+
+	_, err = Parse(strings.Repeat("a", MaxTagLen+1))
+	assert.EqualError(t, err, fmt.Sprintf("Tag %q are too long", strings.Repeat("a", MaxTagLen+1)))
+
+	_, err = Parse("a,b,c,d,e,f,g,h,i,j,k")
+	assert.EqualError(t, err, "File cannot have more than 10 tags")
+}
+
+func TestSet(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("rock").WillReturnResult(sqlmock.NewResult(1, 1))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs(1, "rock").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("jazz").WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT INTO file_tags").WithArgs(1, "jazz").WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	err = Set(db, 1, []string{"rock", "jazz"})
+	require.NoError(t, err)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestSetDBError(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectExec("DELETE FROM file_tags WHERE fileID = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	sqlMock.ExpectExec("INSERT IGNORE INTO tags").WithArgs("rock").WillReturnError(fmt.Errorf("testing error"))
+	sqlMock.ExpectRollback()
+
+	err = Set(db, 1, []string{"rock"})
+	assert.EqualError(t, err, "testing error")
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestForFile(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT tags.name FROM file_tags JOIN tags").WithArgs("1").WillReturnRows(
+		sqlmock.NewRows([]string{"name"}).AddRow("jazz").AddRow("rock"))
+
+	tags, err := ForFile(db, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jazz", "rock"}, tags)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCloud(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT tags.name, COUNT(.+) GROUP BY tags.name").WithArgs(100).WillReturnRows(
+		sqlmock.NewRows([]string{"name", "filesCount"}).AddRow("rock", 9).AddRow("jazz", 5).AddRow("blues", 1))
+
+	cloud, err := Cloud(db, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []CloudTag{
+		{Name: "blues", Count: 1, Size: 1},
+		{Name: "jazz", Count: 5, Size: 3},
+		{Name: "rock", Count: 9, Size: 5},
+	}, cloud)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
+
+func TestCloudSameCounts(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT tags.name, COUNT").WithArgs(100).WillReturnRows(
+		sqlmock.NewRows([]string{"name", "filesCount"}).AddRow("rock", 2).AddRow("jazz", 2))
+
+	cloud, err := Cloud(db, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []CloudTag{{Name: "jazz", Count: 2, Size: 1}, {Name: "rock", Count: 2, Size: 1}}, cloud)
+}
+
+func TestSuggest(t *testing.T) {
+	db, sqlMock, err := sqlmock.New()
+	require.NoError(t, err)
+	sqlMock.ExpectQuery("SELECT tags.name FROM tags JOIN file_tags (.+) WHERE tags.name LIKE").WithArgs("ro%", 10).WillReturnRows(
+		sqlmock.NewRows([]string{"name"}).AddRow("rock").AddRow("rock-n-roll"))
+
+	suggestions, err := Suggest(db, " Ro", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rock", "rock-n-roll"}, suggestions)
+
+	// empty prefix doesn't send query to database
+	suggestions, err = Suggest(db, " ", 10)
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+	require.NoError(t, sqlMock.ExpectationsWereMet())
+}
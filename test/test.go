@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/rafaeljusto/redigomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/csrf"
 	"github.com/vpoletaev11/fileHostingSite/session"
+	"github.com/vpoletaev11/fileHostingSite/storage"
 )
 
+// CSRFToken is CSRF token of dependencies returned by NewDep
+const CSRFToken = "csrf"
+
 // NewDep returns dependencies for Page()'s and sqlMock and redisMock interfaces to writting mocks
 func NewDep(t *testing.T) (session.Dependency, sqlmock.Sqlmock, *redigomock.Conn) {
 	db, sqlMock, err := sqlmock.New()
@@ -20,7 +26,23 @@ func NewDep(t *testing.T) (session.Dependency, sqlmock.Sqlmock, *redigomock.Conn
 
 	redisMock := redigomock.NewConn()
 
-	return session.Dependency{Db: db, Redis: redisMock, Username: "username"}, sqlMock, redisMock
+	// path to files directory is relative, so tests which use storage should change working directory to project root
+	return session.Dependency{Db: db, Redis: redisMock, Storage: storage.NewLocal("files"), Username: "username", CSRFToken: CSRFToken}, sqlMock, redisMock
+}
+
+// CategoryRows returns rows of categories query with default categories (the same as in migration 0014)
+func CategoryRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"slug", "name", "description", "sortOrder", "parent", "retired"}).
+		AddRow("other", "Other", "", 10, "", false).
+		AddRow("games", "Games", "", 20, "", false).
+		AddRow("documents", "Documents", "", 30, "", false).
+		AddRow("projects", "Projects", "", 40, "", false).
+		AddRow("music", "Music", "", 50, "", false)
+}
+
+// CSRFCookie returns double-submit cookie with CSRFToken for forms of unauthorized users
+func CSRFCookie() *http.Cookie {
+	return &http.Cookie{Name: csrf.CookieName, Value: CSRFToken}
 }
 
 // AssertBodyEqual checks if responce body equal expected value
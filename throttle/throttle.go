@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Rule describes how failed attempts identified by some key (username, IP address) are throttled:
+// 1) first FreeAttempts failures don't delay next attempt,
+// 2) every next failure doubles delay starting from BaseDelay,
+// 3) after MaxAttempts failures key is locked out for Lockout time.
+// Counter of failures expires after Lockout time since last failure.
+type Rule struct {
+	Name         string // Name is part of redis keys of rule
+	FreeAttempts int
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	Lockout      time.Duration
+}
+
+// Rules of failed login attempts.
+// Several users can share one IP address (NAT, proxies), so IP rule allows more failures than username rule.
+var (
+	LoginByUsername = Rule{Name: "login:user", FreeAttempts: 3, MaxAttempts: 10, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+	LoginByIP       = Rule{Name: "login:ip", FreeAttempts: 10, MaxAttempts: 50, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+)
+
+// failuresKey returns redis key of failures counter
+func (rule Rule) failuresKey(key string) string {
+	return "failures:" + rule.Name + ":" + key
+}
+
+// lockKey returns redis key which exists while next attempt isn't allowed
+func (rule Rule) lockKey(key string) string {
+	return "lock:" + rule.Name + ":" + key
+}
+
+// Delay returns delay before next attempt after inputted count of failures
+func (rule Rule) Delay(failures int) time.Duration {
+	switch {
+	case failures < rule.FreeAttempts:
+		return 0
+
+	case failures >= rule.MaxAttempts:
+		return rule.Lockout
+	}
+
+	delay := rule.BaseDelay << uint(failures-rule.FreeAttempts)
+	if delay > rule.Lockout {
+		return rule.Lockout
+	}
+	return delay
+}
+
+// Wait returns time which should pass before next attempt for inputted key will be allowed.
+// If attempt is allowed now Wait returns 0.
+func Wait(redisConn redis.Conn, rule Rule, key string) (time.Duration, error) {
+	ttl, err := redis.Int(redisConn.Do("TTL", rule.lockKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	// TTL returns negative value if key doesn't exist
+	if ttl <= 0 {
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+// Fail registers failed attempt for inputted key and returns delay before next attempt
+func Fail(redisConn redis.Conn, rule Rule, key string) (time.Duration, error) {
+	failures, err := redis.Int(redisConn.Do("INCR", rule.failuresKey(key)))
+	if err != nil {
+		return 0, err
+	}
+	_, err = redisConn.Do("EXPIRE", rule.failuresKey(key), rule.Lockout.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	delay := rule.Delay(failures)
+	if delay == 0 {
+		return 0, nil
+	}
+	_, err = redisConn.Do("SET", rule.lockKey(key), failures, "EX", delay.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Reset removes failures counter and lock of inputted key
+func Reset(redisConn redis.Conn, rule Rule, key string) error {
+	_, err := redisConn.Do("DEL", rule.failuresKey(key), rule.lockKey(key))
+	return err
+}
@@ -0,0 +1,108 @@
+package throttle_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/throttle"
+)
+
+var rule = throttle.Rule{Name: "test", FreeAttempts: 3, MaxAttempts: 10, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+
+func TestDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), rule.Delay(1))
+	assert.Equal(t, time.Duration(0), rule.Delay(2))
+	assert.Equal(t, 1*time.Second, rule.Delay(3))
+	assert.Equal(t, 2*time.Second, rule.Delay(4))
+	assert.Equal(t, 64*time.Second, rule.Delay(9))
+	assert.Equal(t, 15*time.Minute, rule.Delay(10))
+	assert.Equal(t, 15*time.Minute, rule.Delay(100))
+}
+
+func TestDelayCappedByLockout(t *testing.T) {
+	rule := throttle.Rule{Name: "test", FreeAttempts: 0, MaxAttempts: 100, BaseDelay: time.Minute, Lockout: 15 * time.Minute}
+
+	assert.Equal(t, 8*time.Minute, rule.Delay(3))
+	assert.Equal(t, 15*time.Minute, rule.Delay(4))
+}
+
+func TestWaitNotLocked(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("TTL", "lock:test:key").Expect(int64(-2))
+
+	wait, err := throttle.Wait(redisMock, rule, "key")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), wait)
+}
+
+func TestWaitLocked(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("TTL", "lock:test:key").Expect(int64(30))
+
+	wait, err := throttle.Wait(redisMock, rule, "key")
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, wait)
+}
+
+func TestWaitRedisError(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("TTL", "lock:test:key").ExpectError(fmt.Errorf("testing error"))
+
+	_, err := throttle.Wait(redisMock, rule, "key")
+	assert.EqualError(t, err, "testing error")
+}
+
+func TestFailFreeAttempt(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("INCR", "failures:test:key").Expect(int64(1))
+	expire := redisMock.Command("EXPIRE", "failures:test:key", rule.Lockout.Seconds())
+
+	delay, err := throttle.Fail(redisMock, rule, "key")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), delay)
+	assert.Equal(t, 1, redisMock.Stats(expire))
+}
+
+func TestFailBackoff(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("INCR", "failures:test:key").Expect(int64(5))
+	redisMock.Command("EXPIRE", "failures:test:key", rule.Lockout.Seconds())
+	lock := redisMock.Command("SET", "lock:test:key", 5, "EX", float64(4))
+
+	delay, err := throttle.Fail(redisMock, rule, "key")
+	require.NoError(t, err)
+	assert.Equal(t, 4*time.Second, delay)
+	assert.Equal(t, 1, redisMock.Stats(lock))
+}
+
+func TestFailLockout(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("INCR", "failures:test:key").Expect(int64(10))
+	redisMock.Command("EXPIRE", "failures:test:key", rule.Lockout.Seconds())
+	lock := redisMock.Command("SET", "lock:test:key", 10, "EX", rule.Lockout.Seconds())
+
+	delay, err := throttle.Fail(redisMock, rule, "key")
+	require.NoError(t, err)
+	assert.Equal(t, rule.Lockout, delay)
+	assert.Equal(t, 1, redisMock.Stats(lock))
+}
+
+func TestFailRedisError(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	redisMock.Command("INCR", "failures:test:key").ExpectError(fmt.Errorf("testing error"))
+
+	_, err := throttle.Fail(redisMock, rule, "key")
+	assert.EqualError(t, err, "testing error")
+}
+
+func TestReset(t *testing.T) {
+	redisMock := redigomock.NewConn()
+	del := redisMock.Command("DEL", "failures:test:key", "lock:test:key")
+
+	require.NoError(t, throttle.Reset(redisMock, rule, "key"))
+	assert.Equal(t, 1, redisMock.Stats(del))
+}
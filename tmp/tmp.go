@@ -4,10 +4,44 @@ import (
 	"html/template"
 	"os"
 	"path/filepath"
+
+	"github.com/vpoletaev11/fileHostingSite/csrf"
 )
 
 // CreateTemplate creates template from inputted template file path
 func CreateTemplate(path string) (*template.Template, error) {
+	// creating template from template file path
+	page, err := template.ParseFiles(findTemplate(path))
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// CreateFormTemplate creates template with forms from inputted template file path.
+// Template can use {{ csrfField}} to insert hidden field with inputted CSRF token into form.
+// Templates defined in inputted shared template files can be used by {{template "name"}}.
+func CreateFormTemplate(path, csrfToken string, shared ...string) (*template.Template, error) {
+	path = findTemplate(path)
+	paths := []string{path}
+	for _, s := range shared {
+		paths = append(paths, findTemplate(s))
+	}
+	funcs := template.FuncMap{
+		"csrfField": func() template.HTML {
+			return csrf.Field(csrfToken)
+		},
+	}
+	// creating template from template file path
+	page, err := template.New(filepath.Base(path)).Funcs(funcs).ParseFiles(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// findTemplate returns path to template file
+func findTemplate(path string) string {
 	// if working directory != root - directory level will be lowered (case when used tests)
 	for i := 0; i < 5; i++ {
 		if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -16,10 +50,5 @@ func CreateTemplate(path string) (*template.Template, error) {
 			break
 		}
 	}
-	// creating template from template file path
-	page, err := template.ParseFiles(path)
-	if err != nil {
-		return nil, err
-	}
-	return page, nil
+	return path
 }
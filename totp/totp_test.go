@@ -0,0 +1,83 @@
+package totp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vpoletaev11/fileHostingSite/totp"
+)
+
+// secret is base32 encoded "12345678901234567890" secret from RFC 6238 test vectors
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// RFC 6238 contains 8 digit codes, 6 digit codes are their last digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := totp.Code(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	_, err := totp.Code("not base32!", time.Unix(59, 0))
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	step, ok := totp.Verify(secret, "050471", now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// code of previous period is accepted because of clock drift
+	step, ok = totp.Verify(secret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now)-1, step)
+
+	// lower case secret and spaces in code
+	_, ok = totp.Verify("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050 471", now)
+	assert.True(t, ok)
+}
+
+func TestVerifyWrongCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	_, ok := totp.Verify(secret, "123456", now)
+	assert.False(t, ok)
+	_, ok = totp.Verify(secret, "05047", now)
+	assert.False(t, ok)
+	// code is too old
+	_, ok = totp.Verify(secret, "287082", now)
+	assert.False(t, ok)
+}
+
+func TestNewSecret(t *testing.T) {
+	secret1, err := totp.NewSecret()
+	require.NoError(t, err)
+	secret2, err := totp.NewSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, secret1, 32)
+	assert.NotEqual(t, secret1, secret2)
+
+	_, err = totp.Code(secret1, time.Now())
+	assert.NoError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	assert.Equal(t,
+		"otpauth://totp/File%20Hosting:john%20doe?algorithm=SHA1&digits=6&issuer=File+Hosting&period=30&secret="+secret,
+		totp.URI("File Hosting", "john doe", secret))
+}